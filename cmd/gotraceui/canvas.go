@@ -108,12 +108,19 @@ type Canvas struct {
 	}
 
 	locationHistory []LocationHistoryEntry
-	// All timelines. Index 0 and 1 are the GC and STW timelines, followed by processors and goroutines.
+	// Displayed timelines. Index 0 and 1 are the GC and STW timelines, followed by processors and goroutines.
 	timelines      []*Timeline
 	itemToTimeline map[any]*Timeline
 	scrollbar      widget.Scrollbar
 	axis           Axis
 
+	// When the canvas is focused on a subset of timelines, all holds all timelines. generation changes every time the
+	// set of displayed timelines changes.
+	focus struct {
+		all        []*Timeline
+		generation int
+	}
+
 	memoryGraph Plot
 
 	// State for dragging the canvas
@@ -132,6 +139,8 @@ type Canvas struct {
 		ready   bool
 		clickAt f32.Point
 		active  bool
+		// Whether to offer a menu of things to do with the selection instead of zooming to it right away.
+		menu bool
 	}
 
 	// We have multiple sources of the pointer position, which are valid during different times: Canvas.hover and
@@ -140,8 +149,11 @@ type Canvas struct {
 	hover     gesture.Hover
 
 	timeline struct {
-		filter             Filter
-		automaticFilter    Filter
+		filter          Filter
+		automaticFilter Filter
+		// Highlights requested by the main window's active panel, see HighlightingPanel.
+		panelFilter Filter
+
 		displayAllLabels   bool
 		compact            bool
 		displayStackTracks bool
@@ -172,6 +184,8 @@ type Canvas struct {
 		width              int
 		filter             Filter
 		automaticFilter    Filter
+		panelFilter        Filter
+		focusGeneration    int
 	}
 
 	// timelineEnds[i] describes the absolute Y pixel offset where timeline i ends. It is computed by
@@ -205,10 +219,41 @@ func (cv *Canvas) End() trace.Timestamp {
 	return cv.start + trace.Timestamp(float64(cv.width)*cv.nsPerPx)
 }
 
+// allTimelines returns all timelines, including those that aren't displayed because the canvas is focused on other
+// timelines.
+func (cv *Canvas) allTimelines() []*Timeline {
+	if cv.focus.all != nil {
+		return cv.focus.all
+	}
+	return cv.timelines
+}
+
+// focusTimelines limits the displayed timelines to the GC and STW timelines and tls.
+func (cv *Canvas) focusTimelines(tls []*Timeline) {
+	all := cv.allTimelines()
+	cv.focus.all = all
+	cv.focus.generation++
+	cv.timelines = append(all[:2:2], tls...)
+	cv.timelineEnds = cv.timelineEnds[:0]
+	cv.y = 0
+}
+
+// unfocusTimelines displays all timelines again.
+func (cv *Canvas) unfocusTimelines() {
+	if cv.focus.all == nil {
+		return
+	}
+	cv.timelines = cv.focus.all
+	cv.focus.all = nil
+	cv.focus.generation++
+	cv.timelineEnds = cv.timelineEnds[:0]
+}
+
 func (cv *Canvas) computeTimelinePositions(gtx layout.Context) {
 	if len(cv.timelineEnds) == len(cv.timelines) &&
 		cv.timeline.compact == cv.prevFrame.compact &&
-		cv.timeline.displayStackTracks == cv.prevFrame.displayStackTracks {
+		cv.timeline.displayStackTracks == cv.prevFrame.displayStackTracks &&
+		cv.focus.generation == cv.prevFrame.focusGeneration {
 		return
 	}
 
@@ -325,7 +370,9 @@ func (cv *Canvas) unchanged() bool {
 		cv.prevFrame.compact == cv.timeline.compact &&
 		cv.prevFrame.displayStackTracks == cv.timeline.displayStackTracks &&
 		cv.prevFrame.filter == cv.timeline.filter &&
-		cv.prevFrame.automaticFilter == cv.timeline.automaticFilter
+		cv.prevFrame.automaticFilter == cv.timeline.automaticFilter &&
+		cv.prevFrame.panelFilter == cv.timeline.panelFilter &&
+		cv.prevFrame.focusGeneration == cv.focus.generation
 }
 
 func (cv *Canvas) startZoomSelection(pos f32.Point) {
@@ -359,6 +406,26 @@ func (cv *Canvas) endZoomSelection(win *theme.Window, gtx layout.Context, pos f3
 		return
 	}
 
+	if cv.zoomSelection.menu {
+		win.SetContextMenu([]*theme.MenuItem{
+			{
+				Label: PlainLabel("Zoom to selection"),
+				Action: func() theme.Action {
+					return theme.ExecuteAction(func(gtx layout.Context) {
+						cv.navigateToStartAndEnd(gtx, start, end, cv.y)
+					})
+				},
+			},
+			{
+				Label: PlainLabel("Save selection as trace…"),
+				Action: func() theme.Action {
+					return &SaveTraceSelectionAction{Start: start, End: end}
+				},
+			},
+		})
+		return
+	}
+
 	cv.navigateToStartAndEnd(gtx, start, end, cv.y)
 }
 
@@ -489,6 +556,11 @@ func (cv *Canvas) timelineY(gtx layout.Context, dst *Timeline) int {
 		}
 		off += tl.Height(gtx, cv)
 	}
+	if cv.focus.all != nil {
+		// The timeline isn't displayed because we're focused on other timelines.
+		cv.unfocusTimelines()
+		return cv.timelineY(gtx, dst)
+	}
 	panic("unreachable")
 }
 
@@ -502,6 +574,11 @@ func (cv *Canvas) objectY(gtx layout.Context, act any) int {
 		}
 		off += tl.Height(gtx, cv)
 	}
+	if cv.focus.all != nil {
+		// The object's timeline isn't displayed because we're focused on other timelines.
+		cv.unfocusTimelines()
+		return cv.objectY(gtx, act)
+	}
 	panic("unreachable")
 }
 
@@ -597,7 +674,7 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 	}
 
 	if cv.nsPerPx == 0 {
-		end := cv.trace.Events[len(cv.trace.Events)-1].Ts
+		end := cv.trace.Events.Last().Ts
 		slack := float64(end) * 0.05
 		cv.nsPerPx = (float64(end) + 2*slack) / float64(cv.width)
 	}
@@ -786,6 +863,10 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 				cv.drag.ready = true
 			} else if ev.Modifiers == key.ModShortcut {
 				cv.zoomSelection.ready = true
+				cv.zoomSelection.menu = false
+			} else if ev.Modifiers == key.ModShortcut|key.ModShift {
+				cv.zoomSelection.ready = true
+				cv.zoomSelection.menu = true
 			}
 		case pointer.Drag:
 			cv.pointerAt = ev.Position
@@ -1012,6 +1093,22 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 			drawRegionOverlays(sSTW, c, gtx.Constraints.Max.Y)
 		}
 
+		// Draw the gaps between concatenated traces, during which nothing was recorded
+		for _, gap := range cv.trace.Gaps {
+			start := max(gap.Start, cv.start)
+			end := min(gap.End, cv.End())
+			if end <= start {
+				continue
+			}
+			c := colors[colorTraceGap]
+			c.A = 0xCC
+			rect := clip.FRect{
+				Min: f32.Pt(cv.tsToPx(start), 0),
+				Max: f32.Pt(cv.tsToPx(end), float32(gtx.Constraints.Max.Y)),
+			}
+			paint.FillShape(gtx.Ops, c, rect.Op(gtx.Ops))
+		}
+
 		// Draw cursor
 		rect := clip.Rect{
 			Min: image.Pt(int(round32(cv.pointerAt.X)), 0),
@@ -1031,6 +1128,8 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 	cv.prevFrame.hoveredTimeline = cv.timeline.hoveredTimeline
 	cv.prevFrame.filter = cv.timeline.filter
 	cv.prevFrame.automaticFilter = cv.timeline.automaticFilter
+	cv.prevFrame.panelFilter = cv.timeline.panelFilter
+	cv.prevFrame.focusGeneration = cv.focus.generation
 
 	cv.clickedSpans = cv.clickedSpans[:0]
 	cv.timeline.hoveredSpans = NoItems[ptrace.Span]{}
@@ -1061,7 +1160,7 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 
 			case *ptrace.Processor:
 				if spans.Len() == 1 {
-					cv.timeline.automaticFilter.Processor.Goroutine = cv.trace.Event(spans.At(0).Event).G
+					cv.timeline.automaticFilter.Processor.Goroutine = cv.trace.Event(spans.At(0).Event()).G
 				}
 
 				cv.timeline.automaticFilter.Machine.Processor = hitem.ID
@@ -1070,11 +1169,11 @@ func (cv *Canvas) Layout(win *theme.Window, gtx layout.Context) layout.Dimension
 				if spans.Len() == 1 {
 					o := spans.At(0)
 					if o.State == ptrace.StateRunningG {
-						cv.timeline.automaticFilter.Processor.Goroutine = cv.trace.Event(o.Event).G
+						cv.timeline.automaticFilter.Processor.Goroutine = cv.trace.Event(o.Event()).G
 					}
 
 					if o.State == ptrace.StateRunningP {
-						cv.timeline.automaticFilter.Machine.Processor = cv.trace.Event(o.Event).P
+						cv.timeline.automaticFilter.Machine.Processor = cv.trace.Event(o.Event()).P
 					}
 				}
 			}
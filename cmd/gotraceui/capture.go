@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	rtrace "runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+)
+
+// defaultCaptureDuration is the default duration of traces captured from net/http/pprof endpoints.
+const defaultCaptureDuration = 5 * time.Second
+
+// isCaptureURL reports whether s refers to a net/http/pprof endpoint instead of a file.
+func isCaptureURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// captureURL returns the URL for capturing a trace of duration d from the net/http/pprof endpoint at addr. Addresses
+// without a path refer to the default endpoint, /debug/pprof/trace.
+func captureURL(addr string, d time.Duration) (string, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/debug/pprof/trace"
+	}
+	q := u.Query()
+	q.Set("seconds", strconv.FormatFloat(d.Seconds(), 'f', -1, 64))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// captureReader streams a trace from a net/http/pprof endpoint. The endpoint sends the trace while it's being
+// recorded, so we report progress based on the time that has passed. It implements trace.ProgressReader.
+type captureReader struct {
+	body  io.ReadCloser
+	r     io.Reader
+	save  *os.File
+	start time.Time
+	d     time.Duration
+}
+
+func (r *captureReader) Read(b []byte) (int, error) {
+	return r.r.Read(b)
+}
+
+func (r *captureReader) Progress() float64 {
+	p := float64(time.Since(r.start)) / float64(r.d)
+	if p > 1 {
+		p = 1
+	}
+	return p
+}
+
+// Close closes the connection and the file that the trace is being saved to.
+func (r *captureReader) Close() error {
+	err := r.body.Close()
+	if r.save != nil {
+		if serr := r.save.Close(); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// captureTrace starts capturing a trace of duration d from the net/http/pprof endpoint at addr. The trace can be read
+// from the returned reader as it's being recorded. If save isn't empty, the raw trace is also written to the file at
+// that path.
+func captureTrace(ctx context.Context, addr string, d time.Duration, save string) (*captureReader, error) {
+	if d <= 0 {
+		return nil, fmt.Errorf("invalid trace duration %s", d)
+	}
+	u, err := captureURL(addr, d)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// net/http/pprof describes errors in plain text.
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		if msg = bytes.TrimSpace(msg); len(msg) > 0 {
+			return nil, fmt.Errorf("couldn't capture trace: %s: %s", resp.Status, msg)
+		}
+		return nil, fmt.Errorf("couldn't capture trace: %s", resp.Status)
+	}
+
+	cr := &captureReader{
+		body:  resp.Body,
+		r:     resp.Body,
+		start: start,
+		d:     d,
+	}
+	if save != "" {
+		f, err := os.Create(save)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		cr.save = f
+		cr.r = io.TeeReader(resp.Body, f)
+	}
+	return cr, nil
+}
+
+// OpenTraceFromURL captures a trace of duration d from the net/http/pprof endpoint at addr and loads it, optionally
+// saving the raw trace to the file at save. It should be called from a different goroutine than the render loop.
+func (mwin *MainWindow) OpenTraceFromURL(addr string, d time.Duration, save string) {
+	mwin.SetState("loadingTrace")
+	r, err := captureTrace(context.Background(), addr, d, save)
+	if err != nil {
+		mwin.SetError(err)
+		return
+	}
+	defer r.Close()
+	mwin.OpenTrace(r)
+}
+
+func (mwin *MainWindow) showCaptureDialog() {
+	cd := &mwin.captureDialog
+	cd.Reset()
+	mwin.twin.SetModal(func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+		gtx.Constraints.Min = gtx.Constraints.Constrain(image.Pt(1000, 300))
+		gtx.Constraints.Max = gtx.Constraints.Min
+		dims := theme.Dialog(win.Theme, "Open trace from URL").Layout(win, gtx, CaptureDialog(cd).Layout)
+
+		if cd.Captured() {
+			win.CloseModal()
+			addr, d, save := cd.URL(), cd.Duration(), cd.SavePath()
+			go mwin.OpenTraceFromURL(addr, d, save)
+		}
+		if cd.Cancelled() {
+			win.CloseModal()
+		}
+		return dims
+	})
+}
+
+type CaptureDialogState struct {
+	captured, cancelled bool
+
+	urlEditor      widget.Editor
+	durationEditor widget.Editor
+	saveEditor     widget.Editor
+	capture        widget.PrimaryClickable
+	cancel         widget.PrimaryClickable
+}
+
+func (cds *CaptureDialogState) Captured() bool {
+	b := cds.captured
+	cds.captured = false
+	return b
+}
+
+func (cds *CaptureDialogState) Cancelled() bool {
+	b := cds.cancelled
+	cds.cancelled = false
+	return b
+}
+
+// Reset prepares the dialog for being displayed. The URL and the path to save to are retained from the previous use.
+func (cds *CaptureDialogState) Reset() {
+	cds.urlEditor.SingleLine = true
+	cds.urlEditor.Submit = true
+	cds.durationEditor.SingleLine = true
+	cds.durationEditor.Submit = true
+	cds.saveEditor.SingleLine = true
+	cds.saveEditor.Submit = true
+	if cds.durationEditor.Len() == 0 {
+		cds.durationEditor.SetText(defaultCaptureDuration.String())
+	}
+}
+
+func (cds *CaptureDialogState) URL() string {
+	return strings.TrimSpace(cds.urlEditor.Text())
+}
+
+func (cds *CaptureDialogState) Duration() time.Duration {
+	d, _ := time.ParseDuration(strings.TrimSpace(cds.durationEditor.Text()))
+	return d
+}
+
+func (cds *CaptureDialogState) SavePath() string {
+	return strings.TrimSpace(cds.saveEditor.Text())
+}
+
+func (cds *CaptureDialogState) valid() bool {
+	_, err := captureURL(cds.URL(), time.Second)
+	return err == nil && cds.Duration() > 0
+}
+
+type CaptureDialogStyle struct {
+	State *CaptureDialogState
+}
+
+func CaptureDialog(state *CaptureDialogState) CaptureDialogStyle {
+	return CaptureDialogStyle{State: state}
+}
+
+func (cd CaptureDialogStyle) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.CaptureDialogStyle.Layout").End()
+
+	cd.State.captured = false
+	cd.State.cancelled = false
+
+	settingLabel := func(s string) layout.Dimensions {
+		gtx := gtx
+		gtx.Constraints.Min.Y = 0
+		return widget.Label{MaxLines: 1}.Layout(gtx, win.Theme.Shaper, font.Font{Weight: font.Bold}, 12, s, widget.ColorTextMaterial(gtx, rgba(0x000000FF)))
+	}
+	spacer := func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 5}.Layout(gtx) }
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return settingLabel("URL of net/http/pprof endpoint")
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			tb := theme.TextBox(win.Theme, &cd.State.urlEditor, "http://localhost:6060/debug/pprof/trace")
+			tb.Validate = func(s string) bool {
+				_, err := captureURL(strings.TrimSpace(s), time.Second)
+				return s == "" || err == nil
+			}
+			return tb.Layout(gtx)
+		}),
+		layout.Rigid(spacer),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return settingLabel("Duration")
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			tb := theme.TextBox(win.Theme, &cd.State.durationEditor, "Duration, e.g. 5s")
+			tb.Validate = func(s string) bool {
+				d, err := time.ParseDuration(strings.TrimSpace(s))
+				return err == nil && d > 0
+			}
+			return tb.Layout(gtx)
+		}),
+		layout.Rigid(spacer),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return settingLabel("Save raw trace to file (optional)")
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return theme.TextBox(win.Theme, &cd.State.saveEditor, "Path of file").Layout(gtx)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Spacer{Height: 10}.Layout(gtx)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					btn := theme.Button(win.Theme, &cd.State.capture.Clickable, "Capture trace")
+					if !cd.State.valid() {
+						gtx.Queue = nil
+					}
+					return btn.Layout(win, gtx)
+				}),
+
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Width: 5}.Layout(gtx) }),
+
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					return theme.Button(win.Theme, &cd.State.cancel.Clickable, "Cancel").Layout(win, gtx)
+				}),
+			)
+		}),
+	)
+
+	for cd.State.capture.Clicked() {
+		cd.State.captured = true
+	}
+	for _, ed := range []*widget.Editor{&cd.State.urlEditor, &cd.State.durationEditor, &cd.State.saveEditor} {
+		for _, ev := range ed.Events() {
+			if _, ok := ev.(widget.SubmitEvent); ok && cd.State.valid() {
+				cd.State.captured = true
+			}
+		}
+	}
+	for cd.State.cancel.Clicked() {
+		cd.State.cancelled = true
+	}
+
+	return dims
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+func TestCaptureTrace(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "trace", "testdata", "stress_1_26_good"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := trace.Parse(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seconds string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/debug/pprof/trace" {
+			http.NotFound(w, r)
+			return
+		}
+		seconds = r.URL.Query().Get("seconds")
+		w.Header().Set("Content-Type", "application/octet-stream")
+		// Send the trace in pieces, like net/http/pprof does while the trace is being recorded.
+		for b := data; len(b) > 0; {
+			n := min(len(b), 4096)
+			w.Write(b[:n])
+			w.(http.Flusher).Flush()
+			b = b[n:]
+		}
+	}))
+	defer srv.Close()
+
+	save := filepath.Join(t.TempDir(), "trace")
+	r, err := captureTrace(context.Background(), srv.URL, 1500*time.Millisecond, save)
+	if err != nil {
+		t.Fatalf("couldn't capture trace: %s", err)
+	}
+	if _, ok := any(r).(trace.ProgressReader); !ok {
+		t.Errorf("captureReader doesn't implement trace.ProgressReader")
+	}
+	got, err := trace.Parse(r, nil)
+	if err != nil {
+		t.Fatalf("couldn't parse captured trace: %s", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if seconds != "1.5" {
+		t.Errorf("got seconds=%q, want 1.5", seconds)
+	}
+	if got.Events.Len() != want.Events.Len() {
+		t.Errorf("got %d events, want %d", got.Events.Len(), want.Events.Len())
+	}
+	saved, err := os.ReadFile(save)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(saved, data) {
+		t.Errorf("saved trace differs from the served trace")
+	}
+
+	if _, err := captureTrace(context.Background(), srv.URL+"/not/pprof", time.Second, ""); err == nil {
+		t.Errorf("expected error for endpoint that doesn't exist")
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"image"
+	rtrace "runtime/trace"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/mem"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/text"
+)
+
+func channelSiteName(frame trace.Frame) string {
+	if frame.Fn == "" {
+		return "unknown"
+	}
+	return frame.Fn
+}
+
+func channelSiteLocation(frame trace.Frame) string {
+	if frame.File == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+}
+
+// ChannelsPanel displays which code locations communicated via channels, and how long they had to wait for each other.
+type ChannelsPanel struct {
+	mwin  *theme.Window
+	trace *Trace
+
+	analysis *theme.Future[*ptrace.ChannelAnalysis]
+	// Site pairs of the analysis, in display order
+	pairs  []*ptrace.ChannelSitePair
+	sorted *ptrace.ChannelAnalysis
+
+	list            widget.List
+	sort            theme.TableSortState
+	descriptionText Text
+	cells           TableCells
+
+	theme.PanelButtons
+}
+
+func NewChannelsPanel(tr *Trace, mwin *theme.Window) *ChannelsPanel {
+	cp := &ChannelsPanel{
+		mwin:  mwin,
+		trace: tr,
+	}
+	cp.list.Axis = layout.Vertical
+	// Sort by time spent blocked sending, descending.
+	cp.sort.Column = 3
+	cp.sort.Descending = true
+	return cp
+}
+
+func (cp *ChannelsPanel) Title() string {
+	return "Channels"
+}
+
+var channelPairsColumns = []theme.TableListColumn{
+	{
+		Name: "Sender",
+		// XXX the width depends on the font and scaling
+		MinWidth: 350,
+		MaxWidth: 350,
+	},
+
+	{
+		Name: "Receiver",
+		// XXX the width depends on the font and scaling
+		MinWidth: 350,
+		MaxWidth: 350,
+	},
+
+	{
+		Name: "Blocked sends",
+		// XXX the width depends on the font and scaling
+		MinWidth: 130,
+		MaxWidth: 130,
+	},
+
+	{
+		Name: "Send wait",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Send p99",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Blocked receives",
+		// XXX the width depends on the font and scaling
+		MinWidth: 150,
+		MaxWidth: 150,
+	},
+
+	{
+		Name: "Receive wait",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Receive p99",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+}
+
+func (cp *ChannelsPanel) sortPairs(ca *ptrace.ChannelAnalysis) {
+	cp.pairs = append(cp.pairs[:0], ca.Pairs...)
+	sortTableRows(cp.pairs, &cp.sort, func(a, b *ptrace.ChannelSitePair, col int) int {
+		switch col {
+		case 0:
+			return cmp.Compare(a.Sender.Fn, b.Sender.Fn)
+		case 1:
+			return cmp.Compare(a.Receiver.Fn, b.Receiver.Fn)
+		case 2:
+			return cmp.Compare(a.SendBlocked.Count, b.SendBlocked.Count)
+		case 3:
+			return cmp.Compare(a.SendBlocked.Total, b.SendBlocked.Total)
+		case 4:
+			return cmp.Compare(a.SendBlocked.P99, b.SendBlocked.P99)
+		case 5:
+			return cmp.Compare(a.RecvBlocked.Count, b.RecvBlocked.Count)
+		case 6:
+			return cmp.Compare(a.RecvBlocked.Total, b.RecvBlocked.Total)
+		case 7:
+			return cmp.Compare(a.RecvBlocked.P99, b.RecvBlocked.P99)
+		default:
+			panic("unreachable")
+		}
+	})
+	cp.sorted = ca
+}
+
+func (cp *ChannelsPanel) buildDescription(win *theme.Window, ca *ptrace.ChannelAnalysis) Description {
+	tb := TextBuilder{Theme: win.Theme}
+	var sends, recvs int
+	for _, pair := range ca.Pairs {
+		sends += pair.SendBlocked.Count
+		recvs += pair.RecvBlocked.Count
+	}
+	attrs := []DescriptionAttribute{
+		{
+			Key:   "# of site pairs",
+			Value: *(tb.Span(local.Sprintf("%d", len(ca.Pairs)))),
+		},
+		{
+			Key:   "# of blocked sends",
+			Value: *(tb.Span(local.Sprintf("%d", sends))),
+		},
+		{
+			Key:   "Time blocked sending",
+			Value: *(tb.Span(roundDuration(ca.SendTotal).String())),
+		},
+		{
+			Key:   "# of blocked receives",
+			Value: *(tb.Span(local.Sprintf("%d", recvs))),
+		},
+		{
+			Key:   "Time blocked receiving",
+			Value: *(tb.Span(roundDuration(ca.RecvTotal).String())),
+		},
+	}
+	return Description{Attributes: attrs}
+}
+
+func (cp *ChannelsPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.ChannelsPanel.Layout").End()
+
+	if cp.analysis == nil {
+		cp.analysis = theme.NewFuture(win, func(cancelled <-chan struct{}) *ptrace.ChannelAnalysis {
+			return cp.trace.Channels()
+		})
+	}
+	ca, haveAnalysis := cp.analysis.Result()
+	if haveAnalysis && (cp.sort.Changed() || cp.sorted != ca) {
+		cp.sortPairs(ca)
+	}
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	cellFn := func(tb *TextBuilder, txt *Text, row, col int) {
+		pair := cp.pairs[row]
+		switch col {
+		case 0: // Sender
+			tb.DefaultLink(channelSiteName(pair.Sender), "", pair)
+		case 1: // Receiver
+			tb.DefaultLink(channelSiteName(pair.Receiver), "", pair)
+		case 2: // Blocked sends
+			tb.Span(local.Sprintf("%d", pair.SendBlocked.Count))
+			txt.Alignment = text.End
+		case 3: // Send wait
+			durationCell(tb, txt, pair.SendBlocked.Total)
+		case 4: // Send p99
+			durationCell(tb, txt, pair.SendBlocked.P99)
+		case 5: // Blocked receives
+			tb.Span(local.Sprintf("%d", pair.RecvBlocked.Count))
+			txt.Alignment = text.End
+		case 6: // Receive wait
+			durationCell(tb, txt, pair.RecvBlocked.Total)
+		case 7: // Receive p99
+			durationCell(tb, txt, pair.RecvBlocked.P99)
+		}
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, cp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if !haveAnalysis {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing channel analysis…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			gtx.Constraints.Min = image.Point{}
+			cp.descriptionText.Reset(win.Theme)
+			return cp.buildDescription(win, ca).Layout(win, gtx, &cp.descriptionText)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !haveAnalysis {
+				return layout.Dimensions{}
+			}
+			return cp.cells.Table(win, gtx, channelPairsColumns, &cp.list, &cp.sort, len(cp.pairs), cellFn)
+		}),
+	)
+
+	cp.cells.Finish(win)
+	for cp.PanelButtons.Backed() {
+		cp.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
+
+// ChannelSitePairPanel displays all the times goroutines blocked communicating between a pair of code locations.
+type ChannelSitePairPanel struct {
+	mwin   *theme.Window
+	trace  *Trace
+	canvas *Canvas
+	pair   *ptrace.ChannelSitePair
+
+	tabbedState      theme.TabbedState
+	sendsList        widget.List
+	recvsList        widget.List
+	hist             InteractiveHistogram
+	initialized      bool
+	descriptionText  Text
+	timestampObjects mem.BucketSlice[trace.Timestamp]
+	cells            TableCells
+
+	theme.PanelButtons
+}
+
+func NewChannelSitePairPanel(tr *Trace, mwin *theme.Window, canvas *Canvas, pair *ptrace.ChannelSitePair) *ChannelSitePairPanel {
+	pp := &ChannelSitePairPanel{
+		mwin:   mwin,
+		trace:  tr,
+		canvas: canvas,
+		pair:   pair,
+	}
+	pp.sendsList.Axis = layout.Vertical
+	pp.recvsList.Axis = layout.Vertical
+	return pp
+}
+
+func (pp *ChannelSitePairPanel) Title() string {
+	return local.Sprintf("Channel: %s → %s", channelSiteName(pp.pair.Sender), channelSiteName(pp.pair.Receiver))
+}
+
+var channelWaitsColumns = []theme.TableListColumn{
+	{
+		Name: "Goroutine",
+		// XXX the width depends on the font and scaling
+		MinWidth: 300,
+		MaxWidth: 300,
+	},
+
+	{
+		Name: "Start time",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Duration",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Unblocked by",
+	},
+}
+
+func (pp *ChannelSitePairPanel) buildDescription(win *theme.Window, gtx layout.Context) Description {
+	tb := TextBuilder{Theme: win.Theme}
+	var attrs []DescriptionAttribute
+
+	site := func(label string, frame trace.Frame) {
+		if fn, ok := pp.trace.Functions[frame.Fn]; ok {
+			attrs = append(attrs, DescriptionAttribute{
+				Key:   label,
+				Value: *(tb.DefaultLink(fn.Fn, "", fn)),
+			})
+		} else {
+			attrs = append(attrs, DescriptionAttribute{
+				Key:   label,
+				Value: *(tb.Span(channelSiteName(frame))),
+			})
+		}
+		if loc := channelSiteLocation(frame); loc != "" {
+			attrs = append(attrs, DescriptionAttribute{
+				Key:   label + " location",
+				Value: *(tb.Span(loc)),
+			})
+		}
+	}
+	site("Sender", pp.pair.Sender)
+	site("Receiver", pp.pair.Receiver)
+
+	attrs = append(attrs,
+		DescriptionAttribute{
+			Key:   "# of blocked sends",
+			Value: *(tb.Span(local.Sprintf("%d", pp.pair.SendBlocked.Count))),
+		},
+		DescriptionAttribute{
+			Key:   "Time blocked sending",
+			Value: *(tb.Span(roundDuration(pp.pair.SendBlocked.Total).String())),
+		},
+		DescriptionAttribute{
+			Key:   "# of blocked receives",
+			Value: *(tb.Span(local.Sprintf("%d", pp.pair.RecvBlocked.Count))),
+		},
+		DescriptionAttribute{
+			Key:   "Time blocked receiving",
+			Value: *(tb.Span(roundDuration(pp.pair.RecvBlocked.Total).String())),
+		},
+	)
+
+	return Description{Attributes: attrs}
+}
+
+func (pp *ChannelSitePairPanel) computeHistogram(win *theme.Window) {
+	cfg := &pp.hist.Config
+	var ds []time.Duration
+	for _, waits := range [][]ptrace.ChannelWait{pp.pair.SendWaits, pp.pair.RecvWaits} {
+		for _, w := range waits {
+			d := w.Span.Duration()
+			if fd := widget.FloatDuration(d); fd >= cfg.Start && (cfg.End == 0 || fd <= cfg.End) {
+				ds = append(ds, d)
+			}
+		}
+	}
+	pp.hist.Set(win, ds)
+}
+
+func (pp *ChannelSitePairPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.ChannelSitePairPanel.Layout").End()
+
+	if !pp.initialized {
+		pp.hist.Config = widget.HistogramConfig{RejectOutliers: true, Bins: widget.DefaultHistogramBins}
+		pp.computeHistogram(win)
+		pp.initialized = true
+	}
+
+	pp.timestampObjects.Reset()
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	layoutWaits := func(win *theme.Window, gtx layout.Context, list *widget.List, waits []ptrace.ChannelWait) layout.Dimensions {
+		return pp.cells.Table(win, gtx, channelWaitsColumns, list, nil, len(waits), func(tb *TextBuilder, txt *Text, row, col int) {
+			w := waits[row]
+			switch col {
+			case 0: // Goroutine
+				tb.DefaultLink(local.Sprintf("goroutine %d: %s", w.Goroutine.ID, w.Goroutine.Function.Fn), "", w.Goroutine)
+			case 1: // Start time
+				tb.Link(formatTimestamp(w.Span.Start), pp.timestampObjects.Append(w.Span.Start), goroutineSpanLink(pp.canvas, w.Goroutine, w.Span))
+				txt.Alignment = text.End
+			case 2: // Duration
+				durationCell(tb, txt, w.Span.Duration())
+			case 3: // Unblocked by
+				if w.Partner != nil {
+					tb.DefaultLink(local.Sprintf("goroutine %d: %s", w.Partner.ID, w.Partner.Function.Fn), "", w.Partner)
+				}
+			}
+		})
+	}
+
+	tabs := []string{"Blocked sends", "Blocked receives", "Histogram"}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, pp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			pp.descriptionText.Reset(win.Theme)
+			return pp.buildDescription(win, gtx).Layout(win, gtx, &pp.descriptionText)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return theme.Tabbed(&pp.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				switch tabs[pp.tabbedState.Current] {
+				case "Blocked sends":
+					return layoutWaits(win, gtx, &pp.sendsList, pp.pair.SendWaits)
+				case "Blocked receives":
+					return layoutWaits(win, gtx, &pp.recvsList, pp.pair.RecvWaits)
+				case "Histogram":
+					return pp.hist.Layout(win, gtx)
+				default:
+					panic("unreachable")
+				}
+			})
+		}),
+	)
+
+	for _, ev := range pp.descriptionText.Events() {
+		handleLinkClick(win, ev)
+	}
+	pp.cells.Finish(win)
+	if pp.hist.Changed() {
+		pp.computeHistogram(win)
+	}
+	for pp.PanelButtons.Backed() {
+		pp.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"image/color"
+	"math/bits"
+	"os"
 
 	mycolor "honnef.co/go/gotraceui/color"
 	"honnef.co/go/gotraceui/trace/ptrace"
@@ -38,6 +41,7 @@ func init() {
 
 	colorsOklch[colorTimelineLabel] = oklch(62.68, 0, 0)
 	colorsOklch[colorTimelineBorder] = oklch(89.75, 0, 0)
+	colorsOklch[colorTraceGap] = oklch(89.75, 0, 0)
 
 	// 	// TODO(dh): find a nice color for this
 	// We don't use the l constant for thse colors because they're independent from the span colors
@@ -59,6 +63,44 @@ func init() {
 
 type colorIndex uint8
 
+// maxTagColors is the maximum number of span tags that can have their own colors.
+const maxTagColors = 8
+
+// tagColors maps span tags to their colors, or 0 for tags without colors. coloredTags is a bitmask of the tags that
+// have colors.
+var (
+	tagColors   [ptrace.MaxSpanTags]colorIndex
+	coloredTags uint64
+)
+
+// assignTagColors assigns colors to the span tags whose definitions specify colors. It has to be called after all rules
+// have been loaded.
+func assignTagColors() {
+	next := colorStateTag0
+	for i, def := range ptrace.TagDefinitions() {
+		if def.Color.A == 0 {
+			continue
+		}
+		if next > colorStateTagLast {
+			fmt.Fprintf(os.Stderr, "not using color of tag %s, at most %d tags can have colors\n", def.Name, maxTagColors)
+			continue
+		}
+		colors[next] = def.Color
+		tagColors[i] = next
+		coloredTags |= 1 << i
+		next++
+	}
+}
+
+// spanColor returns the color of a span, which is the color of its tag with the lowest ID that has a color, or
+// otherwise the color of its state.
+func spanColor(s ptrace.Span) colorIndex {
+	if mask := s.Tags.Mask() & coloredTags; mask != 0 {
+		return tagColors[bits.TrailingZeros64(mask)]
+	}
+	return stateColors[s.State]
+}
+
 const (
 	colorStateUnknown colorIndex = iota
 
@@ -82,10 +124,17 @@ const (
 	colorStateDone
 	colorStatePlaceholderStackSpan
 
-	colorStateLast
+	// Colors of span tags that have colors, see assignTagColors.
+	colorStateTag0
+	colorStateTagLast = colorStateTag0 + maxTagColors - 1
+)
+
+const (
+	colorStateLast colorIndex = colorStateTagLast + 1 + iota
 
 	colorTimelineLabel
 	colorTimelineBorder
+	colorTraceGap
 
 	colorSpanHighlightedPrimaryOutline
 	colorSpanHighlightedSecondaryOutline
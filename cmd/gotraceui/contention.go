@@ -0,0 +1,497 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"image"
+	rtrace "runtime/trace"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/mem"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/text"
+)
+
+func contentionSiteName(site *ptrace.ContentionSite) string {
+	if site.Frame.Fn == "" {
+		return "unknown"
+	}
+	return site.Frame.Fn
+}
+
+func contentionSiteLocation(site *ptrace.ContentionSite) string {
+	if site.Frame.File == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", site.Frame.File, site.Frame.Line)
+}
+
+// ContentionPanel displays a contention profile, showing where goroutines blocked on synchronization primitives.
+type ContentionPanel struct {
+	mwin  *theme.Window
+	trace *Trace
+
+	profile *theme.Future[*ptrace.ContentionProfile]
+	// Sites of the profile, in display order
+	sites  []*ptrace.ContentionSite
+	sorted *ptrace.ContentionProfile
+
+	list            widget.List
+	sort            theme.TableSortState
+	descriptionText Text
+	cells           TableCells
+
+	theme.PanelButtons
+}
+
+func NewContentionPanel(tr *Trace, mwin *theme.Window) *ContentionPanel {
+	cp := &ContentionPanel{
+		mwin:  mwin,
+		trace: tr,
+	}
+	cp.list.Axis = layout.Vertical
+	// Sort by total blocked time, descending.
+	cp.sort.Column = 3
+	cp.sort.Descending = true
+	return cp
+}
+
+func (cp *ContentionPanel) Title() string {
+	return "Contention"
+}
+
+var contentionSitesColumns = []theme.TableListColumn{
+	{
+		Name: "Site",
+		// XXX the width depends on the font and scaling
+		MinWidth: 350,
+		MaxWidth: 350,
+	},
+
+	{
+		Name: "Location",
+		// XXX the width depends on the font and scaling
+		MinWidth: 350,
+		MaxWidth: 350,
+	},
+
+	{
+		Name: "Waits",
+		// XXX the width depends on the font and scaling
+		MinWidth: 100,
+		MaxWidth: 100,
+	},
+
+	{
+		Name: "Total",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "p50",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "p99",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Max",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Top unblocker",
+	},
+}
+
+func (cp *ContentionPanel) sortSites(prof *ptrace.ContentionProfile) {
+	cp.sites = append(cp.sites[:0], prof.Sites...)
+	sortTableRows(cp.sites, &cp.sort, func(a, b *ptrace.ContentionSite, col int) int {
+		switch col {
+		case 0:
+			return cmp.Compare(a.Frame.Fn, b.Frame.Fn)
+		case 1:
+			return cmp.Compare(contentionSiteLocation(a), contentionSiteLocation(b))
+		case 2:
+			return cmp.Compare(a.Blocked.Count, b.Blocked.Count)
+		case 3:
+			return cmp.Compare(a.Blocked.Total, b.Blocked.Total)
+		case 4:
+			return cmp.Compare(a.Blocked.P50, b.Blocked.P50)
+		case 5:
+			return cmp.Compare(a.Blocked.P99, b.Blocked.P99)
+		case 6:
+			return cmp.Compare(a.Blocked.Max, b.Blocked.Max)
+		case 7:
+			var ua, ub uint64
+			if len(a.Unblockers) > 0 {
+				ua = a.Unblockers[0].Goroutine.ID
+			}
+			if len(b.Unblockers) > 0 {
+				ub = b.Unblockers[0].Goroutine.ID
+			}
+			return cmp.Compare(ua, ub)
+		default:
+			panic("unreachable")
+		}
+	})
+	cp.sorted = prof
+}
+
+func (cp *ContentionPanel) buildDescription(win *theme.Window, prof *ptrace.ContentionProfile) Description {
+	tb := TextBuilder{Theme: win.Theme}
+	var waits int
+	for _, site := range prof.Sites {
+		waits += len(site.Waits)
+	}
+	attrs := []DescriptionAttribute{
+		{
+			Key:   "# of sites",
+			Value: *(tb.Span(local.Sprintf("%d", len(prof.Sites)))),
+		},
+		{
+			Key:   "# of waits",
+			Value: *(tb.Span(local.Sprintf("%d", waits))),
+		},
+		{
+			Key:   "Time blocked",
+			Value: *(tb.Span(roundDuration(prof.Total).String())),
+		},
+	}
+	return Description{Attributes: attrs}
+}
+
+func (cp *ContentionPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.ContentionPanel.Layout").End()
+
+	if cp.profile == nil {
+		cp.profile = theme.NewFuture(win, func(cancelled <-chan struct{}) *ptrace.ContentionProfile {
+			return cp.trace.ContentionProfile()
+		})
+	}
+	prof, haveProfile := cp.profile.Result()
+	if haveProfile && (cp.sort.Changed() || cp.sorted != prof) {
+		cp.sortSites(prof)
+	}
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	cellFn := func(tb *TextBuilder, txt *Text, row, col int) {
+		site := cp.sites[row]
+		switch col {
+		case 0: // Site
+			tb.DefaultLink(contentionSiteName(site), "", site)
+		case 1: // Location
+			tb.Span(contentionSiteLocation(site))
+		case 2: // Waits
+			tb.Span(local.Sprintf("%d", site.Blocked.Count))
+			txt.Alignment = text.End
+		case 3: // Total
+			durationCell(tb, txt, site.Blocked.Total)
+		case 4: // p50
+			durationCell(tb, txt, site.Blocked.P50)
+		case 5: // p99
+			durationCell(tb, txt, site.Blocked.P99)
+		case 6: // Max
+			durationCell(tb, txt, site.Blocked.Max)
+		case 7: // Top unblocker
+			if len(site.Unblockers) > 0 {
+				u := site.Unblockers[0]
+				tb.DefaultLink(local.Sprintf("goroutine %d", u.Goroutine.ID), "", u.Goroutine)
+				tb.Span(local.Sprintf(" (%d×)", u.Count))
+			}
+		}
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, cp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if !haveProfile {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing contention profile…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			gtx.Constraints.Min = image.Point{}
+			cp.descriptionText.Reset(win.Theme)
+			return cp.buildDescription(win, prof).Layout(win, gtx, &cp.descriptionText)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !haveProfile {
+				return layout.Dimensions{}
+			}
+			return cp.cells.Table(win, gtx, contentionSitesColumns, &cp.list, &cp.sort, len(cp.sites), cellFn)
+		}),
+	)
+
+	cp.cells.Finish(win)
+	for cp.PanelButtons.Backed() {
+		cp.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
+
+// ContentionSitePanel displays all the times goroutines blocked at a single contention site, and which goroutines
+// unblocked them.
+type ContentionSitePanel struct {
+	mwin   *theme.Window
+	trace  *Trace
+	canvas *Canvas
+	site   *ptrace.ContentionSite
+
+	tabbedState      theme.TabbedState
+	waitsList        widget.List
+	unblockersList   widget.List
+	hist             InteractiveHistogram
+	initialized      bool
+	descriptionText  Text
+	timestampObjects mem.BucketSlice[trace.Timestamp]
+	cells            TableCells
+
+	theme.PanelButtons
+}
+
+func NewContentionSitePanel(tr *Trace, mwin *theme.Window, canvas *Canvas, site *ptrace.ContentionSite) *ContentionSitePanel {
+	sp := &ContentionSitePanel{
+		mwin:   mwin,
+		trace:  tr,
+		canvas: canvas,
+		site:   site,
+	}
+	sp.waitsList.Axis = layout.Vertical
+	sp.unblockersList.Axis = layout.Vertical
+	return sp
+}
+
+func (sp *ContentionSitePanel) Title() string {
+	return local.Sprintf("Contention at %s", contentionSiteName(sp.site))
+}
+
+var contentionWaitsColumns = []theme.TableListColumn{
+	{
+		Name: "Goroutine",
+		// XXX the width depends on the font and scaling
+		MinWidth: 300,
+		MaxWidth: 300,
+	},
+
+	{
+		Name: "Start time",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Duration",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "State",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Unblocked by",
+	},
+}
+
+var contentionUnblockersColumns = []theme.TableListColumn{
+	{
+		Name: "Goroutine",
+		// XXX the width depends on the font and scaling
+		MinWidth: 300,
+		MaxWidth: 300,
+	},
+
+	{
+		Name: "Unblocks",
+		// XXX the width depends on the font and scaling
+		MinWidth: 100,
+		MaxWidth: 100,
+	},
+
+	{
+		Name: "Total wait",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+}
+
+func (sp *ContentionSitePanel) buildDescription(win *theme.Window, gtx layout.Context) Description {
+	tb := TextBuilder{Theme: win.Theme}
+	var attrs []DescriptionAttribute
+
+	if fn, ok := sp.trace.Functions[sp.site.Frame.Fn]; ok {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Function",
+			Value: *(tb.DefaultLink(fn.Fn, "Function of current contention site", fn)),
+		})
+	} else {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Function",
+			Value: *(tb.Span(contentionSiteName(sp.site))),
+		})
+	}
+	if loc := contentionSiteLocation(sp.site); loc != "" {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Location",
+			Value: *(tb.Span(loc)),
+		})
+	}
+	attrs = append(attrs, buildLatencyDescription(&tb, &sp.site.Blocked)...)
+
+	return Description{Attributes: attrs}
+}
+
+func (sp *ContentionSitePanel) computeHistogram(win *theme.Window) {
+	cfg := &sp.hist.Config
+	var ds []time.Duration
+	for _, w := range sp.site.Waits {
+		d := w.Span.Duration()
+		if fd := widget.FloatDuration(d); fd >= cfg.Start && (cfg.End == 0 || fd <= cfg.End) {
+			ds = append(ds, d)
+		}
+	}
+	sp.hist.Set(win, ds)
+}
+
+func (sp *ContentionSitePanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.ContentionSitePanel.Layout").End()
+
+	if !sp.initialized {
+		sp.hist.Config = widget.HistogramConfig{RejectOutliers: true, Bins: widget.DefaultHistogramBins}
+		sp.computeHistogram(win)
+		sp.initialized = true
+	}
+
+	sp.timestampObjects.Reset()
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	goroutine := func(tb *TextBuilder, g *ptrace.Goroutine) {
+		tb.DefaultLink(local.Sprintf("goroutine %d: %s", g.ID, g.Function.Fn), "", g)
+	}
+
+	layoutWaits := func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+		return sp.cells.Table(win, gtx, contentionWaitsColumns, &sp.waitsList, nil, len(sp.site.Waits), func(tb *TextBuilder, txt *Text, row, col int) {
+			w := sp.site.Waits[row]
+			switch col {
+			case 0: // Goroutine
+				goroutine(tb, w.Goroutine)
+			case 1: // Start time
+				tb.Link(formatTimestamp(w.Span.Start), sp.timestampObjects.Append(w.Span.Start), goroutineSpanLink(sp.canvas, w.Goroutine, w.Span))
+				txt.Alignment = text.End
+			case 2: // Duration
+				durationCell(tb, txt, w.Span.Duration())
+			case 3: // State
+				tb.Span(stateNamesCapitalized[w.Span.State])
+			case 4: // Unblocked by
+				if w.Unblocker != nil {
+					goroutine(tb, w.Unblocker)
+				}
+			}
+		})
+	}
+
+	layoutUnblockers := func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+		return sp.cells.Table(win, gtx, contentionUnblockersColumns, &sp.unblockersList, nil, len(sp.site.Unblockers), func(tb *TextBuilder, txt *Text, row, col int) {
+			u := sp.site.Unblockers[row]
+			switch col {
+			case 0: // Goroutine
+				goroutine(tb, u.Goroutine)
+			case 1: // Unblocks
+				tb.Span(local.Sprintf("%d", u.Count))
+				txt.Alignment = text.End
+			case 2: // Total wait
+				durationCell(tb, txt, u.Wait)
+			}
+		})
+	}
+
+	tabs := []string{"Waits", "Unblockers", "Histogram"}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, sp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			sp.descriptionText.Reset(win.Theme)
+			return sp.buildDescription(win, gtx).Layout(win, gtx, &sp.descriptionText)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return theme.Tabbed(&sp.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				switch tabs[sp.tabbedState.Current] {
+				case "Waits":
+					return layoutWaits(win, gtx)
+				case "Unblockers":
+					return layoutUnblockers(win, gtx)
+				case "Histogram":
+					return sp.hist.Layout(win, gtx)
+				default:
+					panic("unreachable")
+				}
+			})
+		}),
+	)
+
+	for _, ev := range sp.descriptionText.Events() {
+		handleLinkClick(win, ev)
+	}
+	sp.cells.Finish(win)
+	if sp.hist.Changed() {
+		sp.computeHistogram(win)
+	}
+	for sp.PanelButtons.Backed() {
+		sp.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"image"
+	rtrace "runtime/trace"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/mem"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/text"
+)
+
+// CriticalPathPanel lists the hops of a goroutine's critical path. The spans on the path are highlighted on the canvas
+// while the panel is displayed, unless the user turns highlighting off.
+type CriticalPathPanel struct {
+	mwin  *theme.Window
+	trace *Trace
+	g     *ptrace.Goroutine
+	path  *ptrace.CriticalPath
+
+	list               widget.List
+	highlight          bool
+	toggleHighlighting widget.PrimaryClickable
+	timestampObjects   mem.BucketSlice[trace.Timestamp]
+	cells              TableCells
+
+	theme.PanelButtons
+}
+
+func NewCriticalPathPanel(mwin *theme.Window, tr *Trace, g *ptrace.Goroutine, path *ptrace.CriticalPath) *CriticalPathPanel {
+	cp := &CriticalPathPanel{
+		mwin:  mwin,
+		trace: tr,
+		g:     g,
+		path:  path,
+
+		highlight: true,
+	}
+	cp.list.Axis = layout.Vertical
+	return cp
+}
+
+// Highlight implements HighlightingPanel.
+func (cp *CriticalPathPanel) Highlight() Filter {
+	if !cp.highlight {
+		return Filter{}
+	}
+	return Filter{Path: cp.path}
+}
+
+func (cp *CriticalPathPanel) Title() string {
+	return local.Sprintf("Critical path of goroutine %d", cp.g.ID)
+}
+
+var criticalPathColumns = []theme.TableListColumn{
+	{
+		Name: "Goroutine",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Start time",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Duration",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "State",
+	},
+}
+
+func (cp *CriticalPathPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.CriticalPathPanel.Layout").End()
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	cp.timestampObjects.Reset()
+
+	cellFn := func(tb *TextBuilder, txt *Text, row, col int) {
+		hop := cp.path.Hops[row]
+		switch col {
+		case 0: // Goroutine
+			tb.DefaultLink(local.Sprintf("%d", hop.Goroutine), "", cp.trace.G(hop.Goroutine))
+			txt.Alignment = text.End
+		case 1: // Time
+			tb.DefaultLink(formatTimestamp(hop.Start), "", cp.timestampObjects.Append(hop.Start))
+			txt.Alignment = text.End
+		case 2: // Duration
+			durationCell(tb, txt, hop.Duration())
+		case 3: // State
+			tb.Span(stateNamesCapitalized[hop.Span.State])
+		}
+	}
+
+	for cp.toggleHighlighting.Clicked() {
+		cp.highlight = !cp.highlight
+	}
+	toggleLabel := "Stop highlighting"
+	if !cp.highlight {
+		toggleLabel = "Highlight path"
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &cp.toggleHighlighting.Clickable, toggleLabel).Layout)),
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, cp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			value, unit := durationNumberFormatSITable.format(cp.path.Duration())
+			s := local.Sprintf("%d hops through %d goroutines, covering %s %s.", len(cp.path.Hops), cp.path.NumGoroutines(), value, unit)
+			return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{Weight: font.Bold}, win.Theme.TextSize, s, widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return cp.cells.Table(win, gtx, criticalPathColumns, &cp.list, nil, len(cp.path.Hops), cellFn)
+		}),
+	)
+
+	cp.cells.Finish(win)
+	for cp.PanelButtons.Backed() {
+		cp.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
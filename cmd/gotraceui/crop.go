@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func cropUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintln(os.Stderr, "Usage: gotraceui crop [flags] <input trace> <output trace>")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Crop writes the part of a trace that lies within a time range to a new trace file.")
+		fmt.Fprintln(os.Stderr, "Times are relative to the start of the trace, e.g. 1.5s or 200ms.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Flags:")
+		printDefaults(fs)
+	}
+}
+
+// cropMain implements the 'crop' subcommand.
+func cropMain(args []string) error {
+	fs := flag.NewFlagSet("crop", flag.ExitOnError)
+	fs.Usage = cropUsage(fs)
+	start := fs.Duration("start", 0, "Start of the time range")
+	end := fs.Duration("end", math.MaxInt64, "End of the time range (default end of trace)")
+	goroutines := fs.String("g", "", "Comma-separated list of goroutine IDs to keep")
+	fn := fs.String("fn", "", "Only keep goroutines with this start function, e.g. net/http.(*conn).serve")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *end < *start {
+		return errors.New("end of time range is before its start")
+	}
+
+	var gids map[uint64]struct{}
+	if *goroutines != "" {
+		gids = map[uint64]struct{}{}
+		for _, s := range strings.Split(*goroutines, ",") {
+			gid, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid goroutine ID %q", s)
+			}
+			gids[gid] = struct{}{}
+		}
+	}
+
+	in, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	r, done, err := decompress(in)
+	if err != nil {
+		return err
+	}
+	defer done()
+	tr, err := trace.Parse(r, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't parse trace: %w", err)
+	}
+
+	if *fn != "" {
+		// Function names are only known once we've processed the trace.
+		pt, err := ptrace.Parse(tr, func(float64) {})
+		if err != nil {
+			return fmt.Errorf("couldn't process trace: %w", err)
+		}
+		fnGids := map[uint64]struct{}{}
+		for _, g := range pt.Goroutines {
+			if g.Function != nil && g.Function.Fn == *fn {
+				if _, ok := gids[g.ID]; gids == nil || ok {
+					fnGids[g.ID] = struct{}{}
+				}
+			}
+		}
+		if len(fnGids) == 0 {
+			return fmt.Errorf("found no goroutines with start function %s", *fn)
+		}
+		gids = fnGids
+	}
+
+	var keep func(uint64) bool
+	if gids != nil {
+		keep = func(gid uint64) bool {
+			_, ok := gids[gid]
+			return ok
+		}
+	}
+
+	out, err := os.Create(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if err := trace.Crop(out, &tr, trace.Timestamp(*start), trace.Timestamp(*end), keep); err != nil {
+		out.Close()
+		return fmt.Errorf("couldn't write trace: %w", err)
+	}
+	return out.Close()
+}
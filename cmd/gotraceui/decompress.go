@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"honnef.co/go/gotraceui/trace"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// progressReader reports progress against the underlying input, not against the data returned by Read. It
+// implements trace.ProgressReader.
+type progressReader struct {
+	io.Reader
+	progress func() float64
+}
+
+func (r *progressReader) Progress() float64 {
+	return r.progress()
+}
+
+// decompress sniffs the magic bytes of r and returns a reader that transparently decompresses gzip, zstd and xz
+// compressed input. Uncompressed input is read from r directly. The returned function releases the resources of the
+// decompressor and has to be called once the reader is no longer needed. It doesn't close r.
+//
+// The returned reader implements trace.ProgressReader if the size of r is known or if r itself implements
+// trace.ProgressReader.
+func decompress(r io.Reader) (io.Reader, func(), error) {
+	size := int64(-1)
+	if seeker, ok := r.(io.Seeker); ok {
+		cur, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, nil, err
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := seeker.Seek(cur, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		size = end - cur
+	}
+
+	cr := &trace.CountingReader{R: r}
+	br := bufio.NewReader(cr)
+	var progress func() float64
+	if pr, ok := r.(trace.ProgressReader); ok {
+		progress = pr.Progress
+	} else if size > 0 {
+		progress = func() float64 { return float64(cr.N) / float64(size) }
+	}
+	withProgress := func(r io.Reader) io.Reader {
+		if progress == nil {
+			return r
+		}
+		return &progressReader{Reader: r, progress: progress}
+	}
+
+	// Peek returns fewer bytes and an error for short inputs, which can't be compressed traces anyway.
+	magic, _ := br.Peek(len(xzMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return withProgress(zr), func() {}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return withProgress(zr), zr.Close, nil
+	case bytes.HasPrefix(magic, xzMagic):
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return withProgress(xr), func() {}, nil
+	default:
+		if seeker, ok := r.(io.Seeker); ok {
+			// Rewind and return the original reader so that the parser can determine the size of the input on its
+			// own.
+			if _, err := seeker.Seek(-int64(br.Buffered()), io.SeekCurrent); err != nil {
+				return nil, nil, err
+			}
+			return r, func() {}, nil
+		}
+		return withProgress(br), func() {}, nil
+	}
+}
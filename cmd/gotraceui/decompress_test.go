@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func TestDecompress(t *testing.T) {
+	want, err := os.ReadFile(filepath.Join("..", "..", "trace", "testdata", "stress_1_26_good"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressors := map[string]func(io.Writer) (io.WriteCloser, error){
+		"gzip": func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		"zstd": func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+		"xz":   func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) },
+	}
+	for name, fn := range compressors {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := fn(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(want); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, done, err := decompress(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer done()
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("decompressed data differs from original")
+			}
+			if p := r.(trace.ProgressReader).Progress(); p != 1 {
+				t.Errorf("got progress %f after reading all data, want 1", p)
+			}
+		})
+	}
+
+	t.Run("uncompressed", func(t *testing.T) {
+		in := bytes.NewReader(want)
+		r, done, err := decompress(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer done()
+		if r != io.Reader(in) {
+			t.Fatalf("got %T, want the original reader", r)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("data differs from original")
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"image"
+	rtrace "runtime/trace"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+)
+
+// DiagnosticsPanel lists the problems that were found while parsing a damaged trace or resolving its symbols.
+type DiagnosticsPanel struct {
+	mwin  *theme.Window
+	diags []trace.Diagnostic
+	list  widget.List
+
+	theme.PanelButtons
+}
+
+func NewDiagnosticsPanel(mwin *theme.Window, diags []trace.Diagnostic) *DiagnosticsPanel {
+	dp := &DiagnosticsPanel{
+		mwin:  mwin,
+		diags: diags,
+	}
+	dp.list.Axis = layout.Vertical
+	return dp
+}
+
+func (dp *DiagnosticsPanel) Title() string {
+	return "Trace problems"
+}
+
+func (dp *DiagnosticsPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.DiagnosticsPanel.Layout").End()
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+	label := func(gtx layout.Context, f font.Font, s string) layout.Dimensions {
+		return widget.Label{}.Layout(gtx, win.Theme.Shaper, f, win.Theme.TextSize, s, widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, dp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			return label(gtx, font.Font{Weight: font.Bold}, local.Sprintf("%d problems were found while loading the trace. Damaged parts of the trace and symbols that couldn't be resolved aren't displayed:", len(dp.diags)))
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return theme.List(win.Theme, &dp.list).Layout(gtx, len(dp.diags), func(gtx layout.Context, index int) layout.Dimensions {
+				gtx.Constraints.Min.Y = 0
+				return label(gtx, font.Font{}, dp.diags[index].String())
+			})
+		}),
+	)
+
+	for dp.PanelButtons.Backed() {
+		dp.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
@@ -25,6 +25,9 @@ type Filter struct {
 	// Bitmap of ptrace.SchedulingState
 	States uint64
 
+	// Bitmap of ptrace.SpanTag
+	Tags uint64
+
 	// Filters specific to processor timelines
 	Processor struct {
 		// Highlight processor spans for this goroutine
@@ -38,6 +41,12 @@ type Filter struct {
 	Machine struct {
 		Processor int32
 	}
+
+	// Highlight goroutine spans that are on this critical path. Only set by CriticalPathPanel.
+	Path *ptrace.CriticalPath
+
+	// Highlight user regions that belong to this task. Only set by TaskInfo.
+	Task *ptrace.Task
 }
 
 func (f Filter) HasState(state ptrace.SchedulingState) bool {
@@ -64,6 +73,19 @@ func (f Filter) Match(spans ptrace.Spans, container ItemContainer) bool {
 			return false, false
 		},
 
+		func() (bool, bool) {
+			if f.Tags == 0 {
+				return false, true
+			}
+
+			for i := 0; i < spans.Len(); i++ {
+				if spans.At(i).Tags.Mask()&f.Tags != 0 {
+					return true, false
+				}
+			}
+			return false, false
+		},
+
 		func() (bool, bool) {
 			if f.Processor.StartAfter == 0 && f.Processor.EndBefore == 0 {
 				return false, true
@@ -99,7 +121,7 @@ func (f Filter) Match(spans ptrace.Spans, container ItemContainer) bool {
 					// merged span as a whole, which means that finding some spans with the right goroutine and some
 					// spans with the time range would allow the merged span to match, even if the two sets of spans
 					// didn't intersect.
-					if container.Timeline.cv.trace.Event(span.Event).G != f.Processor.Goroutine {
+					if container.Timeline.cv.trace.Event(span.Event()).G != f.Processor.Goroutine {
 						continue
 					}
 				}
@@ -118,7 +140,7 @@ func (f Filter) Match(spans ptrace.Spans, container ItemContainer) bool {
 					tr := container.Timeline.cv.trace
 					for i := 0; i < spans.Len(); i++ {
 						s := spans.At(i)
-						g := tr.G(tr.Event(s.Event).G)
+						g := tr.G(tr.Event(s.Event()).G)
 						if g.ID == f.Processor.Goroutine {
 							return true, false
 						}
@@ -137,7 +159,7 @@ func (f Filter) Match(spans ptrace.Spans, container ItemContainer) bool {
 					tr := container.Timeline.cv.trace
 					for i := 0; i < spans.Len(); i++ {
 						s := spans.At(i)
-						p := tr.P(tr.Event(s.Event).P)
+						p := tr.P(tr.Event(s.Event()).P)
 						if p.ID == f.Machine.Processor {
 							return true, false
 						}
@@ -149,6 +171,35 @@ func (f Filter) Match(spans ptrace.Spans, container ItemContainer) bool {
 				return false, true
 			}
 		},
+
+		func() (bool, bool) {
+			if f.Path == nil {
+				return false, true
+			}
+
+			g, ok := container.Timeline.item.(*ptrace.Goroutine)
+			if !ok || container.Track.kind != TrackKindUnspecified {
+				return false, false
+			}
+			return f.Path.Overlaps(g.ID, spans.At(0).Start, LastSpan(spans).End), false
+		},
+
+		func() (bool, bool) {
+			if f.Task == nil {
+				return false, true
+			}
+
+			if container.Track.kind != TrackKindUserRegions {
+				return false, false
+			}
+			tr := container.Timeline.cv.trace
+			for i := 0; i < spans.Len(); i++ {
+				if tr.Event(spans.At(i).Event()).Args[trace.ArgUserRegionTaskID] == f.Task.ID {
+					return true, false
+				}
+			}
+			return false, false
+		},
 	}
 
 	switch f.Mode {
@@ -193,10 +244,34 @@ func (f Filter) couldMatch(spans ptrace.Spans, container ItemContainer) bool {
 	}
 
 	b := f.couldMatchState(spans, container)
+	b = b || f.couldMatchTags(spans, container)
 	b = b || f.couldMatchProcessor(spans, container)
+	b = b || f.couldMatchPath(spans, container)
+	b = b || f.couldMatchTask(spans, container)
 	return b
 }
 
+func (f Filter) couldMatchTask(spans ptrace.Spans, container ItemContainer) bool {
+	return f.Task != nil && container.Track.kind == TrackKindUserRegions
+}
+
+func (f Filter) couldMatchPath(spans ptrace.Spans, container ItemContainer) bool {
+	if f.Path == nil {
+		return false
+	}
+	_, ok := container.Timeline.item.(*ptrace.Goroutine)
+	return ok && container.Track.kind == TrackKindUnspecified
+}
+
+func (f Filter) couldMatchTags(spans ptrace.Spans, container ItemContainer) bool {
+	if f.Tags == 0 {
+		return false
+	}
+	// Only goroutine spans have tags that can be displayed.
+	_, ok := container.Timeline.item.(*ptrace.Goroutine)
+	return ok && container.Track.kind == TrackKindUnspecified
+}
+
 func (f Filter) couldMatchProcessor(spans ptrace.Spans, container ItemContainer) bool {
 	switch container.Timeline.item.(type) {
 	case *ptrace.Processor:
@@ -235,13 +310,16 @@ func (f Filter) couldMatchState(spans ptrace.Spans, container ItemContainer) boo
 type HighlightDialogStyle struct {
 	Filter *Filter
 
-	bits [ptrace.StateLast]widget.BackedBit[uint64]
+	bits    [ptrace.StateLast]widget.BackedBit[uint64]
+	tagBits [ptrace.MaxSpanTags]widget.BackedBit[uint64]
 
 	list      widget.List
 	foldables struct {
 		states widget.Bool
+		tags   widget.Bool
 	}
 	stateClickables []widget.Clickable
+	tagClickable    widget.Clickable
 }
 
 func HighlightDialog(win *theme.Window, f *Filter) HighlightDialogStyle {
@@ -254,6 +332,10 @@ func HighlightDialog(win *theme.Window, f *Filter) HighlightDialogStyle {
 		hd.bits[i].Bits = &f.States
 		hd.bits[i].Bit = i
 	}
+	for i := range hd.tagBits {
+		hd.tagBits[i].Bits = &f.Tags
+		hd.tagBits[i].Bit = i
+	}
 
 	hd.stateClickables = make([]widget.Clickable, 3)
 
@@ -263,7 +345,10 @@ func HighlightDialog(win *theme.Window, f *Filter) HighlightDialogStyle {
 func (hd *HighlightDialogStyle) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
 	defer rtrace.StartRegion(context.Background(), "main.HighlightDialogStyle.Layout").End()
 
-	return theme.List(win.Theme, &hd.list).Layout(gtx, 1, func(gtx layout.Context, index int) layout.Dimensions {
+	return theme.List(win.Theme, &hd.list).Layout(gtx, 2, func(gtx layout.Context, index int) layout.Dimensions {
+		if index == 1 {
+			return hd.layoutTags(win, gtx)
+		}
 		return theme.Foldable(win.Theme, &hd.foldables.states, "States").Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
 			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
 				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
@@ -304,3 +389,16 @@ func (hd *HighlightDialogStyle) Layout(win *theme.Window, gtx layout.Context) la
 		})
 	})
 }
+
+func (hd *HighlightDialogStyle) layoutTags(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	return theme.Foldable(win.Theme, &hd.foldables.tags, "Tags").Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+		// Tags without labels are internal and aren't displayed anywhere.
+		var boxes []theme.CheckBoxStyle
+		for i, def := range ptrace.TagDefinitions() {
+			if def.Label != "" {
+				boxes = append(boxes, theme.CheckBox(win.Theme, &hd.tagBits[i], def.Label))
+			}
+		}
+		return theme.CheckBoxGroup(win.Theme, &hd.tagClickable, "Goroutine tags").Layout(win, gtx, boxes...)
+	})
+}
@@ -120,7 +120,7 @@ func (fgwin *FlameGraphWindow) Run(win *app.Window, trace *ptrace.Trace, g *ptra
 				if root != "" {
 					var frames widget.FlamegraphSample
 					if root != "ready" {
-						stack := trace.Stacks[trace.Event(span.Event).StkID]
+						stack := trace.Stacks[trace.Event(span.Event()).StkID]
 						for i := len(stack) - 1; i >= 0; i-- {
 							fn := trace.PCs[stack[i]].Fn
 							frames = append(frames, widget.FlamegraphFrame{
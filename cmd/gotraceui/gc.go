@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"image"
+	rtrace "runtime/trace"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/text"
+)
+
+func gcCyclesToCSV(cycles []ptrace.GCCycle) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	w.Write([]string{"Cycle", "Start", "Duration", "STW", "STW phases", "Mark assist", "Dedicated", "Fractional", "Idle", "Heap at start", "Heap at end", "Heap goal"})
+
+	for i := range cycles {
+		c := &cycles[i]
+		phases := make([]string, len(c.STW))
+		for j, stw := range c.STW {
+			phases[j] = fmt.Sprintf("%s: %d", stw.Reason, stw.Span.Duration())
+		}
+		fields := []string{
+			fmt.Sprintf("%d", i+1),
+			fmt.Sprintf("%d", c.Span.Start),
+			fmt.Sprintf("%d", c.Span.Duration()),
+			fmt.Sprintf("%d", c.STWDuration()),
+			strings.Join(phases, "; "),
+			fmt.Sprintf("%d", c.MarkAssist),
+			fmt.Sprintf("%d", c.Dedicated),
+			fmt.Sprintf("%d", c.Fractional),
+			fmt.Sprintf("%d", c.Idle),
+			fmt.Sprintf("%d", c.HeapStart),
+			fmt.Sprintf("%d", c.HeapEnd),
+			fmt.Sprintf("%d", c.HeapGoal),
+		}
+		w.Write(fields)
+	}
+
+	w.Flush()
+	return buf.String()
+}
+
+// GCPanel displays one row per garbage collection cycle.
+type GCPanel struct {
+	mwin   *theme.Window
+	trace  *Trace
+	canvas *Canvas
+
+	cycles *theme.Future[[]ptrace.GCCycle]
+	// Indices of cycles, in display order
+	order []int
+	// Whether order has been sorted at least once
+	sorted bool
+
+	list            widget.List
+	sort            theme.TableSortState
+	copyAsCSV       widget.PrimaryClickable
+	descriptionText Text
+	cells           TableCells
+
+	theme.PanelButtons
+}
+
+func NewGCPanel(tr *Trace, mwin *theme.Window, canvas *Canvas) *GCPanel {
+	gp := &GCPanel{
+		mwin:   mwin,
+		trace:  tr,
+		canvas: canvas,
+	}
+	gp.list.Axis = layout.Vertical
+	return gp
+}
+
+func (gp *GCPanel) Title() string {
+	return "GC cycles"
+}
+
+var gcCyclesColumns = []theme.TableListColumn{
+	{
+		Name: "Cycle",
+		// XXX the width depends on the font and scaling
+		MinWidth: 80,
+		MaxWidth: 80,
+	},
+
+	{
+		Name: "Start",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Duration",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "STW",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Mark assist",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Dedicated",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Fractional",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Idle",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Heap at start",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Heap at end",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Heap goal",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "STW phases",
+	},
+}
+
+func (gp *GCPanel) sortCycles(cycles []ptrace.GCCycle) {
+	gp.order = tableOrder(gp.order, len(cycles))
+	sortTableRows(gp.order, &gp.sort, func(i, j, col int) int {
+		a, b := &cycles[i], &cycles[j]
+		switch col {
+		case 0, 1:
+			return cmp.Compare(a.Span.Start, b.Span.Start)
+		case 2:
+			return cmp.Compare(a.Span.Duration(), b.Span.Duration())
+		case 3:
+			return cmp.Compare(a.STWDuration(), b.STWDuration())
+		case 4:
+			return cmp.Compare(a.MarkAssist, b.MarkAssist)
+		case 5:
+			return cmp.Compare(a.Dedicated, b.Dedicated)
+		case 6:
+			return cmp.Compare(a.Fractional, b.Fractional)
+		case 7:
+			return cmp.Compare(a.Idle, b.Idle)
+		case 8:
+			return cmp.Compare(a.HeapStart, b.HeapStart)
+		case 9:
+			return cmp.Compare(a.HeapEnd, b.HeapEnd)
+		case 10:
+			return cmp.Compare(a.HeapGoal, b.HeapGoal)
+		case 11:
+			return cmp.Compare(len(a.STW), len(b.STW))
+		default:
+			panic("unreachable")
+		}
+	})
+	gp.sorted = true
+}
+
+func (gp *GCPanel) buildDescription(win *theme.Window, cycles []ptrace.GCCycle) Description {
+	tb := TextBuilder{Theme: win.Theme}
+	var total, stw, assist, workers time.Duration
+	for i := range cycles {
+		c := &cycles[i]
+		total += c.Span.Duration()
+		stw += c.STWDuration()
+		assist += c.MarkAssist
+		workers += c.Dedicated + c.Fractional + c.Idle
+	}
+	attrs := []DescriptionAttribute{
+		{
+			Key:   "# of cycles",
+			Value: *(tb.Span(local.Sprintf("%d", len(cycles)))),
+		},
+		{
+			Key:   "Time in GC",
+			Value: *(tb.Span(roundDuration(total).String())),
+		},
+		{
+			Key:   "Time in STW",
+			Value: *(tb.Span(roundDuration(stw).String())),
+		},
+		{
+			Key:   "Mark assist time",
+			Value: *(tb.Span(roundDuration(assist).String())),
+		},
+		{
+			Key:   "GC worker time",
+			Value: *(tb.Span(roundDuration(workers).String())),
+		},
+	}
+	return Description{Attributes: attrs}
+}
+
+func (gp *GCPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.GCPanel.Layout").End()
+
+	if gp.cycles == nil {
+		gp.cycles = theme.NewFuture(win, func(cancelled <-chan struct{}) []ptrace.GCCycle {
+			return gp.trace.GCCycles()
+		})
+	}
+	cycles, haveCycles := gp.cycles.Result()
+	if haveCycles && (gp.sort.Changed() || !gp.sorted) {
+		gp.sortCycles(cycles)
+	}
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	cellFn := func(tb *TextBuilder, txt *Text, row, col int) {
+		memory := func(v uint64) {
+			tb.Span(local.Sprintf("%d bytes", v))
+			txt.Alignment = text.End
+		}
+
+		idx := gp.order[row]
+		c := &cycles[idx]
+		switch col {
+		case 0: // Cycle
+			ss := SimpleItems[ptrace.Span]{
+				// Use the cycle's span, not the one in Trace.GC, as the latter lacks an end if the cycle didn't finish
+				// before the trace ended.
+				items: []ptrace.Span{c.Span},
+				container: ItemContainer{
+					Timeline: gp.canvas.timelines[0],
+					Track:    gp.canvas.timelines[0].tracks[0],
+				},
+			}
+			tb.Link(local.Sprintf("%d", idx+1), c, &GCCycleObjectLink{Spans: ss})
+			txt.Alignment = text.End
+		case 1: // Start
+			tb.Span(formatTimestamp(c.Span.Start))
+			txt.Alignment = text.End
+		case 2: // Duration
+			durationCell(tb, txt, c.Span.Duration())
+		case 3: // STW
+			durationCell(tb, txt, c.STWDuration())
+		case 4: // Mark assist
+			durationCell(tb, txt, c.MarkAssist)
+		case 5: // Dedicated
+			durationCell(tb, txt, c.Dedicated)
+		case 6: // Fractional
+			durationCell(tb, txt, c.Fractional)
+		case 7: // Idle
+			durationCell(tb, txt, c.Idle)
+		case 8: // Heap at start
+			memory(c.HeapStart)
+		case 9: // Heap at end
+			memory(c.HeapEnd)
+		case 10: // Heap goal
+			memory(c.HeapGoal)
+		case 11: // STW phases
+			for i, stw := range c.STW {
+				if i > 0 {
+					tb.Span(", ")
+				}
+				tb.Span(local.Sprintf("%s (%s)", stw.Reason, roundDuration(stw.Span.Duration())))
+			}
+		}
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &gp.copyAsCSV.Clickable, "Copy as CSV").Layout)),
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, gp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if !haveCycles {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing GC cycles…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			gtx.Constraints.Min = image.Point{}
+			gp.descriptionText.Reset(win.Theme)
+			return gp.buildDescription(win, cycles).Layout(win, gtx, &gp.descriptionText)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !haveCycles {
+				return layout.Dimensions{}
+			}
+			return gp.cells.Table(win, gtx, gcCyclesColumns, &gp.list, &gp.sort, len(gp.order), cellFn)
+		}),
+	)
+
+	gp.cells.Finish(win)
+	for gp.copyAsCSV.Clicked() {
+		if haveCycles {
+			win.AppWindow.WriteClipboard(gcCyclesToCSV(cycles))
+		}
+	}
+	for gp.PanelButtons.Backed() {
+		gp.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
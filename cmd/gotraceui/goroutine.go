@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	rtrace "runtime/trace"
+	"sort"
 	"strings"
 	"time"
 	"unsafe"
@@ -79,7 +80,7 @@ func goroutineTrack0SpanLabel(spans Items[ptrace.Span], tr *Trace, out []string)
 	span := spans.At(0)
 	state := span.State
 	if state == ptrace.StateBlockedSyscall {
-		ev := tr.Event(span.Event)
+		ev := tr.Event(span.Event())
 		if ev.StkID != 0 {
 			frames := tr.Stacks[ev.StkID]
 			fn := tr.PCs[frames[0]].Fn
@@ -95,18 +96,33 @@ func goroutineTrack0SpanLabel(spans Items[ptrace.Span], tr *Trace, out []string)
 
 func goroutineTrack0SpanContextMenu(spans Items[ptrace.Span], cv *Canvas) []*theme.MenuItem {
 	var items []*theme.MenuItem
-	items = append(items, newZoomMenuItem(cv, spans))
+	items = append(items, newZoomMenuItem(cv, spans), newSaveTraceMenuItem(spans))
+
+	if c, ok := spans.Container(); ok {
+		if g, ok := c.Timeline.item.(*ptrace.Goroutine); ok {
+			items = append(items, &theme.MenuItem{
+				Label: PlainLabel("Show critical path"),
+				Action: func() theme.Action {
+					return &ShowCriticalPathAction{
+						Goroutine: g,
+						Start:     spans.At(0).Start,
+						End:       LastSpan(spans).End,
+					}
+				},
+			})
+		}
+	}
 
 	if spans.Len() == 1 {
 		switch spans.At(0).State {
 		case ptrace.StateActive, ptrace.StateGCIdle, ptrace.StateGCDedicated, ptrace.StateGCFractional, ptrace.StateGCMarkAssist, ptrace.StateGCSweep:
 			// These are the states that are actually on-CPU
-			pid := cv.trace.Event((spans.At(0).Event)).P
+			pid := cv.trace.Event((spans.At(0).Event())).P
 			items = append(items, &theme.MenuItem{
 				Label: PlainLabel(local.Sprintf("Scroll to processor %d", pid)),
 				Action: func() theme.Action {
 					return ScrollToProcessorAction{
-						Processor: cv.trace.P(cv.trace.Event((spans.At(0).Event)).P),
+						Processor: cv.trace.P(cv.trace.Event((spans.At(0).Event())).P),
 					}
 				},
 			})
@@ -136,7 +152,7 @@ func userRegionSpanLabel(spans Items[ptrace.Span], tr *Trace, out []string) []st
 		return out
 	}
 	// OPT(dh): avoid this allocation
-	s := tr.Strings[tr.Events[spans.At(0).Event].Args[trace.ArgUserRegionTypeID]]
+	s := tr.Strings[tr.Event(spans.At(0).Event()).Args[trace.ArgUserRegionTypeID]]
 	return append(out, s)
 }
 
@@ -378,16 +394,8 @@ var reasonLabels = [256]string{
 }
 
 func unblockedByGoroutine(tr *Trace, s ptrace.Span) (uint64, bool) {
-	ev := tr.Event(s.Event)
-	switch s.State {
-	case ptrace.StateBlocked, ptrace.StateBlockedSend, ptrace.StateBlockedRecv, ptrace.StateBlockedSelect, ptrace.StateBlockedSync,
-		ptrace.StateBlockedSyncOnce, ptrace.StateBlockedSyncTriggeringGC, ptrace.StateBlockedCond, ptrace.StateBlockedNet, ptrace.StateBlockedGC:
-		if link := ptrace.EventID(ev.Link); link != -1 {
-			// g0 unblocks goroutines that are blocked on pollable I/O, for example.
-			if g := tr.Event(link).G; g != 0 {
-				return g, true
-			}
-		}
+	if ev, ok := tr.UnblockingEvent(s); ok {
+		return tr.Event(ev).G, true
 	}
 	return 0, false
 }
@@ -396,13 +404,13 @@ func goroutineSpanTooltip(win *theme.Window, gtx layout.Context, tr *Trace, stat
 	var label string
 	if debug {
 		label += local.Sprintf("Event ID: %d\n", state.spans.At(0).Event)
-		label += fmt.Sprintf("Event type: %d\n", tr.Event(state.spans.At(0).Event).Type)
+		label += fmt.Sprintf("Event type: %d\n", tr.Event(state.spans.At(0).Event()).Type)
 	}
 	label += "State: "
 	var at string
 	if state.spans.Len() == 1 {
 		s := state.spans.At(0)
-		ev := tr.Event(s.Event)
+		ev := tr.Event(s.Event())
 		if at == "" && ev.StkID > 0 {
 			at = tr.PCs[tr.Stacks[ev.StkID][s.At]].Fn
 		}
@@ -451,8 +459,8 @@ func goroutineSpanTooltip(win *theme.Window, gtx layout.Context, tr *Trace, stat
 			label += "GC mark assist"
 		case ptrace.StateGCSweep:
 			label += "GC sweep"
-			if ev.Link != -1 {
-				l := tr.Events[ev.Link]
+			if link := ev.Link(); link != -1 {
+				l := tr.Event(ptrace.EventID(link))
 				label += local.Sprintf("\nSwept %d bytes, reclaimed %d bytes",
 					l.Args[trace.ArgGCSweepDoneSwept], l.Args[trace.ArgGCSweepDoneReclaimed])
 			}
@@ -489,7 +497,7 @@ func goroutineSpanTooltip(win *theme.Window, gtx layout.Context, tr *Trace, stat
 	if state.spans.Len() == 1 {
 		switch state.spans.At(0).State {
 		case ptrace.StateActive, ptrace.StateGCIdle, ptrace.StateGCDedicated, ptrace.StateGCMarkAssist, ptrace.StateGCSweep:
-			pid := tr.Event(state.spans.At(0).Event).P
+			pid := tr.Event(state.spans.At(0).Event()).P
 			label += local.Sprintf("On: processor %d\n", pid)
 		}
 	}
@@ -549,7 +557,7 @@ func userRegionSpanTooltip(win *theme.Window, gtx layout.Context, tr *Trace, sta
 	var label string
 	if state.spans.Len() == 1 {
 		s := state.spans.At(0)
-		ev := tr.Event(s.Event)
+		ev := tr.Event(s.Event())
 		if s.State != ptrace.StateUserRegion {
 			panic(fmt.Sprintf("unexpected state %d", s.State))
 		}
@@ -640,7 +648,7 @@ func addStackTracks(tl *Timeline, g *ptrace.Goroutine, tr *Trace) {
 		}
 
 		if offSpans < len(g.Spans) {
-			id := g.Spans[offSpans].Event
+			id := g.Spans[offSpans].Event()
 			if offSamples < len(cpuSamples) {
 				oid := cpuSamples[offSamples]
 				if id <= oid {
@@ -684,7 +692,7 @@ func addStackTracks(tl *Timeline, g *ptrace.Goroutine, tr *Trace) {
 			break
 		}
 
-		ev := &tr.Events[evID]
+		ev := tr.Event(evID)
 		stk := tr.Stacks[ev.StkID]
 		switch ev.Type {
 		case trace.EvGoUnblock:
@@ -719,7 +727,7 @@ func addStackTracks(tl *Timeline, g *ptrace.Goroutine, tr *Trace) {
 		tracks = ensureAtLeastLen(tracks, len(stk))
 		var end trace.Timestamp
 		if endEvID, _, ok := nextEvent(false); ok {
-			end = tr.Events[endEvID].Ts
+			end = tr.Event(endEvID).Ts
 		} else {
 			end = g.Spans[len(g.Spans)-1].End
 		}
@@ -747,7 +755,7 @@ func addStackTracks(tl *Timeline, g *ptrace.Goroutine, tr *Trace) {
 					prevState = ptrace.StateStack
 				}
 				fn := tr.PCs[stk[len(stk)-i-1]].Fn
-				if prevEnd == tr.Events[evID].Ts && prevFn == fn && state == prevState {
+				if prevEnd == tr.Event(evID).Ts && prevFn == fn && state == prevState {
 					// This is a continuation of the previous span. Merging these can have massive memory usage savings,
 					// which is why we do it here and not during display.
 					//
@@ -822,6 +830,21 @@ func addStackTracks(tl *Timeline, g *ptrace.Goroutine, tr *Trace) {
 	tl.tracks = append(tl.tracks, stackTracks...)
 }
 
+// goroutineSpanLink returns a link to one of a goroutine's spans.
+func goroutineSpanLink(canvas *Canvas, g *ptrace.Goroutine, s ptrace.Span) ObjectLink {
+	idx := sort.Search(len(g.Spans), func(i int) bool { return g.Spans[i].Start >= s.Start })
+	tl := canvas.itemToTimeline[g]
+	ss := SimpleItems[ptrace.Span]{
+		items: g.Spans[idx : idx+1],
+		container: ItemContainer{
+			Timeline: tl,
+			Track:    tl.tracks[0],
+		},
+		subslice: true,
+	}
+	return &SpansObjectLink{Spans: ss}
+}
+
 func NewGoroutineInfo(tr *Trace, mwin *theme.Window, canvas *Canvas, g *ptrace.Goroutine, allTimelines []*Timeline) *SpansInfo {
 	var title string
 	if g.Function.Fn != "" {
@@ -834,7 +857,7 @@ func NewGoroutineInfo(tr *Trace, mwin *theme.Window, canvas *Canvas, g *ptrace.G
 
 	var stacktrace string
 	if spans[0].State == ptrace.StateCreated {
-		ev := tr.Events[spans[0].Event]
+		ev := tr.Event(spans[0].Event())
 		stk := tr.Stacks[ev.StkID]
 		sb := strings.Builder{}
 		for _, f := range stk {
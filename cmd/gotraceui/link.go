@@ -78,6 +78,8 @@ type CanvasToggleCompactDisplayAction struct{}
 type CanvasToggleStackTracksAction struct{}
 type OpenScrollToTimelineAction struct{}
 type OpenFileOpenAction struct{}
+type OpenCaptureDialogAction struct{}
+type SaveTraceSelectionAction struct{ Start, End trace.Timestamp }
 type ExitAction struct{}
 type WriteMemoryProfileAction struct{}
 type RunGarbageCollectionAction struct{}
@@ -86,6 +88,31 @@ type StartCPUProfileAction struct{}
 type StopCPUProfileAction struct{}
 type OpenPanelAction struct{ Panel theme.Panel }
 type PrevPanelAction struct{}
+type OpenTaskAction struct {
+	Task       *ptrace.Task
+	Provenance string
+}
+type OpenTaskNameAction struct {
+	Name       string
+	Provenance string
+}
+type CanvasShowAllTimelinesAction struct{}
+type ShowCriticalPathAction struct {
+	Goroutine  *ptrace.Goroutine
+	Start, End trace.Timestamp
+}
+type ScrollAndPanToEventAction struct {
+	Event      ptrace.EventID
+	Provenance string
+}
+type OpenContentionSiteAction struct {
+	Site       *ptrace.ContentionSite
+	Provenance string
+}
+type OpenChannelSitePairAction struct {
+	Pair       *ptrace.ChannelSitePair
+	Provenance string
+}
 type GoroutineObjectLink struct {
 	Goroutine  *ptrace.Goroutine
 	Provenance string
@@ -102,7 +129,28 @@ type FunctionObjectLink struct {
 	Function   *ptrace.Function
 	Provenance string
 }
+type TaskObjectLink struct {
+	Task       *ptrace.Task
+	Provenance string
+}
+type TaskNameObjectLink struct {
+	Name       string
+	Provenance string
+}
+type EventObjectLink struct {
+	Event      ptrace.EventID
+	Provenance string
+}
+type ContentionSiteObjectLink struct {
+	Site       *ptrace.ContentionSite
+	Provenance string
+}
+type ChannelSitePairObjectLink struct {
+	Pair       *ptrace.ChannelSitePair
+	Provenance string
+}
 type SpansObjectLink struct{ Spans Items[ptrace.Span] }
+type GCCycleObjectLink struct{ Spans Items[ptrace.Span] }
 
 func (OpenGoroutineAction) IsAction()              {}
 func (ScrollToGoroutineAction) IsAction()          {}
@@ -129,6 +177,8 @@ func (CanvasToggleCompactDisplayAction) IsAction() {}
 func (CanvasToggleStackTracksAction) IsAction()    {}
 func (OpenScrollToTimelineAction) IsAction()       {}
 func (OpenFileOpenAction) IsAction()               {}
+func (OpenCaptureDialogAction) IsAction()          {}
+func (SaveTraceSelectionAction) IsAction()         {}
 func (ExitAction) IsAction()                       {}
 func (WriteMemoryProfileAction) IsAction()         {}
 func (RunGarbageCollectionAction) IsAction()       {}
@@ -137,6 +187,13 @@ func (StartCPUProfileAction) IsAction()            {}
 func (StopCPUProfileAction) IsAction()             {}
 func (*OpenPanelAction) IsAction()                 {}
 func (PrevPanelAction) IsAction()                  {}
+func (OpenTaskAction) IsAction()                   {}
+func (OpenTaskNameAction) IsAction()               {}
+func (CanvasShowAllTimelinesAction) IsAction()     {}
+func (ShowCriticalPathAction) IsAction()           {}
+func (ScrollAndPanToEventAction) IsAction()        {}
+func (OpenContentionSiteAction) IsAction()         {}
+func (OpenChannelSitePairAction) IsAction()        {}
 
 func defaultObjectLink(obj any, provenance string) ObjectLink {
 	switch obj := obj.(type) {
@@ -150,6 +207,12 @@ func defaultObjectLink(obj any, provenance string) ObjectLink {
 		return &TimestampObjectLink{obj, provenance}
 	case *ptrace.Function:
 		return &FunctionObjectLink{obj, provenance}
+	case *ptrace.Task:
+		return &TaskObjectLink{obj, provenance}
+	case *ptrace.ContentionSite:
+		return &ContentionSiteObjectLink{obj, provenance}
+	case *ptrace.ChannelSitePair:
+		return &ChannelSitePairObjectLink{obj, provenance}
 	default:
 		panic(fmt.Sprintf("unsupported type: %T", obj))
 	}
@@ -238,6 +301,46 @@ func (l *FunctionObjectLink) ContextMenu() []*theme.MenuItem {
 	return nil
 }
 
+func (l *TaskObjectLink) Action(ev gesture.ClickEvent) theme.Action {
+	return (*OpenTaskAction)(l)
+}
+
+func (l *TaskObjectLink) ContextMenu() []*theme.MenuItem {
+	return nil
+}
+
+func (l *TaskNameObjectLink) Action(ev gesture.ClickEvent) theme.Action {
+	return (*OpenTaskNameAction)(l)
+}
+
+func (l *TaskNameObjectLink) ContextMenu() []*theme.MenuItem {
+	return nil
+}
+
+func (l *ContentionSiteObjectLink) Action(ev gesture.ClickEvent) theme.Action {
+	return (*OpenContentionSiteAction)(l)
+}
+
+func (l *ContentionSiteObjectLink) ContextMenu() []*theme.MenuItem {
+	return nil
+}
+
+func (l *ChannelSitePairObjectLink) Action(ev gesture.ClickEvent) theme.Action {
+	return (*OpenChannelSitePairAction)(l)
+}
+
+func (l *ChannelSitePairObjectLink) ContextMenu() []*theme.MenuItem {
+	return nil
+}
+
+func (l *EventObjectLink) Action(ev gesture.ClickEvent) theme.Action {
+	return (*ScrollAndPanToEventAction)(l)
+}
+
+func (l *EventObjectLink) ContextMenu() []*theme.MenuItem {
+	return nil
+}
+
 func (l *SpansObjectLink) Action(ev gesture.ClickEvent) theme.Action {
 	switch ev.Modifiers {
 	default:
@@ -255,6 +358,14 @@ func (l *SpansObjectLink) Action(ev gesture.ClickEvent) theme.Action {
 	}
 }
 
+func (l *GCCycleObjectLink) Action(ev gesture.ClickEvent) theme.Action {
+	return (*ZoomToSpansAction)(l)
+}
+
+func (l *GCCycleObjectLink) ContextMenu() []*theme.MenuItem {
+	return (*SpansObjectLink)(l).ContextMenu()
+}
+
 func (l *SpansObjectLink) ContextMenu() []*theme.MenuItem {
 	if _, ok := l.Spans.Container(); ok {
 		return []*theme.MenuItem{
@@ -357,6 +468,29 @@ func (l *OpenFunctionAction) Open(_ layout.Context, mwin *MainWindow) {
 	mwin.openFunction(l.Function)
 }
 
+func (l *OpenTaskAction) Open(gtx layout.Context, mwin *MainWindow) {
+	mwin.openTask(gtx, l.Task)
+}
+
+func (l *OpenTaskNameAction) Open(gtx layout.Context, mwin *MainWindow) {
+	mwin.openPanel(NewTaskNameInfo(mwin.trace, mwin.twin, l.Name))
+}
+
+func (l *OpenContentionSiteAction) Open(gtx layout.Context, mwin *MainWindow) {
+	mwin.openPanel(NewContentionSitePanel(mwin.trace, mwin.twin, &mwin.canvas, l.Site))
+}
+
+func (l *OpenChannelSitePairAction) Open(gtx layout.Context, mwin *MainWindow) {
+	mwin.openPanel(NewChannelSitePairPanel(mwin.trace, mwin.twin, &mwin.canvas, l.Pair))
+}
+
+func (l *ScrollAndPanToEventAction) Open(gtx layout.Context, mwin *MainWindow) {
+	ev := mwin.trace.Event(l.Event)
+	y := mwin.canvas.objectY(gtx, mwin.trace.G(ev.G))
+	d := mwin.canvas.End() - mwin.canvas.start
+	mwin.canvas.navigateTo(gtx, ev.Ts-d/2, mwin.canvas.nsPerPx, y)
+}
+
 func (l *OpenSpansAction) Open(gtx layout.Context, mwin *MainWindow) {
 	mwin.openSpan(l.Spans)
 }
@@ -392,6 +526,9 @@ func handleLinkClick(win *theme.Window, ev TextEvent) {
 func (l CanvasJumpToBeginningAction) Open(gtx layout.Context, mwin *MainWindow) {
 	mwin.canvas.JumpToBeginning(gtx)
 }
+func (l CanvasShowAllTimelinesAction) Open(gtx layout.Context, mwin *MainWindow) {
+	mwin.canvas.unfocusTimelines()
+}
 func (l CanvasScrollToTopAction) Open(gtx layout.Context, mwin *MainWindow) {
 	mwin.canvas.ScrollToTop(gtx)
 }
@@ -421,12 +558,18 @@ func (l CanvasToggleStackTracksAction) Open(gtx layout.Context, mwin *MainWindow
 }
 func (l OpenScrollToTimelineAction) Open(gtx layout.Context, mwin *MainWindow) {
 	pl := theme.CommandPalette{Prompt: "Scroll to timeline"}
-	pl.Set(GotoTimelineCommandProvider{mwin.twin, mwin.canvas.timelines})
+	pl.Set(GotoTimelineCommandProvider{mwin.twin, mwin.canvas.allTimelines()})
 	mwin.twin.SetModal(pl.Layout)
 }
 func (l OpenFileOpenAction) Open(gtx layout.Context, mwin *MainWindow) {
 	mwin.showFileOpenDialog()
 }
+func (l OpenCaptureDialogAction) Open(gtx layout.Context, mwin *MainWindow) {
+	mwin.showCaptureDialog()
+}
+func (l *SaveTraceSelectionAction) Open(gtx layout.Context, mwin *MainWindow) {
+	mwin.showFileSaveDialog(l.Start, l.End)
+}
 func (l ExitAction) Open(gtx layout.Context, mwin *MainWindow) {
 	os.Exit(0)
 }
@@ -501,6 +644,11 @@ func (PrevPanelAction) Open(gtx layout.Context, mwin *MainWindow) {
 	mwin.prevPanel()
 }
 
+func (l *ShowCriticalPathAction) Open(gtx layout.Context, mwin *MainWindow) {
+	path := mwin.trace.CriticalPath(l.Goroutine, l.Start, l.End)
+	mwin.openPanel(NewCriticalPathPanel(mwin.twin, mwin.trace, l.Goroutine, path))
+}
+
 func (l *GoroutineObjectLink) Commands() []theme.Command {
 	return []theme.Command{
 		theme.NormalCommand{
@@ -599,4 +747,87 @@ func (l *FunctionObjectLink) Commands() []theme.Command {
 		},
 	}
 }
+func (l *TaskObjectLink) Commands() []theme.Command {
+	return []theme.Command{
+		theme.NormalCommand{
+			PrimaryLabel:   local.Sprintf("Open task %d: %s", l.Task.ID, l.Task.Name),
+			SecondaryLabel: l.Provenance,
+			Category:       "Link",
+			Aliases:        []string{"open"},
+			Color:          colorLink,
+			Fn: func() theme.Action {
+				return (*OpenTaskAction)(l)
+			},
+		},
+	}
+}
+func (l *TaskNameObjectLink) Commands() []theme.Command {
+	return []theme.Command{
+		theme.NormalCommand{
+			PrimaryLabel:   local.Sprintf("Show information for tasks named %q", l.Name),
+			SecondaryLabel: l.Provenance,
+			Category:       "Link",
+			Aliases:        []string{"open"},
+			Color:          colorLink,
+			Fn: func() theme.Action {
+				return (*OpenTaskNameAction)(l)
+			},
+		},
+	}
+}
+func (l *ContentionSiteObjectLink) Commands() []theme.Command {
+	return []theme.Command{
+		theme.NormalCommand{
+			PrimaryLabel:   local.Sprintf("Show contention at %s", l.Site.Frame.Fn),
+			SecondaryLabel: l.Provenance,
+			Category:       "Link",
+			Aliases:        []string{"open"},
+			Color:          colorLink,
+			Fn: func() theme.Action {
+				return (*OpenContentionSiteAction)(l)
+			},
+		},
+	}
+}
+func (l *ChannelSitePairObjectLink) Commands() []theme.Command {
+	return []theme.Command{
+		theme.NormalCommand{
+			PrimaryLabel:   local.Sprintf("Show channel communication from %s to %s", channelSiteName(l.Pair.Sender), channelSiteName(l.Pair.Receiver)),
+			SecondaryLabel: l.Provenance,
+			Category:       "Link",
+			Aliases:        []string{"open"},
+			Color:          colorLink,
+			Fn: func() theme.Action {
+				return (*OpenChannelSitePairAction)(l)
+			},
+		},
+	}
+}
+func (l *EventObjectLink) Commands() []theme.Command {
+	return []theme.Command{
+		theme.NormalCommand{
+			PrimaryLabel:   "Scroll and pan to event",
+			SecondaryLabel: l.Provenance,
+			Category:       "Link",
+			Aliases:        []string{"goto", "go to", "scroll"},
+			Color:          colorLink,
+			Fn: func() theme.Action {
+				return (*ScrollAndPanToEventAction)(l)
+			},
+		},
+	}
+}
 func (l *SpansObjectLink) Commands() []theme.Command { return nil }
+func (l *GCCycleObjectLink) Commands() []theme.Command {
+	return []theme.Command{
+		theme.NormalCommand{
+			PrimaryLabel: "Zoom to GC cycle",
+			Category:     "Link",
+			Aliases:      []string{"open"},
+			Color:        colorLink,
+			Fn: func() theme.Action {
+				return (*ZoomToSpansAction)(l)
+			},
+		},
+	}
+}
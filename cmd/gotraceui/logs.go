@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"image"
+	rtrace "runtime/trace"
+	"sort"
+	"strings"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/mem"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/text"
+	"gioui.org/x/outlay"
+)
+
+// LogBrowser lists all user log messages in the trace.
+type LogBrowser struct {
+	mwin  *theme.Window
+	trace *Trace
+
+	// All log events, sorted by time
+	logs []ptrace.EventID
+	// Log events that match the filter, sorted by time
+	filtered []ptrace.EventID
+
+	categories     []string
+	showCategories []widget.Bool
+	filter         widget.Editor
+
+	list             widget.List
+	timestampObjects mem.BucketSlice[trace.Timestamp]
+	cells            TableCells
+
+	theme.PanelButtons
+}
+
+func NewLogBrowser(tr *Trace, mwin *theme.Window) *LogBrowser {
+	lb := &LogBrowser{
+		mwin:  mwin,
+		trace: tr,
+	}
+	lb.list.Axis = layout.Vertical
+	lb.filter.SingleLine = true
+
+	for cat, evs := range tr.Logs {
+		lb.categories = append(lb.categories, cat)
+		lb.logs = append(lb.logs, evs...)
+	}
+	sort.Strings(lb.categories)
+	// Event IDs are ordered by time.
+	sort.Slice(lb.logs, func(i, j int) bool { return lb.logs[i] < lb.logs[j] })
+
+	lb.showCategories = make([]widget.Bool, len(lb.categories))
+	for i := range lb.showCategories {
+		lb.showCategories[i].Value = true
+	}
+	lb.updateFilter()
+
+	return lb
+}
+
+func (lb *LogBrowser) Title() string {
+	return "Logs"
+}
+
+func (lb *LogBrowser) updateFilter() {
+	shown := make(map[string]bool, len(lb.categories))
+	for i, cat := range lb.categories {
+		shown[cat] = lb.showCategories[i].Value
+	}
+	needle := strings.ToLower(lb.filter.Text())
+
+	lb.filtered = lb.filtered[:0]
+	for _, evID := range lb.logs {
+		ev := lb.trace.Event(evID)
+		cat := lb.trace.Strings[ev.Args[trace.ArgUserLogKeyID]]
+		if !shown[cat] {
+			continue
+		}
+		if needle != "" {
+			msg := lb.trace.Strings[ev.Args[trace.ArgUserLogMessage]]
+			if !strings.Contains(strings.ToLower(msg), needle) && !strings.Contains(strings.ToLower(cat), needle) {
+				continue
+			}
+		}
+		lb.filtered = append(lb.filtered, evID)
+	}
+}
+
+var logBrowserColumns = []theme.TableListColumn{
+	{
+		Name: "Time",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Goroutine",
+		// XXX the width depends on the font and scaling
+		MinWidth: 150,
+		MaxWidth: 150,
+	},
+
+	{
+		Name: "Task",
+		// XXX the width depends on the font and scaling
+		MinWidth: 100,
+		MaxWidth: 100,
+	},
+
+	{
+		Name: "Category",
+		// XXX the width depends on the font and scaling
+		MinWidth: 150,
+		MaxWidth: 150,
+	},
+
+	{
+		Name: "Message",
+	},
+}
+
+func (lb *LogBrowser) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.LogBrowser.Layout").End()
+
+	lb.timestampObjects.Reset()
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	cellFn := func(tb *TextBuilder, txt *Text, row, col int) {
+		evID := lb.filtered[row]
+		ev := lb.trace.Event(evID)
+		switch col {
+		case 0: // Time
+			tb.Link(formatTimestamp(ev.Ts), lb.timestampObjects.Append(ev.Ts), &EventObjectLink{Event: evID})
+			txt.Alignment = text.End
+		case 1: // Goroutine
+			tb.DefaultLink(local.Sprintf("goroutine %d", ev.G), "", lb.trace.G(ev.G))
+		case 2: // Task
+			if id := ev.Args[trace.ArgUserLogTaskID]; id != 0 {
+				tb.DefaultLink(local.Sprintf("%d", id), "", lb.trace.Task(id))
+			}
+			txt.Alignment = text.End
+		case 3: // Category
+			tb.Span(lb.trace.Strings[ev.Args[trace.ArgUserLogKeyID]])
+		case 4: // Message
+			tb.Span(lb.trace.Strings[ev.Args[trace.ArgUserLogMessage]])
+		default:
+			panic("unreachable")
+		}
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Max.X = min(gtx.Constraints.Max.X, gtx.Dp(400))
+					return theme.TextBox(win.Theme, &lb.filter, "Filter messages").Layout(gtx)
+				}),
+				layout.Rigid(layout.Spacer{Width: 10}.Layout),
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					label := local.Sprintf("Showing %d of %d logs", len(lb.filtered), len(lb.logs))
+					return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, label, widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+				}),
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, lb.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 5}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			return outlay.FlowWrap{}.Layout(gtx, len(lb.categories), func(gtx layout.Context, i int) layout.Dimensions {
+				label := lb.categories[i]
+				if label == "" {
+					label = "(no category)"
+				}
+				return layout.Inset{Right: 10}.Layout(gtx, theme.Dumb(win, theme.CheckBox(win.Theme, &lb.showCategories[i], label).Layout))
+			})
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return lb.cells.Table(win, gtx, logBrowserColumns, &lb.list, nil, len(lb.filtered), cellFn)
+		}),
+	)
+
+	changed := false
+	for _, ev := range lb.filter.Events() {
+		if _, ok := ev.(widget.ChangeEvent); ok {
+			changed = true
+		}
+	}
+	for i := range lb.showCategories {
+		if lb.showCategories[i].Changed() {
+			changed = true
+		}
+	}
+	if changed {
+		lb.updateFilter()
+	}
+
+	lb.cells.Finish(win)
+	for lb.PanelButtons.Backed() {
+		lb.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	rtrace "runtime/trace"
+	"sort"
 	"time"
 
 	"honnef.co/go/gotraceui/layout"
@@ -19,9 +21,8 @@ func machineTrack0SpanLabel(spans Items[ptrace.Span], tr *Trace, out []string) [
 	s := spans.At(0)
 	switch s.State {
 	case ptrace.StateRunningP:
-		p := tr.P(tr.Event(s.Event).P)
-		labels := tr.processorSpanLabels(p)
-		return append(out, labels...)
+		// Not all processors that have been started have timelines, so we can't use processorSpanLabels.
+		return append(out, local.Sprintf("p%d", tr.Event(s.Event()).P))
 	case ptrace.StateBlockedSyscall:
 		return append(out, "syscall")
 	default:
@@ -33,7 +34,7 @@ func machineTrack0SpanTooltip(win *theme.Window, gtx layout.Context, tr *Trace,
 	var label string
 	if state.spans.Len() == 1 {
 		s := state.spans.At(0)
-		ev := tr.Event(s.Event)
+		ev := tr.Event(s.Event())
 		switch s.State {
 		case ptrace.StateRunningP:
 			label = local.Sprintf("Processor %d\n", ev.P)
@@ -51,19 +52,21 @@ func machineTrack0SpanTooltip(win *theme.Window, gtx layout.Context, tr *Trace,
 
 func machineTrack0SpanContextMenu(spans Items[ptrace.Span], cv *Canvas) []*theme.MenuItem {
 	var items []*theme.MenuItem
-	items = append(items, newZoomMenuItem(cv, spans))
+	items = append(items, newZoomMenuItem(cv, spans), newSaveTraceMenuItem(spans))
 
 	if spans.Len() == 1 {
 		s := spans.At(0)
 		switch s.State {
 		case ptrace.StateRunningP:
-			pid := cv.trace.Event(s.Event).P
-			items = append(items, &theme.MenuItem{
-				Label: PlainLabel(local.Sprintf("Scroll to processor %d", pid)),
-				Action: func() theme.Action {
-					return &ScrollToProcessorAction{Processor: cv.trace.P(pid)}
-				},
-			})
+			pid := cv.trace.Event(s.Event()).P
+			if p, ok := findProcessor(cv.trace, pid); ok {
+				items = append(items, &theme.MenuItem{
+					Label: PlainLabel(local.Sprintf("Scroll to processor %d", pid)),
+					Action: func() theme.Action {
+						return &ScrollToProcessorAction{Processor: p}
+					},
+				})
+			}
 		case ptrace.StateBlockedSyscall:
 		default:
 			panic(fmt.Sprintf("unexpected state %d", s.State))
@@ -73,11 +76,23 @@ func machineTrack0SpanContextMenu(spans Items[ptrace.Span], cv *Canvas) []*theme
 	return items
 }
 
+// findProcessor looks up a processor. Unlike Trace.P, it doesn't panic for processors that never ran any goroutines
+// and thus have no timelines.
+func findProcessor(tr *Trace, pid int32) (*ptrace.Processor, bool) {
+	idx, found := sort.Find(len(tr.Processors), func(idx int) int {
+		return cmp.Compare(pid, tr.Processors[idx].ID)
+	})
+	if !found {
+		return nil, false
+	}
+	return tr.Processors[idx], true
+}
+
 func machineTrack1SpanLabel(spans Items[ptrace.Span], tr *Trace, out []string) []string {
 	if spans.Len() != 1 {
 		return out
 	}
-	g := tr.G(tr.Event(spans.At(0).Event).G)
+	g := tr.G(tr.Event(spans.At(0).Event()).G)
 	labels := tr.goroutineSpanLabels(g)
 	return append(out, labels...)
 }
@@ -85,7 +100,7 @@ func machineTrack1SpanLabel(spans Items[ptrace.Span], tr *Trace, out []string) [
 func machineTrack1SpanColor(spans Items[ptrace.Span], tr *Trace) [2]colorIndex {
 	// OPT(dh): implement caching
 	do := func(s ptrace.Span, tr *Trace) colorIndex {
-		gid := tr.Events[s.Event].G
+		gid := tr.Event(s.Event()).G
 		g := tr.G(gid)
 		switch fn := g.Function.Fn; fn {
 		case "runtime.bgscavenge", "runtime.bgsweep", "runtime.gcBgMarkWorker":
@@ -114,13 +129,13 @@ func machineTrack1SpanColor(spans Items[ptrace.Span], tr *Trace) [2]colorIndex {
 
 func machineTrack1SpanContextMenu(spans Items[ptrace.Span], cv *Canvas) []*theme.MenuItem {
 	var items []*theme.MenuItem
-	items = append(items, newZoomMenuItem(cv, spans))
+	items = append(items, newZoomMenuItem(cv, spans), newSaveTraceMenuItem(spans))
 
 	if spans.Len() == 1 {
 		s := spans.At(0)
 		switch s.State {
 		case ptrace.StateRunningG:
-			gid := cv.trace.Event(s.Event).G
+			gid := cv.trace.Event(s.Event()).G
 			items = append(items, &theme.MenuItem{
 				Label: PlainLabel(local.Sprintf("Scroll to goroutine %d", gid)),
 				Action: func() theme.Action {
@@ -162,7 +177,7 @@ func machineInvalidateCache(tl *Timeline, cv *Canvas) bool {
 	}
 
 	// If we got to this point, then both slices have exactly one element.
-	if cv.trace.Event(cv.prevFrame.hoveredSpans.At(0).Event).P != cv.trace.Event(cv.timeline.hoveredSpans.At(0).Event).P {
+	if cv.trace.Event(cv.prevFrame.hoveredSpans.At(0).Event()).P != cv.trace.Event(cv.timeline.hoveredSpans.At(0).Event()).P {
 		return true
 	}
 
@@ -180,14 +195,14 @@ func (tt MachineTooltip) Layout(win *theme.Window, gtx layout.Context) layout.Di
 	// OPT(dh): compute statistics once, not on every frame
 
 	tr := tt.trace
-	d := time.Duration(tr.Events[len(tr.Events)-1].Ts)
+	d := time.Duration(tr.Events.Last().Ts)
 
 	var procD, syscallD time.Duration
 	for i := 0; i < len(tt.m.Spans); i++ {
 		s := &tt.m.Spans[i]
 		d := s.Duration()
 
-		ev := tr.Events[s.Event]
+		ev := tr.Event(s.Event())
 		switch ev.Type {
 		case trace.EvProcStart:
 			procD += d
@@ -220,9 +235,6 @@ func (tt MachineTooltip) Layout(win *theme.Window, gtx layout.Context) layout.Di
 }
 
 func NewMachineTimeline(tr *Trace, cv *Canvas, m *ptrace.Machine) *Timeline {
-	if !supportMachineTimelines {
-		panic("NewMachineWidget was called despite supportmachineActivities == false")
-	}
 	l := local.Sprintf("Machine %d", m.ID)
 	tl := &Timeline{
 		item:      m,
@@ -257,7 +269,6 @@ func NewMachineTimeline(tr *Trace, cv *Canvas, m *ptrace.Machine) *Timeline {
 
 	tl.tracks = []*Track{
 		NewTrack(tl, TrackKindUnspecified),
-		NewTrack(tl, TrackKindUnspecified),
 	}
 
 	tl.tracks[0].Start = m.Spans[0].Start
@@ -271,6 +282,12 @@ func NewMachineTimeline(tr *Trace, cv *Canvas, m *ptrace.Machine) *Timeline {
 		},
 		subslice: true,
 	})
+	if len(m.Goroutines) == 0 {
+		// The M never ran any goroutines while we were tracing, for example because it spent all its time in a
+		// syscall.
+		return tl
+	}
+	tl.tracks = append(tl.tracks, NewTrack(tl, TrackKindUnspecified))
 	tl.tracks[1].Start = m.Goroutines[0].Start
 	tl.tracks[1].End = m.Goroutines[len(m.Goroutines)-1].End
 	tl.tracks[1].Len = len(m.Goroutines)
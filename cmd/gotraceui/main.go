@@ -103,11 +103,6 @@ func debugCaching(gtx layout.Context) {
 //   leading up to starting the trace. It will in no way reflect the code that actually, historically, started the
 //   goroutine. To avoid confusion, we should remove those stacks altogether.
 
-// This boolean guards all code involving displaying machine timelines. That feature is currently broken, because the
-// trace parser only produces an event ordering that is consistent for goroutines, but not for machines. For example, it
-// may try to start a P on an M that is currently blocked on a syscall.
-const supportMachineTimelines = false
-
 var (
 	softDebug          bool
 	cpuprofile         string
@@ -119,10 +114,15 @@ var (
 	exitAfterParsing   bool
 	measureFrameAllocs bool
 	invalidateFrames   bool
+	captureDuration    time.Duration
+	captureSave        string
+	binaryPath         string
+	disableTraceCache  bool
+	repairTimestamps   bool
 )
 
 func (mwin *MainWindow) openGoroutine(g *ptrace.Goroutine) {
-	gi := NewGoroutineInfo(mwin.trace, mwin.twin, &mwin.canvas, g, mwin.canvas.timelines)
+	gi := NewGoroutineInfo(mwin.trace, mwin.twin, &mwin.canvas, g, mwin.canvas.allTimelines())
 	mwin.openPanel(gi)
 }
 
@@ -131,6 +131,21 @@ func (mwin *MainWindow) openFunction(fn *ptrace.Function) {
 	mwin.openPanel(fi)
 }
 
+// openTask focuses the canvas on the goroutines that worked on the task and opens the task's panel, which highlights the
+// task's regions.
+func (mwin *MainWindow) openTask(gtx layout.Context, t *ptrace.Task) {
+	act := mwin.trace.TaskActivity(t)
+	tls := make([]*Timeline, 0, len(act.Goroutines))
+	for _, g := range act.Goroutines {
+		tls = append(tls, mwin.canvas.itemToTimeline[g])
+	}
+	mwin.canvas.focusTimelines(tls)
+	if act.End > act.Start {
+		mwin.canvas.navigateToStartAndEnd(gtx, act.Start, act.End, 0)
+	}
+	mwin.openPanel(NewTaskInfo(mwin.trace, mwin.twin, t))
+}
+
 func (mwin *MainWindow) openSpan(s Items[ptrace.Span]) {
 	var labels []string
 	var label string
@@ -145,10 +160,18 @@ func (mwin *MainWindow) openSpan(s Items[ptrace.Span]) {
 	cfg := SpansInfoConfig{
 		Label: label,
 	}
-	si := NewSpansInfo(cfg, mwin.trace, mwin.twin, theme.Immediate[Items[ptrace.Span]](s), mwin.canvas.timelines)
+	si := NewSpansInfo(cfg, mwin.trace, mwin.twin, theme.Immediate[Items[ptrace.Span]](s), mwin.canvas.allTimelines())
 	mwin.openPanel(si)
 }
 
+// A HighlightingPanel is a panel that highlights spans on the canvas while it is the main window's active panel. The
+// highlights are separate from the user's filter and go away when the user goes back from, replaces, or closes the
+// panel.
+type HighlightingPanel interface {
+	theme.Panel
+	Highlight() Filter
+}
+
 func (mwin *MainWindow) openPanel(p theme.Panel) {
 	if mwin.panel != nil {
 		mwin.panelHistory = append(mwin.panelHistory, mwin.panel)
@@ -265,6 +288,7 @@ type MainWindow struct {
 	trace           *Trace
 	explorer        *explorer.Explorer
 	showingExplorer atomic.Bool
+	captureDialog   CaptureDialogState
 
 	cpuProfile *os.File
 
@@ -298,10 +322,11 @@ func NewMainWindow() *MainWindow {
 }
 
 // OpenTrace initiates loading of a trace. It changes the state to loadingTrace, loads the trace, and notifies the
-// window when it's done. OpenTrace should be called from a different goroutine than the render loop.
-func (mwin *MainWindow) OpenTrace(r io.Reader) {
+// window when it's done. OpenTrace should be called from a different goroutine than the render loop. Multiple traces
+// are concatenated, see loadTrace.
+func (mwin *MainWindow) OpenTrace(rs ...io.Reader) {
 	mwin.SetState("loadingTrace")
-	res, err := loadTrace(r, mwin, &mwin.canvas)
+	res, err := loadTrace(rs, mwin, &mwin.canvas)
 	if memprofileLoad != "" {
 		writeMemprofile(memprofileLoad)
 	}
@@ -564,7 +589,7 @@ func (mwin *MainWindow) Run() error {
 						switch s {
 						case theme.Shortcut{Name: "G"}:
 							pl := &theme.CommandPalette{Prompt: "Scroll to timeline"}
-							pl.Set(GotoTimelineCommandProvider{mwin.twin, mwin.canvas.timelines})
+							pl.Set(GotoTimelineCommandProvider{mwin.twin, mwin.canvas.allTimelines()})
 							win.SetModal(pl.Layout)
 
 						case theme.Shortcut{Name: "H"}:
@@ -586,6 +611,11 @@ func (mwin *MainWindow) Run() error {
 						}
 					}
 
+					mwin.canvas.timeline.panelFilter = Filter{}
+					if p, ok := mwin.panel.(HighlightingPanel); ok {
+						mwin.canvas.timeline.panelFilter = p.Highlight()
+					}
+
 					mwin.debugWindow.cvStart.addValue(gtx.Now, float64(mwin.canvas.start))
 					mwin.debugWindow.cvEnd.addValue(gtx.Now, float64(mwin.canvas.End()))
 					mwin.debugWindow.cvY.addValue(gtx.Now, float64(mwin.canvas.y))
@@ -707,6 +737,60 @@ func (mwin *MainWindow) defaultCommands() theme.CommandProvider {
 				return &OpenFlameGraphAction{}
 			}},
 
+		theme.NormalCommand{
+			Category:     "Analysis",
+			PrimaryLabel: "Open wake-up graph",
+			Aliases:      []string{"unblock", "dependencies"},
+			Color:        colorAnalysis,
+			Fn: func() theme.Action {
+				return &OpenPanelAction{Panel: NewWakeupGraphPanel(mwin.twin, mwin.trace)}
+			}},
+
+		theme.NormalCommand{
+			Category:     "Analysis",
+			PrimaryLabel: "Open scheduling latency analysis",
+			Aliases:      []string{"runnable", "ready", "scheduler", "latency"},
+			Color:        colorAnalysis,
+			Fn: func() theme.Action {
+				return &OpenPanelAction{Panel: NewSchedulingLatencyPanel(mwin.trace, mwin.twin, &mwin.canvas)}
+			}},
+
+		theme.NormalCommand{
+			Category:     "Analysis",
+			PrimaryLabel: "Open syscall analysis",
+			Aliases:      []string{"syscalls", "system calls"},
+			Color:        colorAnalysis,
+			Fn: func() theme.Action {
+				return &OpenPanelAction{Panel: NewSyscallsPanel(mwin.trace, mwin.twin, &mwin.canvas)}
+			}},
+
+		theme.NormalCommand{
+			Category:     "Analysis",
+			PrimaryLabel: "Open contention profile",
+			Aliases:      []string{"mutex", "lock", "sync"},
+			Color:        colorAnalysis,
+			Fn: func() theme.Action {
+				return &OpenPanelAction{Panel: NewContentionPanel(mwin.trace, mwin.twin)}
+			}},
+
+		theme.NormalCommand{
+			Category:     "Analysis",
+			PrimaryLabel: "Open channel analysis",
+			Aliases:      []string{"chan", "send", "receive", "communication"},
+			Color:        colorAnalysis,
+			Fn: func() theme.Action {
+				return &OpenPanelAction{Panel: NewChannelsPanel(mwin.trace, mwin.twin)}
+			}},
+
+		theme.NormalCommand{
+			Category:     "Analysis",
+			PrimaryLabel: "Open GC cycles",
+			Aliases:      []string{"garbage collection", "stw", "heap"},
+			Color:        colorAnalysis,
+			Fn: func() theme.Action {
+				return &OpenPanelAction{Panel: NewGCPanel(mwin.trace, mwin.twin, &mwin.canvas)}
+			}},
+
 		theme.NormalCommand{
 			Category:     "General",
 			PrimaryLabel: "Open trace",
@@ -715,6 +799,15 @@ func (mwin *MainWindow) defaultCommands() theme.CommandProvider {
 				return &OpenFileOpenAction{}
 			}},
 
+		theme.NormalCommand{
+			Category:     "General",
+			PrimaryLabel: "Open trace from URL…",
+			Aliases:      []string{"capture", "pprof"},
+			Color:        colorGeneral,
+			Fn: func() theme.Action {
+				return &OpenCaptureDialogAction{}
+			}},
+
 		theme.NormalCommand{
 			Category:     "General",
 			PrimaryLabel: "Quit",
@@ -724,6 +817,65 @@ func (mwin *MainWindow) defaultCommands() theme.CommandProvider {
 			}},
 	}
 
+	if mwin.trace != nil {
+		cmds = append(cmds, theme.NormalCommand{
+			Category:     "General",
+			PrimaryLabel: "Save visible time range as trace…",
+			Aliases:      []string{"export", "cut", "selection"},
+			Color:        colorGeneral,
+			Fn: func() theme.Action {
+				return &SaveTraceSelectionAction{Start: mwin.canvas.start, End: mwin.canvas.End()}
+			},
+		})
+	}
+
+	if mwin.trace != nil && len(mwin.trace.Diagnostics) > 0 {
+		cmds = append(cmds, theme.NormalCommand{
+			Category:     "General",
+			PrimaryLabel: "Show trace problems",
+			Aliases:      []string{"warnings", "errors", "diagnostics"},
+			Color:        colorGeneral,
+			Fn: func() theme.Action {
+				return &OpenPanelAction{Panel: NewDiagnosticsPanel(mwin.twin, mwin.trace.Diagnostics)}
+			},
+		})
+	}
+
+	if mwin.trace != nil && len(mwin.trace.Tasks) > 0 {
+		cmds = append(cmds, theme.NormalCommand{
+			Category:     "Analysis",
+			PrimaryLabel: "Open task list",
+			Aliases:      []string{"tasks"},
+			Color:        colorAnalysis,
+			Fn: func() theme.Action {
+				return &OpenPanelAction{Panel: NewTasksPanel(mwin.trace, mwin.twin)}
+			},
+		})
+	}
+
+	if mwin.trace != nil && len(mwin.trace.Logs) > 0 {
+		cmds = append(cmds, theme.NormalCommand{
+			Category:     "Analysis",
+			PrimaryLabel: "Open log browser",
+			Aliases:      []string{"logs", "user logs"},
+			Color:        colorAnalysis,
+			Fn: func() theme.Action {
+				return &OpenPanelAction{Panel: NewLogBrowser(mwin.trace, mwin.twin)}
+			},
+		})
+	}
+
+	if mwin.canvas.focus.all != nil {
+		cmds = append(cmds, theme.NormalCommand{
+			Category:     "Display",
+			PrimaryLabel: "Show all timelines",
+			Color:        colorDisplay,
+			Fn: func() theme.Action {
+				return &CanvasShowAllTimelinesAction{}
+			},
+		})
+	}
+
 	if mwin.canvas.timeline.displayStackTracks {
 		cmds = append(cmds, theme.NormalCommand{
 			Category:     "Display",
@@ -872,6 +1024,42 @@ func (mwin *MainWindow) showFileOpenDialog() {
 	}
 }
 
+// showFileSaveDialog lets the user choose a file to save the events between start and end to, as a new trace.
+func (mwin *MainWindow) showFileSaveDialog(start, end trace.Timestamp) {
+	if mwin.showingExplorer.CompareAndSwap(false, true) {
+		tr := &mwin.trace.Trace.Trace
+		go func() {
+			notify := func(msg string) {
+				mwin.twin.EmitAction(theme.ExecuteAction(func(gtx layout.Context) {
+					mwin.twin.ShowNotification(gtx, msg)
+				}))
+			}
+
+			wc, err := mwin.explorer.CreateFile("selection.trace")
+			mwin.showingExplorer.Store(false)
+			if err != nil {
+				switch err {
+				case explorer.ErrUserDecline:
+				case explorer.ErrNotAvailable:
+					notify("Saving files isn't supported on this system. Use 'gotraceui crop' instead.")
+				default:
+					notify(fmt.Sprintf("Couldn't save trace: %s", err))
+				}
+				return
+			}
+			err = trace.Crop(wc, tr, start, end, nil)
+			if cerr := wc.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				notify(fmt.Sprintf("Couldn't save trace: %s", err))
+			} else {
+				notify("Saved selection as trace")
+			}
+		}()
+	}
+}
+
 func (mwin *MainWindow) loadTraceImpl(res loadTraceResult) {
 	NewCanvasInto(&mwin.canvas, mwin.debugWindow, res.trace)
 	mwin.canvas.start = res.start
@@ -886,6 +1074,10 @@ func (mwin *MainWindow) loadTraceImpl(res loadTraceResult) {
 	mwin.trace = res.trace
 	mwin.panel = nil
 	mwin.panelHistory = nil
+
+	if len(res.trace.Diagnostics) > 0 {
+		mwin.openPanel(NewDiagnosticsPanel(mwin.twin, res.trace.Diagnostics))
+	}
 }
 
 type durationNumberFormat uint8
@@ -1029,22 +1221,46 @@ func formatTimestamp(ts trace.Timestamp) string {
 }
 
 func openTraceFromCmdline(mwin *MainWindow) {
-	f, err := os.Open(flag.Args()[0])
-	if err != nil {
-		mwin.SetError(fmt.Errorf("couldn't load trace: %w", err))
+	if addr := flag.Args()[0]; isCaptureURL(addr) {
+		if len(flag.Args()) > 1 {
+			mwin.SetError(errors.New("couldn't load trace: can't combine traces captured from URLs with other traces"))
+			return
+		}
+		mwin.SetState("loadingTrace")
+		go mwin.OpenTraceFromURL(addr, captureDuration, captureSave)
 		return
 	}
+
+	var rs []io.Reader
+	closeAll := func() {
+		for _, r := range rs {
+			r.(*os.File).Close()
+		}
+	}
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			closeAll()
+			mwin.SetError(fmt.Errorf("couldn't load trace: %w", err))
+			return
+		}
+		rs = append(rs, f)
+	}
 	// Set state explicitly so user doesn't see a flash of the start state.
 	mwin.SetState("loadingTrace")
 	go func() {
-		defer f.Close()
-		mwin.OpenTrace(f)
+		defer closeAll()
+		mwin.OpenTrace(rs...)
 	}()
 }
 
 func usage(name string, fs *flag.FlagSet) func() {
 	return func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [trace file]\n", name)
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [trace files or URL of net/http/pprof endpoint]\n", name)
+		fmt.Fprintf(os.Stderr, "       %s crop [flags] <input trace> <output trace>\n", name)
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Multiple trace files of the same process, such as traces collected by repeatedly starting and")
+		fmt.Fprintln(os.Stderr, "stopping tracing, are concatenated into a single trace.")
 
 		fmt.Fprintln(os.Stderr)
 		fmt.Fprintln(os.Stderr, "Flags:")
@@ -1109,6 +1325,14 @@ func isZeroValue(f *flag.Flag, value string) bool {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "crop" {
+		if err := cropMain(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "gotraceui crop:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	flag.Usage = usage("gotraceui", flag.CommandLine)
 	flag.BoolVar(&softDebug, "debug", debug, "Enable basic debug functionality")
 	flag.StringVar(&cpuprofile, "debug.cpuprofile", "", "write CPU profile to this file")
@@ -1120,6 +1344,15 @@ func main() {
 	flag.BoolVar(&exitAfterParsing, "debug.exit-after-parsing", false, "Exit after parsing trace")
 	flag.BoolVar(&measureFrameAllocs, "debug.measure-frame-allocs", false, "Measure the number of allocations per frame")
 	flag.BoolVar(&invalidateFrames, "debug.invalidate-frames", false, "Invalidate frame after drawing it")
+	flag.DurationVar(&captureDuration, "capture.duration", defaultCaptureDuration, "Duration of traces captured from net/http/pprof endpoints")
+	flag.StringVar(&captureSave, "capture.save", "", "Save traces captured from net/http/pprof endpoints to this file")
+	flag.StringVar(&binaryPath, "binary", "", "Resolve missing symbols of stack frames using this binary of the traced program")
+	flag.BoolVar(&disableTraceCache, "cache.disable", false, "Don't cache processed traces on disk")
+	flag.BoolVar(&repairTimestamps, "repair-timestamps", false, "Repair out-of-order timestamps instead of clamping them")
+	flag.Func("rules", "Load rules for refining span states from this file (can be repeated)", func(path string) error {
+		ruleFiles = append(ruleFiles, path)
+		return nil
+	})
 	fv := flag.Bool("version", false, "Print version and exit")
 	fdv := flag.Bool("debug.version", false, "Print extended version information and exit")
 	flag.Parse()
@@ -1132,6 +1365,11 @@ func main() {
 		return
 	}
 
+	if err := loadRules(); err != nil {
+		fmt.Fprintln(os.Stderr, "couldn't load rules:", err)
+		os.Exit(1)
+	}
+
 	go func() {
 		if cpuprofile != "" {
 			f, err := os.Create(cpuprofile)
@@ -1206,7 +1444,48 @@ type progresser interface {
 	SetProgress(p float64)
 }
 
-func loadTrace(f io.Reader, p progresser, cv *Canvas) (loadTraceResult, error) {
+// symbolize resolves the missing symbols of the trace's stack frames using the binary at path.
+func symbolize(t *trace.Trace, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := t.Symbolize(f); err != nil {
+		return fmt.Errorf("couldn't symbolize trace using %s: %w", path, err)
+	}
+	return nil
+}
+
+// parseTrace decompresses and parses a single trace and resolves missing symbols if requested. Failing to resolve
+// symbols is reported as a diagnostic of the trace.
+func parseTrace(r io.Reader, progress func(float64)) (trace.Trace, error) {
+	r, done, err := decompress(r)
+	if err != nil {
+		return trace.Trace{}, err
+	}
+	defer done()
+	// Parse leniently so that traces of processes that died while tracing can still be looked at. Any problems are
+	// displayed once the trace has loaded.
+	t, err := trace.ParseWithOptions(r, progress, trace.ParseOptions{Lenient: true, RepairTimestamps: repairTimestamps})
+	if err != nil {
+		return trace.Trace{}, err
+	}
+	if exitAfterParsing {
+		return trace.Trace{}, errExitAfterParsing
+	}
+	if binaryPath != "" {
+		if err := symbolize(&t, binaryPath); err != nil {
+			// The trace is still usable with whatever symbols it has.
+			t.Diagnostics = append(t.Diagnostics, trace.Diagnostic{Offset: -1, Reason: err.Error()})
+		}
+	}
+	return t, nil
+}
+
+// loadTrace loads and processes one or more traces. Multiple traces must have been recorded one after the other by the
+// same process, and are concatenated into a single trace.
+func loadTrace(rs []io.Reader, p progresser, cv *Canvas) (loadTraceResult, error) {
 	names := []string{
 		"Parsing trace",
 		"Parsing trace",
@@ -1218,34 +1497,80 @@ func loadTrace(f io.Reader, p progresser, cv *Canvas) (loadTraceResult, error) {
 		"Processing",
 	}
 
-	p.SetProgressStages(names)
-
-	p.SetProgressStage(0)
-	t, err := trace.Parse(f, p.SetProgress)
-	if err != nil {
-		return loadTraceResult{}, err
-	}
-	if exitAfterParsing {
-		return loadTraceResult{}, errExitAfterParsing
+	// Processed traces are cached on disk, so that opening the same trace again doesn't have to parse and process it
+	// again.
+	var cacheKey string
+	var pt *ptrace.Trace
+	if !disableTraceCache && !exitAfterParsing {
+		key, ok, err := traceCacheKey(rs)
+		if err != nil {
+			return loadTraceResult{}, err
+		}
+		if ok {
+			cacheKey = key
+			names[0] = "Loading cached trace"
+			p.SetProgressStages(names)
+			p.SetProgressStage(0)
+			pt, err = readTraceCache(cacheKey, p.SetProgress)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "couldn't load cached trace:", err)
+			}
+		}
 	}
 
-	p.SetProgressStage(1)
-	pt, err := ptrace.Parse(t, p.SetProgress)
-	if err != nil {
-		return loadTraceResult{}, err
+	if pt == nil {
+		names[0] = "Parsing trace"
+		p.SetProgressStages(names)
+
+		parts := make([]*ptrace.Trace, len(rs))
+		for i, r := range rs {
+			progress := func(f float64) { p.SetProgress((float64(i) + f) / float64(len(rs))) }
+
+			p.SetProgressStage(0)
+			t, err := parseTrace(r, progress)
+			if err != nil {
+				if len(rs) > 1 && err != errExitAfterParsing {
+					err = fmt.Errorf("trace %d: %w", i+1, err)
+				}
+				return loadTraceResult{}, err
+			}
+			if len(rs) > 1 {
+				for j := range t.Diagnostics {
+					t.Diagnostics[j].Reason = fmt.Sprintf("trace %d: %s", i+1, t.Diagnostics[j].Reason)
+				}
+			}
+
+			p.SetProgressStage(1)
+			parts[i], err = ptrace.Parse(t, progress)
+			if err != nil {
+				return loadTraceResult{}, err
+			}
+		}
+		var err error
+		pt, err = ptrace.Concat(parts)
+		if err != nil {
+			return loadTraceResult{}, err
+		}
+
+		if cacheKey != "" {
+			if err := writeTraceCache(cacheKey, pt); err != nil {
+				fmt.Fprintln(os.Stderr, "couldn't cache trace:", err)
+			}
+		}
 	}
 
 	p.SetProgressStage(2)
 	// Assign GC tag to all GC spans so we can later determine their span colors cheaply.
+	gcTags := ptrace.MakeSpanTags(ptrace.SpanTagGC)
 	for i, proc := range pt.Processors {
 		for j := 0; j < len(proc.Spans); j++ {
-			fn := pt.G(pt.Events[proc.Spans[j].Event].G).Function
+			fn := pt.G(pt.Event(proc.Spans[j].Event()).G).Function
 			if fn == nil {
 				continue
 			}
 			switch fn.Fn {
 			case "runtime.bgscavenge", "runtime.bgsweep", "runtime.gcBgMarkWorker":
-				proc.Spans[j].Tags |= ptrace.SpanTagGC
+				proc.Spans[j].Tags = proc.Spans[j].Tags.Union(gcTags)
 			}
 		}
 		p.SetProgress(float64(i+1) / float64(len(pt.Processors)))
@@ -1294,11 +1619,9 @@ func loadTrace(f io.Reader, p progresser, cv *Canvas) (loadTraceResult, error) {
 	var timelines []*Timeline
 
 	p.SetProgressStage(5)
-	if supportMachineTimelines {
-		for i, m := range tr.Machines {
-			timelines = append(timelines, NewMachineTimeline(tr, cv, m))
-			p.SetProgress(float64(i+1) / float64(len(tr.Machines)))
-		}
+	for i, m := range tr.Machines {
+		timelines = append(timelines, NewMachineTimeline(tr, cv, m))
+		p.SetProgress(float64(i+1) / float64(len(tr.Machines)))
 	}
 
 	p.SetProgressStage(6)
@@ -1320,7 +1643,7 @@ func loadTrace(f io.Reader, p progresser, cv *Canvas) (loadTraceResult, error) {
 		return nil
 	})
 
-	end := tr.Events[len(tr.Events)-1].Ts
+	end := tr.Events.Last().Ts
 
 	// Zoom out slightly beyond the end of the trace, so that the user can immediately tell that they're looking at the
 	// entire trace.
@@ -1584,6 +1907,10 @@ func (cmd ScrollToTimelineCommand) Layout(win *theme.Window, gtx layout.Context,
 		numSpans = len(item.Spans)
 		start = item.Spans[0].Start
 		end = item.Spans[len(item.Spans)-1].End
+	case *ptrace.Machine:
+		numSpans = len(item.Spans)
+		start = item.Spans[0].Start
+		end = item.Spans[len(item.Spans)-1].End
 	default:
 		panic(fmt.Sprintf("%T", item))
 	}
@@ -1636,6 +1963,24 @@ func (cmd ScrollToTimelineCommand) Filter(input string) bool {
 					}
 				}
 			}
+			if strings.HasPrefix(f, "m") {
+				if f == "m:" {
+					if _, ok := cmd.Timeline.item.(*ptrace.Machine); ok {
+						return true
+					}
+				} else {
+					id := strings.ReplaceAll(f[len("m"):], ",", "")
+					if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+						if m, ok := cmd.Timeline.item.(*ptrace.Machine); ok {
+							if n <= math.MaxInt32 {
+								if m.ID == int32(n) {
+									return true
+								}
+							}
+						}
+					}
+				}
+			}
 
 			// OPT(dh): don't repeatedly lowercase the label
 			if strings.Contains(strings.ToLower(cmd.Timeline.label), strings.ToLower(f)) {
@@ -340,7 +340,7 @@ func (pl *Plot) end(gtx layout.Context, cv *Canvas) int {
 	// XXX this can probably overflow
 
 	timelineEnd := gtx.Constraints.Max.X
-	lastEvent := cv.trace.Events[len(cv.trace.Events)-1]
+	lastEvent := cv.trace.Events.Last()
 	if end := cv.tsToPx(lastEvent.Ts); int(end) < timelineEnd {
 		timelineEnd = int(end)
 	}
@@ -24,14 +24,14 @@ func (tt ProcessorTooltip) Layout(win *theme.Window, gtx layout.Context) layout.
 	// OPT(dh): compute statistics once, not on every frame
 
 	tr := tt.trace
-	d := time.Duration(tr.Events[len(tr.Events)-1].Ts)
+	d := time.Duration(tr.Events.Last().Ts)
 
 	var userD, gcD time.Duration
 	for i := range tt.p.Spans {
 		s := &tt.p.Spans[i]
 		d := s.Duration()
 
-		ev := tr.Events[s.Event]
+		ev := tr.Event(s.Event())
 		switch ev.Type {
 		case trace.EvGoStart:
 			userD += d
@@ -67,7 +67,7 @@ func processorTrackSpanTooltip(win *theme.Window, gtx layout.Context, tr *Trace,
 	var label string
 	if state.spans.Len() == 1 {
 		s := state.spans.At(0)
-		ev := tr.Event(s.Event)
+		ev := tr.Event(s.Event())
 		if s.State != ptrace.StateRunningG {
 			panic(fmt.Sprintf("unexpected state %d", s.State))
 		}
@@ -106,7 +106,7 @@ func processorInvalidateCache(tl *Timeline, cv *Canvas) bool {
 	}
 
 	// If we got to this point, then both slices have exactly one element.
-	if cv.trace.Event(cv.prevFrame.hoveredSpans.At(0).Event).G != cv.trace.Event(cv.timeline.hoveredSpans.At(0).Event).G {
+	if cv.trace.Event(cv.prevFrame.hoveredSpans.At(0).Event()).G != cv.trace.Event(cv.timeline.hoveredSpans.At(0).Event()).G {
 		return true
 	}
 
@@ -117,14 +117,14 @@ func processorTrackSpanLabel(spans Items[ptrace.Span], tr *Trace, out []string)
 	if spans.Len() != 1 {
 		return out
 	}
-	g := tr.G(tr.Event(spans.At(0).Event).G)
+	g := tr.G(tr.Event(spans.At(0).Event()).G)
 	labels := tr.goroutineSpanLabels(g)
 	return append(out, labels...)
 }
 
 func processorTrackSpanColor(spans Items[ptrace.Span], tr *Trace) (out [2]colorIndex) {
 	do := func(s ptrace.Span, tr *Trace) colorIndex {
-		if s.Tags&ptrace.SpanTagGC != 0 {
+		if s.Tags.Has(ptrace.SpanTagGC) {
 			return colorStateGC
 		} else {
 			// TODO(dh): support goroutines that are currently doing GC assist work. this would require splitting spans, however.
@@ -202,10 +202,10 @@ func processorTrackSpanColor(spans Items[ptrace.Span], tr *Trace) (out [2]colorI
 
 func processorTrackSpanContextMenu(spans Items[ptrace.Span], cv *Canvas) []*theme.MenuItem {
 	var items []*theme.MenuItem
-	items = append(items, newZoomMenuItem(cv, spans))
+	items = append(items, newZoomMenuItem(cv, spans), newSaveTraceMenuItem(spans))
 
 	if spans.Len() == 1 {
-		gid := cv.trace.Event((spans.At(0).Event)).G
+		gid := cv.trace.Event((spans.At(0).Event())).G
 		items = append(items, &theme.MenuItem{
 			Label: PlainLabel(local.Sprintf("Scroll to goroutine %d", gid)),
 			Action: func() theme.Action {
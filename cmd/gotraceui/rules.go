@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+// ruleFiles are the rule files specified on the command line, in addition to the ones in the rules directory.
+var ruleFiles []string
+
+// loadedRules are the contents of all loaded rule files, which affect how traces are processed.
+var loadedRules [][]byte
+
+// rulesDir returns the directory that rule files are automatically loaded from.
+func rulesDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gotraceui", "rules"), nil
+}
+
+// loadRules loads the rule files in the rules directory, in lexical order, followed by the rule files specified on the
+// command line. See ptrace.LoadRules for the syntax of rules and tag definitions.
+func loadRules() error {
+	var paths []string
+	if dir, err := rulesDir(); err == nil {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.rules"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(matches)
+		paths = append(paths, matches...)
+	}
+	paths = append(paths, ruleFiles...)
+
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := ptrace.LoadRules(path, src); err != nil {
+			return err
+		}
+		loadedRules = append(loadedRules, src)
+	}
+	assignTagColors()
+	return nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"image"
+	rtrace "runtime/trace"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/mem"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/text"
+)
+
+// latencyColumns are the columns used by tables that display ptrace.LatencyPercentiles.
+var latencyColumns = []theme.TableListColumn{
+	{
+		Name: "Count",
+		// XXX the width depends on the font and scaling
+		MinWidth: 100,
+		MaxWidth: 100,
+	},
+
+	{
+		Name: "Total",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "p50",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "p90",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "p99",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "p99.9",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Max",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+}
+
+func latencyValue(p *ptrace.LatencyPercentiles, col int) time.Duration {
+	switch col {
+	case 1:
+		return p.Total
+	case 2:
+		return p.P50
+	case 3:
+		return p.P90
+	case 4:
+		return p.P99
+	case 5:
+		return p.P999
+	case 6:
+		return p.Max
+	default:
+		panic("unreachable")
+	}
+}
+
+// layoutLatencyCell fills in the cell of one of latencyColumns.
+func layoutLatencyCell(tb *TextBuilder, txt *Text, p *ptrace.LatencyPercentiles, col int) {
+	txt.Alignment = text.End
+	if col == 0 {
+		tb.Span(local.Sprintf("%d", p.Count))
+		return
+	}
+	if p.Count == 0 {
+		return
+	}
+	durationCell(tb, txt, latencyValue(p, col))
+}
+
+func compareLatencies(a, b *ptrace.LatencyPercentiles, col int) int {
+	if col == 0 {
+		return cmp.Compare(a.Count, b.Count)
+	}
+	return cmp.Compare(latencyValue(a, col), latencyValue(b, col))
+}
+
+func buildLatencyDescription(tb *TextBuilder, p *ptrace.LatencyPercentiles) []DescriptionAttribute {
+	attrs := []DescriptionAttribute{
+		{Key: "Count", Value: *(tb.Span(local.Sprintf("%d", p.Count)))},
+	}
+	for i, col := range latencyColumns[1:] {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   col.Name,
+			Value: *(tb.Span(roundDuration(latencyValue(p, i+1)).String())),
+		})
+	}
+	return attrs
+}
+
+// The number of waits displayed by the "Worst waits" tab
+const numWorstWaits = 100
+
+// The number of time buckets scheduling latencies are grouped into
+const numSchedulingLatencyBuckets = 50
+
+// SchedulingLatencyPanel displays how long goroutines had to wait to be scheduled after becoming runnable.
+type SchedulingLatencyPanel struct {
+	mwin   *theme.Window
+	trace  *Trace
+	canvas *Canvas
+
+	latency *theme.Future[*ptrace.SchedulingLatency]
+	// Indices of ByFunction, in display order
+	fnOrder  []int
+	fnSorted *ptrace.SchedulingLatency
+
+	tabbedState      theme.TabbedState
+	fnList           widget.List
+	fnSort           theme.TableSortState
+	timeList         widget.List
+	waitsList        widget.List
+	hist             InteractiveHistogram
+	initialized      bool
+	descriptionText  Text
+	timestampObjects mem.BucketSlice[trace.Timestamp]
+	cells            TableCells
+
+	theme.PanelButtons
+}
+
+func NewSchedulingLatencyPanel(tr *Trace, mwin *theme.Window, canvas *Canvas) *SchedulingLatencyPanel {
+	sp := &SchedulingLatencyPanel{
+		mwin:   mwin,
+		trace:  tr,
+		canvas: canvas,
+	}
+	sp.fnList.Axis = layout.Vertical
+	sp.timeList.Axis = layout.Vertical
+	sp.waitsList.Axis = layout.Vertical
+	// Sort by p99, descending.
+	sp.fnSort.Column = 5
+	sp.fnSort.Descending = true
+	return sp
+}
+
+func (sp *SchedulingLatencyPanel) Title() string {
+	return "Scheduling latency"
+}
+
+var schedulingLatencyWaitsColumns = []theme.TableListColumn{
+	{
+		Name: "Goroutine",
+		// XXX the width depends on the font and scaling
+		MinWidth: 300,
+		MaxWidth: 300,
+	},
+
+	{
+		Name: "Start time",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Duration",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "State",
+	},
+}
+
+func (sp *SchedulingLatencyPanel) sortFunctions(sl *ptrace.SchedulingLatency) {
+	sp.fnOrder = tableOrder(sp.fnOrder, len(sl.ByFunction))
+	sortTableRows(sp.fnOrder, &sp.fnSort, func(i, j, col int) int {
+		a, b := &sl.ByFunction[i], &sl.ByFunction[j]
+		if col == 0 {
+			return cmp.Compare(a.Function.Fn, b.Function.Fn)
+		}
+		return compareLatencies(&a.LatencyPercentiles, &b.LatencyPercentiles, col-1)
+	})
+	sp.fnSorted = sl
+}
+
+func (sp *SchedulingLatencyPanel) computeHistogram(win *theme.Window, sl *ptrace.SchedulingLatency) {
+	cfg := &sp.hist.Config
+	var ds []time.Duration
+	for _, w := range sl.Waits {
+		d := w.Span.Duration()
+		if fd := widget.FloatDuration(d); fd >= cfg.Start && (cfg.End == 0 || fd <= cfg.End) {
+			ds = append(ds, d)
+		}
+	}
+	sp.hist.Set(win, ds)
+}
+
+func (sp *SchedulingLatencyPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.SchedulingLatencyPanel.Layout").End()
+
+	if sp.latency == nil {
+		bucketSize := max(time.Duration(sp.trace.Events.Last().Ts)/numSchedulingLatencyBuckets, time.Microsecond)
+		sp.latency = theme.NewFuture(win, func(cancelled <-chan struct{}) *ptrace.SchedulingLatency {
+			return sp.trace.SchedulingLatency(bucketSize)
+		})
+	}
+	sl, haveLatency := sp.latency.Result()
+	if haveLatency {
+		if sp.fnSort.Changed() || sp.fnSorted != sl {
+			sp.sortFunctions(sl)
+		}
+		if !sp.initialized {
+			sp.hist.Config = widget.HistogramConfig{RejectOutliers: true, Bins: widget.DefaultHistogramBins}
+			sp.computeHistogram(win, sl)
+			sp.initialized = true
+		}
+	}
+
+	sp.timestampObjects.Reset()
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	layoutFunctions := func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+		cols := append([]theme.TableListColumn{{
+			Name: "Function",
+			// XXX the width depends on the font and scaling
+			MinWidth: 400,
+			MaxWidth: 400,
+		}}, latencyColumns...)
+		return sp.cells.Table(win, gtx, cols, &sp.fnList, &sp.fnSort, len(sp.fnOrder), func(tb *TextBuilder, txt *Text, row, col int) {
+			fl := &sl.ByFunction[sp.fnOrder[row]]
+			if col == 0 {
+				tb.DefaultLink(fl.Function.Fn, "", fl.Function)
+			} else {
+				layoutLatencyCell(tb, txt, &fl.LatencyPercentiles, col-1)
+			}
+		})
+	}
+
+	layoutTime := func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+		cols := append([]theme.TableListColumn{{
+			Name: "Start time",
+			// XXX the width depends on the font and scaling
+			MinWidth: 200,
+			MaxWidth: 200,
+		}}, latencyColumns...)
+		return sp.cells.Table(win, gtx, cols, &sp.timeList, nil, len(sl.ByTime), func(tb *TextBuilder, txt *Text, row, col int) {
+			b := &sl.ByTime[row]
+			if col == 0 {
+				tb.DefaultLink(formatTimestamp(b.Start), "", sp.timestampObjects.Append(b.Start))
+				txt.Alignment = text.End
+			} else {
+				layoutLatencyCell(tb, txt, &b.LatencyPercentiles, col-1)
+			}
+		})
+	}
+
+	layoutWaits := func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+		return sp.cells.Table(win, gtx, schedulingLatencyWaitsColumns, &sp.waitsList, nil, min(len(sl.Waits), numWorstWaits), func(tb *TextBuilder, txt *Text, row, col int) {
+			w := sl.Waits[row]
+			switch col {
+			case 0: // Goroutine
+				tb.DefaultLink(local.Sprintf("goroutine %d: %s", w.Goroutine.ID, w.Goroutine.Function.Fn), "", w.Goroutine)
+			case 1: // Start time
+				tb.Link(formatTimestamp(w.Span.Start), sp.timestampObjects.Append(w.Span.Start), goroutineSpanLink(sp.canvas, w.Goroutine, w.Span))
+				txt.Alignment = text.End
+			case 2: // Duration
+				durationCell(tb, txt, w.Span.Duration())
+			case 3: // State
+				tb.Span(stateNamesCapitalized[w.Span.State])
+			}
+		})
+	}
+
+	tabs := []string{"By function", "Over time", "Histogram", "Worst waits"}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, sp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if !haveLatency {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing scheduling latencies…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			gtx.Constraints.Min = image.Point{}
+			sp.descriptionText.Reset(win.Theme)
+			tb := TextBuilder{Theme: win.Theme}
+			desc := Description{Attributes: buildLatencyDescription(&tb, &sl.Overall)}
+			return desc.Layout(win, gtx, &sp.descriptionText)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !haveLatency {
+				return layout.Dimensions{}
+			}
+			return theme.Tabbed(&sp.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				switch tabs[sp.tabbedState.Current] {
+				case "By function":
+					return layoutFunctions(win, gtx)
+				case "Over time":
+					return layoutTime(win, gtx)
+				case "Histogram":
+					return sp.hist.Layout(win, gtx)
+				case "Worst waits":
+					return layoutWaits(win, gtx)
+				default:
+					panic("unreachable")
+				}
+			})
+		}),
+	)
+
+	sp.cells.Finish(win)
+	if haveLatency && sp.hist.Changed() {
+		sp.computeHistogram(win, sl)
+	}
+	for sp.PanelButtons.Backed() {
+		sp.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
@@ -135,7 +135,7 @@ func (si *SpansInfo) init(win *theme.Window) {
 	}
 
 	if si.cfg.Stacktrace == "" && haveContainer && spans.Len() == 1 {
-		ev := si.trace.Events[spans.At(0).Event]
+		ev := si.trace.Event(spans.At(0).Event())
 		stk := si.trace.Stacks[ev.StkID]
 		sb := strings.Builder{}
 		for _, f := range stk {
@@ -559,7 +559,7 @@ func (si *SpansInfo) Layout(win *theme.Window, gtx layout.Context) layout.Dimens
 		si.mwin.EmitAction(PrevPanelAction{})
 	}
 	for si.buttons.selectUserRegion.Clicked() {
-		needle := si.trace.Strings[si.trace.Event(spans.At(0).Event).Args[2]]
+		needle := si.trace.Strings[si.trace.Event(spans.At(0).Event()).Args[2]]
 		ft := theme.NewFuture[Items[ptrace.Span]](win, func(cancelled <-chan struct{}) Items[ptrace.Span] {
 			var bases []Items[ptrace.Span]
 			for _, tl := range si.allTimelines {
@@ -573,7 +573,7 @@ func (si *SpansInfo) Layout(win *theme.Window, gtx layout.Context) layout.Dimens
 						continue
 					}
 					filtered := FilterItems(track.Spans(win).Wait(), func(span *ptrace.Span) bool {
-						label := si.trace.Strings[si.trace.Event(span.Event).Args[2]]
+						label := si.trace.Strings[si.trace.Event(span.Event()).Args[2]]
 						return label == needle
 					})
 
@@ -614,26 +614,10 @@ func spanTagStrings(tags ptrace.SpanTags) []string {
 	}
 
 	out := make([]string, 0, 4)
-	if tags&ptrace.SpanTagRead != 0 {
-		out = append(out, "read")
-	}
-	if tags&ptrace.SpanTagAccept != 0 {
-		out = append(out, "accept")
-	}
-	if tags&ptrace.SpanTagDial != 0 {
-		out = append(out, "dial")
-	}
-	if tags&ptrace.SpanTagNetwork != 0 {
-		out = append(out, "network")
-	}
-	if tags&ptrace.SpanTagTCP != 0 {
-		out = append(out, "TCP")
-	}
-	if tags&ptrace.SpanTagTLS != 0 {
-		out = append(out, "TLS")
-	}
-	if tags&ptrace.SpanTagHTTP != 0 {
-		out = append(out, "HTTP")
+	for _, tag := range tags.Tags() {
+		if label := tag.Definition().Label; label != "" {
+			out = append(out, label)
+		}
 	}
 	return out
 }
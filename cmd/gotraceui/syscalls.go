@@ -0,0 +1,402 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"image"
+	rtrace "runtime/trace"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/mem"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/text"
+)
+
+// The number of syscalls displayed by the "Slowest calls" tab
+const numSlowestSyscalls = 100
+
+// SyscallGroupList displays a sortable table of syscall groups.
+type SyscallGroupList struct {
+	// The name of the first column
+	Kind string
+
+	groups []ptrace.SyscallGroup
+	// Indices of groups, in display order
+	order []int
+	dirty bool
+
+	list  widget.List
+	sort  theme.TableSortState
+	cells TableCells
+}
+
+func (sl *SyscallGroupList) SetGroups(groups []ptrace.SyscallGroup) {
+	sl.groups = groups
+	sl.dirty = true
+}
+
+func syscallMean(grp *ptrace.SyscallGroup) time.Duration {
+	if grp.Blocking.Count == 0 {
+		return 0
+	}
+	return grp.Blocking.Total / time.Duration(grp.Blocking.Count)
+}
+
+func (sl *SyscallGroupList) sortGroups() {
+	sl.order = tableOrder(sl.order, len(sl.groups))
+	sortTableRows(sl.order, &sl.sort, func(i, j, col int) int {
+		a, b := &sl.groups[i], &sl.groups[j]
+		switch col {
+		case 0:
+			return cmp.Compare(a.Name, b.Name)
+		case 1:
+			return cmp.Compare(a.Calls, b.Calls)
+		case 2:
+			return cmp.Compare(a.Blocking.Count, b.Blocking.Count)
+		case 3:
+			return cmp.Compare(a.Blocking.Total, b.Blocking.Total)
+		case 4:
+			return cmp.Compare(syscallMean(a), syscallMean(b))
+		case 5:
+			return cmp.Compare(a.Blocking.P99, b.Blocking.P99)
+		case 6:
+			return cmp.Compare(a.Blocking.Max, b.Blocking.Max)
+		default:
+			panic("unreachable")
+		}
+	})
+}
+
+func (sl *SyscallGroupList) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.SyscallGroupList.Layout").End()
+
+	sl.list.Axis = layout.Vertical
+	if sl.sort.Changed() || sl.dirty {
+		sl.sortGroups()
+		sl.dirty = false
+	}
+
+	cols := []theme.TableListColumn{
+		{
+			Name: sl.Kind,
+			// XXX the width depends on the font and scaling
+			MinWidth: 400,
+			MaxWidth: 400,
+		},
+
+		{
+			Name: "Calls",
+			// XXX the width depends on the font and scaling
+			MinWidth: 100,
+			MaxWidth: 100,
+		},
+
+		{
+			Name: "P handoffs",
+			// XXX the width depends on the font and scaling
+			MinWidth: 100,
+			MaxWidth: 100,
+		},
+
+		{
+			Name: "Total",
+			// XXX the width depends on the font and scaling
+			MinWidth: 120,
+			MaxWidth: 120,
+		},
+
+		{
+			Name: "Mean",
+			// XXX the width depends on the font and scaling
+			MinWidth: 120,
+			MaxWidth: 120,
+		},
+
+		{
+			Name: "p99",
+			// XXX the width depends on the font and scaling
+			MinWidth: 120,
+			MaxWidth: 120,
+		},
+
+		{
+			Name: "Max",
+			// XXX the width depends on the font and scaling
+			MinWidth: 120,
+			MaxWidth: 120,
+		},
+	}
+
+	cellFn := func(tb *TextBuilder, txt *Text, row, col int) {
+		duration := func(d time.Duration) {
+			txt.Alignment = text.End
+			if d != 0 {
+				durationCell(tb, txt, d)
+			}
+		}
+
+		grp := &sl.groups[sl.order[row]]
+		switch col {
+		case 0: // Name
+			if grp.Name == "" {
+				tb.Span("unknown")
+			} else {
+				tb.Span(grp.Name)
+			}
+		case 1: // Calls
+			tb.Span(local.Sprintf("%d", grp.Calls))
+			txt.Alignment = text.End
+		case 2: // P handoffs
+			tb.Span(local.Sprintf("%d", grp.Blocking.Count))
+			txt.Alignment = text.End
+		case 3: // Total
+			duration(grp.Blocking.Total)
+		case 4: // Mean
+			duration(syscallMean(grp))
+		case 5: // p99
+			duration(grp.Blocking.P99)
+		case 6: // Max
+			duration(grp.Blocking.Max)
+		}
+	}
+
+	dims := sl.cells.Table(win, gtx, cols, &sl.list, &sl.sort, len(sl.order), cellFn)
+	sl.cells.Truncate()
+	return dims
+}
+
+// SyscallsPanel displays all syscalls in the trace, grouped by syscall and by calling function. The trace only records
+// the durations of syscalls that blocked and caused their P to be handed off.
+type SyscallsPanel struct {
+	mwin   *theme.Window
+	trace  *Trace
+	canvas *Canvas
+
+	analysis *theme.Future[*ptrace.SyscallAnalysis]
+	// The analysis that byName and byCaller display
+	displayed *ptrace.SyscallAnalysis
+
+	tabbedState      theme.TabbedState
+	byName           SyscallGroupList
+	byCaller         SyscallGroupList
+	slowestList      widget.List
+	descriptionText  Text
+	timestampObjects mem.BucketSlice[trace.Timestamp]
+	cells            TableCells
+
+	theme.PanelButtons
+}
+
+func NewSyscallsPanel(tr *Trace, mwin *theme.Window, canvas *Canvas) *SyscallsPanel {
+	sp := &SyscallsPanel{
+		mwin:   mwin,
+		trace:  tr,
+		canvas: canvas,
+	}
+	sp.byName.Kind = "Syscall"
+	sp.byCaller.Kind = "Caller"
+	sp.slowestList.Axis = layout.Vertical
+	// Sort by total time spent in blocking syscalls, descending.
+	for _, sl := range []*SyscallGroupList{&sp.byName, &sp.byCaller} {
+		sl.sort.Column = 3
+		sl.sort.Descending = true
+	}
+	return sp
+}
+
+func (sp *SyscallsPanel) Title() string {
+	return "Syscalls"
+}
+
+var slowestSyscallsColumns = []theme.TableListColumn{
+	{
+		Name: "Goroutine",
+		// XXX the width depends on the font and scaling
+		MinWidth: 150,
+		MaxWidth: 150,
+	},
+
+	{
+		Name: "Syscall",
+		// XXX the width depends on the font and scaling
+		MinWidth: 250,
+		MaxWidth: 250,
+	},
+
+	{
+		Name: "Caller",
+		// XXX the width depends on the font and scaling
+		MinWidth: 300,
+		MaxWidth: 300,
+	},
+
+	{
+		Name: "Start time",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Duration",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "P handoff",
+	},
+}
+
+func (sp *SyscallsPanel) buildDescription(win *theme.Window, sa *ptrace.SyscallAnalysis) Description {
+	tb := TextBuilder{Theme: win.Theme}
+
+	var blocking int
+	var total time.Duration
+	for _, sc := range sa.Syscalls {
+		if sc.Blocking {
+			blocking++
+			total += sc.Duration()
+		}
+	}
+	var pct float64
+	if len(sa.Syscalls) != 0 {
+		pct = float64(blocking) / float64(len(sa.Syscalls)) * 100
+	}
+
+	attrs := []DescriptionAttribute{
+		{
+			Key:   "# of syscalls",
+			Value: *(tb.Span(local.Sprintf("%d", len(sa.Syscalls)))),
+		},
+		{
+			Key:   "# of P handoffs",
+			Value: *(tb.Span(local.Sprintf("%d (%.2f%%)", blocking, pct))),
+		},
+		{
+			Key:   "Time in blocking syscalls",
+			Value: *(tb.Span(roundDuration(total).String())),
+		},
+	}
+	return Description{Attributes: attrs}
+}
+
+func (sp *SyscallsPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.SyscallsPanel.Layout").End()
+
+	if sp.analysis == nil {
+		sp.analysis = theme.NewFuture(win, func(cancelled <-chan struct{}) *ptrace.SyscallAnalysis {
+			return sp.trace.Syscalls()
+		})
+	}
+	sa, haveAnalysis := sp.analysis.Result()
+	if haveAnalysis && sp.displayed != sa {
+		sp.byName.SetGroups(sa.ByName)
+		sp.byCaller.SetGroups(sa.ByCaller)
+		sp.displayed = sa
+	}
+
+	sp.timestampObjects.Reset()
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	cellFn := func(tb *TextBuilder, txt *Text, row, col int) {
+		name := func(s string) {
+			if s == "" {
+				tb.Span("unknown")
+			} else {
+				tb.Span(s)
+			}
+		}
+
+		sc := &sa.Syscalls[row]
+		switch col {
+		case 0: // Goroutine
+			tb.DefaultLink(local.Sprintf("goroutine %d", sc.Goroutine.ID), "", sc.Goroutine)
+		case 1: // Syscall
+			name(sc.Name)
+		case 2: // Caller
+			name(sc.Caller)
+		case 3: // Start time
+			start := sp.trace.Event(sc.Event).Ts
+			var link ObjectLink
+			if sc.Blocking {
+				start = sc.Span.Start
+				link = goroutineSpanLink(sp.canvas, sc.Goroutine, sc.Span)
+			} else {
+				link = &EventObjectLink{Event: sc.Event}
+			}
+			tb.Link(formatTimestamp(start), sp.timestampObjects.Append(start), link)
+			txt.Alignment = text.End
+		case 4: // Duration
+			txt.Alignment = text.End
+			if sc.Blocking {
+				durationCell(tb, txt, sc.Duration())
+			}
+		case 5: // P handoff
+			if sc.Blocking {
+				tb.Span("yes")
+			} else {
+				tb.Span("no")
+			}
+		}
+	}
+
+	tabs := []string{"By syscall", "By caller", "Slowest calls"}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, sp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if !haveAnalysis {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Collecting syscalls…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			gtx.Constraints.Min = image.Point{}
+			sp.descriptionText.Reset(win.Theme)
+			return sp.buildDescription(win, sa).Layout(win, gtx, &sp.descriptionText)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !haveAnalysis {
+				return layout.Dimensions{}
+			}
+			return theme.Tabbed(&sp.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				switch tabs[sp.tabbedState.Current] {
+				case "By syscall":
+					return sp.byName.Layout(win, gtx)
+				case "By caller":
+					return sp.byCaller.Layout(win, gtx)
+				case "Slowest calls":
+					return sp.cells.Table(win, gtx, slowestSyscallsColumns, &sp.slowestList, nil, min(len(sa.Syscalls), numSlowestSyscalls), cellFn)
+				default:
+					panic("unreachable")
+				}
+			})
+		}),
+	)
+
+	sp.cells.Finish(win)
+	for sp.PanelButtons.Backed() {
+		sp.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"image"
+	"sort"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/mem"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/text"
+)
+
+// insetPanel insets a panel's content by 5 pixels on all sides. We can't use layout.Inset because it doesn't decrease
+// the minimum constraint, which we do care about here. The returned stack has to be popped after laying out the panel.
+func insetPanel(gtx *layout.Context) op.TransformStack {
+	gtx.Constraints.Min = gtx.Constraints.Min.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints.Max = gtx.Constraints.Max.Sub(image.Pt(2*5, 2*5))
+	gtx.Constraints = layout.Normalize(gtx.Constraints)
+	return op.Offset(image.Pt(5, 5)).Push(gtx.Ops)
+}
+
+// TableCells lays out the cells of tables as texts. The texts are reused from frame to frame.
+type TableCells struct {
+	texts mem.BucketSlice[Text]
+	used  int
+}
+
+// Cell lays out a single cell, whose text is built by fn.
+func (tc *TableCells) Cell(win *theme.Window, gtx layout.Context, fn func(tb *TextBuilder, txt *Text)) layout.Dimensions {
+	defer clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops).Pop()
+
+	tb := TextBuilder{Theme: win.Theme}
+	var txt *Text
+	if tc.used < tc.texts.Len() {
+		txt = tc.texts.Ptr(tc.used)
+	} else {
+		txt = tc.texts.Append(Text{})
+	}
+	tc.used++
+	txt.Reset(win.Theme)
+
+	fn(&tb, txt)
+
+	dims := txt.Layout(win, gtx, tb.Spans)
+	dims.Size = gtx.Constraints.Constrain(dims.Size)
+	return dims
+}
+
+// Table lays out a table that fills the available space. fn builds the text of the cell in the given row and column.
+// sort may be nil for tables that can't be sorted.
+func (tc *TableCells) Table(
+	win *theme.Window,
+	gtx layout.Context,
+	cols []theme.TableListColumn,
+	list *widget.List,
+	sort *theme.TableSortState,
+	rows int,
+	fn func(tb *TextBuilder, txt *Text, row, col int),
+) layout.Dimensions {
+	tbl := theme.TableListStyle{
+		Columns:       cols,
+		List:          list,
+		ColumnPadding: gtx.Dp(10),
+		Sort:          sort,
+	}
+	gtx.Constraints.Min = gtx.Constraints.Max
+	return tbl.Layout(win, gtx, rows, func(gtx layout.Context, row, col int) layout.Dimensions {
+		return tc.Cell(win, gtx, func(tb *TextBuilder, txt *Text) { fn(tb, txt, row, col) })
+	})
+}
+
+// Truncate drops the texts that weren't used in this frame. It has to be called once per frame, after all cells have
+// been laid out.
+func (tc *TableCells) Truncate() {
+	tc.texts.Truncate(tc.used)
+	tc.used = 0
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last frame.
+func (tc *TableCells) Clicked() []TextEvent {
+	// This only allocates when links have been clicked, which is a very low frequency event.
+	var out []TextEvent
+	for i := 0; i < tc.texts.Len(); i++ {
+		out = append(out, tc.texts.Ptr(i).Events()...)
+	}
+	return out
+}
+
+// Finish calls Truncate and handles clicks on links. Panels call it once per frame, after laying out their tables.
+func (tc *TableCells) Finish(win *theme.Window) {
+	tc.Truncate()
+	for i := 0; i < tc.texts.Len(); i++ {
+		for _, ev := range tc.texts.Ptr(i).Events() {
+			handleLinkClick(win, ev)
+		}
+	}
+}
+
+// durationCell fills a cell with a right-aligned duration.
+func durationCell(tb *TextBuilder, txt *Text, d time.Duration) {
+	value, unit := durationNumberFormatSITable.format(d)
+	tb.Span(value)
+	tb.Span(" ")
+	s := tb.Span(unit)
+	s.Font.Typeface = "Go Mono"
+	txt.Alignment = text.End
+}
+
+// tableOrder returns the indices 0 through n-1, reusing the memory of order.
+func tableOrder(order []int, n int) []int {
+	order = order[:0]
+	for i := 0; i < n; i++ {
+		order = append(order, i)
+	}
+	return order
+}
+
+// sortTableRows stably sorts rows by the column of a table's sort state. compare compares two rows by their values in
+// the given column.
+func sortTableRows[T any](rows []T, st *theme.TableSortState, compare func(a, b T, col int) int) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		c := compare(rows[i], rows[j], st.Column)
+		if st.Descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
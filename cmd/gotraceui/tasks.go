@@ -0,0 +1,698 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"image"
+	rtrace "runtime/trace"
+	"sort"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/mem"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/text"
+)
+
+var taskListColumns = []theme.TableListColumn{
+	{
+		Name: "Task",
+		// XXX the width depends on the font and scaling
+		MinWidth: 100,
+		MaxWidth: 100,
+	},
+
+	{
+		Name: "Name",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Start time",
+		// XXX the width depends on the font and scaling
+		MinWidth: 200,
+		MaxWidth: 200,
+	},
+
+	{
+		Name: "Duration",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Goroutines",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Parent",
+		// XXX the width depends on the font and scaling
+		MinWidth: 100,
+		MaxWidth: 100,
+	},
+
+	{
+		Name: "Stub",
+	},
+}
+
+// TaskList displays a sortable table of tasks.
+type TaskList struct {
+	trace *Trace
+	tasks []*ptrace.Task
+	// Tasks in display order
+	sorted []*ptrace.Task
+	dirty  bool
+
+	list widget.List
+	sort theme.TableSortState
+
+	timestampObjects mem.BucketSlice[trace.Timestamp]
+	cells            TableCells
+}
+
+func (tl *TaskList) SetTasks(tr *Trace, tasks []*ptrace.Task) {
+	tl.trace = tr
+	tl.tasks = tasks
+	tl.dirty = true
+}
+
+func (tl *TaskList) sortTasks() {
+	tr := tl.trace
+	tl.sorted = append(tl.sorted[:0], tl.tasks...)
+	sortTableRows(tl.sorted, &tl.sort, func(a, b *ptrace.Task, col int) int {
+		switch col {
+		case 0:
+			return cmp.Compare(a.ID, b.ID)
+		case 1:
+			return cmp.Compare(a.Name, b.Name)
+		case 2:
+			return cmp.Compare(tr.TaskActivity(a).Start, tr.TaskActivity(b).Start)
+		case 3:
+			return cmp.Compare(tr.TaskActivity(a).Duration(), tr.TaskActivity(b).Duration())
+		case 4:
+			return cmp.Compare(len(tr.TaskActivity(a).Goroutines), len(tr.TaskActivity(b).Goroutines))
+		case 5:
+			var pa, pb uint64
+			if a.Parent != nil {
+				pa = a.Parent.ID
+			}
+			if b.Parent != nil {
+				pb = b.Parent.ID
+			}
+			return cmp.Compare(pa, pb)
+		case 6:
+			switch {
+			case a.Stub() == b.Stub():
+				return 0
+			case a.Stub():
+				return 1
+			default:
+				return -1
+			}
+		default:
+			panic("unreachable")
+		}
+	})
+}
+
+func (tl *TaskList) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.TaskList.Layout").End()
+
+	if tl.sort.Changed() || tl.dirty {
+		tl.sortTasks()
+		tl.dirty = false
+	}
+
+	tl.list.Axis = layout.Vertical
+	tl.timestampObjects.Reset()
+
+	cellFn := func(tb *TextBuilder, txt *Text, row, col int) {
+		t := tl.sorted[row]
+		act := tl.trace.TaskActivity(t)
+		switch col {
+		case 0: // ID
+			tb.DefaultLink(local.Sprintf("%d", t.ID), "", t)
+			txt.Alignment = text.End
+		case 1: // Name
+			tb.Link(t.Name, t.Name, &TaskNameObjectLink{Name: t.Name})
+		case 2: // Time
+			tb.DefaultLink(formatTimestamp(act.Start), "", tl.timestampObjects.Append(act.Start))
+			txt.Alignment = text.End
+		case 3: // Duration
+			durationCell(tb, txt, act.Duration())
+		case 4: // Goroutines
+			tb.Span(local.Sprintf("%d", len(act.Goroutines)))
+			txt.Alignment = text.End
+		case 5: // Parent
+			if p := t.Parent; p != nil {
+				tb.DefaultLink(local.Sprintf("%d", p.ID), "", p)
+			}
+			txt.Alignment = text.End
+		case 6: // Stub
+			if t.Stub() {
+				tb.Span("yes")
+			}
+		}
+	}
+
+	dims := tl.cells.Table(win, gtx, taskListColumns, &tl.list, &tl.sort, len(tl.sorted), cellFn)
+	tl.cells.Truncate()
+	return dims
+}
+
+// Clicked returns all objects of text spans that have been clicked since the last call to Layout.
+func (tl *TaskList) Clicked() []TextEvent {
+	return tl.cells.Clicked()
+}
+
+// TasksPanel lists all tasks in the trace.
+type TasksPanel struct {
+	mwin        *theme.Window
+	trace       *Trace
+	tabbedState theme.TabbedState
+	taskList    TaskList
+
+	names     []string
+	namesList widget.List
+	cells     TableCells
+
+	theme.PanelButtons
+}
+
+func NewTasksPanel(tr *Trace, mwin *theme.Window) *TasksPanel {
+	tp := &TasksPanel{
+		mwin:  mwin,
+		trace: tr,
+	}
+	tp.taskList.SetTasks(tr, tr.Tasks)
+	tp.namesList.Axis = layout.Vertical
+
+	seen := map[string]struct{}{}
+	for _, t := range tr.Tasks {
+		if _, ok := seen[t.Name]; !ok {
+			seen[t.Name] = struct{}{}
+			tp.names = append(tp.names, t.Name)
+		}
+	}
+	sort.Strings(tp.names)
+
+	return tp
+}
+
+func (tp *TasksPanel) Title() string {
+	return "Tasks"
+}
+
+func (tp *TasksPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.TasksPanel.Layout").End()
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	tabs := []string{"Tasks", "Names"}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, tp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return theme.Tabbed(&tp.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				switch tabs[tp.tabbedState.Current] {
+				case "Tasks":
+					return tp.taskList.Layout(win, gtx)
+				case "Names":
+					return tp.layoutNames(win, gtx)
+				default:
+					panic("unreachable")
+				}
+			})
+		}),
+	)
+
+	for _, ev := range tp.taskList.Clicked() {
+		handleLinkClick(win, ev)
+	}
+	tp.cells.Finish(win)
+
+	for tp.PanelButtons.Backed() {
+		tp.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
+
+func (tp *TasksPanel) layoutNames(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	return theme.List(win.Theme, &tp.namesList).Layout(gtx, len(tp.names), func(gtx layout.Context, index int) layout.Dimensions {
+		gtx.Constraints.Min.Y = 0
+		return tp.cells.Cell(win, gtx, func(tb *TextBuilder, txt *Text) {
+			name := tp.names[index]
+			tb.Link(name, name, &TaskNameObjectLink{Name: name})
+		})
+	})
+}
+
+// TaskNameInfo displays all tasks with a given name, and a histogram of their durations.
+type TaskNameInfo struct {
+	mwin        *theme.Window
+	trace       *Trace
+	name        string
+	tasks       []*ptrace.Task
+	tabbedState theme.TabbedState
+	taskList    TaskList
+
+	filterTasks widget.Bool
+	hist        InteractiveHistogram
+
+	descriptionText Text
+
+	initialized bool
+
+	theme.PanelButtons
+}
+
+func NewTaskNameInfo(tr *Trace, mwin *theme.Window, name string) *TaskNameInfo {
+	ti := &TaskNameInfo{
+		mwin:  mwin,
+		trace: tr,
+		name:  name,
+	}
+	for _, t := range tr.Tasks {
+		if t.Name == name {
+			ti.tasks = append(ti.tasks, t)
+		}
+	}
+	ti.taskList.SetTasks(tr, ti.tasks)
+	return ti
+}
+
+func (ti *TaskNameInfo) Title() string {
+	return local.Sprintf("Tasks named %q", ti.name)
+}
+
+func (ti *TaskNameInfo) buildDescription(win *theme.Window, gtx layout.Context) Description {
+	tb := TextBuilder{Theme: win.Theme}
+	var attrs []DescriptionAttribute
+
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "Name",
+		Value: *(tb.Span(ti.name)),
+	})
+
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "# of tasks",
+		Value: *(tb.Span(local.Sprintf("%d", len(ti.tasks)))),
+	})
+
+	var total time.Duration
+	for _, t := range ti.tasks {
+		total += ti.trace.TaskActivity(t).Duration()
+	}
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "Total time",
+		Value: *(tb.Span(total.String())),
+	})
+
+	return Description{Attributes: attrs}
+}
+
+func (ti *TaskNameInfo) computeHistogram(win *theme.Window, cfg *widget.HistogramConfig) []*ptrace.Task {
+	var durations []time.Duration
+	var tasks []*ptrace.Task
+	for _, t := range ti.tasks {
+		d := ti.trace.TaskActivity(t).Duration()
+		if fd := widget.FloatDuration(d); fd >= cfg.Start && (cfg.End == 0 || fd <= cfg.End) {
+			durations = append(durations, d)
+			tasks = append(tasks, t)
+		}
+	}
+
+	ti.hist.Set(win, durations)
+	return tasks
+}
+
+func (ti *TaskNameInfo) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.TaskNameInfo.Layout").End()
+
+	if !ti.initialized {
+		ti.hist.Config = widget.HistogramConfig{RejectOutliers: true, Bins: widget.DefaultHistogramBins}
+		ti.computeHistogram(win, &ti.hist.Config)
+		ti.initialized = true
+	}
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	tabs := []string{"Tasks", "Histogram"}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, ti.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			ti.descriptionText.Reset(win.Theme)
+			return ti.buildDescription(win, gtx).Layout(win, gtx, &ti.descriptionText)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return theme.Tabbed(&ti.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				switch tabs[ti.tabbedState.Current] {
+				case "Tasks":
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+						layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+							return theme.CheckBox(win.Theme, &ti.filterTasks, "Filter list to range of durations selected in histogram").Layout(win, gtx)
+						}),
+
+						layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+							return ti.taskList.Layout(win, gtx)
+						}),
+					)
+				case "Histogram":
+					return ti.hist.Layout(win, gtx)
+				default:
+					panic("unreachable")
+				}
+			})
+		}),
+	)
+
+	for _, ev := range ti.taskList.Clicked() {
+		handleLinkClick(win, ev)
+	}
+
+	for _, ev := range ti.descriptionText.Events() {
+		handleLinkClick(win, ev)
+	}
+
+	for ti.PanelButtons.Backed() {
+		ti.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	if ti.hist.Changed() || ti.filterTasks.Changed() {
+		histTasks := ti.computeHistogram(win, &ti.hist.Config)
+		if ti.filterTasks.Value {
+			ti.taskList.SetTasks(ti.trace, histTasks)
+		} else {
+			ti.taskList.SetTasks(ti.trace, ti.tasks)
+		}
+	}
+
+	return dims
+}
+
+// TaskInfo describes a single task. While it is open, the canvas only displays the goroutines that worked on the task,
+// and while it is displayed, the task's regions are highlighted.
+type TaskInfo struct {
+	mwin          *theme.Window
+	trace         *Trace
+	task          *ptrace.Task
+	tabbedState   theme.TabbedState
+	goroutineList GoroutineList
+	subtaskList   TaskList
+
+	breakdown struct {
+		includeSubtasks widget.Bool
+		stats           *theme.Future[*SpansStats]
+		list            widget.List
+		copyAsCSV       widget.PrimaryClickable
+		summaryText     Text
+	}
+
+	showAllTimelines widget.PrimaryClickable
+
+	descriptionText Text
+
+	theme.PanelButtons
+}
+
+func NewTaskInfo(tr *Trace, mwin *theme.Window, t *ptrace.Task) *TaskInfo {
+	ti := &TaskInfo{
+		mwin:  mwin,
+		trace: tr,
+		task:  t,
+	}
+	ti.subtaskList.SetTasks(tr, t.Children)
+	return ti
+}
+
+// Highlight implements HighlightingPanel.
+func (ti *TaskInfo) Highlight() Filter {
+	return Filter{Task: ti.task}
+}
+
+func (ti *TaskInfo) Title() string {
+	return local.Sprintf("Task %d: %s", ti.task.ID, ti.task.Name)
+}
+
+func (ti *TaskInfo) buildDescription(win *theme.Window, gtx layout.Context) Description {
+	tb := TextBuilder{Theme: win.Theme}
+	var attrs []DescriptionAttribute
+	act := ti.trace.TaskActivity(ti.task)
+
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "Task",
+		Value: *(tb.Span(local.Sprintf("%d", ti.task.ID))),
+	})
+
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "Name",
+		Value: *(tb.Link(ti.task.Name, ti.task.Name, &TaskNameObjectLink{Name: ti.task.Name, Provenance: "Name of current task"})),
+	})
+
+	if p := ti.task.Parent; p != nil {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Parent",
+			Value: *(tb.DefaultLink(local.Sprintf("Task %d (%s)", p.ID, p.Name), "Parent of current task", p)),
+		})
+	}
+
+	if ti.task.Stub() {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Created at",
+			Value: *(tb.DefaultLink("before trace start", "Start of current task", act.Start)),
+		})
+	} else {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "Created at",
+			Value: *(tb.DefaultLink(formatTimestamp(act.Start), "Start of current task", act.Start)),
+		})
+	}
+
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "Duration",
+		Value: *(tb.Span(act.Duration().String())),
+	})
+
+	if len(ti.task.Children) > 0 {
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   "# of subtasks",
+			Value: *(tb.Span(local.Sprintf("%d", len(ti.task.Children)))),
+		})
+	}
+
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "# of goroutines",
+		Value: *(tb.Span(local.Sprintf("%d", len(act.Goroutines)))),
+	})
+
+	attrs = append(attrs, DescriptionAttribute{
+		Key:   "# of regions",
+		Value: *(tb.Span(local.Sprintf("%d", len(act.Regions)))),
+	})
+
+	return Description{Attributes: attrs}
+}
+
+func (ti *TaskInfo) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.TaskInfo.Layout").End()
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	if ti.breakdown.stats == nil || ti.breakdown.includeSubtasks.Changed() {
+		ti.computeBreakdown(win)
+	}
+
+	tabs := []string{"Goroutines", "Breakdown"}
+	if len(ti.task.Children) > 0 {
+		tabs = append(tabs, "Subtasks")
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &ti.showAllTimelines.Clickable, "Show all timelines").Layout)),
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, ti.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			gtx.Constraints.Min = image.Point{}
+			ti.descriptionText.Reset(win.Theme)
+			return ti.buildDescription(win, gtx).Layout(win, gtx, &ti.descriptionText)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return theme.Tabbed(&ti.tabbedState, tabs).Layout(win, gtx, func(win *theme.Window, gtx layout.Context) layout.Dimensions {
+				switch tabs[ti.tabbedState.Current] {
+				case "Goroutines":
+					return ti.goroutineList.Layout(win, gtx, ti.trace.TaskActivity(ti.task).Goroutines)
+				case "Breakdown":
+					return ti.layoutBreakdown(win, gtx)
+				case "Subtasks":
+					return ti.subtaskList.Layout(win, gtx)
+				default:
+					panic("unreachable")
+				}
+			})
+		}),
+	)
+
+	for _, ev := range ti.goroutineList.Clicked() {
+		handleLinkClick(win, ev)
+	}
+	for _, ev := range ti.subtaskList.Clicked() {
+		handleLinkClick(win, ev)
+	}
+
+	for _, ev := range ti.descriptionText.Events() {
+		handleLinkClick(win, ev)
+	}
+
+	for ti.breakdown.copyAsCSV.Clicked() {
+		if stats, ok := ti.breakdown.stats.Result(); ok {
+			win.AppWindow.WriteClipboard(statisticsToCSV(&stats.stats))
+		}
+	}
+
+	for ti.showAllTimelines.Clicked() {
+		ti.mwin.EmitAction(CanvasShowAllTimelinesAction{})
+	}
+
+	for ti.PanelButtons.Backed() {
+		ti.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
+
+func (ti *TaskInfo) computeBreakdown(win *theme.Window) {
+	subtasks := ti.breakdown.includeSubtasks.Value
+	ti.breakdown.stats = theme.NewFuture(win, func(cancelled <-chan struct{}) *SpansStats {
+		return NewStats(ti.trace.TaskStatistics(ti.task, subtasks))
+	})
+}
+
+// buildBreakdownSummary describes how the goroutines working on the task spent their time, grouped into broad
+// categories.
+func (ti *TaskInfo) buildBreakdownSummary(win *theme.Window, stats *ptrace.Statistics) Description {
+	tb := TextBuilder{Theme: win.Theme}
+
+	var total time.Duration
+	for _, stat := range stats {
+		total += stat.Total
+	}
+	syscall := stats[ptrace.StateBlockedSyscall].Total
+	categories := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"Running", stats.Running()},
+		{"Runnable", stats[ptrace.StateReady].Total + stats[ptrace.StateCreated].Total},
+		{"Blocked", stats.Blocked() - syscall},
+		{"Syscall", syscall},
+		{"GC assist", stats.GCAssist()},
+	}
+
+	attrs := make([]DescriptionAttribute, 0, len(categories))
+	for _, c := range categories {
+		var pct float64
+		if total != 0 {
+			pct = float64(c.d) / float64(total) * 100
+		}
+		attrs = append(attrs, DescriptionAttribute{
+			Key:   c.name,
+			Value: *(tb.Span(local.Sprintf("%s (%.2f%%)", roundDuration(c.d), pct))),
+		})
+	}
+	return Description{Attributes: attrs}
+}
+
+func (ti *TaskInfo) layoutBreakdown(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	stats, ok := ti.breakdown.stats.Result()
+
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					if len(ti.task.Children) == 0 {
+						return layout.Dimensions{}
+					}
+					return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+						layout.Rigid(theme.Dumb(win, theme.CheckBox(win.Theme, &ti.breakdown.includeSubtasks, "Include subtasks").Layout)),
+						layout.Rigid(layout.Spacer{Width: 10}.Layout),
+					)
+				}),
+				layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &ti.breakdown.copyAsCSV.Clickable, "Copy as CSV").Layout)),
+			)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			if !ok {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing breakdown…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			gtx.Constraints.Min = image.Point{}
+			ti.breakdown.summaryText.Reset(win.Theme)
+			return ti.buildBreakdownSummary(win, &stats.stats).Layout(win, gtx, &ti.breakdown.summaryText)
+		}),
+
+		layout.Rigid(layout.Spacer{Height: 10}.Layout),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !ok {
+				return layout.Dimensions{}
+			}
+			return theme.List(win.Theme, &ti.breakdown.list).Layout(gtx, 1, func(gtx layout.Context, index int) layout.Dimensions {
+				if index != 0 {
+					panic("impossible")
+				}
+				return stats.Layout(win, gtx)
+			})
+		}),
+	)
+}
@@ -205,9 +205,9 @@ func (tr *Track) Spans(win *theme.Window) *theme.Future[Items[ptrace.Span]] {
 			span := ptrace.Span{
 				Start: trace.Timestamp(startsEndsPairwise[i][0]),
 				End:   trace.Timestamp(startsEndsPairwise[i][1]),
-				Event: ptrace.EventID(eventIDs[i]),
 				State: state,
 			}
+			span.SetEvent(ptrace.EventID(eventIDs[i]))
 			meta := stackSpanMeta{
 				pc:  pcs[i],
 				num: int(nums[i]),
@@ -282,6 +282,15 @@ func newZoomMenuItem(cv *Canvas, spans Items[ptrace.Span]) *theme.MenuItem {
 	}
 }
 
+func newSaveTraceMenuItem(spans Items[ptrace.Span]) *theme.MenuItem {
+	return &theme.MenuItem{
+		Label: PlainLabel("Save as trace…"),
+		Action: func() theme.Action {
+			return &SaveTraceSelectionAction{Start: spans.At(0).Start, End: LastSpan(spans).End}
+		},
+	}
+}
+
 type TrackWidget struct {
 	spanLabel       func(spans Items[ptrace.Span], tr *Trace, out []string) []string
 	spanColor       func(spans Items[ptrace.Span], tr *Trace) [2]colorIndex
@@ -475,7 +484,7 @@ func (tl *Timeline) Layout(win *theme.Window, gtx layout.Context, cv *Canvas, fo
 		if track.kind == TrackKindStack && !tl.cv.timeline.displayStackTracks {
 			continue
 		}
-		dims := track.Layout(win, gtx, tl, cv.timeline.filter, cv.timeline.automaticFilter, trackSpanLabels)
+		dims := track.Layout(win, gtx, tl, cv.timeline.filter, cv.timeline.automaticFilter, cv.timeline.panelFilter, trackSpanLabels)
 		op.Offset(image.Pt(0, dims.Size.Y+timelineTrackGap)).Add(gtx.Ops)
 		if spans := track.HoveredSpans(); spans.Len() != 0 {
 			tl.hoveredSpans = spans
@@ -505,15 +514,15 @@ func (tl *Timeline) Layout(win *theme.Window, gtx layout.Context, cv *Canvas, fo
 
 func defaultSpanColor(spans Items[ptrace.Span]) [2]colorIndex {
 	if spans.Len() == 1 {
-		return [2]colorIndex{stateColors[spans.At(0).State], 0}
+		return [2]colorIndex{spanColor(spans.At(0)), 0}
 	} else {
 		// OPT(dh): this would benefit from iterators, for span selectors backed by data that isn't already made of
 		// ptrace.Span
 		spans := spans
-		c := stateColors[spans.At(0).State]
+		c := spanColor(spans.At(0))
 		for i := 1; i < spans.Len(); i++ {
 			s := spans.At(i)
-			cc := stateColors[s.State]
+			cc := spanColor(s)
 			if cc != c {
 				return [2]colorIndex{colorStateMerged, 0}
 			}
@@ -614,7 +623,7 @@ func (it *renderedSpansIterator) next(gtx layout.Context) (spansOut Items[ptrace
 	return it.spans.Slice(startOffset, offset), startPx, endPx, true
 }
 
-func (track *Track) Layout(win *theme.Window, gtx layout.Context, tl *Timeline, filter Filter, automaticFilter Filter, labelsOut *[]string) (dims layout.Dimensions) {
+func (track *Track) Layout(win *theme.Window, gtx layout.Context, tl *Timeline, filter Filter, automaticFilter Filter, panelFilter Filter, labelsOut *[]string) (dims layout.Dimensions) {
 	defer rtrace.StartRegion(context.Background(), "main.TimelineWidgetTrack.Layout").End()
 
 	cv := tl.cv
@@ -743,7 +752,7 @@ func (track *Track) Layout(win *theme.Window, gtx layout.Context, tl *Timeline,
 				if track.spanContextMenu != nil {
 					win.SetContextMenu(track.spanContextMenu(dspSpans, cv))
 				} else {
-					win.SetContextMenu([]*theme.MenuItem{newZoomMenuItem(cv, dspSpans)})
+					win.SetContextMenu([]*theme.MenuItem{newZoomMenuItem(cv, dspSpans), newSaveTraceMenuItem(dspSpans)})
 				}
 			}
 		}
@@ -764,7 +773,9 @@ func (track *Track) Layout(win *theme.Window, gtx layout.Context, tl *Timeline,
 		minP = f32.Pt((max(startPx, 0)), 0)
 		maxP = f32.Pt((min(endPx, float32(gtx.Constraints.Max.X))), float32(trackHeight))
 
-		highlighted := filter.Match(dspSpans, ItemContainer{Timeline: tl, Track: track}) || automaticFilter.Match(dspSpans, ItemContainer{Timeline: tl, Track: track})
+		highlighted := filter.Match(dspSpans, ItemContainer{Timeline: tl, Track: track}) ||
+			automaticFilter.Match(dspSpans, ItemContainer{Timeline: tl, Track: track}) ||
+			panelFilter.Match(dspSpans, ItemContainer{Timeline: tl, Track: track})
 		if hovered {
 			highlightedPrimaryOutlinesPath.MoveTo(minP)
 			highlightedPrimaryOutlinesPath.LineTo(f32.Point{X: maxP.X, Y: minP.Y})
@@ -1136,7 +1147,7 @@ func NewSTWTimeline(cv *Canvas, tr *Trace, spans []ptrace.Span) *Timeline {
 					if spans.Len() != 1 {
 						return nil
 					}
-					kindID := tr.Events[spans.At(0).Event].Args[trace.ArgSTWStartKind]
+					kindID := tr.Event(spans.At(0).Event()).Args[trace.ArgSTWStartKind]
 					return append(out, stwSpanLabels[tr.STWReason(kindID)])
 				},
 				spanColor: singleSpanColor(colorStateSTW),
@@ -42,5 +42,5 @@ func (t *Trace) processorSpanLabels(p *ptrace.Processor) []string {
 
 //gcassert:inline
 func (t *Trace) Reason(s ptrace.Span) reason {
-	return reasonByEventType[t.Events[s.Event].Type]
+	return reasonByEventType[t.Event(s.Event()).Type]
 }
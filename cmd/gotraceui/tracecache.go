@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	rdebug "runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+// maxCachedTraces is the number of processed traces that we keep in the cache. Caches of large traces are large, too,
+// so we only keep the ones that were used most recently.
+const maxCachedTraces = 5
+
+// traceCacheDir returns the directory that processed traces are cached in.
+func traceCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gotraceui", "traces"), nil
+}
+
+// traceCacheVersion identifies the build of gotraceui. Caches written by other builds are ignored, as they might have
+// processed traces differently.
+func traceCacheVersion() string {
+	parts := []string{Version, runtime.Version()}
+	if info, ok := rdebug.ReadBuildInfo(); ok {
+		parts = append(parts, info.Main.Version, info.Main.Sum)
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision", "vcs.time", "vcs.modified":
+				parts = append(parts, s.Value)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// traceCacheKey computes the key of the cache of the traces read from rs, which is a hash of their contents, of the
+// binary used for symbolization, and of the options and rules that affect processing. It reports false if the traces
+// can't be cached because not all of them can be rewound after hashing them.
+func traceCacheKey(rs []io.Reader) (string, bool, error) {
+	h := sha256.New()
+	for _, r := range rs {
+		seeker, ok := r.(io.ReadSeeker)
+		if !ok {
+			return "", false, nil
+		}
+		off, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return "", false, err
+		}
+		if _, err := io.Copy(h, seeker); err != nil {
+			return "", false, err
+		}
+		if _, err := seeker.Seek(off, io.SeekStart); err != nil {
+			return "", false, err
+		}
+		// Separate the files so that different splits of the same data don't collide.
+		h.Write([]byte{0})
+	}
+	if repairTimestamps {
+		h.Write([]byte("repair-timestamps"))
+	}
+	for _, src := range loadedRules {
+		h.Write(src)
+		h.Write([]byte{0})
+	}
+	if binaryPath != "" {
+		f, err := os.Open(binaryPath)
+		if err != nil {
+			return "", false, err
+		}
+		defer f.Close()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", false, err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), true, nil
+}
+
+// readTraceCache loads the cached processed trace with the given key. It returns nil if there is no usable cache.
+// Caches that can't be used are removed.
+func readTraceCache(key string, progress func(float64)) (*ptrace.Trace, error) {
+	dir, err := traceCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, key)
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	tr, err := ptrace.ReadCache(f, fi.Size(), traceCacheVersion(), progress)
+	if err != nil {
+		os.Remove(path)
+		if err == ptrace.ErrCacheVersion {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// Mark the cache as recently used.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return tr, nil
+}
+
+// writeTraceCache caches the processed trace under the given key and removes the least recently used caches.
+func writeTraceCache(key string, tr *ptrace.Trace) error {
+	dir, err := traceCacheDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that we never leave partial caches behind.
+	f, err := os.CreateTemp(dir, key+".tmp*")
+	if err != nil {
+		return err
+	}
+	if err := tr.WriteCache(f, traceCacheVersion()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(dir, key)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	type cached struct {
+		name    string
+		modTime time.Time
+	}
+	var caches []cached
+	for _, e := range entries {
+		if e.IsDir() || strings.Contains(e.Name(), ".tmp") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		caches = append(caches, cached{e.Name(), info.ModTime()})
+	}
+	if len(caches) <= maxCachedTraces {
+		return nil
+	}
+	sort.Slice(caches, func(i, j int) bool { return caches[i].modTime.After(caches[j].modTime) })
+	for _, c := range caches[maxCachedTraces:] {
+		os.Remove(filepath.Join(dir, c.name))
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	rtrace "runtime/trace"
+	"time"
+
+	"honnef.co/go/gotraceui/layout"
+	"honnef.co/go/gotraceui/theme"
+	"honnef.co/go/gotraceui/trace/ptrace"
+	"honnef.co/go/gotraceui/widget"
+
+	"gioui.org/font"
+	"gioui.org/text"
+)
+
+// WakeupGraphPanel displays which goroutines, or functions, unblocked which other ones over the course of the whole
+// trace.
+type WakeupGraphPanel struct {
+	mwin  *theme.Window
+	trace *Trace
+
+	byFunction widget.Bool
+	graph      *theme.Future[*ptrace.WakeupGraph]
+	// The graph that order was computed for
+	sorted *ptrace.WakeupGraph
+	// Indices of edges, in display order
+	order []int
+
+	list    widget.List
+	sort    theme.TableSortState
+	buttons struct {
+		copyAsDOT  widget.PrimaryClickable
+		copyAsJSON widget.PrimaryClickable
+	}
+	cells TableCells
+
+	theme.PanelButtons
+}
+
+func NewWakeupGraphPanel(mwin *theme.Window, tr *Trace) *WakeupGraphPanel {
+	wp := &WakeupGraphPanel{
+		mwin:  mwin,
+		trace: tr,
+	}
+	wp.list.Axis = layout.Vertical
+	// Sort by number of wake-ups, descending.
+	wp.sort.Column = 2
+	wp.sort.Descending = true
+	return wp
+}
+
+func (wp *WakeupGraphPanel) Title() string {
+	return "Wake-up graph"
+}
+
+var wakeupGraphColumns = []theme.TableListColumn{
+	{
+		Name: "Unblocking",
+		// XXX the width depends on the font and scaling
+		MinWidth: 300,
+		MaxWidth: 300,
+	},
+
+	{
+		Name: "Unblocked",
+		// XXX the width depends on the font and scaling
+		MinWidth: 300,
+		MaxWidth: 300,
+	},
+
+	{
+		Name: "Count",
+		// XXX the width depends on the font and scaling
+		MinWidth: 100,
+		MaxWidth: 100,
+	},
+
+	{
+		Name: "Total wait",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+
+	{
+		Name: "Average wait",
+		// XXX the width depends on the font and scaling
+		MinWidth: 120,
+		MaxWidth: 120,
+	},
+}
+
+func (wp *WakeupGraphPanel) computeGraph(win *theme.Window) {
+	byFunction := wp.byFunction.Value
+	wp.graph = theme.NewFuture(win, func(cancelled <-chan struct{}) *ptrace.WakeupGraph {
+		return wp.trace.WakeupGraph(byFunction)
+	})
+}
+
+func (wp *WakeupGraphPanel) sortEdges(wg *ptrace.WakeupGraph) {
+	avg := func(e ptrace.WakeupEdge) time.Duration { return e.Wait / time.Duration(e.Count) }
+
+	wp.order = tableOrder(wp.order, len(wg.Edges))
+	sortTableRows(wp.order, &wp.sort, func(i, j, col int) int {
+		a, b := wg.Edges[i], wg.Edges[j]
+		switch col {
+		case 0:
+			// Sort nodes by what we display, not by their arbitrary indices.
+			return cmp.Compare(wg.Nodes[a.From].String(), wg.Nodes[b.From].String())
+		case 1:
+			return cmp.Compare(wg.Nodes[a.To].String(), wg.Nodes[b.To].String())
+		case 2:
+			return cmp.Compare(a.Count, b.Count)
+		case 3:
+			return cmp.Compare(a.Wait, b.Wait)
+		case 4:
+			return cmp.Compare(avg(a), avg(b))
+		default:
+			panic("unreachable")
+		}
+	})
+	wp.sorted = wg
+}
+
+func (wp *WakeupGraphPanel) Layout(win *theme.Window, gtx layout.Context) layout.Dimensions {
+	defer rtrace.StartRegion(context.Background(), "main.WakeupGraphPanel.Layout").End()
+
+	if wp.graph == nil || wp.byFunction.Changed() {
+		wp.computeGraph(win)
+	}
+	wg, haveGraph := wp.graph.Result()
+	if haveGraph && (wp.sort.Changed() || wp.sorted != wg) {
+		wp.sortEdges(wg)
+	}
+
+	defer insetPanel(&gtx).Pop()
+
+	nothing := func(gtx layout.Context) layout.Dimensions {
+		return layout.Dimensions{Size: gtx.Constraints.Min}
+	}
+
+	cellFn := func(tb *TextBuilder, txt *Text, row, col int) {
+		node := func(n ptrace.WakeupNode) {
+			if n.Goroutine != nil {
+				tb.DefaultLink(n.String(), "", n.Goroutine)
+			} else {
+				tb.DefaultLink(n.String(), "", n.Function)
+			}
+		}
+
+		e := wg.Edges[wp.order[row]]
+		switch col {
+		case 0: // Unblocking
+			node(wg.Nodes[e.From])
+		case 1: // Unblocked
+			node(wg.Nodes[e.To])
+		case 2: // Count
+			tb.Span(local.Sprintf("%d", e.Count))
+			txt.Alignment = text.End
+		case 3: // Total wait
+			durationCell(tb, txt, e.Wait)
+		case 4: // Average wait
+			durationCell(tb, txt, e.Wait/time.Duration(e.Count))
+		}
+	}
+
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Flex{Axis: layout.Horizontal, Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(theme.Dumb(win, theme.CheckBox(win.Theme, &wp.byFunction, "Group by function").Layout)),
+				layout.Rigid(layout.Spacer{Width: 10}.Layout),
+				layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &wp.buttons.copyAsDOT.Clickable, "Copy as DOT").Layout)),
+				layout.Rigid(layout.Spacer{Width: 5}.Layout),
+				layout.Rigid(theme.Dumb(win, theme.Button(win.Theme, &wp.buttons.copyAsJSON.Clickable, "Copy as JSON").Layout)),
+				layout.Flexed(1, nothing),
+				layout.Rigid(theme.Dumb(win, wp.PanelButtons.Layout)),
+			)
+		}),
+
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions { return layout.Spacer{Height: 10}.Layout(gtx) }),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			if !haveGraph {
+				return widget.Label{}.Layout(gtx, win.Theme.Shaper, font.Font{}, win.Theme.TextSize, "Computing wake-up graph…", widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+
+			return wp.cells.Table(win, gtx, wakeupGraphColumns, &wp.list, &wp.sort, len(wp.order), cellFn)
+		}),
+	)
+
+	wp.cells.Finish(win)
+	for wp.buttons.copyAsDOT.Clicked() {
+		if haveGraph {
+			var buf bytes.Buffer
+			wg.WriteDOT(&buf)
+			win.AppWindow.WriteClipboard(buf.String())
+		}
+	}
+	for wp.buttons.copyAsJSON.Clicked() {
+		if haveGraph {
+			var buf bytes.Buffer
+			wg.WriteJSON(&buf)
+			win.AppWindow.WriteClipboard(buf.String())
+		}
+	}
+	for wp.PanelButtons.Backed() {
+		wp.mwin.EmitAction(PrevPanelAction{})
+	}
+
+	return dims
+}
@@ -5,6 +5,8 @@ go 1.21
 require (
 	gioui.org v0.2.0
 	gioui.org/x v0.2.0
+	github.com/klauspost/compress v1.17.0
+	github.com/ulikunitz/xz v0.5.11
 	golang.org/x/exp v0.0.0-20221012211006-4de253d81b95
 	golang.org/x/image v0.7.0
 	golang.org/x/text v0.9.0
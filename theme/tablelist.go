@@ -7,7 +7,9 @@ import (
 	"honnef.co/go/gotraceui/widget"
 
 	"gioui.org/font"
+	"gioui.org/io/pointer"
 	"gioui.org/layout"
+	"gioui.org/op/clip"
 )
 
 type TableListColumn struct {
@@ -20,6 +22,34 @@ type TableListStyle struct {
 	Columns       []TableListColumn
 	List          *widget.List
 	ColumnPadding int
+	// If Sort is set, clicking on column headers changes the sort order.
+	Sort *TableSortState
+}
+
+// TableSortState tracks by which column a TableListStyle is sorted. Sorting the items is the responsibility of the
+// user.
+type TableSortState struct {
+	Column     int
+	Descending bool
+
+	clicks []widget.PrimaryClickable
+}
+
+// Changed reports whether the sort order has changed since the last call to Changed.
+func (s *TableSortState) Changed() bool {
+	var changed bool
+	for col := range s.clicks {
+		for s.clicks[col].Clicked() {
+			if col == s.Column {
+				s.Descending = !s.Descending
+			} else {
+				s.Column = col
+				s.Descending = false
+			}
+			changed = true
+		}
+	}
+	return changed
 }
 
 func (tbl *TableListStyle) Layout(
@@ -33,10 +63,33 @@ func (tbl *TableListStyle) Layout(
 	st := List(win.Theme, tbl.List)
 	st.EnableCrossScrolling = true
 
+	if tbl.Sort != nil && len(tbl.Sort.clicks) != len(tbl.Columns) {
+		tbl.Sort.clicks = make([]widget.PrimaryClickable, len(tbl.Columns))
+	}
+
 	ourCellFn := func(gtx layout.Context, row, col int) layout.Dimensions {
 		if row == 0 {
-			return widget.Label{MaxLines: 1}.
-				Layout(gtx, win.Theme.Shaper, font.Font{Weight: font.Bold}, win.Theme.TextSize, tbl.Columns[col].Name, widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			header := func(gtx layout.Context) layout.Dimensions {
+				name := tbl.Columns[col].Name
+				if tbl.Sort != nil && tbl.Sort.Column == col {
+					if tbl.Sort.Descending {
+						name += "▼"
+					} else {
+						name += "▲"
+					}
+				}
+				return widget.Label{MaxLines: 1}.
+					Layout(gtx, win.Theme.Shaper, font.Font{Weight: font.Bold}, win.Theme.TextSize, name, widget.ColorTextMaterial(gtx, win.Theme.Palette.Foreground))
+			}
+			if tbl.Sort == nil {
+				return header(gtx)
+			}
+			return tbl.Sort.clicks[col].Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				dims := header(gtx)
+				defer clip.Rect{Max: dims.Size}.Push(gtx.Ops).Pop()
+				pointer.CursorPointer.Add(gtx.Ops)
+				return dims
+			})
 		} else {
 			return cellFn(gtx, row-1, col)
 		}
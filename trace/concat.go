@@ -0,0 +1,108 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Concat concatenates traces that were recorded one after the other by the same process, such as traces that were
+// collected by repeatedly starting and stopping tracing. The traces must be in the order in which they were recorded.
+//
+// The timestamps of events are made relative to the start of the first trace and the gaps between traces are
+// retained. Stacks and strings are renumbered and links between events are updated to refer to the events' new
+// indices. Each trace's events that describe the state at its start, such as the creation of goroutines that already
+// existed, are retained as they are, which means that the result is a sequence of individually consistent traces and
+// not a single consistent trace. The traces' diagnostics are retained, too.
+func Concat(trs []Trace) (Trace, error) {
+	if len(trs) == 0 {
+		return Trace{}, errors.New("no traces to concatenate")
+	}
+
+	res := Trace{
+		Version: trs[0].Version,
+		Start:   trs[0].Start,
+		Stacks:  make(map[uint32][]uint64),
+		PCs:     make(map[uint64]Frame),
+		Strings: make(map[uint64]string),
+	}
+	stringIDs := map[string]uint64{}
+	var stkBase uint32
+	var end Timestamp
+	for i := range trs {
+		tr := &trs[i]
+		if tr.Version != res.Version {
+			return Trace{}, fmt.Errorf("trace %d has version %d, but trace 0 has version %d", i, tr.Version, res.Version)
+		}
+		shift := tr.Start - res.Start
+		if i > 0 && shift < end {
+			return Trace{}, fmt.Errorf("trace %d starts before trace %d ends", i, i-1)
+		}
+
+		// Strings are deduplicated, which also keeps the IDs dense. IDs without a string refer to the empty string.
+		str := func(id uint64) uint64 {
+			s := tr.Strings[id]
+			nid, ok := stringIDs[s]
+			if !ok {
+				nid = uint64(len(stringIDs))
+				stringIDs[s] = nid
+				res.Strings[nid] = s
+			}
+			return nid
+		}
+		stk := func(id uint32) uint32 {
+			if id == 0 {
+				return 0
+			}
+			return id + stkBase
+		}
+
+		var maxStk uint32
+		for id, pcs := range tr.Stacks {
+			res.Stacks[stk(id)] = pcs
+			maxStk = max(maxStk, id)
+		}
+		for pc, frame := range tr.PCs {
+			if _, ok := res.PCs[pc]; !ok {
+				res.PCs[pc] = frame
+			}
+		}
+
+		evBase := int64(res.Events.Len())
+		for j := 0; j < tr.Events.Len(); j++ {
+			ev := res.Events.Append(tr.Events.Get(j))
+			ev.Ts += shift
+			ev.StkID = stk(ev.StkID)
+			if link := ev.Link(); link != -1 {
+				ev.SetLink(link + evBase)
+			}
+			switch ev.Type {
+			case EvGoCreate:
+				ev.Args[ArgGoCreateStack] = uint64(stk(uint32(ev.Args[ArgGoCreateStack])))
+			case EvGoStartLabel:
+				ev.Args[ArgGoStartLabelLabelID] = str(ev.Args[ArgGoStartLabelLabelID])
+			case EvUserTaskCreate:
+				ev.Args[ArgUserTaskCreateTypeID] = str(ev.Args[ArgUserTaskCreateTypeID])
+			case EvUserRegion:
+				ev.Args[ArgUserRegionTypeID] = str(ev.Args[ArgUserRegionTypeID])
+			case EvUserLog:
+				ev.Args[ArgUserLogKeyID] = str(ev.Args[ArgUserLogKeyID])
+				ev.Args[ArgUserLogMessage] = str(ev.Args[ArgUserLogMessage])
+			case EvSTWStart:
+				if res.Version >= 1022 {
+					// The generation-based format records the reason as a string.
+					ev.Args[ArgSTWStartKind] = str(ev.Args[ArgSTWStartKind])
+				}
+			}
+		}
+		if int64(res.Events.Len()) > maxEvents {
+			return Trace{}, ErrTooManyEvents
+		}
+		if tr.Events.Len() > 0 {
+			end = res.Events.Last().Ts
+		}
+
+		stkBase += maxStk
+		res.Diagnostics = append(res.Diagnostics, tr.Diagnostics...)
+	}
+	return res, nil
+}
@@ -0,0 +1,115 @@
+package trace_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+// splitTrace parses the trace stored in ./testdata/name and splits it in two, as if tracing had been stopped and
+// started again. The parts are returned in the order in which they were recorded.
+func splitTrace(t *testing.T, name string) (trace.Trace, []trace.Trace) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("./testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := trace.Parse(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := tr.Events.Last().Ts
+	mid := last / 2
+	var parts []trace.Trace
+	for _, window := range [][2]trace.Timestamp{{0, mid}, {mid + 1, last}} {
+		var buf bytes.Buffer
+		if err := trace.Crop(&buf, &tr, window[0], window[1], nil); err != nil {
+			t.Fatal(err)
+		}
+		part, err := trace.Parse(&buf, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part)
+	}
+	return tr, parts
+}
+
+func TestConcat(t *testing.T) {
+	// Arguments that are string IDs, by event type
+	stringArgs := map[byte][]int{
+		trace.EvGoStartLabel:   {trace.ArgGoStartLabelLabelID},
+		trace.EvUserTaskCreate: {trace.ArgUserTaskCreateTypeID},
+		trace.EvUserRegion:     {trace.ArgUserRegionTypeID},
+		trace.EvUserLog:        {trace.ArgUserLogKeyID, trace.ArgUserLogMessage},
+	}
+
+	for _, name := range []string{"user_task_region_1_21_good", "stress_1_21_good"} {
+		t.Run(name, func(t *testing.T) {
+			_, parts := splitTrace(t, name)
+			got, err := trace.Concat(parts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Start != parts[0].Start {
+				t.Errorf("got start %d, want %d", got.Start, parts[0].Start)
+			}
+			if n := parts[0].Events.Len() + parts[1].Events.Len(); got.Events.Len() != n {
+				t.Fatalf("got %d events, want %d", got.Events.Len(), n)
+			}
+
+			var base int
+			for _, part := range parts {
+				shift := part.Start - parts[0].Start
+				for j := 0; j < part.Events.Len(); j++ {
+					want := part.Events.Ptr(j)
+					ev := got.Events.Ptr(base + j)
+					if ev.Type != want.Type || ev.G != want.G || ev.Ts != want.Ts+shift {
+						t.Fatalf("event %d: got %s, want %s shifted by %d", base+j, ev, want, shift)
+					}
+					if !reflect.DeepEqual(got.Stacks[ev.StkID], part.Stacks[want.StkID]) {
+						t.Errorf("event %d: got stack %v, want %v", base+j, got.Stacks[ev.StkID], part.Stacks[want.StkID])
+					}
+					if ev.Type == trace.EvGoCreate {
+						gs, ws := got.Stacks[uint32(ev.Args[trace.ArgGoCreateStack])], part.Stacks[uint32(want.Args[trace.ArgGoCreateStack])]
+						if !reflect.DeepEqual(gs, ws) {
+							t.Errorf("event %d: got creation stack %v, want %v", base+j, gs, ws)
+						}
+					}
+					for _, arg := range stringArgs[ev.Type] {
+						if gs, ws := got.Strings[ev.Args[arg]], part.Strings[want.Args[arg]]; gs != ws {
+							t.Errorf("event %d: got string %q, want %q", base+j, gs, ws)
+						}
+					}
+					if link := want.Link(); link == -1 {
+						if ev.Link() != -1 {
+							t.Errorf("event %d: got link %d, want none", base+j, ev.Link())
+						}
+					} else if ev.Link() != link+int64(base) {
+						t.Errorf("event %d: got link %d, want %d", base+j, ev.Link(), link+int64(base))
+					}
+				}
+				base += part.Events.Len()
+			}
+		})
+	}
+}
+
+func TestConcatVersion(t *testing.T) {
+	_, parts := splitTrace(t, "stress_1_21_good")
+	parts[1].Version++
+	if _, err := trace.Concat(parts); err == nil {
+		t.Errorf("expected error for traces of different versions")
+	}
+}
+
+func TestConcatOverlap(t *testing.T) {
+	tr, _ := splitTrace(t, "stress_1_21_good")
+	if _, err := trace.Concat([]trace.Trace{tr, tr}); err == nil {
+		t.Errorf("expected error for overlapping traces")
+	}
+}
@@ -0,0 +1,250 @@
+package trace
+
+import (
+	"io"
+
+	"golang.org/x/exp/slices"
+)
+
+// Crop writes a trace to w that contains the events of tr that happened between start and end, inclusive. If keep
+// isn't nil, events that belong to goroutines for which keep returns false are omitted.
+//
+// The state at the start of the window is described by synthesized events, the same way the runtime describes the
+// state when tracing starts: goroutines that exist are created, waiting goroutines and goroutines in syscalls are
+// marked as such, and running processors, goroutines, sweeps, garbage collections, stop-the-world pauses and tasks
+// are started. Regions and mark assists that are in progress aren't synthesized; the parser already accepts their
+// ends without starts.
+func Crop(w io.Writer, tr *Trace, start, end Timestamp, keep func(gid uint64) bool) error {
+	if keep == nil {
+		keep = func(uint64) bool { return true }
+	}
+
+	const (
+		gDead = iota
+		gRunnable
+		gRunning
+		gWaiting
+	)
+	type gdesc struct {
+		state   int
+		syscall bool
+		// The event that started the goroutine, if it's running.
+		evStart *Event
+		// The stack of the goroutine's start function.
+		createStk uint64
+	}
+	type pdesc struct {
+		running bool
+		thread  uint64
+		g       uint64
+		evSweep *Event
+	}
+
+	gs := map[uint64]*gdesc{}
+	ps := map[int32]*pdesc{}
+	tasks := map[uint64]*Event{}
+	var evGC, evSTW *Event
+	getP := func(pid int32) *pdesc {
+		p, ok := ps[pid]
+		if !ok {
+			p = &pdesc{}
+			ps[pid] = p
+		}
+		return p
+	}
+	getG := func(gid uint64) *gdesc {
+		g, ok := gs[gid]
+		if !ok {
+			g = &gdesc{}
+			gs[gid] = g
+		}
+		return g
+	}
+
+	// Compute the state at the start of the window.
+	i := 0
+	for ; i < tr.Events.Len() && tr.Events.Ptr(i).Ts < start; i++ {
+		ev := tr.Events.Ptr(i)
+		switch ev.Type {
+		case EvProcStart:
+			p := getP(ev.P)
+			p.running = true
+			p.thread = ev.Args[0]
+		case EvProcStop:
+			p := getP(ev.P)
+			p.running = false
+			p.g = 0
+		case EvGCStart:
+			evGC = ev
+		case EvGCDone:
+			evGC = nil
+		case EvSTWStart:
+			evSTW = ev
+		case EvSTWDone:
+			evSTW = nil
+		case EvGCSweepStart:
+			getP(ev.P).evSweep = ev
+		case EvGCSweepDone:
+			getP(ev.P).evSweep = nil
+		case EvGoCreate:
+			gs[ev.Args[0]] = &gdesc{state: gRunnable, createStk: ev.Args[ArgGoCreateStack]}
+		case EvGoStart, EvGoStartLabel:
+			g := getG(ev.G)
+			g.state = gRunning
+			g.evStart = ev
+			getP(ev.P).g = ev.G
+		case EvGoEnd, EvGoStop:
+			delete(gs, ev.G)
+			getP(ev.P).g = 0
+		case EvGoSched, EvGoPreempt:
+			g := getG(ev.G)
+			g.state = gRunnable
+			g.evStart = nil
+			getP(ev.P).g = 0
+		case EvGoSleep, EvGoBlock, EvGoBlockSend, EvGoBlockRecv,
+			EvGoBlockSelect, EvGoBlockSync, EvGoBlockCond, EvGoBlockNet, EvGoBlockGC:
+			g := getG(ev.G)
+			g.state = gWaiting
+			g.evStart = nil
+			getP(ev.P).g = 0
+		case EvGoSysBlock:
+			g := getG(ev.G)
+			g.state = gWaiting
+			g.syscall = true
+			g.evStart = nil
+			getP(ev.P).g = 0
+		case EvGoSysExit:
+			g := getG(ev.G)
+			g.state = gRunnable
+			g.syscall = false
+		case EvGoUnblock:
+			getG(ev.Args[0]).state = gRunnable
+		case EvGoWaiting:
+			getG(ev.G).state = gWaiting
+		case EvGoInSyscall:
+			g := getG(ev.G)
+			g.state = gWaiting
+			g.syscall = true
+		case EvUserTaskCreate:
+			tasks[ev.Args[0]] = ev
+		case EvUserTaskEnd:
+			delete(tasks, ev.Args[0])
+		}
+	}
+
+	tw, err := NewWriter(w, tr.Version, tr)
+	if err != nil {
+		return err
+	}
+	// synth writes a synthesized event.
+	synth := func(typ byte, pid int32, gid uint64, stk uint32, args ...uint64) error {
+		ev := Event{Ts: start, Type: typ, P: pid, G: gid, StkID: stk}
+		copy(ev.Args[:], args)
+		return tw.WriteEvent(&ev)
+	}
+
+	gids := make([]uint64, 0, len(gs))
+	for gid, g := range gs {
+		if gid != 0 && g.state != gDead && keep(gid) {
+			gids = append(gids, gid)
+		}
+	}
+	slices.Sort(gids)
+	for _, gid := range gids {
+		g := gs[gid]
+		if err := synth(EvGoCreate, -1, 0, 0, gid, g.createStk); err != nil {
+			return err
+		}
+		if g.state == gWaiting {
+			typ := byte(EvGoWaiting)
+			if g.syscall {
+				typ = EvGoInSyscall
+			}
+			if err := synth(typ, -1, gid, 0, gid); err != nil {
+				return err
+			}
+		}
+	}
+
+	pids := make([]int32, 0, len(ps))
+	for pid := range ps {
+		pids = append(pids, pid)
+	}
+	slices.Sort(pids)
+	for _, pid := range pids {
+		p := ps[pid]
+		if !p.running {
+			continue
+		}
+		if err := synth(EvProcStart, pid, 0, 0, p.thread); err != nil {
+			return err
+		}
+		gid := p.g
+		// The goroutine may have stopped running on another P, in which case it no longer has a start event.
+		if g := gs[gid]; gid != 0 && g != nil && g.evStart != nil && keep(gid) {
+			if err := synth(g.evStart.Type, pid, gid, 0, gid, 0, g.evStart.Args[2]); err != nil {
+				return err
+			}
+		} else {
+			gid = 0
+		}
+		if ev := p.evSweep; ev != nil {
+			if err := synth(EvGCSweepStart, pid, gid, ev.StkID); err != nil {
+				return err
+			}
+		}
+	}
+	if evGC != nil {
+		if err := synth(EvGCStart, GCP, 0, evGC.StkID, evGC.Args[:]...); err != nil {
+			return err
+		}
+	}
+	if evSTW != nil {
+		if err := synth(EvSTWStart, -1, 0, 0, evSTW.Args[:]...); err != nil {
+			return err
+		}
+	}
+
+	taskIDs := make([]uint64, 0, len(tasks))
+	for id, ev := range tasks {
+		if ev.G == 0 || keep(ev.G) {
+			taskIDs = append(taskIDs, id)
+		}
+	}
+	slices.Sort(taskIDs)
+	for _, id := range taskIDs {
+		ev := tasks[id]
+		if err := synth(EvUserTaskCreate, -1, 0, ev.StkID, ev.Args[:]...); err != nil {
+			return err
+		}
+	}
+
+	for ; i < tr.Events.Len() && tr.Events.Ptr(i).Ts <= end; i++ {
+		ev := tr.Events.Get(i)
+		switch ev.Type {
+		case EvGoCreate, EvGoUnblock:
+			// These events belong to the goroutine they affect, not the one that emitted them.
+			if !keep(ev.Args[0]) {
+				continue
+			}
+		case EvGoStart, EvGoStartLabel, EvGoEnd, EvGoStop, EvGoSched, EvGoPreempt,
+			EvGoSleep, EvGoBlock, EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect,
+			EvGoBlockSync, EvGoBlockCond, EvGoBlockNet, EvGoBlockGC,
+			EvGoSysCall, EvGoSysBlock, EvGoSysExit, EvGoWaiting, EvGoInSyscall,
+			EvGCMarkAssistStart, EvGCMarkAssistDone,
+			EvUserTaskCreate, EvUserTaskEnd, EvUserRegion, EvUserLog, EvCPUSample:
+			if ev.G != 0 && !keep(ev.G) {
+				continue
+			}
+		}
+		if ev.G != 0 && !keep(ev.G) {
+			// The event isn't specific to a goroutine, but we've omitted the goroutine that was running at the time.
+			ev.G = 0
+		}
+		if err := tw.WriteEvent(&ev); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
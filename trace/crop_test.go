@@ -0,0 +1,104 @@
+package trace_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestCrop(t *testing.T) {
+	files, err := os.ReadDir("./testdata")
+	if err != nil {
+		t.Fatalf("failed to read ./testdata: %v", err)
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), "_good") {
+			continue
+		}
+		t.Run(f.Name(), func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("./testdata", f.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr, err := trace.Parse(bytes.NewReader(data), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := tr.Events.Last().Ts
+			start, end := last/3, last/3*2
+
+			for _, tt := range []struct {
+				name string
+				keep func(gid uint64) bool
+			}{
+				{"all", nil},
+				{"odd", func(gid uint64) bool { return gid%2 == 1 }},
+			} {
+				var buf bytes.Buffer
+				if err := trace.Crop(&buf, &tr, start, end, tt.keep); err != nil {
+					t.Fatalf("%s: failed to crop trace: %s", tt.name, err)
+				}
+				cropped, err := trace.Parse(&buf, nil)
+				if err != nil {
+					t.Fatalf("%s: failed to parse cropped trace: %s", tt.name, err)
+				}
+				if n := cropped.Events.Len(); n == 0 || n > tr.Events.Len() {
+					t.Errorf("%s: got %d events, original trace has %d", tt.name, n, tr.Events.Len())
+				}
+				if tt.keep != nil {
+					for i := 0; i < cropped.Events.Len(); i++ {
+						ev := cropped.Events.Ptr(i)
+						if ev.G != 0 && !tt.keep(ev.G) {
+							t.Fatalf("%s: found event %s of omitted goroutine %d", tt.name, trace.EventDescriptions[ev.Type].Name, ev.G)
+						}
+					}
+				}
+				if _, err := ptrace.Parse(cropped, func(float64) {}); err != nil {
+					t.Fatalf("%s: failed to process cropped trace: %s", tt.name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCropStoppedProcessor(t *testing.T) {
+	// Goroutine 1 is running when P 0 stops. It later blocks on P 1, after P 0 has started again. Cropping after that
+	// mustn't synthesize a start of goroutine 1 on P 0.
+	tr := trace.Trace{
+		Version: 1021,
+		Stacks:  map[uint32][]uint64{},
+		PCs:     map[uint64]trace.Frame{},
+		Strings: map[uint64]string{},
+	}
+	for _, ev := range []trace.Event{
+		{Ts: 1, Type: trace.EvProcStart, P: 0, Args: [4]uint64{1}},
+		{Ts: 2, Type: trace.EvGoCreate, P: 0, Args: [4]uint64{1}},
+		{Ts: 3, Type: trace.EvGoStart, P: 0, G: 1, Args: [4]uint64{1}},
+		{Ts: 4, Type: trace.EvProcStop, P: 0},
+		{Ts: 5, Type: trace.EvProcStart, P: 1, Args: [4]uint64{2}},
+		{Ts: 6, Type: trace.EvGoBlock, P: 1, G: 1},
+		{Ts: 7, Type: trace.EvProcStart, P: 0, Args: [4]uint64{1}},
+		{Ts: 20, Type: trace.EvProcStop, P: 0},
+	} {
+		tr.Events.Append(ev)
+	}
+
+	var buf bytes.Buffer
+	if err := trace.Crop(&buf, &tr, 10, 20, nil); err != nil {
+		t.Fatal(err)
+	}
+	cropped, err := trace.Parse(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < cropped.Events.Len(); i++ {
+		if ev := cropped.Events.Ptr(i); ev.Type == trace.EvGoStart {
+			t.Errorf("found synthesized start of goroutine %d on P %d", ev.G, ev.P)
+		}
+	}
+}
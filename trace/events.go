@@ -0,0 +1,93 @@
+package trace
+
+import "fmt"
+
+// maxEvents is the maximum number of events in a trace. Event indices are stored in 40 bits to keep the Event type
+// compact.
+const maxEvents = 1<<40 - 1
+
+var ErrTooManyEvents = fmt.Errorf("trace contains more than %d events", int64(maxEvents))
+
+// eventsChunkSize is the number of events in each chunk of Events. Chunks are 4 MiB large.
+const eventsChunkSize = 1 << 16
+
+// Events is a list of events. It is like a slice, but stores events in fixed-size chunks. This avoids having to make a
+// single, enormous allocation for traces that contain billions of events, as well as having to copy all events when
+// the list grows. Pointers to events remain valid as the list grows.
+type Events struct {
+	n      int
+	chunks [][]Event
+}
+
+//gcassert:inline
+func (evs *Events) index(i int) (int, int) {
+	return i / eventsChunkSize, i % eventsChunkSize
+}
+
+// Len returns the number of events.
+func (evs *Events) Len() int {
+	return evs.n
+}
+
+// Ptr returns a pointer to the i-th event.
+//
+//gcassert:inline
+func (evs *Events) Ptr(i int) *Event {
+	a, b := evs.index(i)
+	return &evs.chunks[a][b]
+}
+
+// Get returns the i-th event.
+func (evs *Events) Get(i int) Event {
+	return *evs.Ptr(i)
+}
+
+// Grow grows the list by one and returns a pointer to the new event, which is zeroed.
+func (evs *Events) Grow() *Event {
+	a, _ := evs.index(evs.n)
+	if a == len(evs.chunks) {
+		evs.chunks = append(evs.chunks, make([]Event, 0, eventsChunkSize))
+	}
+	evs.chunks[a] = evs.chunks[a][:len(evs.chunks[a])+1]
+	ptr := &evs.chunks[a][len(evs.chunks[a])-1]
+	*ptr = Event{}
+	evs.n++
+	return ptr
+}
+
+// Append appends ev to the list and returns a pointer to the new event.
+func (evs *Events) Append(ev Event) *Event {
+	ptr := evs.Grow()
+	*ptr = ev
+	return ptr
+}
+
+// Truncate shortens the list to n events. Chunks are retained for reuse.
+func (evs *Events) Truncate(n int) {
+	if n >= evs.n {
+		return
+	}
+	a, b := evs.index(n)
+	evs.chunks[a] = evs.chunks[a][:b]
+	for i := a + 1; i < len(evs.chunks); i++ {
+		evs.chunks[i] = evs.chunks[i][:0]
+	}
+	evs.n = n
+}
+
+// Last returns a pointer to the last event. The list must not be empty.
+func (evs *Events) Last() *Event {
+	return evs.Ptr(evs.n - 1)
+}
+
+// eventsByTs sorts Events by timestamp.
+type eventsByTs struct{ *Events }
+
+func (l eventsByTs) Less(i, j int) bool {
+	return l.Ptr(i).Ts < l.Ptr(j).Ts
+}
+
+func (l eventsByTs) Swap(i, j int) {
+	a, b := l.Ptr(i), l.Ptr(j)
+	*a, *b = *b, *a
+}
@@ -0,0 +1,46 @@
+package trace
+
+import "testing"
+
+func TestEvents(t *testing.T) {
+	var evs Events
+	const n = eventsChunkSize*2 + eventsChunkSize/2
+	for i := 0; i < n; i++ {
+		evs.Append(Event{Ts: Timestamp(i)})
+	}
+	if evs.Len() != n {
+		t.Fatalf("got %d events, want %d", evs.Len(), n)
+	}
+	for i := 0; i < n; i++ {
+		if ts := evs.Get(i).Ts; ts != Timestamp(i) {
+			t.Fatalf("event %d has timestamp %d", i, ts)
+		}
+	}
+	if ts := evs.Last().Ts; ts != n-1 {
+		t.Fatalf("last event has timestamp %d, want %d", ts, n-1)
+	}
+
+	evs.Truncate(eventsChunkSize + 1)
+	if evs.Len() != eventsChunkSize+1 {
+		t.Fatalf("got %d events after truncating, want %d", evs.Len(), eventsChunkSize+1)
+	}
+	if ev := evs.Grow(); *ev != (Event{}) {
+		t.Fatalf("Grow returned a non-zero event: %v", *ev)
+	}
+	if ts := evs.Get(eventsChunkSize).Ts; ts != eventsChunkSize {
+		t.Fatalf("event %d has timestamp %d after truncating", eventsChunkSize, ts)
+	}
+}
+
+func TestEventLink(t *testing.T) {
+	var ev Event
+	if link := ev.Link(); link != -1 {
+		t.Fatalf("zero event has link %d, want -1", link)
+	}
+	for _, idx := range []int64{0, 1, 1<<31 - 1, 1 << 31, 1 << 32, maxEvents - 1, -1} {
+		ev.SetLink(idx)
+		if link := ev.Link(); link != idx {
+			t.Errorf("got link %d, want %d", link, idx)
+		}
+	}
+}
@@ -50,8 +50,8 @@ const (
 //
 // If the UtilPerProc flag is not given, this always returns a single
 // utilization function. Otherwise, it returns one function per P.
-func MutatorUtilization(events []Event, res Trace, flags UtilFlags) [][]MutatorUtil {
-	if len(events) == 0 {
+func MutatorUtilization(events *Events, res Trace, flags UtilFlags) [][]MutatorUtil {
+	if events.Len() == 0 {
 		return nil
 	}
 
@@ -71,8 +71,8 @@ func MutatorUtilization(events []Event, res Trace, flags UtilFlags) [][]MutatorU
 	block := map[uint64]*Event{}
 	bgMark := map[uint64]bool{}
 
-	for i := range events {
-		ev := &events[i]
+	for i := 0; i < events.Len(); i++ {
+		ev := events.Ptr(i)
 		switch ev.Type {
 		case EvGomaxprocs:
 			gomaxprocs := int(ev.Args[0])
@@ -140,7 +140,7 @@ func MutatorUtilization(events []Event, res Trace, flags UtilFlags) [][]MutatorU
 				// Unblocked during assist.
 				ps[ev.P].gc++
 			}
-			block[ev.G] = &events[ev.Link]
+			block[ev.G] = events.Ptr(int(ev.Link()))
 		default:
 			if ev != block[ev.G] {
 				continue
@@ -195,7 +195,7 @@ func MutatorUtilization(events []Event, res Trace, flags UtilFlags) [][]MutatorU
 	// is important to mark the end of the trace. The exact value
 	// shouldn't matter since no window should extend beyond this,
 	// but using 0 is symmetric with the start of the trace.
-	mu := MutatorUtil{events[len(events)-1].Ts, 0}
+	mu := MutatorUtil{events.Last().Ts, 0}
 	for i := range ps {
 		out[ps[i].series] = addUtil(out[ps[i].series], mu)
 	}
@@ -0,0 +1,64 @@
+package trace
+
+import (
+	"fmt"
+	"io"
+)
+
+// A Diagnostic describes a problem that ParseLenient encountered and recovered from.
+type Diagnostic struct {
+	// The offset in the input at which the problem was found, or -1 if the problem isn't tied to a location in the
+	// input, such as events that are inconsistent with earlier events.
+	Offset int64
+	// The type of the affected event, or EvNone.
+	Type byte
+	// A description of the problem and how it was dealt with.
+	Reason string
+}
+
+func (d Diagnostic) String() string {
+	s := d.Reason
+	if d.Type != EvNone {
+		s = fmt.Sprintf("%s: %s", EventDescriptions[d.Type].Name, s)
+	}
+	if d.Offset >= 0 {
+		s = fmt.Sprintf("offset %d: %s", d.Offset, s)
+	}
+	return s
+}
+
+// maxDiagnostics is the maximum number of diagnostics we record. Badly corrupted traces can have problems with most
+// of their events, and we don't want to use more memory for diagnostics than for the trace itself.
+const maxDiagnostics = 1000
+
+// ParseLenient is like Parse but recovers from corrupted and truncated traces, such as those of processes that were
+// killed while tracing. It returns the part of the trace that could be parsed, and describes the problems it
+// encountered in Trace.Diagnostics. Events that aren't consistent with the rest of the trace are dropped.
+//
+// An error is still returned for inputs that can't be parsed at all, such as inputs with invalid headers.
+func ParseLenient(r io.Reader, progress func(float64)) (Trace, error) {
+	return ParseWithOptions(r, progress, ParseOptions{Lenient: true})
+}
+
+// diagnose records a problem that we've recovered from.
+func (p *Parser) diagnose(off int64, typ byte, format string, args ...any) {
+	if len(p.diagnostics) >= maxDiagnostics {
+		p.omittedDiagnostics++
+		return
+	}
+	if typ >= EvCount {
+		typ = EvNone
+	}
+	p.diagnostics = append(p.diagnostics, Diagnostic{Offset: off, Type: typ, Reason: fmt.Sprintf(format, args...)})
+}
+
+// finishDiagnostics returns the recorded diagnostics.
+func (p *Parser) finishDiagnostics() []Diagnostic {
+	if p.omittedDiagnostics > 0 {
+		p.diagnostics = append(p.diagnostics, Diagnostic{
+			Offset: -1,
+			Reason: fmt.Sprintf("omitted %d more problems", p.omittedDiagnostics),
+		})
+	}
+	return p.diagnostics
+}
@@ -13,6 +13,17 @@ if [ $# != 1 ]; then
     exit 1
 fi
 
+if [ -d "$(go env GOROOT)/src/internal/trace/testdata/testprog" ]; then
+    # Go 1.22 and later use a new trace format and have dedicated programs for producing traces.
+    testprog="$(go env GOROOT)/src/internal/trace/testdata/testprog"
+    go run "$testprog/stress.go" > "testdata/stress_$1_good"
+    go run "$testprog/stress-start-stop.go" > "testdata/stress_start_stop_$1_good"
+    go run "$testprog/annotations.go" > "testdata/user_task_region_$1_good"
+    go run "$testprog/iter-pull.go" > "testdata/iter_pull_$1_good"
+    go run "$testprog/cgo-callback.go" > "testdata/cgo_callback_$1_good"
+    exit 0
+fi
+
 go test -run ClientServerParallel4 -trace "testdata/http_$1_good" net/http
 go test -run 'TraceStress$|TraceStressStartStop$|TestUserTaskSpan$' runtime/trace -savetraces
 mv ../../runtime/trace/TestTraceStress.trace "testdata/stress_$1_good"
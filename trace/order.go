@@ -118,6 +118,59 @@ func transition(gs map[uint64]gState, g uint64, init, next gState) error {
 	return nil
 }
 
+// forceState puts goroutine g into the state that an event expects, for recovering from traces with missing events.
+func forceState(gs map[uint64]gState, g uint64, init gState) {
+	if g == unordered {
+		return
+	}
+	curr := gs[g]
+	if init.seq != noseq {
+		curr.seq = init.seq
+	}
+	curr.status = init.status
+	gs[g] = curr
+}
+
+// mOrder links the events that delimit each M's ownership of Ps. It doesn't reorder events. The parser only orders
+// events per P and per goroutine, which doesn't order the events of an M that moves between Ps. In particular, when a
+// goroutine blocks in a syscall, the P can be retaken and its EvProcStop emitted after the M has already returned from
+// the syscall and started running a different P, which would have the M run two Ps at once.
+//
+// mOrder links each EvProcStart to the event that ends the M's ownership of the P. That is either the P's
+// EvProcStop, or the M's next EvProcStart, if the M started another P before the stop of its previous P was emitted.
+type mOrder struct {
+	// The EvProcStart of the P that each M is running
+	running map[uint64]*Event
+	// The M that each P has last been started by
+	ms map[int32]uint64
+}
+
+func (o *mOrder) procStart(ev *Event, idx int) {
+	if o.running == nil {
+		o.running = make(map[uint64]*Event)
+		o.ms = make(map[int32]uint64)
+	}
+	m := ev.Args[ArgProcStartThread]
+	if prev := o.running[m]; prev != nil {
+		// The M's previous P has been handed off.
+		prev.SetLink(int64(idx))
+	}
+	o.running[m] = ev
+	o.ms[ev.P] = m
+}
+
+func (o *mOrder) procStop(ev *Event, idx int) {
+	m, ok := o.ms[ev.P]
+	if !ok {
+		return
+	}
+	delete(o.ms, ev.P)
+	if start := o.running[m]; start != nil && start.P == ev.P {
+		start.SetLink(int64(idx))
+		delete(o.running, m)
+	}
+}
+
 type orderEventList []orderEvent
 
 func (l *orderEventList) Less(i, j int) bool {
@@ -0,0 +1,43 @@
+package trace
+
+import "testing"
+
+func TestMOrder(t *testing.T) {
+	// M 1 blocks in a syscall on P 0. P 0 gets retaken, but M 1 returns from the syscall and starts P 1 before P 0's
+	// stop has been emitted. M 2 then starts P 0.
+	var events Events
+	for _, ev := range []Event{
+		{Ts: 10, P: 0, Type: EvProcStart, Args: [4]uint64{1}},
+		{Ts: 20, P: 1, Type: EvProcStart, Args: [4]uint64{1}},
+		{Ts: 30, P: 0, Type: EvProcStop},
+		{Ts: 40, P: 0, Type: EvProcStart, Args: [4]uint64{2}},
+		{Ts: 50, P: 1, Type: EvProcStop},
+		{Ts: 60, P: 0, Type: EvProcStop},
+		{Ts: 70, P: 0, Type: EvProcStart, Args: [4]uint64{1}},
+	} {
+		events.Append(ev)
+	}
+
+	var o mOrder
+	for i := 0; i < events.Len(); i++ {
+		switch ev := events.Ptr(i); ev.Type {
+		case EvProcStart:
+			o.procStart(ev, i)
+		case EvProcStop:
+			o.procStop(ev, i)
+		}
+	}
+
+	want := []int64{
+		// M 1's ownership of P 0 ends when it starts P 1.
+		0: 1,
+		1: 4,
+		3: 5,
+		6: -1,
+	}
+	for i, link := range want {
+		if ev := events.Ptr(i); ev.Type == EvProcStart && ev.Link() != link {
+			t.Errorf("event %d: got link %d, want %d", i, ev.Link(), link)
+		}
+	}
+}
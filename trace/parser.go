@@ -2,6 +2,7 @@
 package trace
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
@@ -10,8 +11,6 @@ import (
 	"sort"
 )
 
-var ErrTooManyEvents = fmt.Errorf("trace contains more than %d events", math.MaxInt32)
-
 type Timestamp int64
 
 // Event describes one event in the trace.
@@ -19,18 +18,22 @@ type Event struct {
 	// The Event type is carefully laid out to optimize its size and to avoid pointers, the latter so that the garbage
 	// collector won't have to scan any memory of our millions of events.
 	//
-	// Instead of pointers, fields like StkID and Link are indices into slices.
+	// Instead of pointers, fields like StkID and the linked event are indices into slices. The index of the linked
+	// event is stored in 40 bits, split across linkLo and linkHi, which is enough for a trillion events and keeps the
+	// Event type at 64 bytes.
 
 	Ts    Timestamp // timestamp in nanoseconds
 	G     uint64    // G on which the event happened
 	Args  [4]uint64 // event-type-specific arguments
 	StkID uint32    // unique stack ID
 	P     int32     // P on which the event happened (can be one of TimerP, NetpollP, SyscallP)
-	// linked event (can be nil), depends on event type:
+	// linked event (see Link), depends on event type:
 	// for GCStart: the GCStop
 	// for GCSTWStart: the GCSTWDone
 	// for GCSweepStart: the GCSweepDone
 	// for GoCreate: first GoStart, GoWaiting, or GoInSyscall of the created goroutine
+	// for ProcStart: the event that ends the M's ownership of the P; the ProcStop, or the M's next ProcStart if the P was
+	// handed off before it stopped. Events aren't reordered, and the ProcStop may come later.
 	// for GoStart/GoStartLabel: the associated GoEnd, GoBlock or other blocking event
 	// for GoSched/GoPreempt: the next GoStart
 	// for GoBlock and other blocking events: the unblock event
@@ -40,8 +43,27 @@ type Event struct {
 	// for GCMarkAssistStart: the associated GCMarkAssistDone
 	// for UserTaskCreate: the UserTaskEnd
 	// for UserRegion: if the start region, the corresponding UserRegion end event
-	Link int32
-	Type byte // one of Ev*
+	//
+	// The index of the linked event plus one, so that the zero value means that there is no linked event.
+	linkLo uint32
+	linkHi uint8
+	Type   byte // one of Ev*
+}
+
+// Link returns the index of the linked event in Trace.Events, or -1 if there is no linked event.
+//
+//gcassert:inline
+func (ev *Event) Link() int64 {
+	return (int64(ev.linkHi)<<32 | int64(ev.linkLo)) - 1
+}
+
+// SetLink sets the index of the linked event. An index of -1 removes the link.
+//
+//gcassert:inline
+func (ev *Event) SetLink(idx int64) {
+	v := idx + 1
+	ev.linkLo = uint32(v)
+	ev.linkHi = uint8(v >> 32)
 }
 
 // Frame is a frame in stack traces.
@@ -68,7 +90,7 @@ type Trace struct {
 	Version int
 
 	// Events is the sorted list of Events in the trace.
-	Events []Event
+	Events Events
 	// Stacks is the stack traces keyed by stack IDs from the trace.
 	//
 	// OPT(dh): we could renumber stacks, PCs and Strings densely and store them in slices instead of maps. I don't know
@@ -76,6 +98,15 @@ type Trace struct {
 	Stacks  map[uint32][]uint64
 	PCs     map[uint64]Frame
 	Strings map[uint64]string
+	// Start is the time of the first event, in nanoseconds on the runtime's clock. The timestamps of events are
+	// relative to it. Traces that were recorded by the same process can be put in order by comparing their starts.
+	Start Timestamp
+	// Diagnostics describes the problems that ParseLenient recovered from and the PCs that Symbolize couldn't resolve.
+	// It is always empty for traces returned by Parse.
+	Diagnostics []Diagnostic
+	// TimestampRepair describes the timestamps that were repaired, if timestamp repair was requested with
+	// ParseWithOptions.
+	TimestampRepair TimestampRepair
 }
 
 type batch struct {
@@ -98,10 +129,32 @@ type pState struct {
 type Parser struct {
 	progress func(p float64)
 
-	ver  int
+	// Whether to recover from errors, and the problems we've recovered from.
+	lenient            bool
+	diagnostics        []Diagnostic
+	omittedDiagnostics int
+	// Whether to repair timestamps that are out of order, and the repairs we've made.
+	repair  bool
+	repairs TimestampRepair
+	// Whether we've stopped reading the input because of an error.
+	stopped bool
+
+	// The input, and its size if known, or -1. input counts the number of bytes read from the input so far.
+	r     *bufio.Reader
+	size  int64
+	input CountingReader
+	// The input, if it can report its own progress.
+	pr ProgressReader
+
+	ver int
+	// The entire input, for traces in the legacy format.
 	data []byte
 	off  int
 
+	// Events that have been parsed but not yet returned by Next.
+	pending    Events
+	pendingIdx int
+
 	bigArgsBuf []byte
 
 	strings map[uint64]string
@@ -111,8 +164,16 @@ type Parser struct {
 	stacks      map[uint32][]uint64
 	stacksData  []uint64
 	ticksPerSec int64
-	pcs         map[uint64]Frame
-	cpuSamples  []Event
+	// The time of the first event, which timestamps are relative to.
+	startTs    Timestamp
+	pcs        map[uint64]Frame
+	cpuSamples []Event
+
+	// state for the generation-based format, which has per-generation string and stack IDs
+	stringIDs map[string]uint64
+	stackIDs  map[string]uint32
+	order     *v2Ordering
+	spill     *v2Spill
 
 	// state for indexing
 	curP int32
@@ -151,8 +212,9 @@ func (p *Parser) discard(n uint64) bool {
 	return true
 }
 
+// NewParser returns a parser for the trace read from r. Data is read from r as it is needed.
 func NewParser(r io.Reader) (*Parser, error) {
-	var buf []byte
+	size := int64(-1)
 	if seeker, ok := r.(io.Seeker); ok {
 		cur, err := seeker.Seek(0, io.SeekCurrent)
 		if err != nil {
@@ -166,20 +228,13 @@ func NewParser(r io.Reader) (*Parser, error) {
 		if err != nil {
 			return nil, err
 		}
-
-		buf = make([]byte, end-cur)
-		_, err = io.ReadFull(r, buf)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		var err error
-		buf, err = io.ReadAll(r)
-		if err != nil {
-			return nil, err
-		}
+		size = end - cur
 	}
-	return &Parser{data: buf}, nil
+	p := &Parser{size: size}
+	p.pr, _ = r.(ProgressReader)
+	p.input.R = r
+	p.r = bufio.NewReader(&p.input)
+	return p, nil
 }
 
 func Parse(r io.Reader, progress func(float64)) (Trace, error) {
@@ -191,51 +246,216 @@ func Parse(r io.Reader, progress func(float64)) (Trace, error) {
 	return p.Parse()
 }
 
+// ParseOptions configures ParseWithOptions.
+type ParseOptions struct {
+	// Lenient makes the parser recover from corrupted and truncated traces, as described for ParseLenient.
+	Lenient bool
+	// RepairTimestamps makes the parser repair timestamps that are inconsistent with the order of events, instead of
+	// failing with ErrTimeOrder. Trace.TimestampRepair describes the changes. This only affects traces produced by Go
+	// 1.21 and older, as the timestamps of newer traces are always made consistent while parsing.
+	RepairTimestamps bool
+}
+
+// ParseWithOptions is like Parse but allows configuring the parser.
+func ParseWithOptions(r io.Reader, progress func(float64), opts ParseOptions) (Trace, error) {
+	p, err := NewParser(r)
+	if err != nil {
+		return Trace{}, err
+	}
+	p.progress = progress
+	p.lenient = opts.Lenient
+	p.repair = opts.RepairTimestamps
+	return p.Parse()
+}
+
+// Parse parses the entire trace. It must not be combined with calls to Next.
 func (p *Parser) Parse() (Trace, error) {
 	res, err := p.parse()
 	p.data = nil
 	return res, err
 }
 
-// parse parses, post-processes and verifies the trace.
-func (p *Parser) parse() (Trace, error) {
+// Version returns the version of the trace, reading the trace's header if it hasn't been read yet.
+func (p *Parser) Version() (int, error) {
+	if p.ver == 0 {
+		if err := p.start(); err != nil {
+			return 0, err
+		}
+	}
+	return p.ver, nil
+}
+
+// Next returns the next event in the trace. It returns io.EOF after the last event.
+//
+// Events are returned in the same order and with the same timestamps as by Parse, but they aren't post-processed: their
+// Link fields aren't set, GC events and unblocks by the network poller aren't moved to fake Ps, and the first EvGoStart
+// of each goroutine doesn't carry the goroutine's creation stack. The stacks, strings and frames that events refer to
+// can be looked up with Stack, String and PC once the events have been returned.
+//
+// Traces produced by Go 1.22 and newer are parsed incrementally, one generation at a time, and memory usage is
+// bounded by the size of a generation and the number of unique stacks and strings. Older traces have to be parsed in
+// their entirety before the first event can be returned.
+func (p *Parser) Next() (Event, error) {
+	if _, err := p.Version(); err != nil {
+		return Event{}, err
+	}
+	for p.pendingIdx == p.pending.Len() {
+		if err := p.refill(); err != nil {
+			return Event{}, err
+		}
+	}
+	ev := p.pending.Get(p.pendingIdx)
+	p.pendingIdx++
+	return ev, nil
+}
+
+// Stack returns the PCs of the stack with the given ID.
+func (p *Parser) Stack(id uint32) []uint64 { return p.stacks[id] }
+
+// String returns the string with the given ID.
+func (p *Parser) String(id uint64) string { return p.strings[id] }
+
+// PC returns the frame of the given PC.
+func (p *Parser) PC(pc uint64) Frame { return p.pcs[pc] }
+
+// start reads the trace header and prepares the parser for parsing the trace's version.
+func (p *Parser) start() error {
 	p.strings = make(map[uint64]string)
 	p.pStates = make(map[int32]*pState)
 	p.stacks = make(map[uint32][]uint64)
 	p.pcs = make(map[uint64]Frame)
+	if p.progress == nil {
+		p.progress = func(p float64) {}
+	}
 
 	ver, err := p.readHeader()
 	if err != nil {
-		return Trace{}, err
+		return err
 	}
 
+	if ver >= 1022 {
+		p.startGenerations()
+	} else {
+		// The legacy format doesn't order events in the file in any meaningful way and stores stacks and the
+		// frequency at the end, so we have no choice but to read all of it. Reading the input is the first half of
+		// the first stage of parsing.
+		p.data, err = p.readAll(func(r float64) { p.progress((1.0 / 6.0) * r) })
+		if err != nil {
+			return err
+		}
+		p.off = headerLength
+	}
 	p.ver = ver
+	return nil
+}
 
-	if p.progress == nil {
-		p.progress = func(p float64) {}
+// refill replaces the events returned by Next.
+func (p *Parser) refill() error {
+	p.pendingIdx = 0
+	p.pending.Truncate(0)
+	if p.ver >= 1022 {
+		return p.nextGeneration(&p.pending)
 	}
-	progress := func(r float64) { p.progress((1.0 / 3.0) * r) }
-	if err := p.indexAndPartiallyParse(progress); err != nil {
+
+	if p.data == nil {
+		return io.EOF
+	}
+	err := p.parseLegacy(&p.pending)
+	p.data = nil
+	return err
+}
+
+// parse parses, post-processes and verifies the trace.
+func (p *Parser) parse() (Trace, error) {
+	ver, err := p.Version()
+	if err != nil {
 		return Trace{}, err
 	}
 
-	progress = func(r float64) { p.progress(1.0/3.0 + (1.0/3.0)*r) }
-	events, err := p.parseRest(progress)
+	var events Events
+	if ver >= 1022 {
+		for {
+			if frac, ok := p.inputProgress(); ok {
+				p.progress((2.0 / 3.0) * frac)
+			}
+			err = p.nextGeneration(&events)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				if !p.lenient {
+					return Trace{}, err
+				}
+				// We can't know where the next intact generation starts, so we stop at the first broken one.
+				p.diagnose(p.input.N-int64(p.r.Buffered()), EvNone, "dropped the rest of the trace: %s", err)
+				break
+			}
+			if int64(events.Len()) > maxEvents {
+				return Trace{}, ErrTooManyEvents
+			}
+		}
+	} else {
+		err = p.parseLegacy(&events)
+		if err != nil {
+			return Trace{}, err
+		}
+	}
+
+	progress := func(r float64) { p.progress(2.0/3.0 + (1.0/3.0)*r) }
+	err = p.postProcessTrace(&events, progress)
 	if err != nil {
 		return Trace{}, err
 	}
 
+	res := Trace{
+		Version:     ver,
+		Events:      events,
+		Stacks:      p.stacks,
+		Strings:     p.strings,
+		PCs:         p.pcs,
+		Start:       p.startTs,
+		Diagnostics: p.finishDiagnostics(),
+
+		TimestampRepair: p.repairs,
+	}
+	return res, nil
+}
+
+// parseLegacy parses traces in the format used before Go 1.22 and appends their events to events, which must be empty.
+func (p *Parser) parseLegacy(events *Events) error {
+	progress := func(r float64) { p.progress(1.0/6.0 + (1.0/6.0)*r) }
+	if err := p.indexAndPartiallyParse(progress); err != nil {
+		return err
+	}
+
+	progress = func(r float64) { p.progress(1.0/3.0 + (1.0/3.0)*r) }
+	if err := p.parseRest(events, progress); err != nil {
+		return err
+	}
+
 	if p.ticksPerSec == 0 {
-		return Trace{}, errors.New("no EvFrequency event")
+		if !p.lenient {
+			return errors.New("no EvFrequency event")
+		}
+		// The frequency is written at the very end of the trace. Most platforms use CPU ticks that are close enough
+		// to nanoseconds.
+		p.diagnose(-1, EvFrequency, "no EvFrequency event, assuming timestamps are in nanoseconds")
+		p.ticksPerSec = 1e9
 	}
 
-	if len(events) > 0 {
+	if events.Len() > 0 {
 		// Translate cpu ticks to real time.
-		minTs := events[0].Ts
+		minTs := events.Ptr(0).Ts
 		// Use floating point to avoid integer overflows.
 		freq := 1e9 / float64(p.ticksPerSec)
-		for i := range events {
-			ev := &events[i]
+		p.startTs = Timestamp(float64(minTs) * freq)
+		p.repairs.Total = Timestamp(float64(p.repairs.Total) * freq)
+		p.repairs.Max = Timestamp(float64(p.repairs.Max) * freq)
+		if p.repairs.Events > 0 && p.lenient {
+			p.diagnose(-1, EvNone, "moved the timestamps of %d events forward by up to %d ns to restore the order of events",
+				p.repairs.Events, p.repairs.Max)
+		}
+		for i := 0; i < events.Len(); i++ {
+			ev := events.Ptr(i)
 			ev.Ts = Timestamp(float64(ev.Ts-minTs) * freq)
 			// Move syscalls to separate fake Ps.
 			if ev.Type == EvGoSysExit {
@@ -243,20 +463,63 @@ func (p *Parser) parse() (Trace, error) {
 			}
 		}
 	}
+	return nil
+}
 
-	progress = func(r float64) { p.progress(2.0/3.0 + (1.0/3.0)*r) }
-	if err := p.postProcessTrace(events, progress); err != nil {
-		return Trace{}, err
+// A ProgressReader is a reader that knows how much of its input it has consumed. Parsers use it to report progress
+// when the number of bytes read doesn't relate to the size of the input, such as when decompressing the input.
+type ProgressReader interface {
+	io.Reader
+	// Progress returns the fraction of the input that has been consumed, between 0 and 1.
+	Progress() float64
+}
+
+// inputProgress returns the fraction of the input that has been read, if known.
+func (p *Parser) inputProgress() (float64, bool) {
+	if p.pr != nil {
+		return p.pr.Progress(), true
+	}
+	if p.size > 0 {
+		return float64(p.input.N) / float64(p.size), true
 	}
+	return 0, false
+}
 
-	res := Trace{
-		Version: ver,
-		Events:  events,
-		Stacks:  p.stacks,
-		Strings: p.strings,
-		PCs:     p.pcs,
+// A CountingReader counts the number of bytes read from R, for reporting progress.
+type CountingReader struct {
+	R io.Reader
+	// The number of bytes read so far
+	N int64
+}
+
+func (r *CountingReader) Read(b []byte) (int, error) {
+	n, err := r.R.Read(b)
+	r.N += int64(n)
+	return n, err
+}
+
+// readAll reads the rest of the input, reporting the fraction of the input that has been read, if known.
+func (p *Parser) readAll(progress func(float64)) ([]byte, error) {
+	var buf []byte
+	if p.size >= 0 {
+		buf = make([]byte, 0, p.size)
+	}
+	for {
+		if len(buf) == cap(buf) {
+			buf = append(buf, 0)[:len(buf)]
+		}
+		// Read in chunks so that we can report progress even when we know the size of the input.
+		n, err := p.r.Read(buf[len(buf):min(cap(buf), len(buf)+1<<20)])
+		buf = buf[:len(buf)+n]
+		if frac, ok := p.inputProgress(); ok {
+			progress(frac)
+		}
+		if err == io.EOF {
+			return buf, nil
+		} else if err != nil {
+			return nil, err
+		}
 	}
-	return res, nil
 }
 
 // rawEvent is a helper type used during parsing.
@@ -267,17 +530,19 @@ type rawEvent struct {
 }
 
 func (p *Parser) readHeader() (ver int, err error) {
-	// Read and validate trace header.
-	if len(p.data) < headerLength {
+	// Read and validate trace header. We only peek at it because the legacy parser expects to see the whole file.
+	hdr, err := p.r.Peek(headerLength)
+	if err == io.EOF {
 		return 0, errors.New("trace too short")
+	} else if err != nil {
+		return 0, err
 	}
-	ver, err = parseHeader(p.data[:headerLength])
+	ver, err = parseHeader(hdr)
 	if err != nil {
 		return 0, err
 	}
-	p.off += headerLength
 	switch ver {
-	case 1011, 1019, 1021:
+	case 1011, 1019, 1021, 1022, 1023, 1025, 1026:
 		// Note: When adding a new version, add canned traces
 		// from the old version to the test suite using mkcanned.bash.
 	default:
@@ -304,7 +569,9 @@ type proc struct {
 // event with the lowest timestamp from the subset, merge it and repeat.
 // This approach ensures that we form a consistent stream even if timestamps are
 // incorrect (condition observed on some machines).
-func (p *Parser) parseRest(progress func(float64)) ([]Event, error) {
+//
+// The merged events are appended to events, which must be empty.
+func (p *Parser) parseRest(events *Events, progress func(float64)) error {
 	// The ordering of CPU profile sample events in the data stream is based on
 	// when each run of the signal handler was able to acquire the spinlock,
 	// with original timestamps corresponding to when ReadTrace pulled the data
@@ -321,15 +588,15 @@ func (p *Parser) parseRest(progress func(float64)) ([]Event, error) {
 			totalEvents += uint64(b.numEvents)
 		}
 	}
+	// Process Ps in a fixed order so that events with identical timestamps are always merged in the same order.
+	sort.Slice(allProcs, func(i, j int) bool { return allProcs[i].pid < allProcs[j].pid })
 	allProcs = append(allProcs, proc{pid: ProfileP, events: p.cpuSamples})
 	totalEvents += uint64(len(p.cpuSamples))
 
-	if totalEvents > math.MaxInt32 {
-		return nil, ErrTooManyEvents
+	if totalEvents > maxEvents {
+		return ErrTooManyEvents
 	}
 
-	events := make([]Event, 0, totalEvents)
-
 	// Merge events as long as at least one P has more events
 	gs := make(map[uint64]gState)
 	// Note: technically we don't need a priority queue here. We're only ever interested in the earliest elligible
@@ -344,8 +611,8 @@ func (p *Parser) parseRest(progress func(float64)) ([]Event, error) {
 		availableProcs[i] = &allProcs[i]
 	}
 	for {
-		if progress != nil && len(events)%100_000 == 0 {
-			progress((float64(len(events)+1) / float64(cap(events))))
+		if progress != nil && events.Len()%100_000 == 0 {
+			progress((float64(events.Len()+1) / float64(totalEvents)))
 		}
 	pidLoop:
 		for i := 0; i < len(availableProcs); i++ {
@@ -354,6 +621,11 @@ func (p *Parser) parseRest(progress func(float64)) ([]Event, error) {
 			for len(proc.events) == 0 {
 				// Call loadBatch in a loop because sometimes batches are empty
 				evs, err := p.loadBatch(proc.pid)
+				if err != nil && err != io.EOF && p.lenient {
+					p.diagnose(int64(p.off), EvNone, "dropped the remaining events of P %d: %s", proc.pid, err)
+					p.pState(proc.pid).batches = nil
+					err = io.EOF
+				}
 				if err == io.EOF {
 					// This P has no more events
 					proc.done = true
@@ -363,7 +635,7 @@ func (p *Parser) parseRest(progress func(float64)) ([]Event, error) {
 					i--
 					continue pidLoop
 				} else if err != nil {
-					return nil, err
+					return err
 				} else {
 					proc.events = evs
 				}
@@ -391,12 +663,25 @@ func (p *Parser) parseRest(progress func(float64)) ([]Event, error) {
 		}
 
 		if len(frontier) == 0 {
-			for i := range allProcs {
-				if !allProcs[i].done {
-					return nil, fmt.Errorf("no consistent ordering of events possible")
+			if len(availableProcs) == 0 {
+				break
+			}
+			if !p.lenient {
+				return fmt.Errorf("no consistent ordering of events possible")
+			}
+			// Events are missing, most likely because they were in a batch that we had to drop. Force the goroutine
+			// of the earliest pending event into the state the event expects, and carry on.
+			earliest := availableProcs[0]
+			for _, proc := range availableProcs[1:] {
+				if proc.events[0].Ts < earliest.events[0].Ts {
+					earliest = proc
 				}
 			}
-			break
+			ev := &earliest.events[0]
+			g, init, _ := stateTransition(ev)
+			p.diagnose(-1, ev.Type, "no consistent ordering of events possible, forcing goroutine %d into the expected state (time %d)", g, ev.Ts)
+			forceState(gs, g, init)
+			continue
 		}
 		f := frontier.Pop()
 
@@ -414,10 +699,15 @@ func (p *Parser) parseRest(progress func(float64)) ([]Event, error) {
 		case EvGoSysExitLocal:
 			f.ev.Type = EvGoSysExit
 		}
-		events = append(events, f.ev)
+		events.Append(f.ev)
 
 		if err := transition(gs, g, init, next); err != nil {
-			return nil, err
+			if !p.lenient {
+				return err
+			}
+			p.diagnose(-1, f.ev.Type, "%s, forcing goroutine %d into the expected state (time %d)", err, g, f.ev.Ts)
+			forceState(gs, g, init)
+			transition(gs, g, init, next)
 		}
 		availableProcs = append(availableProcs, f.proc)
 	}
@@ -425,8 +715,21 @@ func (p *Parser) parseRest(progress func(float64)) ([]Event, error) {
 	// At this point we have a consistent stream of events.
 	// Make sure time stamps respect the ordering.
 	// The tests will skip (not fail) the test case if they see this error.
-	if !sort.IsSorted((*eventList)(&events)) {
-		return nil, ErrTimeOrder
+	if p.repair {
+		// The events get sorted by their repaired timestamps below.
+		p.repairs = repairTimestamps(events)
+	} else if !sort.IsSorted(eventsByTs{events}) {
+		if !p.lenient {
+			return ErrTimeOrder
+		}
+		// Clamp timestamps instead of reordering events, to preserve the order required by goroutine states.
+		for i := 1; i < events.Len(); i++ {
+			ev := events.Ptr(i)
+			if prev := events.Ptr(i - 1).Ts; ev.Ts < prev {
+				p.diagnose(-1, ev.Type, "timestamp %d is earlier than that of the previous event, using %d instead", ev.Ts, prev)
+				ev.Ts = prev
+			}
+		}
 	}
 
 	// The last part is giving correct timestamps to EvGoSysExit events.
@@ -439,7 +742,8 @@ func (p *Parser) parseRest(progress func(float64)) ([]Event, error) {
 	// if timestamps are broken we will misplace the event and later report
 	// logically broken trace (instead of reporting broken timestamps).
 	lastSysBlock := make(map[uint64]Timestamp)
-	for _, ev := range events {
+	for i := 0; i < events.Len(); i++ {
+		ev := events.Get(i)
 		switch ev.Type {
 		case EvGoSysBlock, EvGoInSyscall:
 			lastSysBlock[ev.G] = ev.Ts
@@ -450,17 +754,31 @@ func (p *Parser) parseRest(progress func(float64)) ([]Event, error) {
 			}
 			block := lastSysBlock[ev.G]
 			if block == 0 {
-				return nil, fmt.Errorf("stray syscall exit")
+				if !p.lenient {
+					return fmt.Errorf("stray syscall exit")
+				}
+				p.diagnose(-1, ev.Type, "stray syscall exit of goroutine %d (time %d)", ev.G, ev.Ts)
+				continue
 			}
-			if ts < block {
-				return nil, ErrTimeOrder
+			if ts < block && p.repair {
+				// The syscall can't have exited before it blocked.
+				p.repairs.Events++
+				p.repairs.Total += block - ts
+				p.repairs.Max = max(p.repairs.Max, block-ts)
+				ts = block
+			} else if ts < block {
+				if !p.lenient {
+					return ErrTimeOrder
+				}
+				p.diagnose(-1, ev.Type, "syscall of goroutine %d exited before it blocked (time %d)", ev.G, ev.Ts)
+				continue
 			}
 			ev.Ts = ts
 		}
 	}
-	sort.Stable((*eventList)(&events))
+	sort.Stable(eventsByTs{events})
 
-	return events, nil
+	return nil
 }
 
 // indexAndPartiallyParse records the offsets of batches and parses strings and CPU samples.
@@ -471,12 +789,17 @@ func (p *Parser) indexAndPartiallyParse(progress func(float64)) error {
 		if n%1_000_000 == 0 {
 			progress((float64(p.off+1) / float64(len(p.data))))
 		}
+		off := p.off
 		err := p.readRawEvent(skipArgs|skipStrings|trackBatches, &raw)
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return err
+			if !p.lenient {
+				return err
+			}
+			p.truncate(off, err)
+			break
 		}
 		if raw.typ == EvNone {
 			continue
@@ -488,7 +811,12 @@ func (p *Parser) indexAndPartiallyParse(progress func(float64)) error {
 			argOffset := 1
 			narg := argNum(&raw)
 			if len(raw.args) != narg {
-				return fmt.Errorf("CPU sample has wrong number of arguments: want %d, got %d", narg, len(raw.args))
+				err := fmt.Errorf("CPU sample has wrong number of arguments: want %d, got %d", narg, len(raw.args))
+				if !p.lenient {
+					return err
+				}
+				p.truncate(off, err)
+				break
 			}
 			for i := argOffset; i < narg; i++ {
 				if i == narg-1 {
@@ -524,6 +852,13 @@ func (p *Parser) indexAndPartiallyParse(progress func(float64)) error {
 	return nil
 }
 
+// truncate drops all data starting at the event at offset off, which couldn't be parsed. The legacy format doesn't let
+// us resynchronize after a corrupted event.
+func (p *Parser) truncate(off int, err error) {
+	p.diagnose(int64(off), p.data[off]<<2>>2, "dropped the rest of the trace: %s", err)
+	p.data = p.data[:off]
+}
+
 const (
 	skipArgs = 1 << iota
 	skipStrings
@@ -920,7 +1255,7 @@ func (p *Parser) parseEvent(raw *rawEvent, ev *Event) error {
 	case EvCPUSample:
 		// These events get parsed during the indexing step and don't strictly belong to the batch.
 	default:
-		*ev = Event{Type: raw.typ, P: p.lastP, G: p.lastG, Link: -1}
+		*ev = Event{Type: raw.typ, P: p.lastP, G: p.lastG}
 		var argOffset int
 		ev.Ts = p.lastTs + Timestamp(raw.args[0])
 		argOffset = 1
@@ -974,7 +1309,7 @@ var ErrTimeOrder = errors.New("time stamps out of order")
 // The resulting trace is guaranteed to be consistent
 // (for example, a P does not run two Gs at the same time, or a G is indeed
 // blocked before an unblock event).
-func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error {
+func (p *Parser) postProcessTrace(events *Events, progress func(float64)) error {
 	const (
 		gDead = iota
 		gRunnable
@@ -1000,6 +1335,7 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 	activeRegions := make(map[uint64][]*Event) // goroutine id to stack of regions
 	gs[0] = gdesc{state: gRunning}
 	var evGC, evSTW *Event
+	var ms mOrder
 
 	checkRunning := func(p pdesc, g gdesc, ev *Event, allowG0 bool) error {
 		name := EventDescriptions[ev.Type].Name
@@ -1015,8 +1351,10 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		return nil
 	}
 
-	for evIdx := range events {
-		ev := &events[evIdx]
+	var err error
+	dropped := 0
+	for evIdx := 0; evIdx < events.Len(); evIdx++ {
+		ev := events.Ptr(evIdx)
 
 		// Note: each branch is responsible for retrieving P and G descriptions and writing back modifications to the
 		// maps. Deduplicating this step and pulling it outside the switch is too expensive.
@@ -1024,52 +1362,62 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		case EvProcStart:
 			p := ps[ev.P]
 			if p.running {
-				return fmt.Errorf("p %d is running before start (time %d)", ev.P, ev.Ts)
+				err = fmt.Errorf("p %d is running before start (time %d)", ev.P, ev.Ts)
+				break
 			}
 			p.running = true
+			ms.procStart(ev, evIdx)
 
 			ps[ev.P] = p
 		case EvProcStop:
 			p := ps[ev.P]
 			if !p.running {
-				return fmt.Errorf("p %d is not running before stop (time %d)", ev.P, ev.Ts)
+				err = fmt.Errorf("p %d is not running before stop (time %d)", ev.P, ev.Ts)
+				break
 			}
 			if p.g != 0 {
-				return fmt.Errorf("p %d is running a goroutine %d during stop (time %d)", ev.P, p.g, ev.Ts)
+				err = fmt.Errorf("p %d is running a goroutine %d during stop (time %d)", ev.P, p.g, ev.Ts)
+				break
 			}
 			p.running = false
+			ms.procStop(ev, evIdx)
 
 			ps[ev.P] = p
 		case EvGCStart:
 			if evGC != nil {
-				return fmt.Errorf("previous GC is not ended before a new one (time %d)", ev.Ts)
+				err = fmt.Errorf("previous GC is not ended before a new one (time %d)", ev.Ts)
+				break
 			}
 			evGC = ev
 			// Attribute this to the global GC state.
 			ev.P = GCP
 		case EvGCDone:
 			if evGC == nil {
-				return fmt.Errorf("bogus GC end (time %d)", ev.Ts)
+				err = fmt.Errorf("bogus GC end (time %d)", ev.Ts)
+				break
 			}
-			evGC.Link = int32(evIdx)
+			evGC.SetLink(int64(evIdx))
 			evGC = nil
 		case EvSTWStart:
 			evp := &evSTW
 			if *evp != nil {
-				return fmt.Errorf("previous STW is not ended before a new one (time %d)", ev.Ts)
+				err = fmt.Errorf("previous STW is not ended before a new one (time %d)", ev.Ts)
+				break
 			}
 			*evp = ev
 		case EvSTWDone:
 			evp := &evSTW
 			if *evp == nil {
-				return fmt.Errorf("bogus STW end (time %d)", ev.Ts)
+				err = fmt.Errorf("bogus STW end (time %d)", ev.Ts)
+				break
 			}
-			(*evp).Link = int32(evIdx)
+			(*evp).SetLink(int64(evIdx))
 			*evp = nil
 		case EvGCSweepStart:
 			p := ps[ev.P]
 			if p.evSweep != nil {
-				return fmt.Errorf("previous sweeping is not ended before a new one (time %d)", ev.Ts)
+				err = fmt.Errorf("previous sweeping is not ended before a new one (time %d)", ev.Ts)
+				break
 			}
 			p.evSweep = ev
 
@@ -1077,7 +1425,8 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		case EvGCMarkAssistStart:
 			g := gs[ev.G]
 			if g.evMarkAssist != nil {
-				return fmt.Errorf("previous mark assist is not ended before a new one (time %d)", ev.Ts)
+				err = fmt.Errorf("previous mark assist is not ended before a new one (time %d)", ev.Ts)
+				break
 			}
 			g.evMarkAssist = ev
 
@@ -1087,7 +1436,7 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 			// goroutine starts tracing, so we can't report an error here.
 			g := gs[ev.G]
 			if g.evMarkAssist != nil {
-				g.evMarkAssist.Link = int32(evIdx)
+				g.evMarkAssist.SetLink(int64(evIdx))
 				g.evMarkAssist = nil
 			}
 
@@ -1095,19 +1444,21 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		case EvGCSweepDone:
 			p := ps[ev.P]
 			if p.evSweep == nil {
-				return fmt.Errorf("bogus sweeping end (time %d)", ev.Ts)
+				err = fmt.Errorf("bogus sweeping end (time %d)", ev.Ts)
+				break
 			}
-			p.evSweep.Link = int32(evIdx)
+			p.evSweep.SetLink(int64(evIdx))
 			p.evSweep = nil
 
 			ps[ev.P] = p
 		case EvGoWaiting:
 			g := gs[ev.G]
 			if g.state != gRunnable {
-				return fmt.Errorf("g %d is not runnable before EvGoWaiting (time %d)", ev.G, ev.Ts)
+				err = fmt.Errorf("g %d is not runnable before EvGoWaiting (time %d)", ev.G, ev.Ts)
+				break
 			}
 			if g.ev != nil {
-				g.ev.Link = int32(evIdx)
+				g.ev.SetLink(int64(evIdx))
 			}
 			g.state = gWaiting
 			g.ev = ev
@@ -1116,10 +1467,11 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		case EvGoInSyscall:
 			g := gs[ev.G]
 			if g.state != gRunnable {
-				return fmt.Errorf("g %d is not runnable before EvGoInSyscall (time %d)", ev.G, ev.Ts)
+				err = fmt.Errorf("g %d is not runnable before EvGoInSyscall (time %d)", ev.G, ev.Ts)
+				break
 			}
 			if g.ev != nil {
-				g.ev.Link = int32(evIdx)
+				g.ev.SetLink(int64(evIdx))
 			}
 			g.state = gWaiting
 			g.ev = ev
@@ -1128,11 +1480,12 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		case EvGoCreate:
 			g := gs[ev.G]
 			p := ps[ev.P]
-			if err := checkRunning(p, g, ev, true); err != nil {
-				return err
+			if err = checkRunning(p, g, ev, true); err != nil {
+				break
 			}
 			if _, ok := gs[ev.Args[0]]; ok {
-				return fmt.Errorf("g %d already exists (time %d)", ev.Args[0], ev.Ts)
+				err = fmt.Errorf("g %d already exists (time %d)", ev.Args[0], ev.Ts)
+				break
 			}
 			gs[ev.Args[0]] = gdesc{state: gRunnable, ev: ev, evCreate: ev}
 
@@ -1140,10 +1493,12 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 			g := gs[ev.G]
 			p := ps[ev.P]
 			if g.state != gRunnable {
-				return fmt.Errorf("g %d is not runnable before start (time %d)", ev.G, ev.Ts)
+				err = fmt.Errorf("g %d is not runnable before start (time %d)", ev.G, ev.Ts)
+				break
 			}
 			if p.g != 0 {
-				return fmt.Errorf("p %d is already running g %d while start g %d (time %d)", ev.P, p.g, ev.G, ev.Ts)
+				err = fmt.Errorf("p %d is already running g %d while start g %d (time %d)", ev.P, p.g, ev.G, ev.Ts)
+				break
 			}
 			g.state = gRunning
 			g.evStart = ev
@@ -1154,7 +1509,7 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 			}
 
 			if g.ev != nil {
-				g.ev.Link = int32(evIdx)
+				g.ev.SetLink(int64(evIdx))
 				g.ev = nil
 			}
 
@@ -1163,10 +1518,10 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		case EvGoEnd, EvGoStop:
 			g := gs[ev.G]
 			p := ps[ev.P]
-			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
+			if err = checkRunning(p, g, ev, false); err != nil {
+				break
 			}
-			g.evStart.Link = int32(evIdx)
+			g.evStart.SetLink(int64(evIdx))
 			g.evStart = nil
 			g.state = gDead
 			p.g = 0
@@ -1174,7 +1529,7 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 			if ev.Type == EvGoEnd { // flush all active regions
 				regions := activeRegions[ev.G]
 				for _, s := range regions {
-					s.Link = int32(evIdx)
+					s.SetLink(int64(evIdx))
 				}
 				delete(activeRegions, ev.G)
 			}
@@ -1184,11 +1539,11 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		case EvGoSched, EvGoPreempt:
 			g := gs[ev.G]
 			p := ps[ev.P]
-			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
+			if err = checkRunning(p, g, ev, false); err != nil {
+				break
 			}
 			g.state = gRunnable
-			g.evStart.Link = int32(evIdx)
+			g.evStart.SetLink(int64(evIdx))
 			g.evStart = nil
 			p.g = 0
 			g.ev = ev
@@ -1199,20 +1554,23 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 			g := gs[ev.G]
 			p := ps[ev.P]
 			if g.state != gRunning {
-				return fmt.Errorf("g %d is not running while unpark (time %d)", ev.G, ev.Ts)
+				err = fmt.Errorf("g %d is not running while unpark (time %d)", ev.G, ev.Ts)
+				break
 			}
 			if p.g != ev.G {
-				return fmt.Errorf("p %d is not running g %d while unpark (time %d)", ev.P, ev.G, ev.Ts)
+				err = fmt.Errorf("p %d is not running g %d while unpark (time %d)", ev.P, ev.G, ev.Ts)
+				break
 			}
 			g1 := gs[ev.Args[0]]
 			if g1.state != gWaiting {
-				return fmt.Errorf("g %d is not waiting before unpark (time %d)", ev.Args[0], ev.Ts)
+				err = fmt.Errorf("g %d is not waiting before unpark (time %d)", ev.Args[0], ev.Ts)
+				break
 			}
 			if g1.ev != nil && g1.ev.Type == EvGoBlockNet {
 				ev.P = NetpollP
 			}
 			if g1.ev != nil {
-				g1.ev.Link = int32(evIdx)
+				g1.ev.SetLink(int64(evIdx))
 			}
 			g1.state = gRunnable
 			g1.ev = ev
@@ -1221,8 +1579,8 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		case EvGoSysCall:
 			g := gs[ev.G]
 			p := ps[ev.P]
-			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
+			if err = checkRunning(p, g, ev, false); err != nil {
+				break
 			}
 			g.ev = ev
 
@@ -1230,11 +1588,11 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		case EvGoSysBlock:
 			g := gs[ev.G]
 			p := ps[ev.P]
-			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
+			if err = checkRunning(p, g, ev, false); err != nil {
+				break
 			}
 			g.state = gWaiting
-			g.evStart.Link = int32(evIdx)
+			g.evStart.SetLink(int64(evIdx))
 			g.evStart = nil
 			p.g = 0
 
@@ -1243,10 +1601,11 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		case EvGoSysExit:
 			g := gs[ev.G]
 			if g.state != gWaiting {
-				return fmt.Errorf("g %d is not waiting during syscall exit (time %d)", ev.G, ev.Ts)
+				err = fmt.Errorf("g %d is not waiting during syscall exit (time %d)", ev.G, ev.Ts)
+				break
 			}
 			if g.ev != nil && (g.ev.Type == EvGoSysCall || g.ev.Type == EvGoInSyscall) {
-				g.ev.Link = int32(evIdx)
+				g.ev.SetLink(int64(evIdx))
 			}
 			g.state = gRunnable
 			g.ev = ev
@@ -1256,12 +1615,12 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 			EvGoBlockSelect, EvGoBlockSync, EvGoBlockCond, EvGoBlockNet, EvGoBlockGC:
 			g := gs[ev.G]
 			p := ps[ev.P]
-			if err := checkRunning(p, g, ev, false); err != nil {
-				return err
+			if err = checkRunning(p, g, ev, false); err != nil {
+				break
 			}
 			g.state = gWaiting
 			g.ev = ev
-			g.evStart.Link = int32(evIdx)
+			g.evStart.SetLink(int64(evIdx))
 			g.evStart = nil
 			p.g = 0
 
@@ -1270,14 +1629,15 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		case EvUserTaskCreate:
 			taskid := ev.Args[0]
 			if prevEv, ok := tasks[taskid]; ok {
-				return fmt.Errorf("task id conflicts (id:%d), %q vs %q", taskid, ev, prevEv)
+				err = fmt.Errorf("task id conflicts (id:%d), %q vs %q", taskid, ev, prevEv)
+				break
 			}
 			tasks[ev.Args[0]] = ev
 
 		case EvUserTaskEnd:
 			taskid := ev.Args[0]
 			if taskCreateEv, ok := tasks[taskid]; ok {
-				taskCreateEv.Link = int32(evIdx)
+				taskCreateEv.SetLink(int64(evIdx))
 				delete(tasks, taskid)
 			}
 
@@ -1291,10 +1651,11 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 				if n > 0 { // matching region start event is in the trace.
 					s := regions[n-1]
 					if s.Args[0] != ev.Args[0] || s.Args[2] != ev.Args[2] { // task id, region name mismatch
-						return fmt.Errorf("misuse of region in goroutine %d: span end %q when the inner-most active span start event is %q", ev.G, ev, s)
+						err = fmt.Errorf("misuse of region in goroutine %d: span end %q when the inner-most active span start event is %q", ev.G, ev, s)
+						break
 					}
 					// Link region start event with span end event
-					s.Link = int32(evIdx)
+					s.SetLink(int64(evIdx))
 
 					if n > 1 {
 						activeRegions[ev.G] = regions[:n-1]
@@ -1303,10 +1664,23 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 					}
 				}
 			} else {
-				return fmt.Errorf("invalid user region mode: %q", ev)
+				err = fmt.Errorf("invalid user region mode: %q", ev)
+				break
 			}
 		}
 
+		if err != nil {
+			if !p.lenient {
+				return err
+			}
+			// Branches return errors before modifying any state, which lets us drop the event.
+			p.diagnose(-1, ev.Type, "dropped event: %s", err)
+			ev.Type = EvNone
+			dropped++
+			err = nil
+			continue
+		}
+
 		if ev.StkID != 0 && len(p.stacks[ev.StkID]) == 0 {
 			// Make sure events don't refer to stacks that don't exist or to stacks with zero frames. Neither of these
 			// should be possible, but better be safe than sorry.
@@ -1315,12 +1689,37 @@ func (p *Parser) postProcessTrace(events []Event, progress func(float64)) error
 		}
 
 		if evIdx%1_000_000 == 0 {
-			progress(float64(evIdx+1) / float64(len(events)))
+			progress(float64(evIdx+1) / float64(events.Len()))
 		}
 	}
 
 	progress(1)
 
+	if dropped > 0 {
+		// Remove dropped events and update links, which are indices into events.
+		newIdx := make([]int64, events.Len())
+		j := int64(0)
+		for i := range newIdx {
+			newIdx[i] = j
+			if events.Ptr(i).Type != EvNone {
+				j++
+			}
+		}
+		n := 0
+		for i := 0; i < events.Len(); i++ {
+			ev := *events.Ptr(i)
+			if ev.Type == EvNone {
+				continue
+			}
+			if link := ev.Link(); link >= 0 {
+				ev.SetLink(newIdx[link])
+			}
+			*events.Ptr(n) = ev
+			n++
+		}
+		events.Truncate(n)
+	}
+
 	// TODO(dvyukov): restore stacks for EvGoStart events.
 	// TODO(dvyukov): test that all EvGoStart events has non-nil Link.
 
@@ -1540,42 +1939,59 @@ func (p *Parser) allocateStack(size uint64) []uint64 {
 }
 
 const (
-	ArgGCSweepDoneReclaimed = 1
-	ArgGCSweepDoneSwept     = 0
-	ArgGoCreateG            = 0
-	ArgGoCreateStack        = 1
-	ArgGoStartLabelLabelID  = 2
-	ArgGoUnblockG           = 0
-	ArgUserLogKeyID         = 1
-	ArgUserLogMessage       = 3
-	ArgUserRegionMode       = 1
-	ArgUserRegionTaskID     = 0
-	ArgUserRegionTypeID     = 2
-	ArgUserTaskCreateTaskID = 0
-	ArgUserTaskCreateTypeID = 2
-	ArgHeapAllocMem         = 0
-	ArgHeapGoalMem          = 0
-	ArgSTWStartKind         = 0
+	ArgGCSweepDoneReclaimed       = 1
+	ArgGCSweepDoneSwept           = 0
+	ArgGoCreateG                  = 0
+	ArgGoCreateStack              = 1
+	ArgGoStartLabelLabelID        = 2
+	ArgGoUnblockG                 = 0
+	ArgUserLogKeyID               = 1
+	ArgUserLogMessage             = 3
+	ArgUserLogTaskID              = 0
+	ArgUserRegionMode             = 1
+	ArgUserRegionTaskID           = 0
+	ArgUserRegionTypeID           = 2
+	ArgUserTaskCreateTaskID       = 0
+	ArgUserTaskCreateParentTaskID = 1
+	ArgUserTaskCreateTypeID       = 2
+	ArgHeapAllocMem               = 0
+	ArgProcStartThread            = 0
+	ArgHeapGoalMem                = 0
+	ArgSTWStartKind               = 0
 )
 
 func (tr *Trace) STWReason(kindID uint64) STWReason {
-	if tr.Version < 1021 {
+	return stwReason(tr.Version, kindID, tr)
+}
+
+func stwReason(version int, kindID uint64, r Resolver) STWReason {
+	if version < 1021 {
 		if kindID == 0 || kindID == 1 {
 			return STWReason(kindID + 1)
 		} else {
 			return STWUnknown
 		}
-	} else if tr.Version == 1021 {
+	} else if version == 1021 {
 		if kindID < NumSTWReasons {
 			return STWReason(kindID)
 		} else {
 			return STWUnknown
 		}
 	} else {
-		return STWUnknown
+		// The generation-based format records the reason as a string.
+		return stwReasonsByString[r.String(kindID)]
 	}
 }
 
+// Stack returns the PCs of the stack with the given ID.
+func (tr *Trace) Stack(id uint32) []uint64 { return tr.Stacks[id] }
+
+// String returns the string with the given ID.
+func (tr *Trace) String(id uint64) string { return tr.Strings[id] }
+
+// PC returns the frame of the given PC.
+func (tr *Trace) PC(pc uint64) Frame { return tr.PCs[pc] }
+
 type STWReason int
 
 const (
@@ -1639,3 +2055,24 @@ func (stw STWReason) String() string {
 		return "unknown"
 	}
 }
+
+// stwReasonsByString maps the STW reasons recorded by Go 1.22 and newer to STWReason. The strings are taken from the
+// runtime's stwReasonStrings.
+var stwReasonsByString = map[string]STWReason{
+	"GC mark termination":         STWGCMarkTermination,
+	"GC sweep termination":        STWGCSweepTermination,
+	"write heap dump":             STWWriteHeapDump,
+	"goroutine profile":           STWGoroutineProfile,
+	"goroutine profile cleanup":   STWGoroutineProfileCleanup,
+	"all goroutines stack trace":  STWAllGoroutinesStackTrace,
+	"read mem stats":              STWReadMemStats,
+	"AllThreadsSyscall":           STWAllThreadsSyscall,
+	"GOMAXPROCS":                  STWGOMAXPROCS,
+	"start trace":                 STWStartTrace,
+	"stop trace":                  STWStopTrace,
+	"CountPagesInUse (test)":      STWCountPagesInUse,
+	"ReadMetricsSlow (test)":      STWReadMetricsSlow,
+	"ReadMemStatsSlow (test)":     STWReadMemStatsSlow,
+	"PageCachePagesLeaked (test)": STWPageCachePagesLeaked,
+	"ResetDebugLog (test)":        STWResetDebugLog,
+}
@@ -6,6 +6,7 @@ package trace
 
 import (
 	"bytes"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -26,7 +27,7 @@ func TestCorruptedInputs(t *testing.T) {
 	}
 	for _, data := range tests {
 		res, err := Parse(strings.NewReader(data), nil)
-		if err == nil || res.Events != nil || res.Stacks != nil {
+		if err == nil || res.Events.Len() != 0 || res.Stacks != nil {
 			t.Fatalf("no error on input: %q", data)
 		}
 	}
@@ -124,3 +125,174 @@ func TestParseVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCanned(t *testing.T) {
+	files, err := os.ReadDir("./testdata")
+	if err != nil {
+		t.Fatalf("failed to read ./testdata: %v", err)
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), "_good") {
+			continue
+		}
+		name := filepath.Join("./testdata", f.Name())
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Parse(bytes.NewReader(data), nil); err != nil {
+			t.Errorf("failed to parse good trace %s: %v", f.Name(), err)
+		}
+	}
+}
+
+func TestParseGenerations(t *testing.T) {
+	data, err := os.ReadFile("testdata/user_task_region_1_26_good")
+	if err != nil {
+		t.Fatalf("failed to read input file: %v", err)
+	}
+	res, err := Parse(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("failed to parse trace: %s", err)
+	}
+	if res.Version != 1026 {
+		t.Fatalf("got version %d, want 1026", res.Version)
+	}
+
+	var tasks, regions, logs int
+	var stw []STWReason
+	for i := 0; i < res.Events.Len(); i++ {
+		ev := res.Events.Ptr(i)
+		if i > 0 && ev.Ts < res.Events.Ptr(i-1).Ts {
+			t.Fatalf("event %d has timestamp %d, earlier than the previous event's %d", i, ev.Ts, res.Events.Ptr(i-1).Ts)
+		}
+		switch ev.Type {
+		case EvUserTaskCreate:
+			tasks++
+			if name := res.Strings[ev.Args[ArgUserTaskCreateTypeID]]; name != "task0" {
+				t.Errorf("got task name %q, want %q", name, "task0")
+			}
+		case EvUserRegion:
+			regions++
+		case EvUserLog:
+			logs++
+		case EvSTWStart:
+			stw = append(stw, res.STWReason(ev.Args[ArgSTWStartKind]))
+		}
+	}
+	if tasks != 1 || logs != 1 || regions == 0 {
+		t.Errorf("got %d tasks, %d regions and %d logs, want 1 task, some regions and 1 log", tasks, regions, logs)
+	}
+	if len(stw) != 1 || stw[0] != STWStartTrace {
+		t.Errorf("got STW reasons %v, want [%v]", stw, STWStartTrace)
+	}
+}
+
+func TestNext(t *testing.T) {
+	files, err := os.ReadDir("./testdata")
+	if err != nil {
+		t.Fatalf("failed to read ./testdata: %v", err)
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), "_good") {
+			continue
+		}
+		name := filepath.Join("./testdata", f.Name())
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := Parse(bytes.NewReader(data), nil)
+		if err != nil {
+			t.Fatalf("failed to parse good trace %s: %v", f.Name(), err)
+		}
+
+		// Use a reader that isn't an io.Seeker, to make sure we don't depend on knowing the size of the input.
+		p, err := NewParser(io.MultiReader(bytes.NewReader(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; ; i++ {
+			ev, err := p.Next()
+			if err == io.EOF {
+				if i != res.Events.Len() {
+					t.Errorf("%s: got %d events from Next, want %d", f.Name(), i, res.Events.Len())
+				}
+				break
+			} else if err != nil {
+				t.Fatalf("%s: failed to get event %d: %v", f.Name(), i, err)
+			}
+			if i >= res.Events.Len() {
+				t.Errorf("%s: got more than %d events from Next", f.Name(), res.Events.Len())
+				break
+			}
+			// Post-processing sets links, moves some events to fake Ps and moves the creation stacks of goroutines
+			// to their first EvGoStart.
+			want := res.Events.Get(i)
+			if ev.Ts != want.Ts || ev.Type != want.Type || ev.G != want.G || ev.Args != want.Args {
+				t.Errorf("%s: event %d: got %v, want %v", f.Name(), i, ev, want)
+				break
+			}
+			if got, want := len(p.Stack(ev.StkID)), len(res.Stacks[ev.StkID]); got != want {
+				t.Errorf("%s: event %d: got stack of length %d, want %d", f.Name(), i, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	files, err := os.ReadDir("./testdata")
+	if err != nil {
+		t.Fatalf("failed to read ./testdata: %v", err)
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), "_good") {
+			continue
+		}
+		t.Run(f.Name(), func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("./testdata", f.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := Parse(bytes.NewReader(data), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseLenient(bytes.NewReader(data), nil)
+			if err != nil {
+				t.Fatalf("failed to parse intact trace: %s", err)
+			}
+			if len(got.Diagnostics) != 0 {
+				t.Errorf("got diagnostics for intact trace: %v", got.Diagnostics)
+			}
+			if got.Events.Len() != want.Events.Len() {
+				t.Errorf("got %d events, want %d", got.Events.Len(), want.Events.Len())
+			}
+
+			for _, frac := range []float64{0.5, 0.9} {
+				truncated := data[:int(float64(len(data))*frac)]
+				if _, err := Parse(bytes.NewReader(truncated), nil); err == nil {
+					// Truncating at a generation boundary produces a valid trace.
+					continue
+				}
+				res, err := ParseLenient(bytes.NewReader(truncated), nil)
+				if err != nil {
+					t.Fatalf("failed to parse trace truncated to %d bytes: %s", len(truncated), err)
+				}
+				if len(res.Diagnostics) == 0 {
+					t.Errorf("got no diagnostics for trace truncated to %d bytes", len(truncated))
+				}
+				if res.Events.Len() == 0 && frac == 0.9 {
+					// We recover whole batches, and small traces may only consist of a few.
+					t.Errorf("got no events for trace truncated to %d bytes", len(truncated))
+				}
+				for i := 1; i < res.Events.Len(); i++ {
+					if res.Events.Ptr(i).Ts < res.Events.Ptr(i-1).Ts {
+						t.Fatalf("events aren't sorted for trace truncated to %d bytes", len(truncated))
+					}
+				}
+			}
+		})
+	}
+}
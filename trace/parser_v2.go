@@ -0,0 +1,1456 @@
+package trace
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Go 1.22 introduced a new, generation-based trace format. The trace is split into generations, each of which is
+// self-contained: it has its own string and stack tables, its own timer frequency and per-M (instead of per-P) batches
+// of events. At the beginning of each generation, the runtime emits the status of every goroutine and processor that
+// is relevant to that generation, and events carry sequence numbers that allow us to put them in a consistent order.
+//
+// We parse this format into the same events that we use for older traces, so that the rest of gotraceui doesn't have
+// to care about the differences.
+
+// Event types in the generation-based trace format.
+// Verbatim copy from src/internal/trace/tracev2/events.go.
+const (
+	ev2None = iota // unused
+
+	// Structural events.
+	ev2EventBatch // start of per-M batch of events [generation, M ID, timestamp, batch length]
+	ev2Stacks     // start of a section of the stack table [...EvStack]
+	ev2Stack      // stack table entry [ID, ...{PC, func string ID, file string ID, line #}]
+	ev2Strings    // start of a section of the string dictionary [...EvString]
+	ev2String     // string dictionary entry [ID, length, string]
+	ev2CPUSamples // start of a section of CPU samples [...EvCPUSample]
+	ev2CPUSample  // CPU profiling sample [timestamp, M ID, P ID, goroutine ID, stack ID]
+	ev2Frequency  // timestamp units per sec [freq]
+
+	// Procs.
+	ev2ProcsChange // current value of GOMAXPROCS [timestamp, GOMAXPROCS, stack ID]
+	ev2ProcStart   // start of P [timestamp, P ID, P seq]
+	ev2ProcStop    // stop of P [timestamp]
+	ev2ProcSteal   // P was stolen [timestamp, P ID, P seq, M ID]
+	ev2ProcStatus  // P status at the start of a generation [timestamp, P ID, status]
+
+	// Goroutines.
+	ev2GoCreate            // goroutine creation [timestamp, new goroutine ID, new stack ID, stack ID]
+	ev2GoCreateSyscall     // goroutine appears in syscall (cgo callback) [timestamp, new goroutine ID]
+	ev2GoStart             // goroutine starts running [timestamp, goroutine ID, goroutine seq]
+	ev2GoDestroy           // goroutine ends [timestamp]
+	ev2GoDestroySyscall    // goroutine ends in syscall (cgo callback) [timestamp]
+	ev2GoStop              // goroutine yields its time, but is runnable [timestamp, reason, stack ID]
+	ev2GoBlock             // goroutine blocks [timestamp, reason, stack ID]
+	ev2GoUnblock           // goroutine is unblocked [timestamp, goroutine ID, goroutine seq, stack ID]
+	ev2GoSyscallBegin      // syscall enter [timestamp, P seq, stack ID]
+	ev2GoSyscallEnd        // syscall exit [timestamp]
+	ev2GoSyscallEndBlocked // syscall exit and it blocked at some point [timestamp]
+	ev2GoStatus            // goroutine status at the start of a generation [timestamp, goroutine ID, M ID, status]
+
+	// STW.
+	ev2STWBegin // STW start [timestamp, kind, stack ID]
+	ev2STWEnd   // STW done [timestamp]
+
+	// GC events.
+	ev2GCActive           // GC active [timestamp, seq]
+	ev2GCBegin            // GC start [timestamp, seq, stack ID]
+	ev2GCEnd              // GC done [timestamp, seq]
+	ev2GCSweepActive      // GC sweep active [timestamp, P ID]
+	ev2GCSweepBegin       // GC sweep start [timestamp, stack ID]
+	ev2GCSweepEnd         // GC sweep done [timestamp, swept bytes, reclaimed bytes]
+	ev2GCMarkAssistActive // GC mark assist active [timestamp, goroutine ID]
+	ev2GCMarkAssistBegin  // GC mark assist start [timestamp, stack ID]
+	ev2GCMarkAssistEnd    // GC mark assist done [timestamp]
+	ev2HeapAlloc          // gcController.heapLive change [timestamp, heap alloc in bytes]
+	ev2HeapGoal           // gcController.heapGoal() change [timestamp, heap goal in bytes]
+
+	// Annotations.
+	ev2GoLabel         // apply string label to current running goroutine [timestamp, label string ID]
+	ev2UserTaskBegin   // trace.NewTask [timestamp, internal task ID, internal parent task ID, name string ID, stack ID]
+	ev2UserTaskEnd     // end of a task [timestamp, internal task ID, stack ID]
+	ev2UserRegionBegin // trace.{Start,With}Region [timestamp, internal task ID, name string ID, stack ID]
+	ev2UserRegionEnd   // trace.{End,With}Region [timestamp, internal task ID, name string ID, stack ID]
+	ev2UserLog         // trace.Log [timestamp, internal task ID, key string ID, value string ID, stack]
+
+	// Coroutines. Added in Go 1.23.
+	ev2GoSwitch        // goroutine switch (coroswitch) [timestamp, goroutine ID, goroutine seq]
+	ev2GoSwitchDestroy // goroutine switch and destroy [timestamp, goroutine ID, goroutine seq]
+	ev2GoCreateBlocked // goroutine creation (starts blocked) [timestamp, new goroutine ID, new stack ID, stack ID]
+
+	// GoStatus with stack. Added in Go 1.23.
+	ev2GoStatusStack // goroutine status at the start of a generation, with a stack [timestamp, goroutine ID, M ID, status, stack ID]
+
+	// Batch event for an experimental batch with a custom format. Added in Go 1.23.
+	ev2ExperimentalBatch // start of extra data [experiment ID, generation, M ID, timestamp, batch length, batch data...]
+
+	// Sync batch. Added in Go 1.25. Previously a lone EvFrequency event.
+	ev2Sync          // start of a sync batch [...EvFrequency|EvClockSnapshot]
+	ev2ClockSnapshot // snapshot of trace, mono and wall clocks [timestamp, mono, sec, nsec]
+
+	// In-band end-of-generation signal. Added in Go 1.26.
+	ev2EndOfGeneration
+
+	ev2Count
+)
+
+// v2EventArgs is the number of arguments of each timed event, including the timestamp delta.
+var v2EventArgs = [ev2Count]uint8{
+	ev2ProcsChange:         3,
+	ev2ProcStart:           3,
+	ev2ProcStop:            1,
+	ev2ProcSteal:           4,
+	ev2ProcStatus:          3,
+	ev2GoCreate:            4,
+	ev2GoCreateSyscall:     2,
+	ev2GoStart:             3,
+	ev2GoDestroy:           1,
+	ev2GoDestroySyscall:    1,
+	ev2GoStop:              3,
+	ev2GoBlock:             3,
+	ev2GoUnblock:           4,
+	ev2GoSyscallBegin:      3,
+	ev2GoSyscallEnd:        1,
+	ev2GoSyscallEndBlocked: 1,
+	ev2GoStatus:            4,
+	ev2STWBegin:            3,
+	ev2STWEnd:              1,
+	ev2GCActive:            2,
+	ev2GCBegin:             3,
+	ev2GCEnd:               2,
+	ev2GCSweepActive:       2,
+	ev2GCSweepBegin:        2,
+	ev2GCSweepEnd:          3,
+	ev2GCMarkAssistActive:  2,
+	ev2GCMarkAssistBegin:   2,
+	ev2GCMarkAssistEnd:     1,
+	ev2HeapAlloc:           2,
+	ev2HeapGoal:            2,
+	ev2GoLabel:             2,
+	ev2UserTaskBegin:       5,
+	ev2UserTaskEnd:         3,
+	ev2UserRegionBegin:     4,
+	ev2UserRegionEnd:       4,
+	ev2UserLog:             5,
+	ev2GoSwitch:            3,
+	ev2GoSwitchDestroy:     3,
+	ev2GoCreateBlocked:     4,
+	ev2GoStatusStack:       5,
+}
+
+// Goroutine states in the generation-based trace format.
+const (
+	goBad = iota
+	goRunnable
+	goRunning
+	goSyscall
+	goWaiting
+)
+
+// Processor states in the generation-based trace format.
+const (
+	procBad = iota
+	procRunning
+	procIdle
+	procSyscall
+	procSyscallAbandoned
+)
+
+// GC states in the generation-based trace format.
+const (
+	gcUndetermined = iota
+	gcNotRunning
+	gcRunning
+)
+
+const (
+	// Limits imposed by the runtime, see src/internal/trace/tracev2/events.go.
+	maxBatchSize            = 64 << 10
+	maxFramesPerStack       = 128
+	maxEventTrailerDataSize = 1 << 10
+)
+
+// v2Batch is a batch of events. Only its header has been parsed.
+type v2Batch struct {
+	m    uint64
+	time uint64
+	data []byte
+}
+
+// v2Generation contains all the data of a single generation.
+type v2Generation struct {
+	gen uint64
+	// Nanoseconds per tick.
+	freq float64
+
+	batches map[uint64][]v2Batch
+	// The Ms that have batches, in the order we've seen them.
+	ms []uint64
+
+	strings map[uint64]string
+	// The stacks of this generation. We only turn stacks into global stacks when they're used by events.
+	stacks map[uint64][]v2Frame
+	// Maps stack IDs local to this generation to global stack IDs.
+	stackIDs map[uint64]uint32
+	samples  []v2Sample
+}
+
+type v2Frame struct {
+	pc, fn, file, line uint64
+}
+
+type v2Sample struct {
+	ev  Event
+	stk uint64
+}
+
+// v2Spill is the first batch of the next generation, which we've read while reading the current generation. Traces
+// produced before Go 1.26 don't delimit generations.
+type v2Spill struct {
+	b   v2Batch
+	gen uint64
+	exp bool
+}
+
+// v2Event is a partially decoded event.
+type v2Event struct {
+	typ  byte
+	ts   Timestamp
+	args [4]uint64
+}
+
+// v2Cursor iterates over all events of an M in a generation.
+type v2Cursor struct {
+	m       uint64
+	batches []v2Batch
+	data    []byte
+	ticks   uint64
+	ev      v2Event
+}
+
+type v2G struct {
+	status uint8
+	seq    uint64
+	seqGen uint64
+	// The P we last started the goroutine on.
+	p int32
+	// Whether we've emitted EvGoInSyscall or EvGoSysBlock, without a matching EvGoSysExit.
+	sysBlocked bool
+	// Index of the goroutine's EvGoCreate if it doesn't have a stack yet, or -1.
+	create int
+}
+
+type v2P struct {
+	status uint8
+	seq    uint64
+	seqGen uint64
+	// Whether we've emitted EvProcStart, without a matching EvProcStop.
+	running  bool
+	sweeping bool
+}
+
+type v2M struct {
+	// The goroutine and processor bound to the M. 0 and -1 denote the absence of a goroutine and processor.
+	g uint64
+	p int32
+	// Index of the last EvGoStart emitted for this M, or -1.
+	lastStart int
+}
+
+// v2Ordering emulates the scheduler to put events in the right order and translates them to the events used by older
+// traces.
+type v2Ordering struct {
+	p   *Parser
+	gen *v2Generation
+
+	gs map[uint64]*v2G
+	ps map[int32]*v2P
+	ms map[uint64]*v2M
+	// Goroutines that belong to cgo callbacks that have returned. We treat them as blocked in a syscall until the next
+	// callback uses the same goroutine.
+	syscallGs map[uint64]struct{}
+
+	gcSeq   uint64
+	gcState uint8
+	inSTW   bool
+
+	lastGen uint64
+	events  *Events
+	lastTs  Timestamp
+	// The timestamp of the first event in the trace. Timestamps are relative to it.
+	minTs   Timestamp
+	started bool
+}
+
+// startGenerations prepares the parser for parsing traces in the generation-based format that has been in use since Go
+// 1.22.
+func (p *Parser) startGenerations() {
+	p.r.Discard(headerLength)
+	p.stringIDs = make(map[string]uint64)
+	p.stackIDs = make(map[string]uint32)
+	p.order = &v2Ordering{
+		p:         p,
+		gs:        make(map[uint64]*v2G),
+		ps:        make(map[int32]*v2P),
+		ms:        make(map[uint64]*v2M),
+		syscallGs: make(map[uint64]struct{}),
+	}
+}
+
+// nextGeneration parses the next generation and appends its events to events. It returns io.EOF if there are no more
+// generations.
+func (p *Parser) nextGeneration(events *Events) error {
+	gen, err := p.readGeneration()
+	if err != nil {
+		return err
+	}
+	if gen == nil {
+		return io.EOF
+	}
+
+	o := p.order
+	if gen.gen <= o.lastGen {
+		return fmt.Errorf("generations out of order: %d follows %d", gen.gen, o.lastGen)
+	}
+	o.lastGen = gen.gen
+	first := events.Len()
+	o.events = events
+	err = o.processGeneration(gen)
+	o.events = nil
+	if err != nil {
+		events.Truncate(first)
+		return err
+	}
+
+	if events.Len() > first && !o.started {
+		o.minTs = events.Ptr(first).Ts
+		o.started = true
+		o.p.startTs = o.minTs
+	}
+	for i := first; i < events.Len(); i++ {
+		ev := events.Ptr(i)
+		ev.Ts -= o.minTs
+		// Move syscalls to separate fake Ps.
+		if ev.Type == EvGoSysExit {
+			ev.P = SyscallP
+		}
+	}
+	return nil
+}
+
+// readUvarint reads a base-128 varint from the input.
+func (p *Parser) readUvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(p.r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	} else if err != nil && err != io.ErrUnexpectedEOF {
+		err = errMalformedVarint
+	}
+	return v, err
+}
+
+// readBatch reads the next batch. It returns the batch, its generation and whether it is an experimental batch. It
+// returns io.EOF if there are no more batches.
+func (p *Parser) readBatch() (v2Batch, uint64, bool, error) {
+	typ, err := p.r.ReadByte()
+	if err != nil {
+		return v2Batch{}, 0, false, err
+	}
+	exp := false
+	switch typ {
+	case ev2EventBatch:
+	case ev2ExperimentalBatch:
+		if p.ver < 1023 {
+			return v2Batch{}, 0, false, fmt.Errorf("unexpected event %d, expected batch", typ)
+		}
+		exp = true
+		if _, err := p.r.ReadByte(); err != nil {
+			return v2Batch{}, 0, false, fmt.Errorf("failed to read trace: %w", io.ErrUnexpectedEOF)
+		}
+	default:
+		return v2Batch{}, 0, false, fmt.Errorf("unexpected event %d, expected batch", typ)
+	}
+
+	var hdr [4]uint64
+	for i := range hdr {
+		v, err := p.readUvarint()
+		if err != nil {
+			return v2Batch{}, 0, false, fmt.Errorf("failed to read batch header: %w", err)
+		}
+		hdr[i] = v
+	}
+	gen, m, ts, size := hdr[0], hdr[1], hdr[2], hdr[3]
+	if gen == 0 {
+		return v2Batch{}, 0, false, errors.New("batch has invalid generation 0")
+	}
+	if size > maxBatchSize {
+		return v2Batch{}, 0, false, fmt.Errorf("batch has invalid size %d, maximum is %d", size, maxBatchSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return v2Batch{}, 0, false, fmt.Errorf("failed to read trace: %w", io.ErrUnexpectedEOF)
+	}
+	return v2Batch{m: m, time: ts, data: data}, gen, exp, nil
+}
+
+// readGeneration reads all batches of the next generation and parses its strings, stacks, CPU samples and frequency.
+// It returns nil if there are no more generations.
+func (p *Parser) readGeneration() (*v2Generation, error) {
+	g := &v2Generation{
+		batches:  make(map[uint64][]v2Batch),
+		strings:  make(map[uint64]string),
+		stacks:   make(map[uint64][]v2Frame),
+		stackIDs: make(map[uint64]uint32),
+	}
+	if p.stopped {
+		return nil, nil
+	}
+	var stringBatches, stackBatches, sampleBatches []v2Batch
+	for {
+		var b v2Batch
+		var gen uint64
+		var exp bool
+		if p.spill != nil {
+			b, gen, exp = p.spill.b, p.spill.gen, p.spill.exp
+			p.spill = nil
+		} else {
+			if p.ver >= 1026 {
+				if typ, err := p.r.Peek(1); err == nil && typ[0] == ev2EndOfGeneration {
+					p.r.Discard(1)
+					if g.gen != 0 {
+						break
+					}
+					continue
+				}
+			}
+
+			var err error
+			off := p.input.N - int64(p.r.Buffered())
+			b, gen, exp, err = p.readBatch()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				if !p.lenient {
+					return nil, err
+				}
+				// Make do with the batches we've already read. Like the legacy format, we can't resynchronize with
+				// the input.
+				p.diagnose(off, EvNone, "dropped the rest of the trace: %s", err)
+				p.stopped = true
+				break
+			}
+		}
+		if g.gen == 0 {
+			g.gen = gen
+		}
+		if gen != g.gen {
+			if p.ver >= 1026 {
+				return nil, errors.New("missing end-of-generation event, or generations are interleaved")
+			}
+			// This batch belongs to the next generation.
+			p.spill = &v2Spill{b: b, gen: gen, exp: exp}
+			break
+		}
+		if exp || len(b.data) == 0 {
+			// We don't support any experiments.
+			continue
+		}
+
+		switch b.data[0] {
+		case ev2Strings:
+			stringBatches = append(stringBatches, b)
+		case ev2Stacks:
+			stackBatches = append(stackBatches, b)
+		case ev2CPUSamples:
+			sampleBatches = append(sampleBatches, b)
+		case ev2Frequency, ev2Sync:
+			if (b.data[0] == ev2Sync) != (p.ver >= 1025) {
+				return nil, fmt.Errorf("unexpected sync batch of type %d", b.data[0])
+			}
+			if err := g.parseSync(b); err != nil {
+				return nil, err
+			}
+		default:
+			if _, ok := g.batches[b.m]; !ok {
+				g.ms = append(g.ms, b.m)
+			}
+			g.batches[b.m] = append(g.batches[b.m], b)
+		}
+	}
+	if g.gen == 0 {
+		return nil, nil
+	}
+	if g.freq == 0 {
+		if !p.lenient {
+			return nil, errors.New("no EvFrequency event")
+		}
+		// Go 1.22 writes the frequency at the end of each generation. Most platforms use nanotime()/64 as the clock.
+		p.diagnose(-1, EvFrequency, "no EvFrequency event, assuming 64 ns per tick")
+		g.freq = 64
+	}
+
+	// Stacks refer to strings, so the order in which we parse these matters.
+	for _, b := range stringBatches {
+		if err := g.parseStrings(b); err != nil {
+			return nil, err
+		}
+	}
+	for _, b := range stackBatches {
+		if err := g.parseStacks(b); err != nil {
+			return nil, err
+		}
+	}
+	for _, b := range sampleBatches {
+		if err := g.parseCPUSamples(b); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(g.samples, func(i, j int) bool { return g.samples[i].ev.Ts < g.samples[j].ev.Ts })
+
+	return g, nil
+}
+
+func (g *v2Generation) parseSync(b v2Batch) error {
+	data := b.data
+	if data[0] == ev2Sync {
+		data = data[1:]
+	}
+	for len(data) > 0 {
+		typ := data[0]
+		data = data[1:]
+		switch typ {
+		case ev2Frequency:
+			if g.freq != 0 {
+				return errors.New("found multiple frequency events")
+			}
+			var freq uint64
+			var ok bool
+			freq, data, ok = readValFrom(data)
+			if !ok {
+				return errMalformedVarint
+			}
+			if freq == 0 {
+				// The most likely cause for this is tick skew on different CPUs.
+				return ErrTimeOrder
+			}
+			g.freq = 1e9 / float64(freq)
+		case ev2ClockSnapshot:
+			// [timestamp, mono, sec, nsec]. We have no use for the wall clock.
+			for i := 0; i < 4; i++ {
+				var ok bool
+				_, data, ok = readValFrom(data)
+				if !ok {
+					return errMalformedVarint
+				}
+			}
+		default:
+			return fmt.Errorf("unexpected event %d in sync batch", typ)
+		}
+	}
+	return nil
+}
+
+func (g *v2Generation) parseStrings(b v2Batch) error {
+	data := b.data[1:]
+	for len(data) > 0 {
+		if data[0] != ev2String {
+			return fmt.Errorf("unexpected event %d in string batch", data[0])
+		}
+		data = data[1:]
+		var id, ln uint64
+		var ok bool
+		if id, data, ok = readValFrom(data); !ok {
+			return errMalformedVarint
+		}
+		if ln, data, ok = readValFrom(data); !ok {
+			return errMalformedVarint
+		}
+		if ln > maxEventTrailerDataSize {
+			return fmt.Errorf("string has too large length %d", ln)
+		}
+		if uint64(len(data)) < ln {
+			return fmt.Errorf("failed to read trace: %w", io.ErrUnexpectedEOF)
+		}
+		if _, ok := g.strings[id]; ok {
+			return fmt.Errorf("string has duplicate id %d", id)
+		}
+		g.strings[id] = string(data[:ln])
+		data = data[ln:]
+	}
+	return nil
+}
+
+func (g *v2Generation) parseStacks(b v2Batch) error {
+	data := b.data[1:]
+	for len(data) > 0 {
+		if data[0] != ev2Stack {
+			return fmt.Errorf("unexpected event %d in stack batch", data[0])
+		}
+		data = data[1:]
+		var id, n uint64
+		var ok bool
+		if id, data, ok = readValFrom(data); !ok {
+			return errMalformedVarint
+		}
+		if n, data, ok = readValFrom(data); !ok {
+			return errMalformedVarint
+		}
+		if n > maxFramesPerStack {
+			return fmt.Errorf("EvStack has bad number of frames: %d", n)
+		}
+		frames := make([]v2Frame, n)
+		for i := range frames {
+			frame := &frames[i]
+			for _, v := range []*uint64{&frame.pc, &frame.fn, &frame.file, &frame.line} {
+				if *v, data, ok = readValFrom(data); !ok {
+					return errMalformedVarint
+				}
+			}
+		}
+		if _, ok := g.stacks[id]; ok {
+			return fmt.Errorf("stack has duplicate id %d", id)
+		}
+		g.stacks[id] = frames
+	}
+	return nil
+}
+
+func (g *v2Generation) parseCPUSamples(b v2Batch) error {
+	data := b.data[1:]
+	for len(data) > 0 {
+		if data[0] != ev2CPUSample {
+			return fmt.Errorf("unexpected event %d in CPU sample batch", data[0])
+		}
+		data = data[1:]
+		// [timestamp, M ID, P ID, goroutine ID, stack ID]
+		var args [5]uint64
+		for i := range args {
+			var ok bool
+			if args[i], data, ok = readValFrom(data); !ok {
+				return errMalformedVarint
+			}
+		}
+		pid := int32(-1)
+		if args[2] <= math.MaxInt32 {
+			pid = int32(args[2])
+		}
+		g.samples = append(g.samples, v2Sample{
+			ev: Event{
+				Type: EvCPUSample,
+				Ts:   Timestamp(float64(args[0]) * g.freq),
+				P:    pid,
+				G:    args[3],
+			},
+			stk: args[4],
+		})
+	}
+	return nil
+}
+
+// internString returns the global ID of a string, allocating a new ID if necessary. Strings in the generation-based
+// format are local to generations, but our strings are global.
+func (p *Parser) internString(s string) uint64 {
+	if s == "" {
+		return 0
+	}
+	if id, ok := p.stringIDs[s]; ok {
+		return id
+	}
+	id := uint64(len(p.stringIDs) + 1)
+	p.stringIDs[s] = id
+	p.strings[id] = s
+	return id
+}
+
+// internStack returns the global ID of a stack, allocating a new ID if necessary. Like strings, stacks in the
+// generation-based format are local to generations, and are usually repeated in each generation.
+func (p *Parser) internStack(pcs []uint64) uint32 {
+	if len(pcs) == 0 {
+		return 0
+	}
+	key := make([]byte, 0, len(pcs)*8)
+	for _, pc := range pcs {
+		key = binary.LittleEndian.AppendUint64(key, pc)
+	}
+	if id, ok := p.stackIDs[string(key)]; ok {
+		return id
+	}
+	id := uint32(len(p.stackIDs) + 1)
+	p.stackIDs[string(key)] = id
+	stk := p.allocateStack(uint64(len(pcs)))
+	copy(stk, pcs)
+	p.stacks[id] = stk
+	return id
+}
+
+// next advances the cursor to the next event. It returns false if there are no more events.
+func (c *v2Cursor) next(p *Parser, freq float64) (bool, error) {
+	for len(c.data) == 0 {
+		if len(c.batches) == 0 {
+			return false, nil
+		}
+		c.data = c.batches[0].data
+		c.ticks = c.batches[0].time
+		c.batches = c.batches[1:]
+	}
+
+	typ := c.data[0]
+	if typ >= ev2Count || v2EventArgs[typ] == 0 || (typ >= ev2GoSwitch && p.ver < 1023) {
+		return false, fmt.Errorf("unknown event type %d", typ)
+	}
+	data := c.data[1:]
+	dt, data, ok := readValFrom(data)
+	if !ok {
+		return false, fmt.Errorf("failed to read event %d timestamp: %w", typ, errMalformedVarint)
+	}
+	c.ev = v2Event{typ: typ}
+	for i := 0; i < int(v2EventArgs[typ])-1; i++ {
+		c.ev.args[i], data, ok = readValFrom(data)
+		if !ok {
+			return false, fmt.Errorf("failed to read event %d argument: %w", typ, errMalformedVarint)
+		}
+	}
+	c.data = data
+	c.ticks += dt
+	c.ev.ts = Timestamp(float64(c.ticks) * freq)
+	return true, nil
+}
+
+// processGeneration merges the per-M batches of a generation into a single, consistent stream of events. Like
+// parseRest, we repeatedly pick the earliest event among the next events of all Ms that is ready to be merged.
+func (o *v2Ordering) processGeneration(gen *v2Generation) error {
+	o.gen = gen
+	defer func() {
+		o.gen = nil
+		// Event indices are only valid within a generation.
+		for _, m := range o.ms {
+			m.lastStart = -1
+		}
+		for _, g := range o.gs {
+			g.create = -1
+		}
+	}()
+
+	cursors := make([]*v2Cursor, 0, len(gen.ms))
+	for _, m := range gen.ms {
+		c := &v2Cursor{m: m, batches: gen.batches[m]}
+		if ok, err := c.next(o.p, gen.freq); err != nil {
+			return err
+		} else if ok {
+			cursors = append(cursors, c)
+		}
+	}
+
+	samples := gen.samples
+	for len(cursors) > 0 {
+		sort.Slice(cursors, func(i, j int) bool { return cursors[i].ev.ts < cursors[j].ev.ts })
+		for len(samples) > 0 && samples[0].ev.Ts < cursors[0].ev.ts {
+			o.emitSample(samples[0])
+			samples = samples[1:]
+		}
+
+		advanced := false
+		for i, c := range cursors {
+			ok, err := o.advance(c.m, &c.ev)
+			if err != nil {
+				if !o.p.lenient {
+					return err
+				}
+				o.p.diagnose(-1, EvNone, "dropped event of type %d on M %d: %s", c.ev.typ, c.m, err)
+				ok = true
+			}
+			if !ok {
+				continue
+			}
+			advanced = true
+			if ok, err := c.next(o.p, gen.freq); err != nil {
+				return err
+			} else if !ok {
+				cursors = append(cursors[:i], cursors[i+1:]...)
+			}
+			break
+		}
+		if !advanced {
+			if !o.p.lenient {
+				return fmt.Errorf("no consistent ordering of events possible")
+			}
+			// Events are missing, most likely because the generation is incomplete. Drop the earliest event, which
+			// is waiting for them.
+			c := cursors[0]
+			o.p.diagnose(-1, EvNone, "no consistent ordering of events possible, dropped event of type %d on M %d", c.ev.typ, c.m)
+			if ok, err := c.next(o.p, gen.freq); err != nil {
+				return err
+			} else if !ok {
+				cursors = cursors[1:]
+			}
+		}
+	}
+	for _, s := range samples {
+		o.emitSample(s)
+	}
+	return nil
+}
+
+func (o *v2Ordering) emitSample(s v2Sample) {
+	ev := s.ev
+	ev.StkID = o.stk(s.stk)
+	o.emitEvent(ev)
+}
+
+func (o *v2Ordering) emitEvent(ev Event) int {
+	// The generation-based format doesn't promise that timestamps are consistent with the order of events. Nudge them
+	// so that they are.
+	if ev.Ts < o.lastTs {
+		ev.Ts = o.lastTs
+	}
+	o.lastTs = ev.Ts
+	o.events.Append(ev)
+	return o.events.Len() - 1
+}
+
+func (o *v2Ordering) emit(typ byte, ts Timestamp, pid int32, gid uint64, stk uint32, args ...uint64) int {
+	if stk != 0 && gid != 0 {
+		if g, ok := o.gs[gid]; ok && g.create != -1 {
+			o.events.Ptr(g.create).Args[ArgGoCreateStack] = uint64(o.startFunction(stk))
+			g.create = -1
+		}
+	}
+	ev := Event{Type: typ, Ts: ts, P: pid, G: gid, StkID: stk}
+	copy(ev.Args[:], args)
+	return o.emitEvent(ev)
+}
+
+// str returns the global ID of a string local to the current generation.
+func (o *v2Ordering) str(id uint64) uint64 {
+	return o.p.internString(o.gen.strings[id])
+}
+
+// stk returns the global ID of a stack local to the current generation.
+func (o *v2Ordering) stk(id uint64) uint32 {
+	if id == 0 {
+		return 0
+	}
+	if gid, ok := o.gen.stackIDs[id]; ok {
+		return gid
+	}
+	frames := o.gen.stacks[id]
+	pcs := make([]uint64, len(frames))
+	for i, frame := range frames {
+		pcs[i] = frame.pc
+		if _, ok := o.p.pcs[frame.pc]; !ok {
+			o.p.pcs[frame.pc] = Frame{
+				PC:   frame.pc,
+				Fn:   o.gen.strings[frame.fn],
+				File: o.gen.strings[frame.file],
+				Line: int(frame.line),
+			}
+		}
+	}
+	gid := o.p.internStack(pcs)
+	o.gen.stackIDs[id] = gid
+	return gid
+}
+
+func (o *v2Ordering) m(mid uint64) *v2M {
+	m, ok := o.ms[mid]
+	if !ok {
+		m = &v2M{p: -1, lastStart: -1}
+		o.ms[mid] = m
+	}
+	return m
+}
+
+// succeeds reports whether seq is the immediate successor of a resource's current sequence number.
+func (o *v2Ordering) succeeds(seq, curSeq, curGen uint64) bool {
+	return curGen == o.gen.gen && seq == curSeq+1
+}
+
+// createG emits the events for a goroutine that we see for the first time in a status event.
+func (o *v2Ordering) createG(ts Timestamp, gid uint64, status uint8, stk uint32) *v2G {
+	g := &v2G{status: status, seqGen: o.gen.gen, p: -1, create: -1}
+	o.gs[gid] = g
+
+	fnStk := o.startFunction(stk)
+	idx := o.emit(EvGoCreate, ts, -1, 0, 0, gid, uint64(fnStk))
+	if fnStk == 0 {
+		// Status events for running goroutines don't have stacks. Fill in the goroutine's function once we see one.
+		g.create = idx
+	}
+	switch status {
+	case goWaiting:
+		o.emit(EvGoWaiting, ts, -1, gid, 0, gid)
+	case goSyscall:
+		o.emit(EvGoInSyscall, ts, -1, gid, 0, gid)
+		g.sysBlocked = true
+	}
+	return g
+}
+
+// startFunction returns a stack consisting of only the outermost frame of stk. EvGoCreate refers to the goroutine's
+// start function, but status events carry the goroutine's current stack.
+func (o *v2Ordering) startFunction(stk uint32) uint32 {
+	pcs := o.p.stacks[stk]
+	for i := len(pcs) - 1; i >= 0; i-- {
+		if o.p.pcs[pcs[i]].Fn != "runtime.goexit" {
+			return o.p.internStack(pcs[i : i+1])
+		}
+	}
+	return 0
+}
+
+// startG emits EvGoStart.
+func (o *v2Ordering) startG(ts Timestamp, m *v2M, gid uint64, g *v2G) {
+	m.lastStart = o.emit(EvGoStart, ts, m.p, gid, 0, gid)
+	g.p = m.p
+}
+
+// sysBlock emits EvGoSysBlock for a goroutine whose syscall blocked, unless we've already done so.
+func (o *v2Ordering) sysBlock(ts Timestamp, gid uint64) {
+	if gid == 0 {
+		return
+	}
+	g, ok := o.gs[gid]
+	if !ok || g.status != goSyscall || g.sysBlocked {
+		return
+	}
+	o.emit(EvGoSysBlock, ts, g.p, gid, 0)
+	g.sysBlocked = true
+}
+
+// stopP emits EvProcStop, unless the P isn't running.
+func (o *v2Ordering) stopP(ts Timestamp, pid int32, p *v2P) {
+	if p.running {
+		o.emit(EvProcStop, ts, pid, 0, 0)
+		p.running = false
+	}
+}
+
+// blockReason maps the reasons for blocking to the event types of older traces. See runtime.traceBlockReasonStrings.
+func blockReason(reason string) byte {
+	switch reason {
+	case "forever":
+		return EvGoStop
+	case "network":
+		return EvGoBlockNet
+	case "select":
+		return EvGoBlockSelect
+	case "sync.(*Cond).Wait":
+		return EvGoBlockCond
+	case "sync":
+		return EvGoBlockSync
+	case "chan send":
+		return EvGoBlockSend
+	case "chan receive":
+		return EvGoBlockRecv
+	case "GC mark assist wait for work":
+		return EvGoBlockGC
+	case "sleep":
+		return EvGoSleep
+	default:
+		return EvGoBlock
+	}
+}
+
+// advance tries to advance the event ev of M mid. It returns false if the event isn't ready to be merged yet, and an
+// error if the trace is inconsistent.
+func (o *v2Ordering) advance(mid uint64, ev *v2Event) (bool, error) {
+	m := o.m(mid)
+	ts := ev.ts
+	args := &ev.args
+
+	needG := func() (*v2G, error) {
+		if m.g == 0 {
+			return nil, fmt.Errorf("event %d on M %d requires a goroutine (time %d)", ev.typ, mid, ts)
+		}
+		g, ok := o.gs[m.g]
+		if !ok {
+			return nil, fmt.Errorf("event %d for goroutine %d that doesn't exist (time %d)", ev.typ, m.g, ts)
+		}
+		return g, nil
+	}
+	needP := func() (*v2P, error) {
+		if m.p == -1 {
+			return nil, fmt.Errorf("event %d on M %d requires a processor (time %d)", ev.typ, mid, ts)
+		}
+		p, ok := o.ps[m.p]
+		if !ok {
+			return nil, fmt.Errorf("event %d for processor %d that doesn't exist (time %d)", ev.typ, m.p, ts)
+		}
+		return p, nil
+	}
+	needRunning := func() (*v2G, error) {
+		if _, err := needP(); err != nil {
+			return nil, err
+		}
+		g, err := needG()
+		if err != nil {
+			return nil, err
+		}
+		if g.status != goRunning {
+			return nil, fmt.Errorf("g %d is not running during event %d (time %d)", m.g, ev.typ, ts)
+		}
+		return g, nil
+	}
+
+	switch ev.typ {
+	case ev2ProcStatus:
+		if args[0] > math.MaxInt32 {
+			return false, fmt.Errorf("processor ID %d is larger than maximum of %d", args[0], math.MaxInt32)
+		}
+		pid := int32(args[0])
+		status := uint8(args[1])
+		if status == procBad || status > procSyscallAbandoned {
+			return false, fmt.Errorf("invalid status %d for processor %d", status, pid)
+		}
+		p, ok := o.ps[pid]
+		if !ok {
+			p = &v2P{status: status}
+			o.ps[pid] = p
+		} else if status == procSyscallAbandoned && (p.status == procSyscall || p.status == procSyscallAbandoned) {
+			// The P is about to be stolen from the M it was bound to; that doesn't change anything for us.
+		} else if p.status != status {
+			return false, fmt.Errorf("inconsistent status for processor %d: old %d vs. new %d", pid, p.status, status)
+		}
+		p.seq = 0
+		p.seqGen = o.gen.gen
+		if status == procRunning || status == procSyscall {
+			m.p = pid
+			if !p.running {
+				o.emit(EvProcStart, ts, pid, 0, 0, mid)
+				p.running = true
+			}
+		}
+
+	case ev2ProcStart:
+		pid := int32(args[0])
+		p, ok := o.ps[pid]
+		if !ok || p.status != procIdle || !o.succeeds(args[1], p.seq, p.seqGen) || m.p != -1 {
+			return false, nil
+		}
+		p.status = procRunning
+		p.seq = args[1]
+		m.p = pid
+		if !p.running {
+			o.emit(EvProcStart, ts, pid, 0, 0, mid)
+			p.running = true
+		}
+
+	case ev2ProcStop:
+		p, err := needP()
+		if err != nil {
+			return false, err
+		}
+		if p.status != procRunning && p.status != procSyscall {
+			return false, fmt.Errorf("processor %d is not running before stop (time %d)", m.p, ts)
+		}
+		// If the M's goroutine is in a syscall then it is handing off its P.
+		o.sysBlock(ts, m.g)
+		p.status = procIdle
+		o.stopP(ts, m.p, p)
+		m.p = -1
+
+	case ev2ProcSteal:
+		pid := int32(args[0])
+		p, ok := o.ps[pid]
+		if !ok || (p.status != procSyscall && p.status != procSyscallAbandoned) || !o.succeeds(args[1], p.seq, p.seqGen) {
+			return false, nil
+		}
+		oldStatus := p.status
+		p.status = procIdle
+		p.seq = args[1]
+		if oldStatus == procSyscallAbandoned {
+			o.stopP(ts, pid, p)
+			break
+		}
+		victim, ok := o.ms[args[2]]
+		if !ok {
+			return false, fmt.Errorf("stole processor %d from non-existent thread %d", pid, args[2])
+		}
+		if victim.p != pid {
+			return false, fmt.Errorf("tried to steal processor %d from thread %d, but got processor %d instead", pid, args[2], victim.p)
+		}
+		victim.p = -1
+		o.sysBlock(ts, victim.g)
+		o.stopP(ts, pid, p)
+
+	case ev2GoStatus, ev2GoStatusStack:
+		gid := args[0]
+		status := uint8(args[2])
+		if status == goBad || status > goWaiting {
+			return false, fmt.Errorf("invalid status %d for goroutine %d", status, gid)
+		}
+		g, ok := o.gs[gid]
+		if ok {
+			if g.status != status {
+				return false, fmt.Errorf("inconsistent status for goroutine %d: old %d vs. new %d", gid, g.status, status)
+			}
+			g.seq = 0
+			g.seqGen = o.gen.gen
+		} else {
+			var stk uint32
+			if ev.typ == ev2GoStatusStack {
+				stk = o.stk(args[3])
+			}
+			g = o.createG(ts, gid, status, stk)
+			if status == goRunning {
+				if m.p == -1 {
+					return false, fmt.Errorf("running goroutine %d has no processor (time %d)", gid, ts)
+				}
+				o.startG(ts, m, gid, g)
+			}
+		}
+		switch status {
+		case goRunning:
+			m.g = gid
+		case goSyscall:
+			if args[1] == mid {
+				m.g = gid
+			} else if other, ok := o.ms[args[1]]; ok {
+				if other.g != gid {
+					return false, fmt.Errorf("inconsistent thread for syscalling goroutine %d: thread has goroutine %d", gid, other.g)
+				}
+			} else {
+				o.ms[args[1]] = &v2M{g: gid, p: -1, lastStart: -1}
+			}
+		}
+
+	case ev2GoCreate, ev2GoCreateBlocked:
+		if _, err := needP(); err != nil {
+			return false, err
+		}
+		gid := args[0]
+		if _, ok := o.gs[gid]; ok {
+			return false, fmt.Errorf("g %d already exists (time %d)", gid, ts)
+		}
+		g := &v2G{status: goRunnable, seqGen: o.gen.gen, p: -1, create: -1}
+		o.gs[gid] = g
+		o.emit(EvGoCreate, ts, m.p, m.g, o.stk(args[2]), gid, uint64(o.stk(args[1])))
+		if ev.typ == ev2GoCreateBlocked {
+			g.status = goWaiting
+			o.emit(EvGoWaiting, ts, m.p, gid, 0, gid)
+		}
+
+	case ev2GoCreateSyscall:
+		if m.g != 0 {
+			return false, fmt.Errorf("goroutine %d created in syscall on thread %d that is already running goroutine %d", args[0], mid, m.g)
+		}
+		gid := args[0]
+		if _, ok := o.gs[gid]; ok {
+			return false, fmt.Errorf("g %d already exists (time %d)", gid, ts)
+		}
+		if _, ok := o.syscallGs[gid]; ok {
+			// The goroutine of a previous cgo callback is being reused. We never ended it.
+			delete(o.syscallGs, gid)
+			o.gs[gid] = &v2G{status: goSyscall, seqGen: o.gen.gen, p: -1, sysBlocked: true, create: -1}
+		} else {
+			o.createG(ts, gid, goSyscall, 0)
+		}
+		m.g = gid
+
+	case ev2GoStart:
+		gid := args[0]
+		g, ok := o.gs[gid]
+		if !ok || g.status != goRunnable || !o.succeeds(args[1], g.seq, g.seqGen) {
+			return false, nil
+		}
+		if _, err := needP(); err != nil {
+			return false, err
+		}
+		if m.g != 0 {
+			return false, fmt.Errorf("thread %d is already running goroutine %d while starting goroutine %d (time %d)", mid, m.g, gid, ts)
+		}
+		g.status = goRunning
+		g.seq = args[1]
+		m.g = gid
+		o.startG(ts, m, gid, g)
+
+	case ev2GoDestroy, ev2GoStop, ev2GoBlock:
+		g, err := needRunning()
+		if err != nil {
+			return false, err
+		}
+		gid := m.g
+		switch ev.typ {
+		case ev2GoDestroy:
+			delete(o.gs, gid)
+			o.emit(EvGoEnd, ts, m.p, gid, 0)
+		case ev2GoStop:
+			g.status = goRunnable
+			typ := byte(EvGoSched)
+			if o.gen.strings[args[0]] == "preempted" {
+				typ = EvGoPreempt
+			}
+			o.emit(typ, ts, m.p, gid, o.stk(args[1]))
+		case ev2GoBlock:
+			g.status = goWaiting
+			o.emit(blockReason(o.gen.strings[args[0]]), ts, m.p, gid, o.stk(args[1]))
+		}
+		m.g = 0
+
+	case ev2GoUnblock:
+		gid := args[0]
+		g, ok := o.gs[gid]
+		if !ok || g.status != goWaiting || !o.succeeds(args[1], g.seq, g.seqGen) {
+			return false, nil
+		}
+		g.status = goRunnable
+		g.seq = args[1]
+		// Anything can unblock a goroutine, including goroutines in syscalls and Ms without Ps. We attribute the
+		// latter two to no goroutine at all.
+		pid, cur := m.p, m.g
+		if cg, ok := o.gs[cur]; !ok || cg.status != goRunning || pid == -1 {
+			pid, cur = -1, 0
+		}
+		o.emit(EvGoUnblock, ts, pid, cur, o.stk(args[2]), gid, args[1])
+
+	case ev2GoSwitch, ev2GoSwitchDestroy:
+		g, err := needRunning()
+		if err != nil {
+			return false, err
+		}
+		nextID := args[0]
+		next, ok := o.gs[nextID]
+		if !ok || next.status != goWaiting || !o.succeeds(args[1], next.seq, next.seqGen) {
+			return false, nil
+		}
+		// A switch unblocks the next goroutine, blocks or destroys the current one and starts the next one.
+		gid := m.g
+		o.emit(EvGoUnblock, ts, m.p, gid, 0, nextID, args[1])
+		if ev.typ == ev2GoSwitch {
+			g.status = goWaiting
+			o.emit(EvGoBlock, ts, m.p, gid, 0)
+		} else {
+			delete(o.gs, gid)
+			o.emit(EvGoEnd, ts, m.p, gid, 0)
+		}
+		next.status = goRunning
+		next.seq = args[1]
+		m.g = nextID
+		o.startG(ts, m, nextID, next)
+
+	case ev2GoSyscallBegin:
+		g, err := needRunning()
+		if err != nil {
+			return false, err
+		}
+		p := o.ps[m.p]
+		if !o.succeeds(args[0], p.seq, p.seqGen) {
+			return false, fmt.Errorf("failed to advance syscall of goroutine %d: bad processor sequence number %d", m.g, args[0])
+		}
+		g.status = goSyscall
+		p.status = procSyscall
+		p.seq = args[0]
+		o.emit(EvGoSysCall, ts, m.p, m.g, o.stk(args[1]))
+
+	case ev2GoSyscallEnd:
+		p, err := needP()
+		if err != nil {
+			return false, err
+		}
+		g, err := needG()
+		if err != nil {
+			return false, err
+		}
+		if g.status != goSyscall {
+			return false, fmt.Errorf("g %d is not in a syscall during syscall exit (time %d)", m.g, ts)
+		}
+		if p.status != procSyscall {
+			return false, fmt.Errorf("processor %d is not in a syscall during syscall exit (time %d)", m.p, ts)
+		}
+		g.status = goRunning
+		p.status = procRunning
+		if g.sysBlocked {
+			// The goroutine was already in a syscall when we first saw it.
+			o.emit(EvGoSysExit, ts, m.p, m.g, 0, m.g)
+			g.sysBlocked = false
+			o.startG(ts, m, m.g, g)
+		}
+
+	case ev2GoSyscallEndBlocked:
+		if m.p != -1 {
+			if p, ok := o.ps[m.p]; ok && p.status == procSyscall {
+				// We'll lose the P at some point, wait for that to happen.
+				return false, nil
+			}
+		}
+		g, err := needG()
+		if err != nil {
+			return false, err
+		}
+		if g.status != goSyscall {
+			return false, fmt.Errorf("g %d is not in a syscall during syscall exit (time %d)", m.g, ts)
+		}
+		o.sysBlock(ts, m.g)
+		g.status = goRunnable
+		g.sysBlocked = false
+		o.emit(EvGoSysExit, ts, m.p, m.g, 0, m.g)
+		m.g = 0
+
+	case ev2GoDestroySyscall:
+		g, err := needG()
+		if err != nil {
+			return false, err
+		}
+		if g.status != goSyscall {
+			return false, fmt.Errorf("g %d is not in a syscall during destruction (time %d)", m.g, ts)
+		}
+		// Older traces have no concept of goroutines ending in syscalls, and the runtime reuses the goroutine for
+		// the next cgo callback on the same thread. We keep the goroutine blocked in the syscall instead.
+		o.sysBlock(ts, m.g)
+		delete(o.gs, m.g)
+		o.syscallGs[m.g] = struct{}{}
+		m.g = 0
+		if m.p != -1 {
+			p := o.ps[m.p]
+			if p.status != procSyscall {
+				return false, fmt.Errorf("processor %d is not in a syscall during goroutine destruction (time %d)", m.p, ts)
+			}
+			p.status = procSyscallAbandoned
+			o.stopP(ts, m.p, p)
+			m.p = -1
+		}
+
+	case ev2STWBegin:
+		if _, err := needG(); err != nil {
+			return false, err
+		}
+		if !o.inSTW {
+			o.emit(EvSTWStart, ts, m.p, m.g, o.stk(args[1]), o.str(args[0]))
+			o.inSTW = true
+		}
+
+	case ev2STWEnd:
+		if o.inSTW {
+			o.emit(EvSTWDone, ts, m.p, m.g, 0)
+			o.inSTW = false
+		}
+
+	case ev2GCActive:
+		if o.gcState == gcUndetermined {
+			o.gcSeq = args[0]
+			o.gcState = gcRunning
+			o.emit(EvGCStart, ts, m.p, m.g, 0, args[0])
+			break
+		}
+		if args[0] != o.gcSeq+1 {
+			return false, nil
+		}
+		if o.gcState != gcRunning {
+			return false, fmt.Errorf("encountered GCActive while GC was not in progress (time %d)", ts)
+		}
+		o.gcSeq = args[0]
+
+	case ev2GCBegin:
+		if o.gcState != gcUndetermined {
+			if args[0] != o.gcSeq+1 {
+				return false, nil
+			}
+			if o.gcState == gcRunning {
+				return false, fmt.Errorf("previous GC is not ended before a new one (time %d)", ts)
+			}
+		}
+		o.gcSeq = args[0]
+		o.gcState = gcRunning
+		o.emit(EvGCStart, ts, m.p, m.g, o.stk(args[1]), args[0])
+
+	case ev2GCEnd:
+		if args[0] != o.gcSeq+1 {
+			return false, nil
+		}
+		if o.gcState != gcRunning {
+			return false, fmt.Errorf("bogus GC end (time %d)", ts)
+		}
+		o.gcSeq = args[0]
+		o.gcState = gcNotRunning
+		o.emit(EvGCDone, ts, m.p, m.g, 0)
+
+	case ev2GCSweepActive, ev2GCMarkAssistActive:
+		// Older traces don't have a notion of sweeping or mark assist being in progress when tracing starts. We drop
+		// the end of sweeping, and older traces already tolerate the end of mark assist without a start.
+
+	case ev2GCSweepBegin:
+		p, err := needP()
+		if err != nil {
+			return false, err
+		}
+		p.sweeping = true
+		o.emit(EvGCSweepStart, ts, m.p, m.g, o.stk(args[0]))
+
+	case ev2GCSweepEnd:
+		p, err := needP()
+		if err != nil {
+			return false, err
+		}
+		if p.sweeping {
+			o.emit(EvGCSweepDone, ts, m.p, m.g, 0, args[0], args[1])
+			p.sweeping = false
+		}
+
+	case ev2GCMarkAssistBegin:
+		if _, err := needG(); err != nil {
+			return false, err
+		}
+		o.emit(EvGCMarkAssistStart, ts, m.p, m.g, o.stk(args[0]))
+
+	case ev2GCMarkAssistEnd:
+		if _, err := needG(); err != nil {
+			return false, err
+		}
+		o.emit(EvGCMarkAssistDone, ts, m.p, m.g, 0)
+
+	case ev2HeapAlloc:
+		o.emit(EvHeapAlloc, ts, m.p, m.g, 0, args[0])
+
+	case ev2HeapGoal:
+		o.emit(EvHeapGoal, ts, m.p, m.g, 0, args[0])
+
+	case ev2ProcsChange:
+		o.emit(EvGomaxprocs, ts, m.p, m.g, o.stk(args[1]), args[0])
+
+	case ev2GoLabel:
+		// Labels are emitted right after the goroutine starts running. Older traces used a single event for this.
+		if m.lastStart != -1 {
+			if start := o.events.Ptr(m.lastStart); start.G == m.g && start.Type == EvGoStart {
+				start.Type = EvGoStartLabel
+				start.Args[ArgGoStartLabelLabelID] = o.str(args[0])
+			}
+		}
+
+	case ev2UserTaskBegin:
+		if _, err := needG(); err != nil {
+			return false, err
+		}
+		o.emit(EvUserTaskCreate, ts, m.p, m.g, o.stk(args[3]), args[0], args[1], o.str(args[2]))
+
+	case ev2UserTaskEnd:
+		if _, err := needG(); err != nil {
+			return false, err
+		}
+		o.emit(EvUserTaskEnd, ts, m.p, m.g, o.stk(args[1]), args[0])
+
+	case ev2UserRegionBegin, ev2UserRegionEnd:
+		if _, err := needG(); err != nil {
+			return false, err
+		}
+		var mode uint64
+		if ev.typ == ev2UserRegionEnd {
+			mode = 1
+		}
+		o.emit(EvUserRegion, ts, m.p, m.g, o.stk(args[2]), args[0], mode, o.str(args[1]))
+
+	case ev2UserLog:
+		if _, err := needG(); err != nil {
+			return false, err
+		}
+		o.emit(EvUserLog, ts, m.p, m.g, o.stk(args[3]), args[0], o.str(args[1]), 0, o.str(args[2]))
+
+	default:
+		return false, fmt.Errorf("unexpected event type %d", ev.typ)
+	}
+	return true, nil
+}
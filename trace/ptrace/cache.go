@@ -0,0 +1,587 @@
+package ptrace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+// The cache format stores a processed trace so that it can be loaded without parsing and processing the trace again.
+// All integers are little-endian. Events, spans and other records that make up the bulk of the data have fixed sizes
+// and are stored as arrays that start at 8-byte aligned offsets, preceded by their lengths. Every record takes up at
+// least 8 bytes.
+//
+// The cache starts with cacheMagic, the version of the format and the version of the program that wrote the cache.
+// Caches are only valid for the exact same format and program version, as the program might process traces
+// differently.
+
+const cacheMagic = "gotraceui ptrace cache\n"
+
+// cacheFormat has to be incremented whenever the cache format or the data stored in it changes.
+const cacheFormat = 5
+
+const (
+	cacheEventSize = 64
+	cacheSpanSize  = 24
+)
+
+// ErrCacheVersion is returned by ReadCache for caches that were written by a different version of the program or in
+// a different format.
+var ErrCacheVersion = errors.New("cache was written by a different version")
+
+type cacheWriter struct {
+	w   *bufio.Writer
+	off int64
+	buf [cacheEventSize]byte
+	err error
+}
+
+func (w *cacheWriter) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(b)
+	w.off += int64(n)
+	w.err = err
+}
+
+func (w *cacheWriter) u8(v uint8) { w.write([]byte{v}) }
+
+func (w *cacheWriter) u32(v uint32) {
+	binary.LittleEndian.PutUint32(w.buf[:4], v)
+	w.write(w.buf[:4])
+}
+
+func (w *cacheWriter) u64(v uint64) {
+	binary.LittleEndian.PutUint64(w.buf[:8], v)
+	w.write(w.buf[:8])
+}
+
+func (w *cacheWriter) i64(v int64) { w.u64(uint64(v)) }
+
+func (w *cacheWriter) str(s string) {
+	w.u32(uint32(len(s)))
+	if w.err == nil {
+		n, err := w.w.WriteString(s)
+		w.off += int64(n)
+		w.err = err
+	}
+}
+
+// count writes the length of an array of fixed-size records and aligns the start of the array.
+func (w *cacheWriter) count(n int) {
+	w.align()
+	w.u64(uint64(n))
+}
+
+func (w *cacheWriter) align() {
+	var zero [8]byte
+	if pad := (8 - w.off%8) % 8; pad != 0 {
+		w.write(zero[:pad])
+	}
+}
+
+func (w *cacheWriter) event(ev *trace.Event) {
+	b := w.buf[:cacheEventSize]
+	binary.LittleEndian.PutUint64(b[0:], uint64(ev.Ts))
+	binary.LittleEndian.PutUint64(b[8:], ev.G)
+	for i, arg := range ev.Args {
+		binary.LittleEndian.PutUint64(b[16+i*8:], arg)
+	}
+	binary.LittleEndian.PutUint32(b[48:], ev.StkID)
+	binary.LittleEndian.PutUint32(b[52:], uint32(ev.P))
+	// Links are stored as the index plus one, in 40 bits.
+	link := uint64(ev.Link() + 1)
+	binary.LittleEndian.PutUint32(b[56:], uint32(link))
+	b[60] = uint8(link >> 32)
+	b[61] = ev.Type
+	b[62], b[63] = 0, 0
+	w.write(b)
+}
+
+func (w *cacheWriter) spans(spans []Span) {
+	w.count(len(spans))
+	b := w.buf[:cacheSpanSize]
+	for _, s := range spans {
+		binary.LittleEndian.PutUint64(b[0:], uint64(s.Start))
+		binary.LittleEndian.PutUint64(b[8:], uint64(s.End))
+		binary.LittleEndian.PutUint32(b[16:], s.eventLo)
+		b[20] = s.eventHi
+		b[21] = s.At
+		b[22] = uint8(s.State)
+		b[23] = uint8(s.Tags)
+		w.write(b)
+	}
+}
+
+func (w *cacheWriter) eventIDs(evs []EventID) {
+	w.count(len(evs))
+	for _, ev := range evs {
+		w.i64(int64(ev))
+	}
+}
+
+func (w *cacheWriter) points(pts []Point) {
+	w.count(len(pts))
+	for _, pt := range pts {
+		w.i64(int64(pt.When))
+		w.u64(pt.Value)
+	}
+}
+
+// WriteCache writes the trace to w in a format that can be read by ReadCache. The version identifies the program
+// writing the cache.
+func (tr *Trace) WriteCache(w io.Writer, version string) error {
+	cw := &cacheWriter{w: bufio.NewWriterSize(w, 1<<20)}
+	cw.write([]byte(cacheMagic))
+	cw.u32(cacheFormat)
+	cw.str(version)
+
+	// Sets of tags are interned in the order they're first used, which differs between processes. Store the sets so
+	// that the reader can map them to its own IDs.
+	masks := spanTagMasks()
+	cw.count(len(masks))
+	for _, mask := range masks {
+		cw.u64(mask)
+	}
+
+	cw.align()
+	cw.i64(int64(tr.Version))
+	cw.i64(int64(tr.Start))
+	cw.count(tr.Events.Len())
+	for i := 0; i < tr.Events.Len(); i++ {
+		cw.event(tr.Events.Ptr(i))
+	}
+	cw.count(len(tr.Stacks))
+	for id, pcs := range tr.Stacks {
+		cw.u64(uint64(id))
+		cw.count(len(pcs))
+		for _, pc := range pcs {
+			cw.u64(pc)
+		}
+	}
+	cw.count(len(tr.PCs))
+	for _, frame := range tr.PCs {
+		cw.u64(frame.PC)
+		cw.i64(int64(frame.Line))
+		cw.str(frame.Fn)
+		cw.str(frame.File)
+		cw.align()
+	}
+	cw.count(len(tr.Strings))
+	for id, s := range tr.Strings {
+		cw.u64(id)
+		cw.str(s)
+		cw.align()
+	}
+	cw.count(len(tr.Diagnostics))
+	for _, d := range tr.Diagnostics {
+		cw.i64(d.Offset)
+		cw.u8(d.Type)
+		cw.str(d.Reason)
+		cw.align()
+	}
+
+	fns := make([]*Function, len(tr.Functions))
+	for _, fn := range tr.Functions {
+		fns[fn.SeqID] = fn
+	}
+	cw.count(len(fns))
+	for _, fn := range fns {
+		cw.u64(fn.PC)
+		cw.i64(int64(fn.Line))
+		cw.str(fn.Fn)
+		cw.str(fn.File)
+		cw.align()
+	}
+
+	cw.count(len(tr.Goroutines))
+	for _, g := range tr.Goroutines {
+		cw.u64(g.ID)
+		cw.u64(g.Parent)
+		cw.i64(int64(g.SeqID))
+		if g.Function != nil {
+			cw.i64(int64(g.Function.SeqID))
+		} else {
+			cw.i64(-1)
+		}
+		cw.spans(g.Spans)
+		cw.count(len(g.UserRegions))
+		for _, spans := range g.UserRegions {
+			cw.spans(spans)
+		}
+		cw.eventIDs(g.Events)
+	}
+	// The goroutines of functions don't have to match the functions of goroutines; for example, Parse doesn't list
+	// goroutines whose functions are unknown.
+	for _, fn := range fns {
+		cw.count(len(fn.Goroutines))
+		for _, g := range fn.Goroutines {
+			cw.u64(g.ID)
+		}
+	}
+	cw.count(len(tr.Processors))
+	for _, p := range tr.Processors {
+		cw.i64(int64(p.ID))
+		cw.i64(int64(p.SeqID))
+		cw.spans(p.Spans)
+	}
+	cw.count(len(tr.Machines))
+	for _, m := range tr.Machines {
+		cw.i64(int64(m.ID))
+		cw.i64(int64(m.SeqID))
+		cw.spans(m.Spans)
+		cw.spans(m.Goroutines)
+	}
+	cw.spans(tr.GC)
+	cw.spans(tr.STW)
+	cw.count(len(tr.Tasks))
+	for _, t := range tr.Tasks {
+		cw.u64(t.ID)
+		cw.i64(int64(t.SeqID))
+		cw.i64(int64(t.Event))
+		cw.u64(t.ParentID)
+		cw.str(t.Name)
+		cw.align()
+	}
+	cw.points(tr.HeapSize)
+	cw.points(tr.HeapGoal)
+	cw.count(len(tr.CPUSamples))
+	for gid, evs := range tr.CPUSamples {
+		cw.u64(gid)
+		cw.eventIDs(evs)
+	}
+	cw.count(len(tr.Logs))
+	for cat, evs := range tr.Logs {
+		cw.str(cat)
+		cw.align()
+		cw.eventIDs(evs)
+	}
+	if tr.HasCPUSamples {
+		cw.u64(1)
+	} else {
+		cw.u64(0)
+	}
+	cw.count(len(tr.Gaps))
+	for _, gap := range tr.Gaps {
+		cw.i64(int64(gap.Start))
+		cw.i64(int64(gap.End))
+	}
+
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+type cacheReader struct {
+	r    *bufio.Reader
+	off  int64
+	size int64
+	buf  [cacheEventSize]byte
+	err  error
+
+	// Maps the IDs of sets of tags in the cache to our IDs.
+	tags [256]SpanTags
+}
+
+func (r *cacheReader) read(b []byte) {
+	if r.err != nil {
+		for i := range b {
+			b[i] = 0
+		}
+		return
+	}
+	n, err := io.ReadFull(r.r, b)
+	r.off += int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	r.err = err
+}
+
+func (r *cacheReader) u8() uint8 {
+	r.read(r.buf[:1])
+	return r.buf[0]
+}
+
+func (r *cacheReader) u32() uint32 {
+	r.read(r.buf[:4])
+	return binary.LittleEndian.Uint32(r.buf[:4])
+}
+
+func (r *cacheReader) u64() uint64 {
+	r.read(r.buf[:8])
+	return binary.LittleEndian.Uint64(r.buf[:8])
+}
+
+func (r *cacheReader) i64() int64 { return int64(r.u64()) }
+
+func (r *cacheReader) str() string {
+	n := r.u32()
+	if r.err != nil {
+		return ""
+	}
+	b := make([]byte, n)
+	r.read(b)
+	return string(b)
+}
+
+func (r *cacheReader) align() {
+	if pad := (8 - r.off%8) % 8; pad != 0 {
+		r.read(r.buf[:pad])
+	}
+}
+
+// count reads the length of an array of records that take up at least size bytes each, and rejects lengths that don't
+// fit in the rest of the cache, to avoid huge allocations for corrupted or truncated caches.
+func (r *cacheReader) count(size int) int {
+	r.align()
+	n := r.u64()
+	if rem := r.size - r.off; (rem < 0 || n > uint64(rem)/uint64(size)) && r.err == nil {
+		r.err = fmt.Errorf("invalid array length %d at offset %d", n, r.off-8)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func (r *cacheReader) event(ev *trace.Event) {
+	b := r.buf[:cacheEventSize]
+	r.read(b)
+	ev.Ts = trace.Timestamp(binary.LittleEndian.Uint64(b[0:]))
+	ev.G = binary.LittleEndian.Uint64(b[8:])
+	for i := range ev.Args {
+		ev.Args[i] = binary.LittleEndian.Uint64(b[16+i*8:])
+	}
+	ev.StkID = binary.LittleEndian.Uint32(b[48:])
+	ev.P = int32(binary.LittleEndian.Uint32(b[52:]))
+	link := uint64(binary.LittleEndian.Uint32(b[56:])) | uint64(b[60])<<32
+	ev.SetLink(int64(link) - 1)
+	ev.Type = b[61]
+}
+
+func (r *cacheReader) spans() []Span {
+	n := r.count(cacheSpanSize)
+	if n == 0 {
+		// Parse doesn't allocate empty lists of spans, either.
+		return nil
+	}
+	spans := make([]Span, n)
+	b := r.buf[:cacheSpanSize]
+	for i := range spans {
+		r.read(b)
+		spans[i] = Span{
+			Start:   trace.Timestamp(binary.LittleEndian.Uint64(b[0:])),
+			End:     trace.Timestamp(binary.LittleEndian.Uint64(b[8:])),
+			eventLo: binary.LittleEndian.Uint32(b[16:]),
+			eventHi: b[20],
+			At:      b[21],
+			State:   SchedulingState(b[22]),
+			Tags:    r.tags[b[23]],
+		}
+	}
+	return spans
+}
+
+func (r *cacheReader) eventIDs() []EventID {
+	n := r.count(8)
+	evs := make([]EventID, n)
+	for i := range evs {
+		evs[i] = EventID(r.i64())
+	}
+	return evs
+}
+
+func (r *cacheReader) points() []Point {
+	n := r.count(16)
+	pts := make([]Point, n)
+	for i := range pts {
+		pts[i] = Point{When: trace.Timestamp(r.i64()), Value: r.u64()}
+	}
+	return pts
+}
+
+// ReadCache reads a trace that was written by Trace.WriteCache. The size of the cache is used to validate it. It returns
+// ErrCacheVersion if the cache was written by a different version of the program.
+func ReadCache(rd io.Reader, size int64, version string, progress func(float64)) (*Trace, error) {
+	r := &cacheReader{r: bufio.NewReaderSize(rd, 1<<20), size: size}
+	magic := make([]byte, len(cacheMagic))
+	r.read(magic)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if string(magic) != cacheMagic {
+		return nil, errors.New("not a trace cache")
+	}
+	if r.u32() != cacheFormat || r.str() != version {
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, ErrCacheVersion
+	}
+
+	n := r.count(8)
+	if n > len(r.tags) {
+		return nil, errors.New("invalid number of sets of tags")
+	}
+	for i := 0; i < n && r.err == nil; i++ {
+		ts, ok := spanTagsFromMask(r.u64())
+		if !ok {
+			return nil, errors.New("too many different sets of tags in use")
+		}
+		r.tags[i] = ts
+	}
+
+	tr := &Trace{
+		Functions:  map[string]*Function{},
+		gsByID:     map[uint64]*Goroutine{},
+		CPUSamples: map[uint64][]EventID{},
+		Logs:       map[string][]EventID{},
+	}
+
+	r.align()
+	tr.Version = int(r.i64())
+	tr.Start = trace.Timestamp(r.i64())
+	n = r.count(cacheEventSize)
+	for i := 0; i < n && r.err == nil; i++ {
+		r.event(tr.Events.Grow())
+		if (i+1)%100_000 == 0 {
+			progress(float64(i) / float64(n) / 2)
+		}
+	}
+	n = r.count(8)
+	tr.Stacks = make(map[uint32][]uint64, n)
+	for i := 0; i < n; i++ {
+		id := uint32(r.u64())
+		pcs := make([]uint64, r.count(8))
+		for j := range pcs {
+			pcs[j] = r.u64()
+		}
+		tr.Stacks[id] = pcs
+	}
+	n = r.count(8)
+	tr.PCs = make(map[uint64]trace.Frame, n)
+	for i := 0; i < n; i++ {
+		frame := trace.Frame{PC: r.u64(), Line: int(r.i64()), Fn: r.str(), File: r.str()}
+		r.align()
+		tr.PCs[frame.PC] = frame
+	}
+	n = r.count(8)
+	tr.Strings = make(map[uint64]string, n)
+	for i := 0; i < n; i++ {
+		id := r.u64()
+		tr.Strings[id] = r.str()
+		r.align()
+	}
+	n = r.count(8)
+	for i := 0; i < n; i++ {
+		d := trace.Diagnostic{Offset: r.i64(), Type: r.u8(), Reason: r.str()}
+		r.align()
+		tr.Diagnostics = append(tr.Diagnostics, d)
+	}
+
+	fns := make([]*Function, r.count(8))
+	for i := range fns {
+		fn := &Function{
+			Frame: trace.Frame{PC: r.u64(), Line: int(r.i64()), Fn: r.str(), File: r.str()},
+			SeqID: i,
+		}
+		r.align()
+		fns[i] = fn
+		tr.Functions[fn.Fn] = fn
+	}
+
+	tr.Goroutines = make([]*Goroutine, r.count(8))
+	for i := range tr.Goroutines {
+		g := &Goroutine{
+			ID:     r.u64(),
+			Parent: r.u64(),
+			SeqID:  int(r.i64()),
+		}
+		if fn := r.i64(); fn >= 0 && fn < int64(len(fns)) {
+			g.Function = fns[fn]
+		}
+		g.Spans = r.spans()
+		if n := r.count(8); n > 0 {
+			g.UserRegions = make([][]Span, n)
+			for j := range g.UserRegions {
+				g.UserRegions[j] = r.spans()
+			}
+		}
+		g.Events = r.eventIDs()
+		tr.Goroutines[i] = g
+		tr.gsByID[g.ID] = g
+		if (i+1)%1000 == 0 {
+			progress(0.5 + float64(i)/float64(len(tr.Goroutines))/2)
+		}
+	}
+	for _, fn := range fns {
+		n := r.count(8)
+		for i := 0; i < n; i++ {
+			if g, ok := tr.gsByID[r.u64()]; ok {
+				fn.Goroutines = append(fn.Goroutines, g)
+			}
+		}
+	}
+	tr.Processors = make([]*Processor, r.count(8))
+	for i := range tr.Processors {
+		tr.Processors[i] = &Processor{
+			ID:    int32(r.i64()),
+			SeqID: int(r.i64()),
+			Spans: r.spans(),
+		}
+	}
+	tr.Machines = make([]*Machine, r.count(8))
+	for i := range tr.Machines {
+		tr.Machines[i] = &Machine{
+			ID:         int32(r.i64()),
+			SeqID:      int(r.i64()),
+			Spans:      r.spans(),
+			Goroutines: r.spans(),
+		}
+	}
+	tr.GC = r.spans()
+	tr.STW = r.spans()
+	tr.Tasks = make([]*Task, r.count(8))
+	for i := range tr.Tasks {
+		tr.Tasks[i] = &Task{
+			ID:       r.u64(),
+			SeqID:    int(r.i64()),
+			Event:    EventID(r.i64()),
+			ParentID: r.u64(),
+			Name:     r.str(),
+		}
+		r.align()
+	}
+	tr.linkTasks()
+	tr.HeapSize = r.points()
+	tr.HeapGoal = r.points()
+	n = r.count(8)
+	for i := 0; i < n; i++ {
+		gid := r.u64()
+		tr.CPUSamples[gid] = r.eventIDs()
+	}
+	n = r.count(8)
+	for i := 0; i < n; i++ {
+		cat := r.str()
+		r.align()
+		tr.Logs[cat] = r.eventIDs()
+	}
+	tr.HasCPUSamples = r.u64() != 0
+	tr.Gaps = make([]Gap, r.count(8))
+	for i := range tr.Gaps {
+		tr.Gaps[i] = Gap{Start: trace.Timestamp(r.i64()), End: trace.Timestamp(r.i64())}
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("couldn't read trace cache: %w", r.err)
+	}
+	progress(1)
+	return tr, nil
+}
@@ -0,0 +1,112 @@
+package ptrace_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestCache(t *testing.T) {
+	for _, name := range []string{"user_task_region_1_21_good", "stress_1_26_good", "http_1_11_good"} {
+		t.Run(name, func(t *testing.T) {
+			want := loadTrace(t, name)
+
+			var buf bytes.Buffer
+			if err := want.WriteCache(&buf, "v1"); err != nil {
+				t.Fatal(err)
+			}
+			size := int64(buf.Len())
+			if _, err := ptrace.ReadCache(bytes.NewReader(buf.Bytes()), size, "v2", func(float64) {}); !errors.Is(err, ptrace.ErrCacheVersion) {
+				t.Errorf("got error %v for cache of different version, want ErrCacheVersion", err)
+			}
+			if _, err := ptrace.ReadCache(bytes.NewReader(buf.Bytes()[:buf.Len()/2]), size/2, "v1", func(float64) {}); err == nil {
+				t.Errorf("expected error for truncated cache")
+			}
+			// The first array, the sets of tags, starts after the magic, the format and the version, at offset 40.
+			corrupt := bytes.Clone(buf.Bytes())
+			binary.LittleEndian.PutUint64(corrupt[40:], 1<<40)
+			if _, err := ptrace.ReadCache(bytes.NewReader(corrupt), size, "v1", func(float64) {}); err == nil {
+				t.Errorf("expected error for cache with invalid array length")
+			}
+			got, err := ptrace.ReadCache(&buf, size, "v1", func(float64) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Version != want.Version || got.Start != want.Start {
+				t.Errorf("got version %d and start %d, want %d and %d", got.Version, got.Start, want.Version, want.Start)
+			}
+			if got.Events.Len() != want.Events.Len() {
+				t.Fatalf("got %d events, want %d", got.Events.Len(), want.Events.Len())
+			}
+			for i := 0; i < want.Events.Len(); i++ {
+				if g, w := got.Events.Get(i), want.Events.Get(i); g != w {
+					t.Fatalf("event %d: got %v, want %v", i, g, w)
+				}
+			}
+			for _, tt := range []struct {
+				name      string
+				got, want any
+			}{
+				{"stacks", got.Stacks, want.Stacks},
+				{"PCs", got.PCs, want.PCs},
+				{"strings", got.Strings, want.Strings},
+				{"diagnostics", got.Diagnostics, want.Diagnostics},
+				{"GC", got.GC, want.GC},
+				{"STW", got.STW, want.STW},
+				{"tasks", got.Tasks, want.Tasks},
+				{"heap size", got.HeapSize, want.HeapSize},
+				{"heap goal", got.HeapGoal, want.HeapGoal},
+				{"CPU samples", got.CPUSamples, want.CPUSamples},
+				{"logs", got.Logs, want.Logs},
+				{"processors", got.Processors, want.Processors},
+				{"machines", got.Machines, want.Machines},
+			} {
+				if !deepEqual(tt.got, tt.want) {
+					t.Errorf("%s differ", tt.name)
+				}
+			}
+
+			if len(got.Functions) != len(want.Functions) {
+				t.Errorf("got %d functions, want %d", len(got.Functions), len(want.Functions))
+			}
+			for name, wfn := range want.Functions {
+				gfn, ok := got.Functions[name]
+				if !ok || gfn.Frame != wfn.Frame || gfn.SeqID != wfn.SeqID || len(gfn.Goroutines) != len(wfn.Goroutines) {
+					t.Errorf("function %q differs", name)
+				}
+			}
+			if len(got.Goroutines) != len(want.Goroutines) {
+				t.Fatalf("got %d goroutines, want %d", len(got.Goroutines), len(want.Goroutines))
+			}
+			for i, wg := range want.Goroutines {
+				gg := got.G(wg.ID)
+				if gg != got.Goroutines[i] {
+					t.Fatalf("goroutine %d isn't at index %d", wg.ID, i)
+				}
+				if gg.Parent != wg.Parent || gg.SeqID != wg.SeqID || (wg.Function == nil) != (gg.Function == nil) ||
+					(wg.Function != nil && gg.Function.Fn != wg.Function.Fn) {
+					t.Errorf("goroutine %d differs", wg.ID)
+				}
+				if !deepEqual(gg.Spans, wg.Spans) || !deepEqual(gg.UserRegions, wg.UserRegions) || !deepEqual(gg.Events, wg.Events) {
+					t.Errorf("spans or events of goroutine %d differ", wg.ID)
+				}
+			}
+		})
+	}
+}
+
+// deepEqual is like reflect.DeepEqual, but considers nil and empty slices and maps to be equal.
+func deepEqual(a, b any) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() == reflect.Slice || va.Kind() == reflect.Map {
+		if va.Len() == 0 && vb.Len() == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,122 @@
+package ptrace
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+// A ChannelWait is a span during which a goroutine was blocked sending to or receiving from a channel.
+type ChannelWait struct {
+	Goroutine *Goroutine
+	Span      Span
+	// The goroutine that completed the communication, unblocking the waiting goroutine, or nil if it is unknown
+	Partner *Goroutine
+}
+
+// A ChannelSitePair is a pair of locations in the code that communicated via a channel. Sender and Receiver are the
+// zero value if the site couldn't be determined, for example because stacks weren't available.
+type ChannelSitePair struct {
+	Sender, Receiver trace.Frame
+	// Waits of senders blocked until a receiver arrived, sorted by duration in descending order. Many or long waits
+	// point at slow consumers or undersized buffers.
+	SendWaits   []ChannelWait
+	SendBlocked LatencyPercentiles
+	// Waits of receivers blocked until a sender arrived, sorted by duration in descending order
+	RecvWaits   []ChannelWait
+	RecvBlocked LatencyPercentiles
+}
+
+// Total returns the time senders and receivers spent blocked.
+func (p *ChannelSitePair) Total() time.Duration {
+	return p.SendBlocked.Total + p.RecvBlocked.Total
+}
+
+// ChannelAnalysis describes the communication between goroutines via channels.
+type ChannelAnalysis struct {
+	// Pairs of sites, sorted by total blocked time in descending order
+	Pairs     []*ChannelSitePair
+	SendTotal time.Duration
+	RecvTotal time.Duration
+}
+
+// userFrame returns the first frame of stk for which skip returns false. If all frames are skipped, it returns the last
+// frame, and the zero value for empty stacks.
+func (tr *Trace) userFrame(stk []uint64, skip func(fn string) bool) trace.Frame {
+	var frame trace.Frame
+	for _, pc := range stk {
+		frame = tr.PCs[pc]
+		if !skip(frame.Fn) {
+			break
+		}
+	}
+	return frame
+}
+
+func isRuntimeFunction(fn string) bool {
+	return strings.HasPrefix(fn, "runtime.")
+}
+
+// Channels pairs goroutines that blocked sending to or receiving from channels with the goroutines that unblocked them,
+// grouping them by the locations of the send and the receive. Blocking select statements aren't included, as the trace
+// doesn't tell us which of their cases caused them to unblock.
+func (tr *Trace) Channels() *ChannelAnalysis {
+	var out ChannelAnalysis
+	pairs := map[[2]uint64]*ChannelSitePair{}
+	for _, g := range tr.Goroutines {
+		for _, s := range g.Spans {
+			if s.State != StateBlockedSend && s.State != StateBlockedRecv {
+				continue
+			}
+
+			site := tr.userFrame(tr.Stacks[tr.Event(s.Event()).StkID], isRuntimeFunction)
+			w := ChannelWait{Goroutine: g, Span: s}
+			var partnerSite trace.Frame
+			if ev, ok := tr.UnblockingEvent(s); ok {
+				w.Partner = tr.gsByID[tr.Event(ev).G]
+				partnerSite = tr.userFrame(tr.Stacks[tr.Event(ev).StkID], isRuntimeFunction)
+			}
+
+			sender, receiver := site, partnerSite
+			if s.State == StateBlockedRecv {
+				sender, receiver = partnerSite, site
+			}
+			key := [2]uint64{sender.PC, receiver.PC}
+			pair, ok := pairs[key]
+			if !ok {
+				pair = &ChannelSitePair{Sender: sender, Receiver: receiver}
+				pairs[key] = pair
+				out.Pairs = append(out.Pairs, pair)
+			}
+			if s.State == StateBlockedSend {
+				pair.SendWaits = append(pair.SendWaits, w)
+			} else {
+				pair.RecvWaits = append(pair.RecvWaits, w)
+			}
+		}
+	}
+
+	summarize := func(waits []ChannelWait) LatencyPercentiles {
+		sort.SliceStable(waits, func(i, j int) bool {
+			return waits[i].Span.Duration() > waits[j].Span.Duration()
+		})
+		ds := make([]time.Duration, len(waits))
+		for i, w := range waits {
+			ds[len(ds)-i-1] = w.Span.Duration()
+		}
+		return ComputeLatencyPercentiles(ds)
+	}
+	for _, pair := range out.Pairs {
+		pair.SendBlocked = summarize(pair.SendWaits)
+		pair.RecvBlocked = summarize(pair.RecvWaits)
+		out.SendTotal += pair.SendBlocked.Total
+		out.RecvTotal += pair.RecvBlocked.Total
+	}
+	sort.SliceStable(out.Pairs, func(i, j int) bool {
+		return out.Pairs[i].Total() > out.Pairs[j].Total()
+	})
+
+	return &out
+}
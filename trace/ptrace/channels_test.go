@@ -0,0 +1,33 @@
+package ptrace_test
+
+import (
+	"testing"
+
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestChannelsPingPong(t *testing.T) {
+	ptr := pingPongTrace(t)
+	g1, g2 := ptr.Goroutines[0], ptr.Goroutines[1]
+
+	ca := ptr.Channels()
+	if ca.SendTotal != 0 || ca.RecvTotal != 4 || len(ca.Pairs) != 1 {
+		t.Fatalf("got %d pairs with totals of %s and %s, want 1 pair with totals of 0s and 4ns",
+			len(ca.Pairs), ca.SendTotal, ca.RecvTotal)
+	}
+	pair := ca.Pairs[0]
+	if pair.Sender.Fn != "main.worker" || pair.Receiver.Fn != "main.main" {
+		t.Errorf("got pair %s → %s, want main.worker → main.main", pair.Sender.Fn, pair.Receiver.Fn)
+	}
+	if len(pair.SendWaits) != 0 || pair.SendBlocked.Count != 0 {
+		t.Errorf("got %d send waits, want none", len(pair.SendWaits))
+	}
+	if len(pair.RecvWaits) != 1 || pair.RecvWaits[0].Goroutine != g1 || pair.RecvWaits[0].Partner != g2 ||
+		pair.RecvWaits[0].Span.Start != 6 || pair.RecvWaits[0].Span.End != 10 {
+		t.Errorf("got receive waits %+v, want g1 waiting from 6 to 10 for g2", pair.RecvWaits)
+	}
+	want := ptrace.LatencyPercentiles{Count: 1, Total: 4, Min: 4, P50: 4, P90: 4, P99: 4, P999: 4, Max: 4}
+	if pair.RecvBlocked != want || pair.Total() != 4 {
+		t.Errorf("got %+v, want %+v", pair.RecvBlocked, want)
+	}
+}
@@ -0,0 +1,258 @@
+package ptrace
+
+import (
+	"sort"
+
+	"honnef.co/go/gotraceui/trace"
+
+	"golang.org/x/exp/slices"
+)
+
+// Gap is a period of time between two concatenated traces during which nothing was recorded.
+type Gap struct {
+	Start trace.Timestamp
+	End   trace.Timestamp
+}
+
+// Concat concatenates traces that were recorded one after the other by the same process, such as traces that were
+// collected by repeatedly starting and stopping tracing, into a single trace. The traces are put in the order in which
+// they were recorded.
+//
+// Goroutines, processors, machines and tasks are merged by their IDs, and the periods between traces are recorded in
+// Trace.Gaps. User regions and tasks that were still active at the end of one trace are ended by the matching events
+// in the following trace.
+//
+// The traces mustn't be used anymore after calling Concat, as their data is reused.
+func Concat(trs []*Trace) (*Trace, error) {
+	if len(trs) == 1 {
+		return trs[0], nil
+	}
+	trs = slices.Clone(trs)
+	sort.SliceStable(trs, func(i, j int) bool { return trs[i].Start < trs[j].Start })
+	parts := make([]trace.Trace, len(trs))
+	for i, tr := range trs {
+		parts[i] = tr.Trace
+	}
+	res, err := trace.Concat(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Trace{
+		Trace:      res,
+		Functions:  map[string]*Function{},
+		gsByID:     map[uint64]*Goroutine{},
+		psByID:     map[int32]*Processor{},
+		msByID:     map[int32]*Machine{},
+		CPUSamples: map[uint64][]EventID{},
+		Logs:       map[string][]EventID{},
+		GC:         make(spansSlice, 0),
+		STW:        make(spansSlice, 0),
+	}
+
+	type regionRef struct {
+		depth int
+		idx   int
+	}
+	// User regions that haven't ended by the end of the previous traces, per goroutine, from the outermost to the
+	// innermost.
+	openRegions := map[uint64][]regionRef{}
+	// Tasks that haven't ended by the end of the previous traces.
+	openTasks := map[uint64]EventID{}
+	tasks := map[uint64]*Task{}
+
+	var evBase EventID
+	var prevEnd trace.Timestamp
+	for i, tr := range trs {
+		shift := tr.Start - res.Start
+		if i > 0 {
+			out.Gaps = append(out.Gaps, Gap{Start: prevEnd, End: shift})
+		}
+		rebase := func(s Span) Span {
+			// Spans that didn't end, such as garbage collections that were still running when the trace ended,
+			// keep their end.
+			if s.End >= s.Start {
+				s.End += shift
+			}
+			s.Start += shift
+			s.SetEvent(s.Event() + evBase)
+			return s
+		}
+
+		// Match the ends of user regions and tasks that started in earlier traces, and compute by how much the depths
+		// of regions have to be increased to account for the regions that are still active.
+		regionDepths := map[uint64]int{}
+		depthOffsets := map[EventID]int{}
+		for j := 0; j < tr.Events.Len(); j++ {
+			ev := out.Event(evBase + EventID(j))
+			switch ev.Type {
+			case trace.EvUserRegion:
+				const regionStart = 0
+				if ev.Args[trace.ArgUserRegionMode] == regionStart {
+					if n := len(openRegions[ev.G]); n > 0 {
+						depthOffsets[EventID(j)] = n
+					}
+					regionDepths[ev.G]++
+				} else if regionDepths[ev.G] > 0 {
+					regionDepths[ev.G]--
+				} else if open := openRegions[ev.G]; len(open) > 0 {
+					ref := open[len(open)-1]
+					g := out.gsByID[ev.G]
+					s := &g.UserRegions[ref.depth][ref.idx]
+					start := out.Event(s.Event())
+					if start.Args[trace.ArgUserRegionTypeID] != ev.Args[trace.ArgUserRegionTypeID] {
+						// The end doesn't belong to the innermost active region, which means that we're missing
+						// events. Leave the regions as they are.
+						continue
+					}
+					start.SetLink(int64(evBase) + int64(j))
+					s.End = ev.Ts
+					openRegions[ev.G] = open[:len(open)-1]
+				}
+			case trace.EvUserTaskEnd:
+				// e.Args 0: taskID
+				id := ev.Args[0]
+				if create, ok := openTasks[id]; ok {
+					out.Event(create).SetLink(int64(evBase) + int64(j))
+					delete(openTasks, id)
+				}
+			}
+		}
+
+		for _, g := range tr.Goroutines {
+			og, ok := out.gsByID[g.ID]
+			if !ok {
+				og = &Goroutine{ID: g.ID, Parent: g.Parent}
+				out.gsByID[g.ID] = og
+			}
+			if og.Function == nil || (og.Function.Fn == "" && g.Function != nil && g.Function.Fn != "") {
+				var frame trace.Frame
+				if g.Function != nil {
+					frame = g.Function.Frame
+				}
+				og.Function = out.function(frame)
+			}
+
+			og.Spans = slices.Grow(og.Spans, len(g.Spans))
+			for _, s := range g.Spans {
+				og.Spans = append(og.Spans, rebase(s))
+			}
+			og.Events = slices.Grow(og.Events, len(g.Events))
+			for _, ev := range g.Events {
+				og.Events = append(og.Events, ev+evBase)
+			}
+
+			var newlyOpen []regionRef
+			for depth, spans := range g.UserRegions {
+				for _, s := range spans {
+					d := depth + depthOffsets[s.Event()]
+					for len(og.UserRegions) <= d {
+						og.UserRegions = append(og.UserRegions, nil)
+					}
+					s = rebase(s)
+					og.UserRegions[d] = append(og.UserRegions[d], s)
+					if out.Event(s.Event()).Link() == -1 {
+						newlyOpen = append(newlyOpen, regionRef{d, len(og.UserRegions[d]) - 1})
+					}
+				}
+			}
+			open := openRegions[g.ID]
+			if len(og.Spans) > 0 {
+				// Regions that are still active last as long as possible, as in Parse.
+				end := og.Spans[len(og.Spans)-1].End
+				for _, ref := range open {
+					og.UserRegions[ref.depth][ref.idx].End = end
+				}
+			}
+			sort.Slice(newlyOpen, func(i, j int) bool { return newlyOpen[i].depth < newlyOpen[j].depth })
+			if open = append(open, newlyOpen...); len(open) > 0 {
+				openRegions[g.ID] = open
+			}
+		}
+
+		for _, p := range tr.Processors {
+			op, ok := out.psByID[p.ID]
+			if !ok {
+				op = &Processor{ID: p.ID}
+				out.psByID[p.ID] = op
+			}
+			op.Spans = slices.Grow(op.Spans, len(p.Spans))
+			for _, s := range p.Spans {
+				op.Spans = append(op.Spans, rebase(s))
+			}
+		}
+		for _, m := range tr.Machines {
+			om, ok := out.msByID[m.ID]
+			if !ok {
+				om = &Machine{ID: m.ID}
+				out.msByID[m.ID] = om
+			}
+			for _, s := range m.Spans {
+				om.Spans = append(om.Spans, rebase(s))
+			}
+			for _, s := range m.Goroutines {
+				om.Goroutines = append(om.Goroutines, rebase(s))
+			}
+		}
+
+		for _, s := range tr.GC {
+			out.GC = append(out.GC, rebase(s))
+		}
+		for _, s := range tr.STW {
+			out.STW = append(out.STW, rebase(s))
+		}
+		for _, pt := range tr.HeapSize {
+			out.HeapSize = append(out.HeapSize, Point{When: pt.When + shift, Value: pt.Value})
+		}
+		for _, pt := range tr.HeapGoal {
+			out.HeapGoal = append(out.HeapGoal, Point{When: pt.When + shift, Value: pt.Value})
+		}
+		for gid, evs := range tr.CPUSamples {
+			for _, ev := range evs {
+				out.CPUSamples[gid] = append(out.CPUSamples[gid], ev+evBase)
+			}
+		}
+		out.HasCPUSamples = out.HasCPUSamples || tr.HasCPUSamples
+		for cat, evs := range tr.Logs {
+			for _, ev := range evs {
+				out.Logs[cat] = append(out.Logs[cat], ev+evBase)
+			}
+		}
+
+		for _, t := range tr.Tasks {
+			ev := t.Event
+			if !t.Stub() {
+				ev += evBase
+				if out.Event(ev).Link() == -1 {
+					openTasks[t.ID] = ev
+				}
+			}
+			if ot, ok := tasks[t.ID]; ok {
+				// Tasks that were created in an earlier trace are stubs in later traces.
+				if ot.Stub() && !t.Stub() {
+					ot.Name = t.Name
+					ot.Event = ev
+					ot.ParentID = t.ParentID
+				}
+				continue
+			}
+			ot := &Task{ID: t.ID, Name: t.Name, Event: ev, ParentID: t.ParentID}
+			tasks[t.ID] = ot
+			out.Tasks = append(out.Tasks, ot)
+		}
+
+		if n := tr.Events.Len(); n > 0 {
+			prevEnd = out.Event(evBase + EventID(n-1)).Ts
+		}
+		evBase += EventID(tr.Events.Len())
+	}
+
+	populateObjects(out, func(float64) {})
+	for _, g := range out.Goroutines {
+		g.Function.Goroutines = append(g.Function.Goroutines, g)
+	}
+	out.psByID = nil
+	out.msByID = nil
+
+	return out, nil
+}
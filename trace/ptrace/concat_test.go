@@ -0,0 +1,95 @@
+package ptrace_test
+
+import (
+	"bytes"
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestConcat(t *testing.T) {
+	for _, name := range []string{"user_task_region_1_21_good", "stress_1_21_good"} {
+		t.Run(name, func(t *testing.T) {
+			want := loadTrace(t, name)
+
+			// Split the trace in two, as if tracing had been stopped and started again.
+			last := want.Events.Last().Ts
+			mid := last / 2
+			var parts []*ptrace.Trace
+			for _, window := range [][2]trace.Timestamp{{mid + 1, last}, {0, mid}} {
+				var buf bytes.Buffer
+				if err := trace.Crop(&buf, &want.Trace, window[0], window[1], nil); err != nil {
+					t.Fatal(err)
+				}
+				part, err := trace.Parse(&buf, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pt, err := ptrace.Parse(part, func(float64) {})
+				if err != nil {
+					t.Fatal(err)
+				}
+				parts = append(parts, pt)
+			}
+			numEvents := parts[0].Events.Len() + parts[1].Events.Len()
+			firstEnd := parts[1].Start + parts[1].Events.Last().Ts
+			secondStart := parts[0].Start
+
+			got, err := ptrace.Concat(parts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Events.Len() != numEvents {
+				t.Errorf("got %d events, want %d", got.Events.Len(), numEvents)
+			}
+			if len(got.Gaps) != 1 {
+				t.Fatalf("got %d gaps, want 1", len(got.Gaps))
+			}
+			if gap := got.Gaps[0]; gap.Start+got.Start != firstEnd || gap.End+got.Start != secondStart {
+				t.Errorf("got gap %v, want [%d, %d]", gap, firstEnd-got.Start, secondStart-got.Start)
+			}
+			for i := 1; i < got.Events.Len(); i++ {
+				if got.Events.Ptr(i).Ts < got.Events.Ptr(i-1).Ts {
+					t.Fatalf("event %d happened before its predecessor", i)
+				}
+			}
+
+			for _, wg := range want.Goroutines {
+				gg := got.G(wg.ID)
+				if len(gg.UserRegions) != len(wg.UserRegions) {
+					t.Errorf("goroutine %d has %d levels of regions, want %d", wg.ID, len(gg.UserRegions), len(wg.UserRegions))
+					continue
+				}
+				for depth := range wg.UserRegions {
+					if len(gg.UserRegions[depth]) != len(wg.UserRegions[depth]) {
+						t.Errorf("goroutine %d has %d regions at depth %d, want %d", wg.ID, len(gg.UserRegions[depth]), depth, len(wg.UserRegions[depth]))
+						continue
+					}
+					for i, ws := range wg.UserRegions[depth] {
+						gs := gg.UserRegions[depth][i]
+						wname := want.Strings[want.Event(ws.Event()).Args[trace.ArgUserRegionTypeID]]
+						gname := got.Strings[got.Event(gs.Event()).Args[trace.ArgUserRegionTypeID]]
+						if gs.Start != ws.Start || gs.End != ws.End || gname != wname {
+							t.Errorf("goroutine %d has region %q [%d, %d] at depth %d, want %q [%d, %d]", wg.ID, gname, gs.Start, gs.End, depth, wname, ws.Start, ws.End)
+						}
+					}
+				}
+			}
+
+			if len(got.Tasks) != len(want.Tasks) {
+				t.Fatalf("got %d tasks, want %d", len(got.Tasks), len(want.Tasks))
+			}
+			for i, wt := range want.Tasks {
+				gt := got.Tasks[i]
+				if gt.ID != wt.ID || gt.Name != wt.Name || gt.Stub() != wt.Stub() {
+					t.Errorf("got task %d %q, want task %d %q", gt.ID, gt.Name, wt.ID, wt.Name)
+					continue
+				}
+				if !wt.Stub() && (got.Event(gt.Event).Link() == -1) != (want.Event(wt.Event).Link() == -1) {
+					t.Errorf("task %d: end wasn't linked", wt.ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package ptrace
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+// A ContentionWait is a span during which a goroutine was blocked on a synchronization primitive.
+type ContentionWait struct {
+	Goroutine *Goroutine
+	Span      Span
+	// The goroutine that unblocked the waiting goroutine, or nil if it is unknown
+	Unblocker *Goroutine
+}
+
+// ContentionUnblocker describes how often a goroutine unblocked goroutines waiting at a contention site.
+type ContentionUnblocker struct {
+	Goroutine *Goroutine
+	Count     int
+	// The total time the unblocked goroutines had been waiting
+	Wait time.Duration
+}
+
+// A ContentionSite is a location in the code at which goroutines blocked on a synchronization primitive.
+type ContentionSite struct {
+	// The first frame of the blocking stack outside the sync and runtime packages. The zero value if the spans had no
+	// stacks.
+	Frame trace.Frame
+	// Waits at this site, sorted by duration in descending order
+	Waits   []ContentionWait
+	Blocked LatencyPercentiles
+	// Goroutines that unblocked waiters, sorted by the time the waiters spent blocked, in descending order
+	Unblockers []ContentionUnblocker
+}
+
+// ContentionProfile describes the contention on sync.Mutex, sync.RWMutex, sync.WaitGroup, sync.Once and sync.Cond.
+type ContentionProfile struct {
+	// Contention sites, sorted by total blocked time in descending order
+	Sites []*ContentionSite
+	Total time.Duration
+}
+
+// isSyncFunction reports whether fn belongs to one of the packages that implement synchronization primitives.
+func isSyncFunction(fn string) bool {
+	for _, prefix := range []string{"sync.", "internal/sync.", "runtime."} {
+		if strings.HasPrefix(fn, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContentionProfile builds a contention profile from all spans of goroutines that were blocked on synchronization
+// primitives, grouping them by the location that tried to acquire the primitive.
+func (tr *Trace) ContentionProfile() *ContentionProfile {
+	var out ContentionProfile
+	sites := map[uint64]*ContentionSite{}
+	for _, g := range tr.Goroutines {
+		for _, s := range g.Spans {
+			switch s.State {
+			case StateBlockedSync, StateBlockedSyncOnce, StateBlockedCond:
+			default:
+				continue
+			}
+
+			frame := tr.userFrame(tr.Stacks[tr.Event(s.Event()).StkID], isSyncFunction)
+			site, ok := sites[frame.PC]
+			if !ok {
+				site = &ContentionSite{Frame: frame}
+				sites[frame.PC] = site
+				out.Sites = append(out.Sites, site)
+			}
+
+			w := ContentionWait{Goroutine: g, Span: s}
+			if ev, ok := tr.UnblockingEvent(s); ok {
+				w.Unblocker = tr.gsByID[tr.Event(ev).G]
+			}
+			site.Waits = append(site.Waits, w)
+		}
+	}
+
+	for _, site := range out.Sites {
+		sort.SliceStable(site.Waits, func(i, j int) bool {
+			return site.Waits[i].Span.Duration() > site.Waits[j].Span.Duration()
+		})
+		ds := make([]time.Duration, len(site.Waits))
+		unblockers := map[*Goroutine]int{}
+		for i, w := range site.Waits {
+			d := w.Span.Duration()
+			ds[len(ds)-i-1] = d
+			if w.Unblocker == nil {
+				continue
+			}
+			idx, ok := unblockers[w.Unblocker]
+			if !ok {
+				idx = len(site.Unblockers)
+				site.Unblockers = append(site.Unblockers, ContentionUnblocker{Goroutine: w.Unblocker})
+				unblockers[w.Unblocker] = idx
+			}
+			u := &site.Unblockers[idx]
+			u.Count++
+			u.Wait += d
+		}
+		sort.SliceStable(site.Unblockers, func(i, j int) bool {
+			return site.Unblockers[i].Wait > site.Unblockers[j].Wait
+		})
+		site.Blocked = ComputeLatencyPercentiles(ds)
+		out.Total += site.Blocked.Total
+	}
+	sort.SliceStable(out.Sites, func(i, j int) bool {
+		return out.Sites[i].Blocked.Total > out.Sites[j].Blocked.Total
+	})
+
+	return &out
+}
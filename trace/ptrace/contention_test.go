@@ -0,0 +1,32 @@
+package ptrace_test
+
+import (
+	"testing"
+
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestContentionProfilePingPong(t *testing.T) {
+	ptr := pingPongTrace(t)
+	g1, g2 := ptr.Goroutines[0], ptr.Goroutines[1]
+
+	cp := ptr.ContentionProfile()
+	if cp.Total != 6 || len(cp.Sites) != 1 {
+		t.Fatalf("got %d sites totalling %s, want 1 totalling 6ns", len(cp.Sites), cp.Total)
+	}
+	site := cp.Sites[0]
+	if site.Frame.Fn != "main.worker" {
+		t.Errorf("got site %q, want main.worker", site.Frame.Fn)
+	}
+	if len(site.Waits) != 1 || site.Waits[0].Goroutine != g2 || site.Waits[0].Unblocker != g1 ||
+		site.Waits[0].Span.Start != 14 || site.Waits[0].Span.End != 20 {
+		t.Errorf("got waits %+v, want g2 waiting from 14 to 20 for g1", site.Waits)
+	}
+	want := ptrace.LatencyPercentiles{Count: 1, Total: 6, Min: 6, P50: 6, P90: 6, P99: 6, P999: 6, Max: 6}
+	if site.Blocked != want {
+		t.Errorf("got %+v, want %+v", site.Blocked, want)
+	}
+	if len(site.Unblockers) != 1 || site.Unblockers[0] != (ptrace.ContentionUnblocker{Goroutine: g1, Count: 1, Wait: 6}) {
+		t.Errorf("got unblockers %+v, want g1 unblocking once after 6ns", site.Unblockers)
+	}
+}
@@ -0,0 +1,151 @@
+package ptrace
+
+import (
+	"sort"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+// A CriticalPathHop is the part of a critical path that was spent in a single span of a goroutine.
+type CriticalPathHop struct {
+	Goroutine uint64
+	// Only the part of the span between Start and End is on the critical path.
+	Span       Span
+	Start, End trace.Timestamp
+}
+
+func (hop CriticalPathHop) Duration() time.Duration {
+	return time.Duration(hop.End - hop.Start)
+}
+
+// A CriticalPath is the chain of work that a goroutine had to wait for during a range of time. It consists of the
+// goroutine's own spans, as well as the spans of the goroutines that unblocked it, of the goroutines that unblocked
+// those, and so on.
+type CriticalPath struct {
+	Start, End trace.Timestamp
+	// Hops in chronological order. Consecutive hops are contiguous.
+	Hops []CriticalPathHop
+
+	// Indices of hops, by goroutine
+	byG map[uint64][]int
+}
+
+// UnblockingEvent returns the event that unblocked a blocked span, if the span was unblocked by a goroutine. Goroutines
+// that were blocked on I/O, for example, get unblocked by the netpoller instead.
+func (tr *Trace) UnblockingEvent(s Span) (EventID, bool) {
+	switch s.State {
+	case StateBlocked, StateBlockedSend, StateBlockedRecv, StateBlockedSelect, StateBlockedSync,
+		StateBlockedSyncOnce, StateBlockedSyncTriggeringGC, StateBlockedCond, StateBlockedNet, StateBlockedGC:
+		if link := EventID(tr.Event(s.Event()).Link()); link != -1 {
+			// g0 unblocks goroutines that are blocked on pollable I/O, for example.
+			if tr.Event(link).G != 0 {
+				return link, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// CriticalPath computes the critical path of goroutine g between start and end. Starting at end, it walks the
+// goroutine's spans backwards in time. When it encounters a span that was blocked on another goroutine, it continues
+// with the unblocking goroutine from the time of the unblocking. Similarly, it continues with the parent goroutine
+// when it reaches the creation of a goroutine. The walk stops at start, or when it runs out of spans.
+func (tr *Trace) CriticalPath(g *Goroutine, start, end trace.Timestamp) *CriticalPath {
+	path := &CriticalPath{
+		Start: start,
+		End:   end,
+		byG:   map[uint64][]int{},
+	}
+
+	t := end
+	// The number of steps that didn't move backwards in time, to guard against cycles in inconsistent traces.
+	stalled := 0
+	for t > start && stalled <= len(tr.gsByID) {
+		// Find the span that contains t.
+		idx := sort.Search(len(g.Spans), func(i int) bool {
+			return g.Spans[i].End >= t
+		})
+		if idx == len(g.Spans) || g.Spans[idx].Start >= t {
+			// We've reached the start of the goroutine. If we know who created it, continue with the creator.
+			if len(g.Spans) == 0 || g.Spans[0].State != StateCreated {
+				break
+			}
+			parent, ok := tr.gsByID[g.Parent]
+			if !ok || parent == g {
+				break
+			}
+			g = parent
+			stalled++
+			continue
+		}
+		s := g.Spans[idx]
+
+		if ev, ok := tr.UnblockingEvent(s); ok {
+			u := tr.Event(ev).Ts
+			ug, ok := tr.gsByID[tr.Event(ev).G]
+			if ok && ug != g && u <= t && u > s.Start {
+				if u < t {
+					// The time between the unblocking and the end of the span is attributed to the blocked
+					// goroutine.
+					path.add(g.ID, s, u, t)
+					stalled = 0
+				} else {
+					stalled++
+				}
+				g = ug
+				t = u
+				continue
+			}
+		}
+
+		path.add(g.ID, s, max(s.Start, start), t)
+		t = s.Start
+		stalled = 0
+	}
+
+	// We collected the hops backwards.
+	for i, j := 0, len(path.Hops)-1; i < j; i, j = i+1, j-1 {
+		path.Hops[i], path.Hops[j] = path.Hops[j], path.Hops[i]
+	}
+	for i, hop := range path.Hops {
+		path.byG[hop.Goroutine] = append(path.byG[hop.Goroutine], i)
+	}
+	return path
+}
+
+func (p *CriticalPath) add(gid uint64, s Span, start, end trace.Timestamp) {
+	if start == end {
+		return
+	}
+	p.Hops = append(p.Hops, CriticalPathHop{
+		Goroutine: gid,
+		Span:      s,
+		Start:     start,
+		End:       end,
+	})
+}
+
+// Duration returns the amount of time covered by the critical path. It can be less than the time between Start and
+// End if the path couldn't be followed to Start.
+func (p *CriticalPath) Duration() time.Duration {
+	if len(p.Hops) == 0 {
+		return 0
+	}
+	return time.Duration(p.Hops[len(p.Hops)-1].End - p.Hops[0].Start)
+}
+
+// NumGoroutines returns the number of goroutines on the critical path.
+func (p *CriticalPath) NumGoroutines() int {
+	return len(p.byG)
+}
+
+// Overlaps reports whether any part of the goroutine's spans between start and end is on the critical path.
+func (p *CriticalPath) Overlaps(gid uint64, start, end trace.Timestamp) bool {
+	idxs := p.byG[gid]
+	// The hops of a goroutine are sorted by time and don't overlap.
+	i := sort.Search(len(idxs), func(i int) bool {
+		return p.Hops[idxs[i]].End > start
+	})
+	return i < len(idxs) && p.Hops[idxs[i]].Start < end
+}
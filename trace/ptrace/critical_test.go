@@ -0,0 +1,112 @@
+package ptrace_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestCriticalPathInvariants(t *testing.T) {
+	ptr := loadTrace(t, "stress_1_21_good")
+
+	// switchedBy reports whether goroutine b could only continue at ts because of goroutine a, either because a
+	// unblocked b at ts or because a created b at ts.
+	switchedBy := func(a, b uint64, ts trace.Timestamp) bool {
+		g := ptr.G(b)
+		if len(g.Spans) > 0 && g.Spans[0].State == ptrace.StateCreated && g.Parent == a && g.Spans[0].Start == ts {
+			return true
+		}
+		for _, s := range g.Spans {
+			if ev, ok := ptr.UnblockingEvent(s); ok && ptr.Event(ev).G == a && ptr.Event(ev).Ts == ts {
+				return true
+			}
+		}
+		return false
+	}
+
+	var n, switches int
+	for _, g := range ptr.Goroutines {
+		for i, s := range g.Spans {
+			if _, ok := ptr.UnblockingEvent(s); !ok || i+2 >= len(g.Spans) {
+				continue
+			}
+			n++
+
+			// Compute the critical path of the blocked span and the span following it.
+			start, end := s.Start, g.Spans[i+1].End
+			path := ptr.CriticalPath(g, start, end)
+			if len(path.Hops) == 0 {
+				t.Fatalf("g%d: span at %d: empty critical path", g.ID, s.Start)
+			}
+			if last := path.Hops[len(path.Hops)-1]; last.End != end || last.Goroutine != g.ID {
+				t.Errorf("g%d: span at %d: path ends at %d in g%d, want %d in g%d", g.ID, s.Start, last.End, last.Goroutine, end, g.ID)
+			}
+			for j, hop := range path.Hops {
+				if hop.Start < start || hop.End > end || hop.Start >= hop.End {
+					t.Errorf("g%d: span at %d: hop %d has invalid bounds [%d, %d]", g.ID, s.Start, j, hop.Start, hop.End)
+				}
+				if hop.Start < hop.Span.Start || hop.End > hop.Span.End {
+					t.Errorf("g%d: span at %d: hop %d isn't within its span", g.ID, s.Start, j)
+				}
+				if j == 0 {
+					continue
+				}
+				prev := path.Hops[j-1]
+				if prev.End != hop.Start {
+					t.Errorf("g%d: span at %d: hop %d doesn't start where hop %d ends", g.ID, s.Start, j, j-1)
+				}
+				if prev.Goroutine != hop.Goroutine {
+					switches++
+					if !switchedBy(prev.Goroutine, hop.Goroutine, hop.Start) {
+						t.Errorf("g%d: span at %d: path moves from g%d to g%d at %d without an unblocking or creation",
+							g.ID, s.Start, prev.Goroutine, hop.Goroutine, hop.Start)
+					}
+				}
+			}
+		}
+	}
+	if n == 0 {
+		t.Fatal("trace has no unblocked spans")
+	}
+	if switches == 0 {
+		t.Error("critical paths never went through unblocking goroutines")
+	}
+}
+
+func TestCriticalPathPingPong(t *testing.T) {
+	ptr := pingPongTrace(t)
+	g1 := ptr.Goroutines[0]
+
+	type hop struct {
+		g          uint64
+		start, end trace.Timestamp
+	}
+	tests := []struct {
+		start, end trace.Timestamp
+		want       []hop
+	}{
+		// g1 waited for g2 to send, and g2 only started running after g1 created it.
+		{0, 26, []hop{{1, 1, 2}, {1, 2, 3}, {2, 3, 5}, {2, 5, 10}, {1, 10, 12}, {1, 12, 26}}},
+		{8, 26, []hop{{2, 8, 10}, {1, 10, 12}, {1, 12, 26}}},
+		{12, 20, []hop{{1, 12, 20}}},
+	}
+	for _, tt := range tests {
+		path := ptr.CriticalPath(g1, tt.start, tt.end)
+		var got []hop
+		for _, h := range path.Hops {
+			got = append(got, hop{h.Goroutine, h.Start, h.End})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d–%d: got hops %v, want %v", tt.start, tt.end, got, tt.want)
+		}
+		if d := path.Duration(); d != time.Duration(tt.want[len(tt.want)-1].end-tt.want[0].start) {
+			t.Errorf("%d–%d: got duration %s", tt.start, tt.end, d)
+		}
+	}
+	if path := ptr.CriticalPath(g1, 0, 26); path.NumGoroutines() != 2 || path.Overlaps(1, 6, 10) || !path.Overlaps(2, 9, 11) {
+		t.Errorf("path covers the wrong goroutines")
+	}
+}
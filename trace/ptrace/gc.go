@@ -0,0 +1,111 @@
+package ptrace
+
+import (
+	"sort"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+// A STWPhase is a period during which the world was stopped.
+type STWPhase struct {
+	Span   Span
+	Reason trace.STWReason
+}
+
+// A GCCycle describes a single garbage collection cycle.
+type GCCycle struct {
+	// The span of the cycle in Trace.GC
+	Span Span
+	// STW phases overlapping the cycle. These are usually the sweep termination and mark termination phases, but can
+	// include other reasons for stopping the world.
+	STW []STWPhase
+	// Time goroutines spent in mark assists, and time GC workers spent running in the different worker modes.
+	MarkAssist time.Duration
+	Dedicated  time.Duration
+	Fractional time.Duration
+	Idle       time.Duration
+	// The heap size at the start and end of the cycle, and the heap goal in effect at the start of the cycle. All three
+	// are 0 if the trace has no heap measurements for that time.
+	HeapStart uint64
+	HeapEnd   uint64
+	HeapGoal  uint64
+}
+
+// STWDuration returns the total duration of the cycle's STW phases.
+func (c *GCCycle) STWDuration() time.Duration {
+	var d time.Duration
+	for _, stw := range c.STW {
+		d += stw.Span.Duration()
+	}
+	return d
+}
+
+// pointAt returns the value of the last point at or before ts, or 0 if there is none.
+func pointAt(points []Point, ts trace.Timestamp) uint64 {
+	idx := sort.Search(len(points), func(i int) bool { return points[i].When > ts })
+	if idx == 0 {
+		return 0
+	}
+	return points[idx-1].Value
+}
+
+// GCCycles returns one entry per GC cycle in the trace, in chronological order.
+func (tr *Trace) GCCycles() []GCCycle {
+	if len(tr.GC) == 0 {
+		return nil
+	}
+	end := tr.Events.Last().Ts
+	cycles := make([]GCCycle, len(tr.GC))
+	for i, s := range tr.GC {
+		if s.End < s.Start {
+			// The cycle didn't finish before the trace ended.
+			s.End = end
+		}
+		cycles[i] = GCCycle{
+			Span:      s,
+			HeapStart: pointAt(tr.HeapSize, s.Start),
+			HeapEnd:   pointAt(tr.HeapSize, s.End),
+			HeapGoal:  pointAt(tr.HeapGoal, s.Start),
+		}
+	}
+
+	// forEachCycle calls fn for all cycles overlapping s, with the duration of the overlap.
+	forEachCycle := func(s Span, fn func(c *GCCycle, d time.Duration)) {
+		idx := sort.Search(len(cycles), func(i int) bool { return cycles[i].Span.End > s.Start })
+		for ; idx < len(cycles) && cycles[idx].Span.Start < s.End; idx++ {
+			c := &cycles[idx]
+			fn(c, time.Duration(min(s.End, c.Span.End)-max(s.Start, c.Span.Start)))
+		}
+	}
+
+	for _, s := range tr.STW {
+		if s.End < s.Start {
+			s.End = end
+		}
+		phase := STWPhase{
+			Span:   s,
+			Reason: tr.STWReason(tr.Event(s.Event()).Args[trace.ArgSTWStartKind]),
+		}
+		forEachCycle(s, func(c *GCCycle, _ time.Duration) {
+			c.STW = append(c.STW, phase)
+		})
+	}
+
+	for _, g := range tr.Goroutines {
+		for _, s := range g.Spans {
+			switch s.State {
+			case StateGCMarkAssist:
+				forEachCycle(s, func(c *GCCycle, d time.Duration) { c.MarkAssist += d })
+			case StateGCDedicated:
+				forEachCycle(s, func(c *GCCycle, d time.Duration) { c.Dedicated += d })
+			case StateGCFractional:
+				forEachCycle(s, func(c *GCCycle, d time.Duration) { c.Fractional += d })
+			case StateGCIdle:
+				forEachCycle(s, func(c *GCCycle, d time.Duration) { c.Idle += d })
+			}
+		}
+	}
+
+	return cycles
+}
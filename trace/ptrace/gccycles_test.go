@@ -0,0 +1,69 @@
+package ptrace_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+func TestGCCyclesSynthetic(t *testing.T) {
+	// The first cycle runs from 5 to 13, with a dedicated worker running from 6 to 10 and g1 assisting from 6 to 8. The
+	// sweep termination STW phase ends when the cycle starts, and the mark termination phase lasts from 12 to 14. The
+	// second cycle doesn't end before the trace does.
+	ptr := synthesizeTrace(t, []string{"GC (dedicated)"}, [][]string{
+		{"main.main"},
+		{"runtime.gcBgMarkWorker"},
+	}, []trace.Event{
+		ev(0, trace.EvProcStart, 0, 0, 0, 1),
+		ev(0, trace.EvProcStart, 1, 0, 0, 2),
+		ev(1, trace.EvGoCreate, 0, 0, 0, 1, 1),
+		ev(1, trace.EvGoCreate, 0, 0, 0, 2, 2),
+		ev(2, trace.EvGoStart, 0, 1, 0, 1),
+		ev(3, trace.EvHeapAlloc, 0, 1, 0, 100),
+		ev(3, trace.EvHeapGoal, 0, 1, 0, 200),
+		ev(4, trace.EvSTWStart, 0, 1, 0, uint64(trace.STWGCSweepTermination)),
+		ev(5, trace.EvSTWDone, 0, 1, 0),
+		ev(5, trace.EvGCStart, 0, 1, 1),
+		ev(6, trace.EvGoStartLabel, 1, 2, 0, 2, 0, 1),
+		ev(6, trace.EvGCMarkAssistStart, 0, 1, 1),
+		ev(8, trace.EvGCMarkAssistDone, 0, 1, 0),
+		ev(10, trace.EvGoBlock, 1, 2, 2),
+		ev(11, trace.EvHeapAlloc, 0, 1, 0, 150),
+		ev(12, trace.EvSTWStart, 0, 1, 0, uint64(trace.STWGCMarkTermination)),
+		ev(13, trace.EvGCDone, 0, 1, 0),
+		ev(14, trace.EvSTWDone, 0, 1, 0),
+		ev(15, trace.EvHeapAlloc, 0, 1, 0, 50),
+		ev(15, trace.EvGCStart, 0, 1, 1),
+		ev(16, trace.EvGoEnd, 0, 1, 0),
+		ev(17, trace.EvProcStop, 0, 0, 0),
+		ev(17, trace.EvProcStop, 1, 0, 0),
+	})
+
+	type stw struct {
+		start, end trace.Timestamp
+		reason     trace.STWReason
+	}
+	type cycle struct {
+		start, end                          trace.Timestamp
+		stw                                 []stw
+		assist, dedicated, fractional, idle time.Duration
+		heapStart, heapEnd, heapGoal        uint64
+	}
+	want := []cycle{
+		{5, 13, []stw{{12, 14, trace.STWGCMarkTermination}}, 2, 4, 0, 0, 100, 150, 200},
+		{15, 17, nil, 0, 0, 0, 0, 50, 50, 200},
+	}
+	var got []cycle
+	for _, c := range ptr.GCCycles() {
+		var stws []stw
+		for _, phase := range c.STW {
+			stws = append(stws, stw{phase.Span.Start, phase.Span.End, phase.Reason})
+		}
+		got = append(got, cycle{c.Span.Start, c.Span.End, stws, c.MarkAssist, c.Dedicated, c.Fractional, c.Idle, c.HeapStart, c.HeapEnd, c.HeapGoal})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got cycles %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,31 @@
+package ptrace
+
+import "testing"
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		glob  glob
+		s     string
+		match bool
+	}{
+		{"_", "main.main", true},
+		{"main.main", "main.main", true},
+		{"main.*", "main.main", true},
+		{"main.*", "runtime.main", false},
+		{"*.main", "main.main", true},
+		{"net/*.(*conn).Read", "net/http.(*conn).Read", true},
+		{"database/sql.*", "database/sql.(*DB).conn", true},
+		// Asterisks after opening parentheses are part of pointer receivers, not wildcards.
+		{"sync.(*Mutex).Lock", "sync.(*Mutex).Lock", true},
+		{"sync.(*Mutex).Lock", "sync.(fooMutex).Lock", false},
+		{"sync.(*Mutex).Lock", "sync.(Mutex).Lock", false},
+		{"net.(*netFD).Read", "net.(xnetFD).Read", false},
+		{"sync.(*Mutex).*", "sync.(*Mutex).Unlock", true},
+		{"sync.(*Mutex).*", "sync.(*RWMutex).Unlock", false},
+	}
+	for _, tt := range tests {
+		if got := tt.glob.match(tt.s); got != tt.match {
+			t.Errorf("%q matching %q: got %t, want %t", tt.glob, tt.s, got, tt.match)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ptrace_test
+
+import (
+	"reflect"
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+func TestLogs(t *testing.T) {
+	ptr := loadTrace(t, "user_task_region_1_21_good")
+
+	want := map[string]int{}
+	for i := 0; i < ptr.Events.Len(); i++ {
+		ev := ptr.Events.Ptr(i)
+		if ev.Type == trace.EvUserLog {
+			want[ptr.Strings[ev.Args[trace.ArgUserLogKeyID]]]++
+		}
+	}
+	if len(want) == 0 {
+		t.Fatal("trace has no logs")
+	}
+
+	if len(ptr.Logs) != len(want) {
+		t.Errorf("got %d categories, want %d", len(ptr.Logs), len(want))
+	}
+	for cat, evs := range ptr.Logs {
+		if len(evs) != want[cat] {
+			t.Errorf("category %q: got %d logs, want %d", cat, len(evs), want[cat])
+		}
+		for i, evID := range evs {
+			ev := ptr.Event(evID)
+			if ev.Type != trace.EvUserLog || ptr.Strings[ev.Args[trace.ArgUserLogKeyID]] != cat {
+				t.Errorf("category %q contains unexpected event %d", cat, evID)
+			}
+			if i > 0 && ptr.Event(evs[i-1]).Ts > ev.Ts {
+				t.Errorf("category %q: logs aren't sorted", cat)
+			}
+		}
+	}
+}
+
+func TestLogsSynthetic(t *testing.T) {
+	ptr := synthesizeTrace(t, []string{"db", "http", "query 1", "query 2", "GET /", "uncategorized"}, nil, []trace.Event{
+		ev(0, trace.EvProcStart, 0, 0, 0, 1),
+		ev(1, trace.EvGoCreate, 0, 0, 0, 1),
+		ev(2, trace.EvGoStart, 0, 1, 0, 1),
+		ev(3, trace.EvUserLog, 0, 1, 0, 0, 1, 0, 3),
+		ev(4, trace.EvUserLog, 0, 1, 0, 0, 2, 0, 5),
+		ev(5, trace.EvUserLog, 0, 1, 0, 0, 0, 0, 6),
+		ev(6, trace.EvUserLog, 0, 1, 0, 0, 1, 0, 4),
+		ev(7, trace.EvGoEnd, 0, 1, 0),
+		ev(8, trace.EvProcStop, 0, 0, 0),
+	})
+
+	got := map[string][]string{}
+	for cat, evs := range ptr.Logs {
+		for _, evID := range evs {
+			got[cat] = append(got[cat], ptr.Strings[ptr.Event(evID).Args[trace.ArgUserLogMessage]])
+		}
+	}
+	want := map[string][]string{
+		"db":   {"query 1", "query 2"},
+		"http": {"GET /"},
+		"":     {"uncategorized"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got logs %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package ptrace_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestMachineSpans(t *testing.T) {
+	names, err := filepath.Glob("../testdata/*_good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			ptr := loadTrace(t, filepath.Base(name))
+			if len(ptr.Machines) == 0 {
+				t.Fatal("trace has no machines")
+			}
+
+			// An M can only run one P at a time, and only one goroutine at a time.
+			check := func(m *ptrace.Machine, kind string, spans []ptrace.Span) {
+				for i, s := range spans {
+					if s.End < s.Start {
+						t.Errorf("m%d: %s span %d ends at %d before it starts at %d", m.ID, kind, i, s.End, s.Start)
+					}
+					if i > 0 && s.Start < spans[i-1].End {
+						t.Errorf("m%d: %s span %d starts at %d before the previous span ends at %d", m.ID, kind, i, s.Start, spans[i-1].End)
+					}
+				}
+			}
+			var syscalls int
+			for _, m := range ptr.Machines {
+				check(m, "processor", m.Spans)
+				check(m, "goroutine", m.Goroutines)
+				for _, s := range m.Spans {
+					switch s.State {
+					case ptrace.StateRunningP:
+						if ev := ptr.Event(s.Event()); ev.Type != trace.EvProcStart || ev.Args[trace.ArgProcStartThread] != uint64(m.ID) {
+							t.Errorf("m%d: span at %d has unexpected event %s", m.ID, s.Start, ev)
+						}
+					case ptrace.StateBlockedSyscall:
+						syscalls++
+					default:
+						t.Errorf("m%d: span at %d has unexpected state %d", m.ID, s.Start, s.State)
+					}
+				}
+			}
+			var blocking int
+			for i := 0; i < ptr.Events.Len(); i++ {
+				if ptr.Events.Ptr(i).Type == trace.EvGoSysBlock {
+					blocking++
+				}
+			}
+			if blocking != 0 && syscalls == 0 {
+				t.Errorf("trace has %d blocking syscalls, but no M was blocked in a syscall", blocking)
+			}
+		})
+	}
+}
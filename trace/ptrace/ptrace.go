@@ -2,7 +2,6 @@
 package ptrace
 
 import (
-	"errors"
 	"fmt"
 	"runtime"
 	"sort"
@@ -14,11 +13,6 @@ import (
 	"honnef.co/go/gotraceui/trace"
 )
 
-// This boolean guards all code involving displaying machine timelines. That feature is currently broken, because the
-// trace parser only produces an event ordering that is consistent for goroutines, but not for machines. For example, it
-// may try to start a P on an M that is currently blocked on a syscall.
-const supportMachineTimelines = false
-
 type SchedulingState uint8
 
 const (
@@ -80,6 +74,11 @@ type Trace struct {
 	HeapGoal   []Point
 	// Mapping from Goroutine ID to list of CPU sample events
 	CPUSamples map[uint64][]EventID
+	// Mapping from log category to list of user log events, sorted by time. Logs without a category are stored under
+	// the empty string.
+	Logs map[string][]EventID
+	// Gaps are the periods between traces that were concatenated by Concat, during which nothing was recorded.
+	Gaps []Gap
 
 	gsByID map[uint64]*Goroutine
 	// psByID and msById will be unset after parsing finishes
@@ -87,6 +86,10 @@ type Trace struct {
 	msByID        map[int32]*Machine
 	HasCPUSamples bool
 
+	// Computed on demand by TaskActivity
+	taskActivities     []TaskActivity
+	taskActivitiesOnce sync.Once
+
 	trace.Trace
 }
 
@@ -187,6 +190,12 @@ type Task struct {
 	SeqID int
 	Name  string
 	Event EventID
+	// ID of the parent task, or 0 if the task has no parent
+	ParentID uint64
+	// The parent task, or nil if the task has no parent or the parent isn't part of the trace
+	Parent *Task
+	// Children of the task, sorted by ID
+	Children []*Task
 }
 
 func (t *Task) Stub() bool {
@@ -197,11 +206,13 @@ type Span struct {
 	// The Span type is carefully laid out to optimize its size and to avoid pointers, the latter so that the garbage
 	// collector won't have to scan any memory of our millions of events.
 	//
-	// Instead of pointers, fields like PC and Event are indices into slices.
+	// Instead of pointers, fields like PC and the event are indices into slices. The event's ID is stored in 40 bits,
+	// split across eventLo and eventHi, which keeps the Span type at 24 bytes. Use Event and SetEvent to access it.
 
-	Start trace.Timestamp
-	End   trace.Timestamp
-	Event EventID
+	Start   trace.Timestamp
+	End     trace.Timestamp
+	eventLo uint32
+	eventHi uint8
 	// At is an offset from the top of the stack, skipping over uninteresting runtime frames.
 	At uint8
 	// We track the scheduling State explicitly, instead of mapping from trace.Event.Type, because we apply pattern
@@ -211,7 +222,29 @@ type Span struct {
 	Tags  SpanTags
 }
 
-type EventID int32
+// EventID is the index of an event in trace.Trace.Events.
+type EventID int64
+
+// Event returns the ID of the event that caused the span.
+//
+//gcassert:inline
+func (s Span) Event() EventID {
+	return EventID(int64(s.eventHi)<<32 | int64(s.eventLo))
+}
+
+// SetEvent sets the ID of the event that caused the span.
+//
+//gcassert:inline
+func (s *Span) SetEvent(ev EventID) {
+	s.eventLo = uint32(ev)
+	s.eventHi = uint8(ev >> 32)
+}
+
+func makeSpan(start, end trace.Timestamp, state SchedulingState, ev EventID) Span {
+	s := Span{Start: start, End: end, State: state}
+	s.SetEvent(ev)
+	return s
+}
 
 func Parse(res trace.Trace, progress func(float64)) (*Trace, error) {
 	tr := &Trace{
@@ -221,6 +254,7 @@ func Parse(res trace.Trace, progress func(float64)) (*Trace, error) {
 		psByID:     map[int32]*Processor{},
 		msByID:     map[int32]*Machine{},
 		CPUSamples: map[uint64][]EventID{},
+		Logs:       map[string][]EventID{},
 		GC:         make(spansSlice, 0),
 		STW:        make(spansSlice, 0),
 	}
@@ -282,9 +316,6 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 		return p
 	}
 	getM := func(mid int32) *Machine {
-		if !supportMachineTimelines {
-			panic("getM was called despite supportmachineActivities == false")
-		}
 		m, ok := tr.msByID[mid]
 		if ok {
 			return m
@@ -317,8 +348,8 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 	eventsPerG := map[uint64]int{}
 	eventsPerP := map[int32]int{}
 	eventsPerM := map[int32]int{}
-	for evID := range res.Events {
-		ev := &res.Events[evID]
+	for evID := 0; evID < res.Events.Len(); evID++ {
+		ev := res.Events.Ptr(evID)
 		var gid uint64
 		switch ev.Type {
 		case trace.EvGoCreate, trace.EvGoUnblock:
@@ -327,9 +358,7 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 			eventsPerP[ev.P]++
 			gid = ev.G
 		case trace.EvProcStart:
-			if supportMachineTimelines {
-				eventsPerM[int32(ev.Args[0])]++
-			}
+			eventsPerM[int32(ev.Args[trace.ArgProcStartThread])]++
 			continue
 		case trace.EvHeapAlloc:
 			tr.HeapSize = append(tr.HeapSize, Point{
@@ -357,17 +386,15 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 	for pid, n := range eventsPerP {
 		getP(pid).Spans = make([]Span, 0, n)
 	}
-	if supportMachineTimelines {
-		for mid, n := range eventsPerM {
-			getM(mid).Spans = make([]Span, 0, n)
-		}
+	for mid, n := range eventsPerM {
+		getM(mid).Spans = make([]Span, 0, n)
 	}
 
 	userRegionDepths := map[uint64]int{}
-	for evID := range res.Events {
-		ev := &res.Events[evID]
+	for evID := 0; evID < res.Events.Len(); evID++ {
+		ev := res.Events.Ptr(evID)
 		if (evID+1)%10_000 == 0 {
-			progress(float64(evID) / float64(len(res.Events)))
+			progress(float64(evID) / float64(res.Events.Len()))
 		}
 		var gid uint64
 		var state SchedulingState
@@ -396,6 +423,11 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 					g.Function = f
 				}
 			}
+			if g.Function == nil {
+				// Goroutines that already existed when tracing started don't always have a known function in Go
+				// 1.22 and newer.
+				g.Function = tr.function(trace.Frame{})
+			}
 			// FIXME(dh): when tracing starts after goroutines have already been created then we receive an EvGoCreate
 			// for them. But those goroutines may not necessarily be in a non-running state. We do receive EvGoWaiting
 			// and EvGoInSyscall for goroutines that are blocked or in a syscall when tracing starts; does that mean
@@ -508,7 +540,9 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 			// EvGoSysblock will be followed by ProcStop. Leave a note for ProcStop to start a new span for the blocking
 			// syscall. Also record enough data for EvGoSysExit to finish that span.
 			blockingSyscallPerP[ev.P] = EventID(evID)
-			blockingSyscallMPerG[ev.G] = lastMPerP[ev.P]
+			if mid, ok := lastMPerP[ev.P]; ok {
+				blockingSyscallMPerG[ev.G] = mid
+			}
 
 		case trace.EvGoInSyscall:
 			gid = ev.G
@@ -517,52 +551,50 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 			gid = ev.G
 			state = StateReady
 
-			if supportMachineTimelines {
-				if mid, ok := blockingSyscallMPerG[ev.G]; ok {
-					delete(blockingSyscallMPerG, ev.G)
-					m := getM(mid)
-					span := &m.Spans[len(m.Spans)-1]
-					switch span.State {
-					case StateBlockedSyscall:
-						// We didn't see an EvProcStart for this M
-						span.End = ev.Ts
-					case StateRunningP:
-						// We saw a EvProcStart for this M before we saw the EvGoSysExit. The blocking syscall span will be
-						// the second last span, and we'll have to slightly adjust its end time to not exceed the next
-						// span's start time.
-
-						// XXX guard against malformed traces
-						pspan := &m.Spans[len(m.Spans)-2]
-						if pspan.State != StateBlockedSyscall {
-							return errors.New("malformed trace: EvGoSysExit was preceeded by more than one EvProcStart or other events")
-						}
-						pspan.End = span.Start
-					default:
-						panic(fmt.Sprintf("unexpected state %d", span.State))
-					}
+			if mid, ok := blockingSyscallMPerG[ev.G]; ok {
+				delete(blockingSyscallMPerG, ev.G)
+				// The syscall span has already been ended if the M started another P before the syscall's exit
+				// time.
+				m := getM(mid)
+				if span := &m.Spans[len(m.Spans)-1]; span.State == StateBlockedSyscall && span.End == -1 {
+					span.End = ev.Ts
 				}
 			}
 
 		case trace.EvProcStart:
-			if supportMachineTimelines {
-				mid := ev.Args[0]
-				m := getM(int32(mid))
-				m.Spans = append(m.Spans, Span{Start: ev.Ts, End: -1, State: StateRunningP, Event: EventID(evID)})
-				lastMPerP[ev.P] = m.ID
+			m := getM(int32(ev.Args[trace.ArgProcStartThread]))
+			if n := len(m.Spans); n > 0 && m.Spans[n-1].End == -1 {
+				// The M returned from a blocking syscall, but we haven't seen the syscall's exit yet.
+				m.Spans[n-1].End = ev.Ts
+			}
+			// The parser links the event to the one ending the M's ownership of the P, which isn't necessarily the
+			// P's next EvProcStop.
+			end := trace.Timestamp(-1)
+			if link := ev.Link(); link != -1 {
+				end = res.Events.Ptr(int(link)).Ts
 			}
+			m.Spans = append(m.Spans, makeSpan(ev.Ts, end, StateRunningP, EventID(evID)))
+			lastMPerP[ev.P] = m.ID
 			continue
 		case trace.EvProcStop:
-			if supportMachineTimelines {
-				m := getM(lastMPerP[ev.P])
-				span := &m.Spans[len(m.Spans)-1]
-				span.End = ev.Ts
-
-				if sevID, ok := blockingSyscallPerP[ev.P]; ok {
-					delete(blockingSyscallPerP, ev.P)
-					m.Spans = append(m.Spans, Span{Start: ev.Ts, End: -1, State: StateBlockedSyscall, Event: sevID})
-				}
+			sevID, ok := blockingSyscallPerP[ev.P]
+			if !ok {
+				continue
+			}
+			delete(blockingSyscallPerP, ev.P)
+			mid, ok := lastMPerP[ev.P]
+			if !ok {
+				continue
+			}
+			if _, ok := blockingSyscallMPerG[res.Events.Ptr(int(sevID)).G]; !ok {
+				// The syscall has already returned.
+				continue
+			}
+			// Only start a syscall span if the M was still running the P when it stopped.
+			m := getM(mid)
+			if span := m.Spans[len(m.Spans)-1]; res.Events.Ptr(int(span.Event())).Link() == int64(evID) {
+				m.Spans = append(m.Spans, makeSpan(ev.Ts, -1, StateBlockedSyscall, sevID))
 			}
-
 			continue
 
 		case trace.EvGCMarkAssistStart:
@@ -613,11 +645,11 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 			state = StateActive
 
 		case trace.EvGCStart:
-			tr.GC = append(tr.GC, Span{Start: ev.Ts, State: StateActive, Event: EventID(evID)})
+			tr.GC = append(tr.GC, makeSpan(ev.Ts, 0, StateActive, EventID(evID)))
 			continue
 
 		case trace.EvSTWStart:
-			tr.STW = append(tr.STW, Span{Start: ev.Ts, State: StateActive, Event: EventID(evID)})
+			tr.STW = append(tr.STW, makeSpan(ev.Ts, 0, StateActive, EventID(evID)))
 			continue
 
 		case trace.EvGCDone:
@@ -646,9 +678,10 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 
 		case trace.EvUserTaskCreate:
 			t := &Task{
-				ID:    ev.Args[trace.ArgUserTaskCreateTaskID],
-				Name:  res.Strings[ev.Args[trace.ArgUserTaskCreateTypeID]],
-				Event: EventID(evID),
+				ID:       ev.Args[trace.ArgUserTaskCreateTaskID],
+				Name:     res.Strings[ev.Args[trace.ArgUserTaskCreateTypeID]],
+				Event:    EventID(evID),
+				ParentID: ev.Args[trace.ArgUserTaskCreateParentTaskID],
 			}
 			tr.Tasks = append(tr.Tasks, t)
 			continue
@@ -660,17 +693,12 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 			gid := ev.G
 			if mode := ev.Args[trace.ArgUserRegionMode]; mode == regionStart {
 				var end trace.Timestamp
-				if ev.Link != -1 {
-					end = res.Events[ev.Link].Ts
+				if link := ev.Link(); link != -1 {
+					end = res.Events.Ptr(int(link)).Ts
 				} else {
 					end = -1
 				}
-				s := Span{
-					Start: ev.Ts,
-					Event: EventID(evID),
-					State: StateUserRegion,
-					End:   end,
-				}
+				s := makeSpan(ev.Ts, end, StateUserRegion, EventID(evID))
 				g := getG(ev.G)
 				depth := userRegionDepths[gid]
 				if depth >= len(g.UserRegions) {
@@ -689,15 +717,7 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 				userRegionDepths[gid]++
 
 				if taskID := ev.Args[trace.ArgUserRegionTaskID]; taskID != 0 {
-					idx, ok := tr.task(taskID)
-					if !ok {
-						// The task with the given ID doesn't exist. This can happen in well-formed traces when the task
-						// was created before tracing began.
-						task := &Task{
-							ID: taskID,
-						}
-						tr.Tasks = slices.Insert(tr.Tasks, idx, task)
-					}
+					tr.ensureTask(taskID)
 				}
 			} else {
 				d := userRegionDepths[gid] - 1
@@ -712,6 +732,11 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 		case trace.EvUserLog:
 			// TODO(dh): incorporate logs in per-goroutine timeline
 			addEventToCurrentSpan(ev.G, EventID(evID))
+			cat := res.Strings[ev.Args[trace.ArgUserLogKeyID]]
+			tr.Logs[cat] = append(tr.Logs[cat], EventID(evID))
+			if taskID := ev.Args[trace.ArgUserLogTaskID]; taskID != 0 {
+				tr.ensureTask(taskID)
+			}
 			continue
 
 		case trace.EvCPUSample:
@@ -738,19 +763,19 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 			}
 		}
 
-		s := Span{Start: ev.Ts, State: state, Event: EventID(evID)}
+		s := makeSpan(ev.Ts, 0, state, EventID(evID))
 		if ev.Type == trace.EvGoSysBlock {
-			if debug && res.Events[s.Event].StkID != 0 {
+			if debug && ev.StkID != 0 {
 				panic("expected zero stack ID")
 			}
-			res.Events[s.Event].StkID = lastSyscall[ev.G]
+			ev.StkID = lastSyscall[ev.G]
 
 			// EvGoSysBlock arrives some time after EvGoSysCall (once sysmon has figured out that the syscall is
 			// blocking). Shrink the previous running span and backdate this span to when the syscall actually started.
 			g := getG(gid)
 			if len(g.Events) > 0 {
 				sysEvID := g.Events[len(g.Events)-1]
-				if sysEv := tr.Events[sysEvID]; sysEv.Type == trace.EvGoSysCall {
+				if sysEv := tr.Event(sysEvID); sysEv.Type == trace.EvGoSysCall {
 					g.Spans[len(g.Spans)-1].End = sysEv.Ts
 					s.Start = sysEv.Ts
 				}
@@ -763,18 +788,16 @@ func processEvents(res trace.Trace, tr *Trace, progress func(float64)) error {
 		switch pState {
 		case pRunG:
 			p := getP(ev.P)
-			p.Spans = append(p.Spans, Span{Start: ev.Ts, State: StateRunningG, Event: EventID(evID)})
-			if supportMachineTimelines {
-				mid := lastMPerP[p.ID]
+			p.Spans = append(p.Spans, makeSpan(ev.Ts, 0, StateRunningG, EventID(evID)))
+			if mid, ok := lastMPerP[p.ID]; ok {
 				m := getM(mid)
-				m.Goroutines = append(m.Goroutines, Span{Start: ev.Ts, Event: EventID(evID), State: StateRunningG})
+				m.Goroutines = append(m.Goroutines, makeSpan(ev.Ts, -1, StateRunningG, EventID(evID)))
 			}
 		case pStopG:
 			// XXX guard against malformed traces
 			p := getP(ev.P)
 			p.Spans[len(p.Spans)-1].End = ev.Ts
-			if supportMachineTimelines {
-				mid := lastMPerP[p.ID]
+			if mid, ok := lastMPerP[p.ID]; ok {
 				m := getM(mid)
 				if len(m.Goroutines) == 0 {
 					return fmt.Errorf("malformed trace: g%d ran on m%d but M has no goroutine spans", ev.G, mid)
@@ -796,7 +819,7 @@ func postProcessSpans(tr *Trace, progress func(float64)) {
 				s.End = g.Spans[i+1].Start
 			}
 
-			stack := tr.Stacks[tr.Events[s.Event].StkID]
+			stack := tr.Stacks[tr.Event(s.Event()).StkID]
 			s = applyPatterns(s, tr.PCs, stack)
 
 			// move s.At out of the runtime
@@ -814,7 +837,7 @@ func postProcessSpans(tr *Trace, progress func(float64)) {
 				s := &g.Spans[len(g.Spans)-1]
 				s.End = s.Start
 			} else {
-				g.Spans[len(g.Spans)-1].End = tr.Events[len(tr.Events)-1].Ts
+				g.Spans[len(g.Spans)-1].End = tr.Events.Last().Ts
 			}
 		}
 
@@ -875,7 +898,8 @@ func postProcessSpans(tr *Trace, progress func(float64)) {
 
 func removeBogusCreatedSpans(tr *Trace) {
 evLoop:
-	for _, ev := range tr.Events {
+	for i := 0; i < tr.Events.Len(); i++ {
+		ev := tr.Events.Ptr(i)
 		switch ev.Type {
 		case trace.EvGoCreate:
 			// This goroutine already existed at the beginning of the trace. Merge the first two spans, of which the
@@ -919,7 +943,12 @@ func populateObjects(tr *Trace, progress func(float64)) {
 		tr.Machines = append(tr.Machines, m)
 		if len(m.Spans) > 0 {
 			if last := &m.Spans[len(m.Spans)-1]; last.End == -1 {
-				last.End = tr.Events[len(tr.Events)-1].Ts
+				last.End = tr.Events.Last().Ts
+			}
+		}
+		if len(m.Goroutines) > 0 {
+			if last := &m.Goroutines[len(m.Goroutines)-1]; last.End == -1 {
+				last.End = tr.Events.Last().Ts
 			}
 		}
 	}
@@ -946,9 +975,30 @@ func populateObjects(tr *Trace, progress func(float64)) {
 	for i, t := range tr.Tasks {
 		t.SeqID = i
 	}
+	tr.linkTasks()
 	progress(1)
 }
 
+// linkTasks populates the Parent and Children fields of tasks. Tasks must already be sorted by ID.
+func (tr *Trace) linkTasks() {
+	for _, t := range tr.Tasks {
+		// The runtime allocates task IDs in increasing order, so parents have smaller IDs than their children.
+		// Malformed traces, or concatenated traces of different processes, can have tasks that are their own ancestors,
+		// which would make the tree infinitely deep.
+		if t.ParentID == 0 || t.ParentID >= t.ID {
+			continue
+		}
+		idx, ok := tr.task(t.ParentID)
+		if !ok {
+			// The parent was created before tracing began.
+			continue
+		}
+		parent := tr.Tasks[idx]
+		t.Parent = parent
+		parent.Children = append(parent.Children, t)
+	}
+}
+
 func (t *Trace) function(frame trace.Frame) *Function {
 	f, ok := t.Functions[frame.Fn]
 	if ok {
@@ -964,7 +1014,7 @@ func (t *Trace) function(frame trace.Frame) *Function {
 
 //gcassert:inline
 func (t *Trace) Event(ev EventID) *trace.Event {
-	return &t.Events[ev]
+	return t.Events.Ptr(int(ev))
 }
 
 func (t *Trace) Task(id uint64) *Task {
@@ -975,6 +1025,18 @@ func (t *Trace) Task(id uint64) *Task {
 	return t.Tasks[idx]
 }
 
+// ensureTask adds a stub task for the given ID if the trace doesn't contain a task with that ID. This can happen in
+// well-formed traces when the task was created before tracing began.
+func (t *Trace) ensureTask(id uint64) {
+	idx, ok := t.task(id)
+	if !ok {
+		task := &Task{
+			ID: id,
+		}
+		t.Tasks = slices.Insert(t.Tasks, idx, task)
+	}
+}
+
 func (t *Trace) task(id uint64) (int, bool) {
 	return sort.Find(len(t.Tasks), func(i int) int {
 		oid := t.Tasks[i].ID
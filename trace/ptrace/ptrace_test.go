@@ -0,0 +1,113 @@
+package ptrace_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+// loadTrace parses and processes the trace stored in ../testdata/name.
+func loadTrace(t *testing.T, name string) *ptrace.Trace {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := trace.Parse(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptr, err := ptrace.Parse(tr, func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ptr
+}
+
+// synthesizeTrace processes a trace made up of the given events. Strings and stacks are assigned IDs in order, starting
+// at 1. Stacks list function names, innermost first, and every function gets its own PC.
+func synthesizeTrace(t *testing.T, strs []string, stacks [][]string, evs []trace.Event) *ptrace.Trace {
+	t.Helper()
+	src := trace.Trace{
+		Version: 1021,
+		Stacks:  map[uint32][]uint64{},
+		PCs:     map[uint64]trace.Frame{},
+		Strings: map[uint64]string{},
+	}
+	for i, s := range strs {
+		src.Strings[uint64(i+1)] = s
+	}
+	pcs := map[string]uint64{}
+	for i, fns := range stacks {
+		stk := make([]uint64, len(fns))
+		for j, fn := range fns {
+			pc, ok := pcs[fn]
+			if !ok {
+				pc = 0x1000 + uint64(len(pcs))
+				pcs[fn] = pc
+				src.PCs[pc] = trace.Frame{PC: pc, Fn: fn}
+			}
+			stk[j] = pc
+		}
+		src.Stacks[uint32(i+1)] = stk
+	}
+	for _, ev := range evs {
+		src.Events.Append(ev)
+	}
+	var buf bytes.Buffer
+	if err := trace.Write(&buf, &src); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := trace.Parse(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptr, err := ptrace.Parse(tr, func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ptr
+}
+
+// ev returns an event for synthesizeTrace.
+func ev(ts trace.Timestamp, typ byte, p int32, g uint64, stk uint32, args ...uint64) trace.Event {
+	ev := trace.Event{Ts: ts, Type: typ, P: p, G: g, StkID: stk}
+	copy(ev.Args[:], args)
+	return ev
+}
+
+// pingPongTrace returns a trace in which two goroutines unblock each other. g1 runs main.main and creates g2, which
+// runs main.worker. g1 blocks receiving from a channel from 6 to 10, when g2 sends to it. g2 blocks on a mutex from 14
+// to 20, when g1 unlocks it. g1 is runnable from 1 to 2 and from 10 to 12, g2 from 3 to 5 and from 20 to 22.
+func pingPongTrace(t *testing.T) *ptrace.Trace {
+	t.Helper()
+	return synthesizeTrace(t, nil, [][]string{
+		{"main.main"},
+		{"main.worker"},
+		{"runtime.chanrecv1", "main.main"},
+		{"runtime.chansend1", "main.worker"},
+		{"sync.(*Mutex).Lock", "main.worker"},
+		{"sync.(*Mutex).Unlock", "main.main"},
+	}, []trace.Event{
+		ev(0, trace.EvProcStart, 0, 0, 0, 1),
+		ev(1, trace.EvGoCreate, 0, 0, 0, 1, 1),
+		ev(2, trace.EvGoStart, 0, 1, 0, 1),
+		ev(3, trace.EvGoCreate, 0, 1, 1, 2, 2),
+		ev(4, trace.EvProcStart, 1, 0, 0, 2),
+		ev(5, trace.EvGoStart, 1, 2, 0, 2),
+		ev(6, trace.EvGoBlockRecv, 0, 1, 3),
+		ev(10, trace.EvGoUnblock, 1, 2, 4, 1),
+		ev(12, trace.EvGoStart, 0, 1, 0, 1),
+		ev(14, trace.EvGoBlockSync, 1, 2, 5),
+		ev(20, trace.EvGoUnblock, 0, 1, 6, 2),
+		ev(22, trace.EvGoStart, 1, 2, 0, 2),
+		ev(24, trace.EvGoEnd, 1, 2, 0),
+		ev(25, trace.EvProcStop, 1, 0, 0),
+		ev(26, trace.EvGoEnd, 0, 1, 0),
+		ev(27, trace.EvProcStop, 0, 0, 0),
+	})
+}
@@ -0,0 +1,583 @@
+package ptrace
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"sync"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+// stateNames maps the names of goroutine states used in rules to states.
+var stateNames = map[string]SchedulingState{
+	"inactive":                   StateInactive,
+	"active":                     StateActive,
+	"gc-idle":                    StateGCIdle,
+	"gc-dedicated":               StateGCDedicated,
+	"gc-fractional":              StateGCFractional,
+	"blocked":                    StateBlocked,
+	"blocked-send":               StateBlockedSend,
+	"blocked-recv":               StateBlockedRecv,
+	"blocked-select":             StateBlockedSelect,
+	"blocked-sync":               StateBlockedSync,
+	"blocked-sync-once":          StateBlockedSyncOnce,
+	"blocked-sync-triggering-gc": StateBlockedSyncTriggeringGC,
+	"blocked-cond":               StateBlockedCond,
+	"blocked-net":                StateBlockedNet,
+	"blocked-gc":                 StateBlockedGC,
+	"blocked-syscall":            StateBlockedSyscall,
+	"stuck":                      StateStuck,
+	"ready":                      StateReady,
+	"created":                    StateCreated,
+	"done":                       StateDone,
+	"gc-mark-assist":             StateGCMarkAssist,
+	"gc-sweep":                   StateGCSweep,
+}
+
+// LoadRules parses rules and adds them to the rules that Parse applies, after the built-in rules and previously loaded
+// rules. The name is used in error messages. No rules are added if there are any errors. LoadRules must not be called
+// concurrently with Parse.
+//
+// Rules describe how to refine the states of goroutine spans and how to tag them, based on the stacks of the spans.
+// For example, a goroutine that is blocked on a mutex inside sync.Once is waiting for the Once to finish, and a
+// goroutine that is blocked on the network inside of net/http is serving or making an HTTP request.
+//
+// Rules are written as S-expressions. Each rule has the form
+//
+//	(rule <state> <matcher> <action>...)
+//
+// and applies to spans that are in the given state and whose stacks are matched by the matcher. The available
+// matchers are
+//
+//	(frame <n> "fn"...)  the functions of consecutive frames, starting at offset n, with 0 being the innermost frame
+//	(frames "fn"...)     the functions of consecutive frames, at any offset
+//	(and <matcher>...)   all of the matchers
+//	(or <matcher>...)    any of the matchers
+//	(not <matcher>)      the inverse of the matcher
+//	_                    any stack
+//
+// In function names, * matches any number of characters, and a function name of _ matches any function. An asterisk
+// that follows an opening parenthesis is literal, so that the names of methods with pointer receivers, such as
+// "sync.(*Mutex).Lock", only match those methods.
+//
+// The available actions are
+//
+//	(state <state>)  changes the span's state
+//	(at <n>)         sets the frame that the span is attributed to, skipping frames of the implementation
+//	(tags <tag>...)  tags the span
+//
+// All rules that match a span are applied, in the order in which they were defined. States are written in lower case,
+// with words separated by dashes, such as blocked-sync-once. Comments start with a semicolon and extend to the end of
+// the line.
+//
+// The built-in tags are read, accept, dial, network, tcp, tls, http, and gc. Further tags can be defined with
+//
+//	(tag <name> (label "label") (color "#rrggbb"))
+//
+// where the label is how the tag is displayed, defaulting to the name, and the optional color is the color of spans
+// with the tag. Tags have to be defined before they can be used, and at most MaxSpanTags tags can exist.
+//
+// For example, the following rule identifies goroutines that are waiting for a sync.Once:
+//
+//	(rule blocked-sync
+//	  (frame 0 "sync.(*Mutex).Lock" "sync.(*Once).doSlow" "sync.(*Once).Do")
+//	  (state blocked-sync-once)
+//	  (at 3))
+//
+// and the following rules tag goroutines that are waiting for a database:
+//
+//	(tag database (color "#c08040"))
+//	(rule blocked-net (frames "database/sql.*") (tags database))
+func LoadRules(name string, src []byte) error {
+	// Compiling rules assigns tags to the tags being defined, which mustn't be assigned by concurrent calls, too.
+	loadRulesMu.Lock()
+	defer loadRulesMu.Unlock()
+
+	ps, tags, err := compileRules(name, src)
+	if err != nil {
+		return err
+	}
+	if err := defineTags(tags); err != nil {
+		return err
+	}
+	for _, p := range ps {
+		p.tags, _ = spanTagsFromMask(p.tagMask)
+		patterns[p.state] = append(patterns[p.state], p)
+	}
+	return nil
+}
+
+// loadRulesMu serializes calls of LoadRules.
+var loadRulesMu sync.Mutex
+
+// A matcher matches the stacks of spans.
+type matcher interface {
+	match(pcs map[uint64]trace.Frame, stack []uint64) bool
+}
+
+type anyMatcher struct{}
+
+// frameMatcher matches the functions of consecutive frames at a fixed offset.
+type frameMatcher struct {
+	off int
+	fns []glob
+}
+
+// framesMatcher matches the functions of consecutive frames at any offset.
+type framesMatcher []glob
+
+type andMatcher []matcher
+type orMatcher []matcher
+type notMatcher struct{ m matcher }
+
+func (anyMatcher) match(pcs map[uint64]trace.Frame, stack []uint64) bool { return true }
+
+func (m frameMatcher) match(pcs map[uint64]trace.Frame, stack []uint64) bool {
+	if len(stack) < m.off+len(m.fns) {
+		return false
+	}
+	return matchFrames(m.fns, pcs, stack[m.off:])
+}
+
+func (m framesMatcher) match(pcs map[uint64]trace.Frame, stack []uint64) bool {
+	// OPT(dh): be better than O(n²)
+	for start := 0; start+len(m) <= len(stack); start++ {
+		if matchFrames(m, pcs, stack[start:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m andMatcher) match(pcs map[uint64]trace.Frame, stack []uint64) bool {
+	for _, mm := range m {
+		if !mm.match(pcs, stack) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m orMatcher) match(pcs map[uint64]trace.Frame, stack []uint64) bool {
+	for _, mm := range m {
+		if mm.match(pcs, stack) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m notMatcher) match(pcs map[uint64]trace.Frame, stack []uint64) bool {
+	return !m.m.match(pcs, stack)
+}
+
+// matchFrames reports whether the functions of the first frames of stack match fns. The stack must have at least as
+// many frames as there are functions.
+func matchFrames(fns []glob, pcs map[uint64]trace.Frame, stack []uint64) bool {
+	for i, fn := range fns {
+		if !fn.match(pcs[stack[i]].Fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// A glob is a function name in which * matches any number of characters, except when it follows an opening
+// parenthesis, as in pointer receivers. The glob _ matches any name.
+type glob string
+
+func (g glob) match(s string) bool {
+	if g == "_" || string(g) == s {
+		return true
+	}
+	p := string(g)
+	wildcard := func(i int) bool { return p[i] == '*' && (i == 0 || p[i-1] != '(') }
+	// Match greedily, backtracking to the most recent asterisk on mismatches.
+	star, next := -1, 0
+	i, j := 0, 0
+	for j < len(s) {
+		if i < len(p) && wildcard(i) {
+			star, next = i, j
+			i++
+		} else if i < len(p) && p[i] == s[j] {
+			i++
+			j++
+		} else if star != -1 {
+			i = star + 1
+			next++
+			j = next
+		} else {
+			return false
+		}
+	}
+	for i < len(p) && wildcard(i) {
+		i++
+	}
+	return i == len(p)
+}
+
+// sexpr is an S-expression: either an atom, a string, or a list.
+type sexpr struct {
+	line, col int
+	atom      string
+	str       bool
+	list      []sexpr
+	isList    bool
+}
+
+func (e sexpr) String() string {
+	switch {
+	case e.isList:
+		return "list"
+	case e.str:
+		return strconv.Quote(e.atom)
+	default:
+		return e.atom
+	}
+}
+
+// ruleParser reads S-expressions and compiles them.
+type ruleParser struct {
+	name      string
+	src       []byte
+	off       int
+	line, col int
+
+	// Tags defined by the rules being compiled.
+	tags []TagDefinition
+}
+
+func (p *ruleParser) errorf(line, col int, format string, args ...any) error {
+	return fmt.Errorf("%s:%d:%d: %s", p.name, line, col, fmt.Sprintf(format, args...))
+}
+
+func (p *ruleParser) advance() {
+	if p.src[p.off] == '\n' {
+		p.line++
+		p.col = 1
+	} else {
+		p.col++
+	}
+	p.off++
+}
+
+// skip skips whitespace and comments.
+func (p *ruleParser) skip() {
+	for p.off < len(p.src) {
+		switch c := p.src[p.off]; c {
+		case ' ', '\t', '\r', '\n':
+			p.advance()
+		case ';':
+			for p.off < len(p.src) && p.src[p.off] != '\n' {
+				p.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next reads the next S-expression. It returns errEndOfRules at the end of the input.
+func (p *ruleParser) next() (sexpr, error) {
+	p.skip()
+	if p.off == len(p.src) {
+		return sexpr{}, errEndOfRules
+	}
+	e := sexpr{line: p.line, col: p.col}
+	switch c := p.src[p.off]; c {
+	case '(':
+		p.advance()
+		e.isList = true
+		for {
+			p.skip()
+			if p.off == len(p.src) {
+				return sexpr{}, p.errorf(e.line, e.col, "unclosed list")
+			}
+			if p.src[p.off] == ')' {
+				p.advance()
+				return e, nil
+			}
+			el, err := p.next()
+			if err != nil {
+				return sexpr{}, err
+			}
+			e.list = append(e.list, el)
+		}
+	case ')':
+		return sexpr{}, p.errorf(e.line, e.col, "unexpected )")
+	case '"':
+		start := p.off
+		p.advance()
+		for {
+			if p.off == len(p.src) || p.src[p.off] == '\n' {
+				return sexpr{}, p.errorf(e.line, e.col, "unterminated string")
+			}
+			c := p.src[p.off]
+			p.advance()
+			if c == '\\' && p.off < len(p.src) {
+				p.advance()
+			} else if c == '"' {
+				break
+			}
+		}
+		s, err := strconv.Unquote(string(p.src[start:p.off]))
+		if err != nil {
+			return sexpr{}, p.errorf(e.line, e.col, "invalid string: %s", err)
+		}
+		e.atom = s
+		e.str = true
+		return e, nil
+	default:
+		start := p.off
+		for p.off < len(p.src) && !strings.ContainsRune(" \t\r\n();\"", rune(p.src[p.off])) {
+			p.advance()
+		}
+		e.atom = string(p.src[start:p.off])
+		return e, nil
+	}
+}
+
+var errEndOfRules = errors.New("end of rules")
+
+// compileRules parses and compiles rules and tag definitions. The tags of the returned patterns have yet to be
+// interned, and the returned tags have yet to be defined.
+func compileRules(name string, src []byte) ([]pattern, []TagDefinition, error) {
+	p := &ruleParser{name: name, src: src, line: 1, col: 1}
+	var out []pattern
+	for {
+		e, err := p.next()
+		if err == errEndOfRules {
+			return out, p.tags, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if e.isList && len(e.list) > 0 && e.list[0].atom == "tag" && !e.list[0].str {
+			if err := p.compileTag(e); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		pat, err := p.compileRule(e)
+		if err != nil {
+			return nil, nil, err
+		}
+		out = append(out, pat)
+	}
+}
+
+func (p *ruleParser) compileTag(e sexpr) error {
+	if len(e.list) < 2 || e.list[1].isList || e.list[1].str {
+		return p.errorf(e.line, e.col, "tag needs a name")
+	}
+	name := e.list[1]
+	if _, ok := p.tag(name.atom); ok {
+		return p.errorf(name.line, name.col, "tag %s is already defined", name.atom)
+	}
+	if numTags()+len(p.tags) >= MaxSpanTags {
+		return p.errorf(e.line, e.col, "too many tags, at most %d tags can be defined", MaxSpanTags)
+	}
+	def := TagDefinition{Name: name.atom, Label: name.atom}
+
+	seen := map[string]bool{}
+	for _, attr := range e.list[2:] {
+		if !attr.isList || len(attr.list) != 2 || attr.list[0].isList || attr.list[0].str || !attr.list[1].str {
+			return p.errorf(attr.line, attr.col, "expected (label \"...\") or (color \"...\")")
+		}
+		kind, val := attr.list[0].atom, attr.list[1]
+		if seen[kind] {
+			return p.errorf(attr.line, attr.col, "duplicate %s", kind)
+		}
+		seen[kind] = true
+		switch kind {
+		case "label":
+			def.Label = val.atom
+		case "color":
+			var r, g, b uint8
+			if n, err := fmt.Sscanf(val.atom, "#%02x%02x%02x", &r, &g, &b); err != nil || n != 3 || len(val.atom) != 7 {
+				return p.errorf(val.line, val.col, "invalid color %s, expected #rrggbb", val)
+			}
+			def.Color = color.NRGBA{R: r, G: g, B: b, A: 0xFF}
+		default:
+			return p.errorf(attr.line, attr.col, "unknown tag attribute %s", kind)
+		}
+	}
+	p.tags = append(p.tags, def)
+	return nil
+}
+
+// tag looks up a tag by name, including the tags defined by the rules being compiled.
+func (p *ruleParser) tag(name string) (SpanTag, bool) {
+	if t, ok := LookupTag(name); ok {
+		return t, true
+	}
+	for i, def := range p.tags {
+		if def.Name == name {
+			return SpanTag(numTags() + i), true
+		}
+	}
+	return 0, false
+}
+
+func (p *ruleParser) compileRule(e sexpr) (pattern, error) {
+	if !e.isList || len(e.list) == 0 || e.list[0].atom != "rule" || e.list[0].str {
+		return pattern{}, p.errorf(e.line, e.col, "expected (rule ...) or (tag ...)")
+	}
+	if len(e.list) < 3 {
+		return pattern{}, p.errorf(e.line, e.col, "rule needs a state and a matcher")
+	}
+	state, err := p.state(e.list[1])
+	if err != nil {
+		return pattern{}, err
+	}
+	m, err := p.compileMatcher(e.list[2])
+	if err != nil {
+		return pattern{}, err
+	}
+	pat := pattern{state: state, match: m}
+
+	seen := map[string]bool{}
+	for _, action := range e.list[3:] {
+		if !action.isList || len(action.list) == 0 || action.list[0].isList || action.list[0].str {
+			return pattern{}, p.errorf(action.line, action.col, "expected action")
+		}
+		kind := action.list[0].atom
+		if seen[kind] {
+			return pattern{}, p.errorf(action.line, action.col, "duplicate %s action", kind)
+		}
+		seen[kind] = true
+		args := action.list[1:]
+		switch kind {
+		case "state":
+			if len(args) != 1 {
+				return pattern{}, p.errorf(action.line, action.col, "state needs exactly one state")
+			}
+			pat.newState, err = p.state(args[0])
+			if err != nil {
+				return pattern{}, err
+			}
+		case "at":
+			if len(args) != 1 {
+				return pattern{}, p.errorf(action.line, action.col, "at needs exactly one offset")
+			}
+			n, err := p.number(args[0], 255)
+			if err != nil {
+				return pattern{}, err
+			}
+			pat.at = uint8(n)
+		case "tags":
+			if len(args) == 0 {
+				return pattern{}, p.errorf(action.line, action.col, "tags needs at least one tag")
+			}
+			for _, arg := range args {
+				tag, ok := p.tag(arg.atom)
+				if !ok || arg.isList || arg.str {
+					return pattern{}, p.errorf(arg.line, arg.col, "unknown tag %s", arg)
+				}
+				pat.tagMask |= 1 << tag
+			}
+		default:
+			return pattern{}, p.errorf(action.line, action.col, "unknown action %s", kind)
+		}
+	}
+	return pat, nil
+}
+
+func (p *ruleParser) compileMatcher(e sexpr) (matcher, error) {
+	if !e.isList {
+		if e.atom == "_" && !e.str {
+			return anyMatcher{}, nil
+		}
+		return nil, p.errorf(e.line, e.col, "expected matcher, got %s", e)
+	}
+	if len(e.list) == 0 || e.list[0].isList || e.list[0].str {
+		return nil, p.errorf(e.line, e.col, "expected matcher")
+	}
+	kind := e.list[0].atom
+	args := e.list[1:]
+	switch kind {
+	case "frame":
+		if len(args) < 2 {
+			return nil, p.errorf(e.line, e.col, "frame needs an offset and at least one function")
+		}
+		off, err := p.number(args[0], 255)
+		if err != nil {
+			return nil, err
+		}
+		fns, err := p.globs(args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return frameMatcher{off: off, fns: fns}, nil
+	case "frames":
+		if len(args) == 0 {
+			return nil, p.errorf(e.line, e.col, "frames needs at least one function")
+		}
+		fns, err := p.globs(args)
+		if err != nil {
+			return nil, err
+		}
+		return framesMatcher(fns), nil
+	case "and", "or":
+		if len(args) == 0 {
+			return nil, p.errorf(e.line, e.col, "%s needs at least one matcher", kind)
+		}
+		ms := make([]matcher, len(args))
+		for i, arg := range args {
+			m, err := p.compileMatcher(arg)
+			if err != nil {
+				return nil, err
+			}
+			ms[i] = m
+		}
+		if kind == "and" {
+			return andMatcher(ms), nil
+		}
+		return orMatcher(ms), nil
+	case "not":
+		if len(args) != 1 {
+			return nil, p.errorf(e.line, e.col, "not needs exactly one matcher")
+		}
+		m, err := p.compileMatcher(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return notMatcher{m}, nil
+	default:
+		return nil, p.errorf(e.line, e.col, "unknown matcher %s", kind)
+	}
+}
+
+func (p *ruleParser) state(e sexpr) (SchedulingState, error) {
+	state, ok := stateNames[e.atom]
+	if !ok || e.isList || e.str {
+		return 0, p.errorf(e.line, e.col, "unknown state %s", e)
+	}
+	return state, nil
+}
+
+func (p *ruleParser) number(e sexpr, max int) (int, error) {
+	n, err := strconv.Atoi(e.atom)
+	if err != nil || e.isList || e.str || n < 0 || n > max {
+		return 0, p.errorf(e.line, e.col, "expected number between 0 and %d, got %s", max, e)
+	}
+	return n, nil
+}
+
+func (p *ruleParser) globs(es []sexpr) ([]glob, error) {
+	out := make([]glob, len(es))
+	for i, e := range es {
+		switch {
+		case e.str:
+			out[i] = glob(e.atom)
+		case !e.isList && e.atom == "_":
+			out[i] = "_"
+		default:
+			return nil, p.errorf(e.line, e.col, "expected function name, got %s", e)
+		}
+	}
+	return out, nil
+}
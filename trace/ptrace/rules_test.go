@@ -0,0 +1,242 @@
+package ptrace_test
+
+import (
+	"bytes"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestLoadRulesErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{`(rule blocked-recv`, "test:1:1: unclosed list"},
+		{`(rule blocked-recv _))`, "test:1:22: unexpected )"},
+		{`(frame 0 "main.main")`, "test:1:1: expected (rule ...) or (tag ...)"},
+		{`(rule blocked-recv)`, "test:1:1: rule needs a state and a matcher"},
+		{`(rule running _)`, "test:1:7: unknown state running"},
+		{"; comment\n(rule blocked-recv\n  (frame x \"main.main\"))", "test:3:10: expected number between 0 and 255, got x"},
+		{`(rule blocked-recv (frame 0 main.main))`, "test:1:29: expected function name, got main.main"},
+		{`(rule blocked-recv (frames))`, "test:1:20: frames needs at least one function"},
+		{`(rule blocked-recv (xor _ _))`, "test:1:20: unknown matcher xor"},
+		{`(rule blocked-recv (not _ _))`, "test:1:20: not needs exactly one matcher"},
+		{`(rule blocked-recv _ (at 1) (at 2))`, "test:1:29: duplicate at action"},
+		{`(rule blocked-recv _ (tags blue))`, "test:1:28: unknown tag blue"},
+		{`(rule blocked-recv _ (color blue))`, "test:1:22: unknown action color"},
+		{`(rule blocked-recv (frames "main.main))`, "test:1:28: unterminated string"},
+		{`(tag)`, "test:1:1: tag needs a name"},
+		{`(tag http)`, "test:1:6: tag http is already defined"},
+		{`(tag db) (tag db)`, "test:1:15: tag db is already defined"},
+		{`(tag db (color "blue"))`, "test:1:16: invalid color \"blue\", expected #rrggbb"},
+		{`(tag db (label "DB") (label "SQL"))`, "test:1:22: duplicate label"},
+		{`(tag db (shape "round"))`, "test:1:9: unknown tag attribute shape"},
+		{`(tag db) (rule blocked-recv _ (tags db blue))`, "test:1:40: unknown tag blue"},
+	}
+	for _, tt := range tests {
+		err := ptrace.LoadRules("test", []byte(tt.src))
+		if err == nil {
+			t.Errorf("%q: expected error %q, got none", tt.src, tt.err)
+		} else if err.Error() != tt.err {
+			t.Errorf("%q: got error %q, want %q", tt.src, err, tt.err)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	before := loadTrace(t, "stress_1_21_good")
+
+	// Compute which spans the rule should match.
+	var want []bool
+	for _, g := range before.Goroutines {
+		for _, s := range g.Spans {
+			tagged := s.Tags.Has(ptrace.SpanTagDial)
+			stack := before.Stacks[before.Event(s.Event()).StkID]
+			if s.State != ptrace.StateBlockedRecv || len(stack) == 0 || before.PCs[stack[0]].Fn == "runtime.chanrecv2" {
+				want = append(want, tagged)
+				continue
+			}
+			var inClosure bool
+			for _, pc := range stack {
+				fn, ok := strings.CutPrefix(before.PCs[pc].Fn, "runtime/trace_test.")
+				inClosure = inClosure || (ok && strings.Contains(fn, ".func"))
+			}
+			want = append(want, tagged || inClosure)
+		}
+	}
+
+	// Loading rules affects all later calls to Parse. Tagging spans as dialing doesn't affect other tests.
+	const rules = `
+; Receives in closures of the tests that don't use the two-value form of receives.
+(rule blocked-recv
+  (and (frames "runtime/trace_test.*.func*") (not (frame 0 "runtime.chanrecv2")))
+  (tags dial))
+`
+	if err := ptrace.LoadRules("test", []byte(rules)); err != nil {
+		t.Fatal(err)
+	}
+	after := loadTrace(t, "stress_1_21_good")
+
+	var i, n int
+	for j, g := range after.Goroutines {
+		for k, s := range g.Spans {
+			got := s.Tags.Has(ptrace.SpanTagDial)
+			if got != want[i] {
+				t.Errorf("goroutine %d: span at %d: got tagged %t, want %t", g.ID, s.Start, got, want[i])
+			}
+			if got && !before.Goroutines[j].Spans[k].Tags.Has(ptrace.SpanTagDial) {
+				n++
+			}
+			i++
+		}
+	}
+	if n == 0 {
+		t.Error("rule didn't match any spans")
+	}
+}
+
+func TestRuleTags(t *testing.T) {
+	const rules = `
+(tag test-sync (label "sync") (color "#c08040"))
+(rule blocked-sync _ (tags test-sync network))
+`
+	if err := ptrace.LoadRules("test", []byte(rules)); err != nil {
+		t.Fatal(err)
+	}
+	tag, ok := ptrace.LookupTag("test-sync")
+	if !ok {
+		t.Fatal("tag wasn't defined")
+	}
+	if def, want := tag.Definition(), (ptrace.TagDefinition{Name: "test-sync", Label: "sync", Color: color.NRGBA{0xC0, 0x80, 0x40, 0xFF}}); def != want {
+		t.Errorf("got definition %+v, want %+v", def, want)
+	}
+
+	ptr := loadTrace(t, "stress_1_21_good")
+	var n int
+	for _, g := range ptr.Goroutines {
+		for _, s := range g.Spans {
+			if got, want := s.Tags.Has(tag), s.State == ptrace.StateBlockedSync; got != want {
+				t.Errorf("goroutine %d: span at %d in state %d: got tagged %t, want %t", g.ID, s.Start, s.State, got, want)
+			}
+			if s.Tags.Has(tag) {
+				n++
+				if !s.Tags.Has(ptrace.SpanTagNetwork) {
+					t.Errorf("goroutine %d: span at %d: missing network tag", g.ID, s.Start)
+				}
+			}
+		}
+	}
+	if n == 0 {
+		t.Fatal("rule didn't match any spans")
+	}
+
+	// Sets of tags have to survive caching.
+	var buf bytes.Buffer
+	if err := ptr.WriteCache(&buf, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := ptrace.ReadCache(&buf, int64(buf.Len()), "v1", func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, g := range ptr.Goroutines {
+		for j, s := range g.Spans {
+			if got := cached.Goroutines[i].Spans[j].Tags; got.Mask() != s.Tags.Mask() {
+				t.Fatalf("goroutine %d: span at %d: got tags %v after caching, want %v", g.ID, s.Start, got.Tags(), s.Tags.Tags())
+			}
+		}
+	}
+}
+
+func TestRulesSynthetic(t *testing.T) {
+	const rules = `
+(tag test-lock (label "lock"))
+(rule blocked-sync (frame 1 "main.lock*" "main.rules") (tags test-lock) (at 1))
+; Waiting for the connection pool isn't interesting.
+(rule blocked-sync (frames "main.(*db).*") (state inactive))
+`
+	if err := ptrace.LoadRules("test", []byte(rules)); err != nil {
+		t.Fatal(err)
+	}
+	tag, ok := ptrace.LookupTag("test-lock")
+	if !ok {
+		t.Fatal("tag wasn't defined")
+	}
+
+	// g1 blocks on mutexes four times, and g2 unblocks it each time.
+	stacks := [][]string{
+		{"main.rules"},
+		{"sync.(*Mutex).Lock", "main.lockA", "main.rules"},
+		{"sync.(*Mutex).Lock", "sync.(*Once).doSlow", "sync.(*Once).Do", "main.rules"},
+		{"sync.(*Mutex).Lock", "main.(*db).conn", "main.rules"},
+		{"sync.(*RWMutex).RLock", "main.lockD"},
+	}
+	evs := []trace.Event{
+		ev(0, trace.EvProcStart, 0, 0, 0, 1),
+		ev(0, trace.EvProcStart, 1, 0, 0, 2),
+		ev(1, trace.EvGoCreate, 0, 0, 0, 1, 1),
+		ev(1, trace.EvGoCreate, 0, 0, 0, 2, 1),
+		ev(2, trace.EvGoStart, 0, 1, 0, 1),
+		ev(2, trace.EvGoStart, 1, 2, 0, 2),
+	}
+	for i := range stacks[1:] {
+		ts := trace.Timestamp(3 + 3*i)
+		evs = append(evs,
+			ev(ts, trace.EvGoBlockSync, 0, 1, uint32(i+2)),
+			ev(ts+1, trace.EvGoUnblock, 1, 2, 0, 1),
+			ev(ts+2, trace.EvGoStart, 0, 1, 0, 1))
+	}
+	evs = append(evs,
+		ev(15, trace.EvGoEnd, 0, 1, 0),
+		ev(15, trace.EvGoEnd, 1, 2, 0),
+		ev(16, trace.EvProcStop, 0, 0, 0),
+		ev(16, trace.EvProcStop, 1, 0, 0))
+	ptr := synthesizeTrace(t, nil, stacks, evs)
+
+	type span struct {
+		state  ptrace.SchedulingState
+		at     uint8
+		tagged bool
+	}
+	want := []span{
+		{ptrace.StateBlockedSync, 1, true},
+		{ptrace.StateBlockedSyncOnce, 3, false},
+		{ptrace.StateInactive, 0, false},
+		{ptrace.StateBlockedSync, 0, false},
+	}
+	var got []span
+	for _, s := range ptr.Goroutines[0].Spans {
+		if ptr.Event(s.Event()).Type == trace.EvGoBlockSync {
+			got = append(got, span{s.State, s.At, s.Tags.Has(tag)})
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got spans %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadRulesConcurrently(t *testing.T) {
+	// Only one of the calls can define the tag.
+	errs := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			errs <- ptrace.LoadRules("test", []byte(`(tag test-concurrent)`))
+		}()
+	}
+	var defined int
+	for i := 0; i < 4; i++ {
+		if err := <-errs; err == nil {
+			defined++
+		} else if !strings.HasSuffix(err.Error(), "tag test-concurrent is already defined") {
+			t.Errorf("got unexpected error %q", err)
+		}
+	}
+	if defined != 1 {
+		t.Errorf("tag was defined %d times, want 1", defined)
+	}
+}
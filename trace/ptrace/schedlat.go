@@ -0,0 +1,132 @@
+package ptrace
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+// LatencyPercentiles summarizes a distribution of latencies.
+type LatencyPercentiles struct {
+	Count int
+	Total time.Duration
+	Min   time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	P999  time.Duration
+	Max   time.Duration
+}
+
+// ComputeLatencyPercentiles summarizes latencies, which must be sorted in ascending order. Percentiles use the
+// nearest-rank method.
+func ComputeLatencyPercentiles(ds []time.Duration) LatencyPercentiles {
+	if len(ds) == 0 {
+		return LatencyPercentiles{}
+	}
+	rank := func(p float64) time.Duration {
+		idx := int(math.Ceil(p*float64(len(ds)))) - 1
+		return ds[max(idx, 0)]
+	}
+	out := LatencyPercentiles{
+		Count: len(ds),
+		Min:   ds[0],
+		P50:   rank(0.5),
+		P90:   rank(0.9),
+		P99:   rank(0.99),
+		P999:  rank(0.999),
+		Max:   ds[len(ds)-1],
+	}
+	for _, d := range ds {
+		out.Total += d
+	}
+	return out
+}
+
+// A SchedulingWait is a span during which a goroutine was runnable, but not running.
+type SchedulingWait struct {
+	Goroutine *Goroutine
+	Span      Span
+}
+
+type FunctionSchedulingLatency struct {
+	Function *Function
+	LatencyPercentiles
+}
+
+type SchedulingLatencyBucket struct {
+	Start, End trace.Timestamp
+	LatencyPercentiles
+}
+
+// SchedulingLatency describes how long goroutines had to wait to be scheduled after becoming runnable.
+type SchedulingLatency struct {
+	// All waits, sorted by duration in descending order
+	Waits   []SchedulingWait
+	Overall LatencyPercentiles
+	// Latencies grouped by the goroutines' functions, sorted by function name
+	ByFunction []FunctionSchedulingLatency
+	// Latencies grouped by the time at which goroutines became runnable. Buckets cover the entire trace.
+	ByTime []SchedulingLatencyBucket
+}
+
+// SchedulingLatency computes scheduling latencies, using spans in the ready and created states. Time is divided into
+// buckets of the given size. A size of zero or less puts all waits in a single bucket.
+func (tr *Trace) SchedulingLatency(bucketSize time.Duration) *SchedulingLatency {
+	var end trace.Timestamp
+	if tr.Events.Len() > 0 {
+		end = tr.Events.Last().Ts
+	}
+	if bucketSize <= 0 {
+		bucketSize = max(time.Duration(end), 1)
+	}
+
+	var out SchedulingLatency
+	for _, g := range tr.Goroutines {
+		for _, s := range g.Spans {
+			if s.State == StateReady || s.State == StateCreated {
+				out.Waits = append(out.Waits, SchedulingWait{Goroutine: g, Span: s})
+			}
+		}
+	}
+	sort.SliceStable(out.Waits, func(i, j int) bool {
+		return out.Waits[i].Span.Duration() > out.Waits[j].Span.Duration()
+	})
+
+	// Iterating over the waits from shortest to longest produces sorted durations for each group.
+	var all []time.Duration
+	byFn := map[*Function][]time.Duration{}
+	n := int(math.Ceil(float64(end) / float64(bucketSize)))
+	byTime := make([][]time.Duration, max(n, 1))
+	for i := len(out.Waits) - 1; i >= 0; i-- {
+		w := out.Waits[i]
+		d := w.Span.Duration()
+		all = append(all, d)
+		byFn[w.Goroutine.Function] = append(byFn[w.Goroutine.Function], d)
+		bucket := min(int(time.Duration(w.Span.Start)/bucketSize), len(byTime)-1)
+		byTime[bucket] = append(byTime[bucket], d)
+	}
+
+	out.Overall = ComputeLatencyPercentiles(all)
+	for fn, ds := range byFn {
+		out.ByFunction = append(out.ByFunction, FunctionSchedulingLatency{
+			Function:           fn,
+			LatencyPercentiles: ComputeLatencyPercentiles(ds),
+		})
+	}
+	sort.Slice(out.ByFunction, func(i, j int) bool {
+		return out.ByFunction[i].Function.Fn < out.ByFunction[j].Function.Fn
+	})
+	out.ByTime = make([]SchedulingLatencyBucket, len(byTime))
+	for i, ds := range byTime {
+		out.ByTime[i] = SchedulingLatencyBucket{
+			Start:              trace.Timestamp(time.Duration(i) * bucketSize),
+			End:                trace.Timestamp(time.Duration(i+1) * bucketSize),
+			LatencyPercentiles: ComputeLatencyPercentiles(ds),
+		}
+	}
+
+	return &out
+}
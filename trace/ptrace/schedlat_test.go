@@ -0,0 +1,73 @@
+package ptrace_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestLatencyPercentiles(t *testing.T) {
+	var ds []time.Duration
+	for i := 1; i <= 1000; i++ {
+		ds = append(ds, time.Duration(i))
+	}
+	got := ptrace.ComputeLatencyPercentiles(ds)
+	want := ptrace.LatencyPercentiles{
+		Count: 1000,
+		Total: 500500,
+		Min:   1,
+		P50:   500,
+		P90:   900,
+		P99:   990,
+		P999:  999,
+		Max:   1000,
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := ptrace.ComputeLatencyPercentiles(nil); got != (ptrace.LatencyPercentiles{}) {
+		t.Errorf("got %+v for no latencies, want zero value", got)
+	}
+}
+
+func TestSchedulingLatencyPingPong(t *testing.T) {
+	ptr := pingPongTrace(t)
+	g1, g2 := ptr.Goroutines[0], ptr.Goroutines[1]
+
+	sl := ptr.SchedulingLatency(10)
+	type wait struct {
+		g          *ptrace.Goroutine
+		start, end trace.Timestamp
+	}
+	var waits []wait
+	for _, w := range sl.Waits {
+		waits = append(waits, wait{w.Goroutine, w.Span.Start, w.Span.End})
+	}
+	if want := []wait{{g1, 10, 12}, {g2, 3, 5}, {g2, 20, 22}, {g1, 1, 2}}; !reflect.DeepEqual(waits, want) {
+		t.Errorf("got waits %v, want %v", waits, want)
+	}
+	if want := (ptrace.LatencyPercentiles{Count: 4, Total: 7, Min: 1, P50: 2, P90: 2, P99: 2, P999: 2, Max: 2}); sl.Overall != want {
+		t.Errorf("got %+v, want %+v", sl.Overall, want)
+	}
+
+	wantFn := []ptrace.FunctionSchedulingLatency{
+		{Function: g1.Function, LatencyPercentiles: ptrace.LatencyPercentiles{Count: 2, Total: 3, Min: 1, P50: 1, P90: 2, P99: 2, P999: 2, Max: 2}},
+		{Function: g2.Function, LatencyPercentiles: ptrace.LatencyPercentiles{Count: 2, Total: 4, Min: 2, P50: 2, P90: 2, P99: 2, P999: 2, Max: 2}},
+	}
+	if !reflect.DeepEqual(sl.ByFunction, wantFn) {
+		t.Errorf("got %+v by function, want %+v", sl.ByFunction, wantFn)
+	}
+
+	// The trace ends at 27, which falls into the third bucket.
+	wantTime := []ptrace.SchedulingLatencyBucket{
+		{Start: 0, End: 10, LatencyPercentiles: ptrace.LatencyPercentiles{Count: 2, Total: 3, Min: 1, P50: 1, P90: 2, P99: 2, P999: 2, Max: 2}},
+		{Start: 10, End: 20, LatencyPercentiles: ptrace.LatencyPercentiles{Count: 1, Total: 2, Min: 2, P50: 2, P90: 2, P99: 2, P999: 2, Max: 2}},
+		{Start: 20, End: 30, LatencyPercentiles: ptrace.LatencyPercentiles{Count: 1, Total: 2, Min: 2, P50: 2, P90: 2, P99: 2, P999: 2, Max: 2}},
+	}
+	if !reflect.DeepEqual(sl.ByTime, wantTime) {
+		t.Errorf("got %+v by time, want %+v", sl.ByTime, wantTime)
+	}
+}
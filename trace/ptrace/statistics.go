@@ -8,7 +8,7 @@ import (
 )
 
 func ComputeProcessorBusy(tr *Trace, p *Processor, bucketSize time.Duration) []int {
-	total := tr.Events[len(tr.Events)-1].Ts
+	total := tr.Events.Last().Ts
 	buckets := make([]time.Duration, int(math.Ceil(float64(total)/float64(bucketSize))))
 	for i := 0; i < len(p.Spans); i++ {
 		span := p.Spans[i]
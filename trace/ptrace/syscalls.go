@@ -0,0 +1,136 @@
+package ptrace
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+// A Syscall is a single system call made by a goroutine.
+type Syscall struct {
+	Goroutine *Goroutine
+	// The EvGoSysCall event, or the event of the span for syscalls that blocked
+	Event EventID
+	// Whether the syscall blocked, causing its P to be handed off
+	Blocking bool
+	// For blocking syscalls, the span during which the goroutine was blocked in the syscall
+	Span Span
+	// The function making the syscall and the first function outside of syscall packages calling it. Both are empty if
+	// the syscall has no stack.
+	Name, Caller string
+}
+
+// Duration returns the duration of the syscall. The trace only records durations for syscalls that blocked; Duration
+// returns 0 for other syscalls.
+func (sc *Syscall) Duration() time.Duration {
+	if !sc.Blocking {
+		return 0
+	}
+	return sc.Span.Duration()
+}
+
+// SyscallGroup summarizes the syscalls with the same name or caller.
+type SyscallGroup struct {
+	Name string
+	// Number of syscalls, blocking or not
+	Calls int
+	// Durations of syscalls that blocked
+	Blocking LatencyPercentiles
+}
+
+type SyscallAnalysis struct {
+	// All syscalls, sorted by duration in descending order
+	Syscalls []Syscall
+	// Syscalls grouped by name, sorted by name
+	ByName []SyscallGroup
+	// Syscalls grouped by caller, sorted by caller
+	ByCaller []SyscallGroup
+}
+
+// isSyscallFunction reports whether fn belongs to one of the packages that implement syscalls, and is thus not
+// interesting as the caller of a syscall.
+func isSyscallFunction(fn string) bool {
+	for _, prefix := range []string{"syscall.", "internal/syscall/", "internal/poll.", "golang.org/x/sys/", "runtime."} {
+		if strings.HasPrefix(fn, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (tr *Trace) syscall(g *Goroutine, evID EventID, blocking bool, span Span) Syscall {
+	sc := Syscall{
+		Goroutine: g,
+		Event:     evID,
+		Blocking:  blocking,
+		Span:      span,
+	}
+	if stk := tr.Stacks[tr.Event(evID).StkID]; len(stk) > 0 {
+		sc.Name = tr.PCs[stk[0]].Fn
+		sc.Caller = sc.Name
+		for _, pc := range stk[1:] {
+			if fn := tr.PCs[pc].Fn; !isSyscallFunction(fn) {
+				sc.Caller = fn
+				break
+			}
+		}
+	}
+	return sc
+}
+
+// Syscalls collects and groups all syscalls in the trace.
+func (tr *Trace) Syscalls() *SyscallAnalysis {
+	var out SyscallAnalysis
+	for _, g := range tr.Goroutines {
+		for _, s := range g.Spans {
+			if s.State == StateBlockedSyscall {
+				out.Syscalls = append(out.Syscalls, tr.syscall(g, s.Event(), true, s))
+			}
+		}
+		for _, evID := range g.Events {
+			ev := tr.Event(evID)
+			if ev.Type != trace.EvGoSysCall {
+				continue
+			}
+			// Spans of blocking syscalls start at the time of the EvGoSysCall event. We've already collected those.
+			idx := sort.Search(len(g.Spans), func(i int) bool { return g.Spans[i].Start >= ev.Ts })
+			if idx < len(g.Spans) && g.Spans[idx].Start == ev.Ts && g.Spans[idx].State == StateBlockedSyscall {
+				continue
+			}
+			out.Syscalls = append(out.Syscalls, tr.syscall(g, evID, false, Span{}))
+		}
+	}
+	sort.SliceStable(out.Syscalls, func(i, j int) bool {
+		return out.Syscalls[i].Duration() > out.Syscalls[j].Duration()
+	})
+
+	group := func(key func(sc *Syscall) string) []SyscallGroup {
+		calls := map[string]int{}
+		durations := map[string][]time.Duration{}
+		// Iterating from shortest to longest produces sorted durations.
+		for i := len(out.Syscalls) - 1; i >= 0; i-- {
+			sc := &out.Syscalls[i]
+			k := key(sc)
+			calls[k]++
+			if sc.Blocking {
+				durations[k] = append(durations[k], sc.Duration())
+			}
+		}
+		groups := make([]SyscallGroup, 0, len(calls))
+		for k, n := range calls {
+			groups = append(groups, SyscallGroup{
+				Name:     k,
+				Calls:    n,
+				Blocking: ComputeLatencyPercentiles(durations[k]),
+			})
+		}
+		sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+		return groups
+	}
+	out.ByName = group(func(sc *Syscall) string { return sc.Name })
+	out.ByCaller = group(func(sc *Syscall) string { return sc.Caller })
+
+	return &out
+}
@@ -0,0 +1,76 @@
+package ptrace_test
+
+import (
+	"reflect"
+	"testing"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestSyscallsSynthetic(t *testing.T) {
+	// g1 makes a fast read, a write that blocks from 5 to 15, and another fast read. g2 was already in a syscall when
+	// tracing started and returns from it at 12.
+	ptr := synthesizeTrace(t, nil, [][]string{
+		{"main.main"},
+		{"syscall.read", "internal/poll.(*FD).Read", "os.(*File).Read", "main.main"},
+		{"syscall.write", "os.(*File).Write", "main.main"},
+		{"main.poll"},
+	}, []trace.Event{
+		ev(0, trace.EvProcStart, 0, 0, 0, 1),
+		ev(1, trace.EvGoCreate, 0, 0, 0, 1, 1),
+		ev(1, trace.EvGoCreate, 0, 0, 0, 2, 4),
+		ev(1, trace.EvGoInSyscall, -1, 2, 0, 2),
+		ev(2, trace.EvGoStart, 0, 1, 0, 1),
+		ev(3, trace.EvGoSysCall, 0, 1, 2),
+		ev(5, trace.EvGoSysCall, 0, 1, 3),
+		ev(6, trace.EvGoSysBlock, 0, 1, 0),
+		ev(7, trace.EvProcStop, 0, 0, 0),
+		ev(12, trace.EvGoSysExit, -1, 0, 0, 2),
+		ev(15, trace.EvGoSysExit, -1, 0, 0, 1),
+		ev(16, trace.EvProcStart, 0, 0, 0, 1),
+		ev(17, trace.EvGoStart, 0, 1, 0, 1),
+		ev(18, trace.EvGoSysCall, 0, 1, 2),
+		ev(20, trace.EvGoEnd, 0, 1, 0),
+		ev(21, trace.EvProcStop, 0, 0, 0),
+	})
+	g1, g2 := ptr.Goroutines[0], ptr.Goroutines[1]
+
+	type syscall struct {
+		g            *ptrace.Goroutine
+		blocking     bool
+		start, end   trace.Timestamp
+		name, caller string
+	}
+	want := []syscall{
+		{g2, true, 1, 12, "", ""},
+		{g1, true, 5, 15, "syscall.write", "os.(*File).Write"},
+		{g1, false, 0, 0, "syscall.read", "os.(*File).Read"},
+		{g1, false, 0, 0, "syscall.read", "os.(*File).Read"},
+	}
+	sa := ptr.Syscalls()
+	var got []syscall
+	for _, sc := range sa.Syscalls {
+		got = append(got, syscall{sc.Goroutine, sc.Blocking, sc.Span.Start, sc.Span.End, sc.Name, sc.Caller})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got syscalls %v, want %v", got, want)
+	}
+
+	wantByName := []ptrace.SyscallGroup{
+		{Name: "", Calls: 1, Blocking: ptrace.LatencyPercentiles{Count: 1, Total: 11, Min: 11, P50: 11, P90: 11, P99: 11, P999: 11, Max: 11}},
+		{Name: "syscall.read", Calls: 2},
+		{Name: "syscall.write", Calls: 1, Blocking: ptrace.LatencyPercentiles{Count: 1, Total: 10, Min: 10, P50: 10, P90: 10, P99: 10, P999: 10, Max: 10}},
+	}
+	if !reflect.DeepEqual(sa.ByName, wantByName) {
+		t.Errorf("got %+v by name, want %+v", sa.ByName, wantByName)
+	}
+	wantByCaller := []ptrace.SyscallGroup{
+		{Name: "", Calls: 1, Blocking: wantByName[0].Blocking},
+		{Name: "os.(*File).Read", Calls: 2},
+		{Name: "os.(*File).Write", Calls: 1, Blocking: wantByName[2].Blocking},
+	}
+	if !reflect.DeepEqual(sa.ByCaller, wantByCaller) {
+		t.Errorf("got %+v by caller, want %+v", sa.ByCaller, wantByCaller)
+	}
+}
@@ -0,0 +1,202 @@
+package ptrace
+
+import (
+	"fmt"
+	"image/color"
+	"math/bits"
+	"sync"
+)
+
+// A SpanTag is a tag that spans can have, such as SpanTagHTTP. In addition to the built-in tags, rule files can define
+// tags, see LoadRules.
+type SpanTag uint8
+
+const (
+	SpanTagRead SpanTag = iota
+	SpanTagAccept
+	SpanTagDial
+	SpanTagNetwork
+	SpanTagTCP
+	SpanTagTLS
+	SpanTagHTTP
+
+	// Used for spans of GC goroutines, used when choosing span colors for processor timelines.
+	SpanTagGC
+)
+
+// MaxSpanTags is the maximum number of tags, including the built-in ones.
+const MaxSpanTags = 64
+
+// A TagDefinition describes a span tag.
+type TagDefinition struct {
+	// Name is the name that rules use to refer to the tag.
+	Name string
+	// Label is how the tag is displayed. Tags without labels aren't displayed.
+	Label string
+	// Color is the color of spans that have the tag, unless it is fully transparent.
+	Color color.NRGBA
+}
+
+// tagDefinitionsMu guards tagDefinitions. Definitions are only ever appended, which means that slices of
+// tagDefinitions remain valid after the lock has been released.
+var tagDefinitionsMu sync.RWMutex
+
+var tagDefinitions = []TagDefinition{
+	SpanTagRead:    {Name: "read", Label: "read"},
+	SpanTagAccept:  {Name: "accept", Label: "accept"},
+	SpanTagDial:    {Name: "dial", Label: "dial"},
+	SpanTagNetwork: {Name: "network", Label: "network"},
+	SpanTagTCP:     {Name: "tcp", Label: "TCP"},
+	SpanTagTLS:     {Name: "tls", Label: "TLS"},
+	SpanTagHTTP:    {Name: "http", Label: "HTTP"},
+	SpanTagGC:      {Name: "gc"},
+}
+
+// TagDefinitions returns the definitions of all tags, indexed by tag. The returned slice must not be modified.
+func TagDefinitions() []TagDefinition {
+	tagDefinitionsMu.RLock()
+	defer tagDefinitionsMu.RUnlock()
+	return tagDefinitions
+}
+
+// Definition returns the tag's definition.
+func (t SpanTag) Definition() TagDefinition {
+	tagDefinitionsMu.RLock()
+	defer tagDefinitionsMu.RUnlock()
+	return tagDefinitions[t]
+}
+
+// LookupTag returns the tag with the given name.
+func LookupTag(name string) (SpanTag, bool) {
+	tagDefinitionsMu.RLock()
+	defer tagDefinitionsMu.RUnlock()
+	return lookupTag(name)
+}
+
+// lookupTag is like LookupTag, but expects the caller to hold tagDefinitionsMu.
+func lookupTag(name string) (SpanTag, bool) {
+	for i, def := range tagDefinitions {
+		if def.Name == name {
+			return SpanTag(i), true
+		}
+	}
+	return 0, false
+}
+
+// SpanTags is a set of span tags. To keep spans small, sets of tags are interned and SpanTags identifies an interned
+// set. At most 255 different non-empty sets can exist; adding tags to a set fails silently once that limit has been
+// reached. The zero value is the empty set.
+type SpanTags uint8
+
+// spanTagSets holds the interned sets of tags. Sets are only ever added, and the IDs of sets only become available
+// after their masks have been stored, which means that masks can be read without holding the lock.
+var spanTagSets struct {
+	mu    sync.Mutex
+	masks [256]uint64
+	ids   map[uint64]SpanTags
+}
+
+// MakeSpanTags returns the set of the given tags.
+func MakeSpanTags(tags ...SpanTag) SpanTags {
+	var mask uint64
+	for _, t := range tags {
+		mask |= 1 << t
+	}
+	ts, _ := spanTagsFromMask(mask)
+	return ts
+}
+
+// spanTagsFromMask returns the set of tags whose bits are set in mask. It returns false if the set can't be
+// represented because too many different sets are in use.
+func spanTagsFromMask(mask uint64) (SpanTags, bool) {
+	if mask == 0 {
+		return 0, true
+	}
+	spanTagSets.mu.Lock()
+	defer spanTagSets.mu.Unlock()
+	if ts, ok := spanTagSets.ids[mask]; ok {
+		return ts, true
+	}
+	n := len(spanTagSets.ids) + 1
+	if n == len(spanTagSets.masks) {
+		return 0, false
+	}
+	if spanTagSets.ids == nil {
+		spanTagSets.ids = map[uint64]SpanTags{}
+	}
+	spanTagSets.masks[n] = mask
+	spanTagSets.ids[mask] = SpanTags(n)
+	return SpanTags(n), true
+}
+
+// spanTagMasks returns the masks of all interned sets of tags, indexed by SpanTags.
+func spanTagMasks() []uint64 {
+	spanTagSets.mu.Lock()
+	defer spanTagSets.mu.Unlock()
+	return append([]uint64(nil), spanTagSets.masks[:len(spanTagSets.ids)+1]...)
+}
+
+// Mask returns a bitmask of the tags in the set, with bit i corresponding to SpanTag(i).
+func (ts SpanTags) Mask() uint64 {
+	return spanTagSets.masks[ts]
+}
+
+// Has reports whether the set contains the tag.
+func (ts SpanTags) Has(t SpanTag) bool {
+	return ts.Mask()&(1<<t) != 0
+}
+
+// Tags returns the tags in the set, in ascending order.
+func (ts SpanTags) Tags() []SpanTag {
+	mask := ts.Mask()
+	out := make([]SpanTag, 0, bits.OnesCount64(mask))
+	for mask != 0 {
+		t := bits.TrailingZeros64(mask)
+		out = append(out, SpanTag(t))
+		mask &^= 1 << t
+	}
+	return out
+}
+
+// Union returns the set of tags that are in either set. It returns ts if the union can't be represented.
+func (ts SpanTags) Union(other SpanTags) SpanTags {
+	a, b := ts.Mask(), other.Mask()
+	switch a | b {
+	case a:
+		return ts
+	case b:
+		return other
+	}
+	if u, ok := spanTagsFromMask(a | b); ok {
+		return u
+	}
+	return ts
+}
+
+// numTags returns the number of defined tags.
+func numTags() int {
+	tagDefinitionsMu.RLock()
+	defer tagDefinitionsMu.RUnlock()
+	return len(tagDefinitions)
+}
+
+// defineTags adds tag definitions. It fails if a name is already in use, either by a defined tag or by an earlier
+// definition in defs.
+func defineTags(defs []TagDefinition) error {
+	tagDefinitionsMu.Lock()
+	defer tagDefinitionsMu.Unlock()
+	if len(tagDefinitions)+len(defs) > MaxSpanTags {
+		return fmt.Errorf("too many tags, at most %d tags can be defined", MaxSpanTags)
+	}
+	for i, def := range defs {
+		_, ok := lookupTag(def.Name)
+		for _, prev := range defs[:i] {
+			ok = ok || prev.Name == def.Name
+		}
+		if ok {
+			return fmt.Errorf("tag %s is already defined", def.Name)
+		}
+	}
+	tagDefinitions = append(tagDefinitions, defs...)
+	return nil
+}
@@ -0,0 +1,143 @@
+package ptrace
+
+import (
+	"sort"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+)
+
+// A TaskRegion is a user region that a goroutine executed on behalf of a task.
+type TaskRegion struct {
+	Goroutine *Goroutine
+	Span      Span
+}
+
+// TaskActivity describes the work that was done on behalf of a task.
+type TaskActivity struct {
+	// Start and End are the times the task was created and ended. Stub tasks use the bounds of their regions instead,
+	// and tasks that didn't end before the trace ended end at the end of the trace.
+	Start, End trace.Timestamp
+	// Regions executed on behalf of the task, sorted by start time.
+	Regions []TaskRegion
+	// Goroutines that executed regions on behalf of the task, sorted by ID.
+	Goroutines []*Goroutine
+}
+
+func (a *TaskActivity) Duration() time.Duration {
+	return time.Duration(a.End - a.Start)
+}
+
+// TaskActivity returns the activity of a task. Activities are computed for all tasks the first time this method is
+// called.
+func (tr *Trace) TaskActivity(t *Task) *TaskActivity {
+	tr.taskActivitiesOnce.Do(tr.computeTaskActivities)
+	return &tr.taskActivities[t.SeqID]
+}
+
+func (tr *Trace) computeTaskActivities() {
+	acts := make([]TaskActivity, len(tr.Tasks))
+	for _, g := range tr.Goroutines {
+		for _, spans := range g.UserRegions {
+			for _, s := range spans {
+				taskID := tr.Event(s.Event()).Args[trace.ArgUserRegionTaskID]
+				if taskID == 0 {
+					continue
+				}
+				idx, ok := tr.task(taskID)
+				if !ok {
+					continue
+				}
+				act := &acts[idx]
+				act.Regions = append(act.Regions, TaskRegion{Goroutine: g, Span: s})
+				// We iterate over goroutines in order, so we only have to check the last goroutine for duplicates.
+				if n := len(act.Goroutines); n == 0 || act.Goroutines[n-1] != g {
+					act.Goroutines = append(act.Goroutines, g)
+				}
+			}
+		}
+	}
+
+	for i, t := range tr.Tasks {
+		act := &acts[i]
+		sort.Slice(act.Regions, func(i, j int) bool {
+			return act.Regions[i].Span.Start < act.Regions[j].Span.Start
+		})
+
+		if t.Stub() {
+			if len(act.Regions) > 0 {
+				act.Start = act.Regions[0].Span.Start
+				for _, r := range act.Regions {
+					act.End = max(act.End, r.Span.End)
+				}
+			}
+			continue
+		}
+		ev := tr.Event(t.Event)
+		act.Start = ev.Ts
+		if link := ev.Link(); link != -1 {
+			act.End = tr.Event(EventID(link)).Ts
+		} else {
+			act.End = tr.Events.Last().Ts
+		}
+	}
+
+	tr.taskActivities = acts
+}
+
+// TaskStatistics breaks down the time that goroutines spent executing regions on behalf of a task, by goroutine state.
+// If subtasks is true, regions of all of the task's descendants are included, too. Only the parts of regions that fall
+// within the task's activity are considered, and overlapping regions on the same goroutine are only counted once.
+func (tr *Trace) TaskStatistics(t *Task, subtasks bool) Statistics {
+	type interval struct {
+		start, end trace.Timestamp
+	}
+
+	act := tr.TaskActivity(t)
+	regions := map[*Goroutine][]interval{}
+	var collect func(t *Task)
+	collect = func(t *Task) {
+		for _, r := range tr.TaskActivity(t).Regions {
+			start := max(r.Span.Start, act.Start)
+			end := min(r.Span.End, act.End)
+			if start < end {
+				regions[r.Goroutine] = append(regions[r.Goroutine], interval{start, end})
+			}
+		}
+		if subtasks {
+			for _, c := range t.Children {
+				collect(c)
+			}
+		}
+	}
+	collect(t)
+
+	var spans []Span
+	for g, ivs := range regions {
+		sort.Slice(ivs, func(i, j int) bool { return ivs[i].start < ivs[j].start })
+		// Merge overlapping intervals, which are caused by nested regions and by regions of subtasks.
+		merged := ivs[:1]
+		for _, iv := range ivs[1:] {
+			last := &merged[len(merged)-1]
+			if iv.start <= last.end {
+				last.end = max(last.end, iv.end)
+			} else {
+				merged = append(merged, iv)
+			}
+		}
+
+		for _, iv := range merged {
+			idx := sort.Search(len(g.Spans), func(i int) bool { return g.Spans[i].End > iv.start })
+			for _, s := range g.Spans[idx:] {
+				if s.Start >= iv.end {
+					break
+				}
+				s.Start = max(s.Start, iv.start)
+				s.End = min(s.End, iv.end)
+				spans = append(spans, s)
+			}
+		}
+	}
+
+	return ComputeStatistics(ToSpans(spans))
+}
@@ -0,0 +1,227 @@
+package ptrace_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"honnef.co/go/gotraceui/trace"
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestTaskActivity(t *testing.T) {
+	ptr := loadTrace(t, "user_task_region_1_21_good")
+	if len(ptr.Tasks) == 0 {
+		t.Fatal("trace has no tasks")
+	}
+
+	var numRegions int
+	for _, g := range ptr.Goroutines {
+		for _, spans := range g.UserRegions {
+			for _, s := range spans {
+				if ptr.Event(s.Event()).Args[trace.ArgUserRegionTaskID] != 0 {
+					numRegions++
+				}
+			}
+		}
+	}
+
+	if numRegions == 0 {
+		t.Fatal("trace has no regions that belong to tasks")
+	}
+
+	var gotRegions int
+	for _, task := range ptr.Tasks {
+		act := ptr.TaskActivity(task)
+		if act.End < act.Start {
+			t.Errorf("task %d ends before it starts", task.ID)
+		}
+		if !task.Stub() && act.Start != ptr.Event(task.Event).Ts {
+			t.Errorf("task %d: got start %d, want %d", task.ID, act.Start, ptr.Event(task.Event).Ts)
+		}
+		gotRegions += len(act.Regions)
+
+		seen := map[*ptrace.Goroutine]bool{}
+		for i, r := range act.Regions {
+			if id := ptr.Event(r.Span.Event()).Args[trace.ArgUserRegionTaskID]; id != task.ID {
+				t.Errorf("task %d has region of task %d", task.ID, id)
+			}
+			if i > 0 && act.Regions[i-1].Span.Start > r.Span.Start {
+				t.Errorf("task %d: regions aren't sorted", task.ID)
+			}
+			seen[r.Goroutine] = true
+		}
+		if len(seen) != len(act.Goroutines) {
+			t.Errorf("task %d: got %d goroutines, want %d", task.ID, len(act.Goroutines), len(seen))
+		}
+		for i, g := range act.Goroutines {
+			if !seen[g] {
+				t.Errorf("task %d: goroutine %d didn't execute any regions", task.ID, g.ID)
+			}
+			if i > 0 && act.Goroutines[i-1].ID >= g.ID {
+				t.Errorf("task %d: goroutines aren't sorted", task.ID)
+			}
+		}
+	}
+	if gotRegions != numRegions {
+		t.Errorf("got %d regions, want %d", gotRegions, numRegions)
+	}
+}
+
+func TestTaskActivitySynthetic(t *testing.T) {
+	// Task 1 lasts from 3 to 20 and has subtask 2, which lasts from 5 to 19. g1 executes a region of task 1 from 6 to
+	// 18, during which it blocks from 7 to 12 and is runnable until 17. g2 executes a region of task 2 from 10 to 14.
+	ptr := synthesizeTrace(t, []string{"request", "handle"}, [][]string{
+		{"main.main"},
+		{"main.worker"},
+		{"runtime.chanrecv1", "main.main"},
+		{"runtime.chansend1", "main.worker"},
+	}, []trace.Event{
+		ev(0, trace.EvProcStart, 0, 0, 0, 1),
+		ev(1, trace.EvGoCreate, 0, 0, 0, 1, 1),
+		ev(2, trace.EvGoStart, 0, 1, 0, 1),
+		ev(3, trace.EvUserTaskCreate, 0, 1, 0, 1, 0, 1),
+		ev(4, trace.EvGoCreate, 0, 1, 0, 2, 2),
+		ev(5, trace.EvUserTaskCreate, 0, 1, 0, 2, 1, 1),
+		ev(6, trace.EvUserRegion, 0, 1, 0, 1, 0, 2),
+		ev(7, trace.EvGoBlockRecv, 0, 1, 3),
+		ev(8, trace.EvProcStart, 1, 0, 0, 2),
+		ev(9, trace.EvGoStart, 1, 2, 0, 2),
+		ev(10, trace.EvUserRegion, 1, 2, 0, 2, 0, 2),
+		ev(12, trace.EvGoUnblock, 1, 2, 4, 1),
+		ev(14, trace.EvUserRegion, 1, 2, 0, 2, 1, 2),
+		ev(15, trace.EvGoEnd, 1, 2, 0),
+		ev(16, trace.EvProcStop, 1, 0, 0),
+		ev(17, trace.EvGoStart, 0, 1, 0, 1),
+		ev(18, trace.EvUserRegion, 0, 1, 0, 1, 1, 2),
+		ev(19, trace.EvUserTaskEnd, 0, 1, 0, 2),
+		ev(20, trace.EvUserTaskEnd, 0, 1, 0, 1),
+		ev(21, trace.EvGoEnd, 0, 1, 0),
+		ev(22, trace.EvProcStop, 0, 0, 0),
+	})
+	if len(ptr.Tasks) != 2 {
+		t.Fatalf("got %d tasks, want 2", len(ptr.Tasks))
+	}
+	g1, g2 := ptr.Goroutines[0], ptr.Goroutines[1]
+	task1, task2 := ptr.Tasks[0], ptr.Tasks[1]
+	if task2.Parent != task1 || len(task1.Children) != 1 || task1.Children[0] != task2 {
+		t.Errorf("task 2 isn't a subtask of task 1")
+	}
+
+	want := []ptrace.TaskActivity{
+		{Start: 3, End: 20, Goroutines: []*ptrace.Goroutine{g1}},
+		{Start: 5, End: 19, Goroutines: []*ptrace.Goroutine{g2}},
+	}
+	wantRegions := [][2]trace.Timestamp{{6, 18}, {10, 14}}
+	for i, task := range ptr.Tasks {
+		act := ptr.TaskActivity(task)
+		if act.Start != want[i].Start || act.End != want[i].End || !reflect.DeepEqual(act.Goroutines, want[i].Goroutines) {
+			t.Errorf("task %d: got %d–%d on %v, want %d–%d on %v", task.ID, act.Start, act.End, act.Goroutines,
+				want[i].Start, want[i].End, want[i].Goroutines)
+		}
+		if len(act.Regions) != 1 || act.Regions[0].Goroutine != want[i].Goroutines[0] ||
+			act.Regions[0].Span.Start != wantRegions[i][0] || act.Regions[0].Span.End != wantRegions[i][1] {
+			t.Errorf("task %d: got regions %v, want %v", task.ID, act.Regions, wantRegions[i])
+		}
+	}
+
+	tests := []struct {
+		task     *ptrace.Task
+		subtasks bool
+		want     map[ptrace.SchedulingState]time.Duration
+	}{
+		{task1, false, map[ptrace.SchedulingState]time.Duration{ptrace.StateActive: 2, ptrace.StateBlockedRecv: 5, ptrace.StateReady: 5}},
+		{task1, true, map[ptrace.SchedulingState]time.Duration{ptrace.StateActive: 6, ptrace.StateBlockedRecv: 5, ptrace.StateReady: 5}},
+		{task2, false, map[ptrace.SchedulingState]time.Duration{ptrace.StateActive: 4}},
+	}
+	for _, tt := range tests {
+		got := map[ptrace.SchedulingState]time.Duration{}
+		for state, stat := range ptr.TaskStatistics(tt.task, tt.subtasks) {
+			if stat.Total != 0 {
+				got[ptrace.SchedulingState(state)] = stat.Total
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("task %d (subtasks=%t): got %v, want %v", tt.task.ID, tt.subtasks, got, tt.want)
+		}
+	}
+}
+
+func TestTaskStatistics(t *testing.T) {
+	ptr := loadTrace(t, "user_task_region_1_26_good")
+	if len(ptr.Tasks) == 0 {
+		t.Fatal("trace has no tasks")
+	}
+
+	for _, task := range ptr.Tasks {
+		if task.Parent != nil {
+			if task.Parent.ID != task.ParentID {
+				t.Errorf("task %d: got parent %d, want %d", task.ID, task.Parent.ID, task.ParentID)
+			}
+			found := false
+			for _, c := range task.Parent.Children {
+				found = found || c == task
+			}
+			if !found {
+				t.Errorf("task %d isn't a child of its parent", task.ID)
+			}
+		}
+		for _, c := range task.Children {
+			if c.Parent != task {
+				t.Errorf("task %d has child %d with a different parent", task.ID, c.ID)
+			}
+		}
+
+		act := ptr.TaskActivity(task)
+		for _, subtasks := range []bool{false, true} {
+			stats := ptr.TaskStatistics(task, subtasks)
+			var total time.Duration
+			for _, stat := range stats {
+				total += stat.Total
+			}
+			if len(act.Regions) > 0 && total == 0 {
+				t.Errorf("task %d (subtasks=%t): regions didn't contribute any time", task.ID, subtasks)
+			}
+			// Goroutines can't spend more time on behalf of the task than the task lasted.
+			if limit := act.Duration() * time.Duration(len(act.Goroutines)); !subtasks && total > limit {
+				t.Errorf("task %d: got %s of activity, want at most %s", task.ID, total, limit)
+			}
+		}
+	}
+}
+
+func TestTaskCycles(t *testing.T) {
+	// Task 2 is its own parent, and tasks 3 and 4 are each other's parents.
+	ptr := synthesizeTrace(t, []string{"task"}, nil, []trace.Event{
+		ev(0, trace.EvProcStart, 0, 0, 0, 1),
+		ev(1, trace.EvGoCreate, 0, 0, 0, 1),
+		ev(2, trace.EvGoStart, 0, 1, 0, 1),
+		ev(3, trace.EvUserTaskCreate, 0, 1, 0, 2, 2, 1),
+		ev(4, trace.EvUserTaskCreate, 0, 1, 0, 3, 4, 1),
+		ev(5, trace.EvUserTaskCreate, 0, 1, 0, 4, 3, 1),
+		ev(6, trace.EvUserRegion, 0, 1, 0, 2, 0, 1),
+		ev(7, trace.EvUserRegion, 0, 1, 0, 2, 1, 1),
+		ev(8, trace.EvUserRegion, 0, 1, 0, 4, 0, 1),
+		ev(9, trace.EvUserRegion, 0, 1, 0, 4, 1, 1),
+		ev(10, trace.EvGoEnd, 0, 1, 0),
+		ev(11, trace.EvProcStop, 0, 0, 0),
+	})
+
+	if len(ptr.Tasks) != 3 {
+		t.Fatalf("got %d tasks, want 3", len(ptr.Tasks))
+	}
+	for _, task := range ptr.Tasks {
+		var want *ptrace.Task
+		if task.ID == 4 {
+			want = ptr.Tasks[1]
+		}
+		if task.Parent != want {
+			t.Errorf("task %d: got parent %v, want %v", task.ID, task.Parent, want)
+		}
+		// This mustn't recurse forever.
+		ptr.TaskStatistics(task, true)
+	}
+	if stats := ptr.TaskStatistics(ptr.Tasks[1], true); stats[ptrace.StateActive].Total != 1 {
+		t.Errorf("got %s of activity for task 3, want the 1ns of its subtask", stats[ptrace.StateActive].Total)
+	}
+}
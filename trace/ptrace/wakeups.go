@@ -0,0 +1,166 @@
+package ptrace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// A WakeupNode is a node in a wake-up graph, either a goroutine or a function.
+type WakeupNode struct {
+	// Goroutine is nil for graphs whose nodes are functions.
+	Goroutine *Goroutine
+	Function  *Function
+}
+
+func (n WakeupNode) String() string {
+	if n.Goroutine != nil {
+		return fmt.Sprintf("g%d: %s", n.Goroutine.ID, n.Function.Fn)
+	}
+	return n.Function.Fn
+}
+
+// A WakeupEdge records how often a node unblocked another node.
+type WakeupEdge struct {
+	// Indices into WakeupGraph.Nodes. From unblocked To.
+	From, To int
+	// The number of times From unblocked To.
+	Count int
+	// The total amount of time To spent blocked before it got unblocked by From.
+	Wait time.Duration
+}
+
+// A WakeupGraph aggregates which goroutines unblocked which other goroutines, over the whole trace.
+type WakeupGraph struct {
+	// Nodes sorted by goroutine or function, in the order they appear in the trace.
+	Nodes []WakeupNode
+	// Edges sorted by From, then To.
+	Edges []WakeupEdge
+}
+
+// WakeupGraph computes the wake-up graph of the trace. If byFunction is true, goroutines are grouped by their
+// functions.
+func (tr *Trace) WakeupGraph(byFunction bool) *WakeupGraph {
+	type edgeKey struct{ from, to any }
+
+	nodes := map[any]WakeupNode{}
+	edges := map[edgeKey]*WakeupEdge{}
+	node := func(g *Goroutine) any {
+		if byFunction {
+			nodes[g.Function] = WakeupNode{Function: g.Function}
+			return g.Function
+		}
+		nodes[g] = WakeupNode{Goroutine: g, Function: g.Function}
+		return g
+	}
+
+	for _, g := range tr.Goroutines {
+		for _, s := range g.Spans {
+			ev, ok := tr.UnblockingEvent(s)
+			if !ok {
+				continue
+			}
+			ug, ok := tr.gsByID[tr.Event(ev).G]
+			if !ok {
+				continue
+			}
+			k := edgeKey{node(ug), node(g)}
+			e, ok := edges[k]
+			if !ok {
+				e = &WakeupEdge{}
+				edges[k] = e
+			}
+			e.Count++
+			e.Wait += s.Duration()
+		}
+	}
+
+	out := &WakeupGraph{
+		Nodes: make([]WakeupNode, 0, len(nodes)),
+		Edges: make([]WakeupEdge, 0, len(edges)),
+	}
+	for _, n := range nodes {
+		out.Nodes = append(out.Nodes, n)
+	}
+	sort.Slice(out.Nodes, func(i, j int) bool {
+		a, b := out.Nodes[i], out.Nodes[j]
+		if byFunction {
+			return a.Function.SeqID < b.Function.SeqID
+		}
+		return a.Goroutine.SeqID < b.Goroutine.SeqID
+	})
+
+	idx := make(map[any]int, len(out.Nodes))
+	for i, n := range out.Nodes {
+		if byFunction {
+			idx[n.Function] = i
+		} else {
+			idx[n.Goroutine] = i
+		}
+	}
+	for k, e := range edges {
+		e.From = idx[k.from]
+		e.To = idx[k.to]
+		out.Edges = append(out.Edges, *e)
+	}
+	sort.Slice(out.Edges, func(i, j int) bool {
+		a, b := out.Edges[i], out.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+
+	return out
+}
+
+// WriteDOT writes the graph in Graphviz's DOT language.
+func (wg *WakeupGraph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph wakeups {")
+	for i, n := range wg.Nodes {
+		fmt.Fprintf(bw, "\tn%d [label=%q];\n", i, n.String())
+	}
+	for _, e := range wg.Edges {
+		fmt.Fprintf(bw, "\tn%d -> n%d [label=%q, weight=%d];\n", e.From, e.To, fmt.Sprintf("%d× / %s", e.Count, e.Wait), e.Count)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// WriteJSON writes the graph as a JSON object with nodes and edges. Edges refer to nodes by their indices, and wait
+// times are in nanoseconds.
+func (wg *WakeupGraph) WriteJSON(w io.Writer) error {
+	type jsonNode struct {
+		Goroutine *uint64 `json:"goroutine,omitempty"`
+		Function  string  `json:"function"`
+	}
+	type jsonEdge struct {
+		From  int   `json:"from"`
+		To    int   `json:"to"`
+		Count int   `json:"count"`
+		Wait  int64 `json:"wait"`
+	}
+	var out struct {
+		Nodes []jsonNode `json:"nodes"`
+		Edges []jsonEdge `json:"edges"`
+	}
+	out.Nodes = make([]jsonNode, len(wg.Nodes))
+	for i, n := range wg.Nodes {
+		out.Nodes[i].Function = n.Function.Fn
+		if n.Goroutine != nil {
+			out.Nodes[i].Goroutine = &n.Goroutine.ID
+		}
+	}
+	out.Edges = make([]jsonEdge, len(wg.Edges))
+	for i, e := range wg.Edges {
+		out.Edges[i] = jsonEdge{e.From, e.To, e.Count, int64(e.Wait)}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(out)
+}
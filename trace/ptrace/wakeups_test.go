@@ -0,0 +1,138 @@
+package ptrace_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"honnef.co/go/gotraceui/trace/ptrace"
+)
+
+func TestWakeupGraphInvariants(t *testing.T) {
+	ptr := loadTrace(t, "stress_1_21_good")
+
+	totals := map[bool]int{}
+	for _, byFunction := range []bool{false, true} {
+		wg := ptr.WakeupGraph(byFunction)
+		if len(wg.Edges) == 0 {
+			t.Fatalf("byFunction=%t: graph has no edges", byFunction)
+		}
+
+		for i, e := range wg.Edges {
+			if e.From < 0 || e.From >= len(wg.Nodes) || e.To < 0 || e.To >= len(wg.Nodes) {
+				t.Fatalf("byFunction=%t: edge %d refers to invalid nodes", byFunction, i)
+			}
+			if i > 0 {
+				prev := wg.Edges[i-1]
+				if prev.From > e.From || (prev.From == e.From && prev.To >= e.To) {
+					t.Errorf("byFunction=%t: edges aren't sorted or contain duplicates", byFunction)
+				}
+			}
+			if e.Count <= 0 || e.Wait < 0 {
+				t.Errorf("byFunction=%t: edge %d has count %d and wait %s", byFunction, i, e.Count, e.Wait)
+			}
+			if !byFunction && e.From == e.To {
+				t.Errorf("byFunction=%t: goroutine %s unblocked itself", byFunction, wg.Nodes[e.From])
+			}
+			totals[byFunction] += e.Count
+		}
+		for _, n := range wg.Nodes {
+			if (n.Goroutine == nil) != byFunction {
+				t.Fatalf("byFunction=%t: unexpected node %s", byFunction, n)
+			}
+		}
+
+		var dot bytes.Buffer
+		if err := wg.WriteDOT(&dot); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(dot.String(), "digraph wakeups {\n") || !strings.HasSuffix(dot.String(), "}\n") {
+			t.Errorf("byFunction=%t: malformed DOT output", byFunction)
+		}
+		if got := strings.Count(dot.String(), " -> "); got != len(wg.Edges) {
+			t.Errorf("byFunction=%t: DOT output has %d edges, want %d", byFunction, got, len(wg.Edges))
+		}
+
+		var buf bytes.Buffer
+		if err := wg.WriteJSON(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var out struct {
+			Nodes []struct {
+				Goroutine *uint64
+				Function  string
+			}
+			Edges []struct {
+				From, To, Count int
+				Wait            int64
+			}
+		}
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Nodes) != len(wg.Nodes) || len(out.Edges) != len(wg.Edges) {
+			t.Fatalf("byFunction=%t: JSON output has %d nodes and %d edges, want %d and %d",
+				byFunction, len(out.Nodes), len(out.Edges), len(wg.Nodes), len(wg.Edges))
+		}
+		for i, e := range out.Edges {
+			want := wg.Edges[i]
+			if e.From != want.From || e.To != want.To || e.Count != want.Count || time.Duration(e.Wait) != want.Wait {
+				t.Errorf("byFunction=%t: JSON edge %d is %v, want %v", byFunction, i, e, want)
+			}
+		}
+	}
+	// Grouping by function merges edges but doesn't lose any wake-ups.
+	if totals[false] != totals[true] {
+		t.Errorf("got %d wake-ups between goroutines but %d between functions", totals[false], totals[true])
+	}
+}
+
+func TestWakeupGraphPingPong(t *testing.T) {
+	ptr := pingPongTrace(t)
+	wantEdges := []ptrace.WakeupEdge{
+		{From: 0, To: 1, Count: 1, Wait: 6},
+		{From: 1, To: 0, Count: 1, Wait: 4},
+	}
+	for _, tt := range []struct {
+		byFunction bool
+		nodes      []string
+		dot        string
+	}{
+		{false, []string{"g1: main.main", "g2: main.worker"}, `digraph wakeups {
+	n0 [label="g1: main.main"];
+	n1 [label="g2: main.worker"];
+	n0 -> n1 [label="1× / 6ns", weight=1];
+	n1 -> n0 [label="1× / 4ns", weight=1];
+}
+`},
+		{true, []string{"main.main", "main.worker"}, `digraph wakeups {
+	n0 [label="main.main"];
+	n1 [label="main.worker"];
+	n0 -> n1 [label="1× / 6ns", weight=1];
+	n1 -> n0 [label="1× / 4ns", weight=1];
+}
+`},
+	} {
+		wg := ptr.WakeupGraph(tt.byFunction)
+		var nodes []string
+		for _, n := range wg.Nodes {
+			nodes = append(nodes, n.String())
+		}
+		if !reflect.DeepEqual(nodes, tt.nodes) {
+			t.Errorf("byFunction=%t: got nodes %q, want %q", tt.byFunction, nodes, tt.nodes)
+		}
+		if !reflect.DeepEqual(wg.Edges, wantEdges) {
+			t.Errorf("byFunction=%t: got edges %v, want %v", tt.byFunction, wg.Edges, wantEdges)
+		}
+		var dot strings.Builder
+		if err := wg.WriteDOT(&dot); err != nil {
+			t.Fatal(err)
+		}
+		if dot.String() != tt.dot {
+			t.Errorf("byFunction=%t: got DOT output\n%s\nwant\n%s", tt.byFunction, dot.String(), tt.dot)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package trace
+
+// TimestampRepair describes how ParseWithOptions repaired the timestamps of a trace whose timestamps contradict the
+// order of its events. This happens on machines whose CPUs' clocks aren't synchronized, see
+// https://go.dev/issue/16755.
+//
+// The order of events is established without looking at timestamps, by the sequence of events on each processor and by
+// the goroutine state transitions described in order.go. Events whose timestamps are earlier than those of events that
+// must have happened before them are moved forward in time just far enough to happen at the same time as the latest of
+// those events. Events that aren't out of order keep their timestamps.
+type TimestampRepair struct {
+	// The number of events whose timestamps were changed.
+	Events int
+	// The sum and the maximum of the amounts by which timestamps were moved forward, in nanoseconds.
+	Total Timestamp
+	Max   Timestamp
+}
+
+// repairTimestamps moves the timestamps of events forward so that they respect the order of events, which must be
+// the order established by parseRest. The timestamps are still in ticks, and so is the returned repair.
+//
+// An event has to happen no earlier than the previous event on the same processor, the previous event of the same
+// goroutine, the previous event that changed the goroutine's state, such as the unblocking of the goroutine by another
+// goroutine, and, for events belonging to garbage collections and stopping the world, the previous such event.
+// Processing events in order and bumping each one to the latest of these gives the smallest possible adjustment.
+// Afterwards, sorting events by timestamp with a stable sort retains the order of all dependent events.
+func repairTimestamps(events *Events) TimestampRepair {
+	var res TimestampRepair
+	procs := map[int32]Timestamp{}
+	gs := map[uint64]Timestamp{}
+	var gc Timestamp
+	for i := 0; i < events.Len(); i++ {
+		ev := events.Ptr(i)
+		if ev.Type == EvCPUSample {
+			// CPU samples aren't ordered with respect to other events.
+			continue
+		}
+		g, _, _ := stateTransition(ev)
+		if g == unordered || g == garbage {
+			g = 0
+		}
+
+		var isGC bool
+		switch ev.Type {
+		case EvGCStart, EvGCDone, EvSTWStart, EvSTWDone:
+			isGC = true
+		}
+
+		lo := procs[ev.P]
+		if ev.G != 0 {
+			lo = max(lo, gs[ev.G])
+		}
+		if g != 0 {
+			lo = max(lo, gs[g])
+		}
+		if isGC {
+			lo = max(lo, gc)
+		}
+
+		if d := lo - ev.Ts; d > 0 {
+			ev.Ts = lo
+			res.Events++
+			res.Total += d
+			res.Max = max(res.Max, d)
+		}
+
+		procs[ev.P] = ev.Ts
+		if ev.G != 0 {
+			gs[ev.G] = ev.Ts
+		}
+		if g != 0 {
+			gs[g] = ev.Ts
+		}
+		if isGC {
+			gc = ev.Ts
+		}
+	}
+	return res
+}
@@ -0,0 +1,102 @@
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestRepairTimestamps(t *testing.T) {
+	data, err := os.ReadFile("./testdata/http_1_21_good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Parse(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	intact, err := ParseWithOptions(bytes.NewReader(data), nil, ParseOptions{RepairTimestamps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intact.TimestampRepair != (TimestampRepair{}) {
+		t.Errorf("got repairs %+v for intact trace", intact.TimestampRepair)
+	}
+
+	// Write the trace, but make the clock of processor 1 lag behind those of the other processors.
+	const skew = 1_000_000
+	var buf bytes.Buffer
+	tw, err := NewWriter(&buf, want.Version, &want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < want.Events.Len(); i++ {
+		if err := tw.WriteEvent(want.Events.Ptr(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := tw.batches[1]; !ok {
+		t.Fatal("trace has no events on processor 1")
+	}
+	for pid, b := range tw.batches {
+		if pid != 1 {
+			b.ts += skew
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Parse(bytes.NewReader(buf.Bytes()), nil); err != ErrTimeOrder {
+		t.Fatalf("got error %v for skewed trace, want %v", err, ErrTimeOrder)
+	}
+	got, err := ParseWithOptions(bytes.NewReader(buf.Bytes()), nil, ParseOptions{RepairTimestamps: true})
+	if err != nil {
+		t.Fatalf("failed to parse skewed trace: %s", err)
+	}
+
+	repair := got.TimestampRepair
+	if repair.Events == 0 {
+		t.Fatal("no timestamps were repaired")
+	}
+	if repair.Max > skew {
+		t.Errorf("timestamps were moved by up to %d ns, but the clock was only off by %d ns", repair.Max, skew)
+	}
+	if repair.Total < repair.Max || repair.Total > skew*Timestamp(repair.Events) {
+		t.Errorf("implausible total of shifts %d for %d events", repair.Total, repair.Events)
+	}
+	for i := 1; i < got.Events.Len(); i++ {
+		if got.Events.Ptr(i).Ts < got.Events.Ptr(i-1).Ts {
+			t.Fatalf("events aren't sorted")
+		}
+	}
+
+	// The events of each processor must still be in the same order.
+	perP := func(tr *Trace) map[int32][]string {
+		out := map[int32][]string{}
+		for i := 0; i < tr.Events.Len(); i++ {
+			ev := tr.Events.Ptr(i)
+			out[ev.P] = append(out[ev.P], fmt.Sprintf("%s g=%d", EventDescriptions[ev.Type].Name, ev.G))
+		}
+		return out
+	}
+	if got.Events.Len() != want.Events.Len() {
+		t.Fatalf("got %d events, want %d", got.Events.Len(), want.Events.Len())
+	}
+	gotP, wantP := perP(&got), perP(&want)
+	for pid, wantEvs := range wantP {
+		gotEvs := gotP[pid]
+		if len(gotEvs) != len(wantEvs) {
+			t.Errorf("P %d: got %d events, want %d", pid, len(gotEvs), len(wantEvs))
+			continue
+		}
+		for i := range wantEvs {
+			if gotEvs[i] != wantEvs[i] {
+				t.Errorf("P %d: event %d: got %s, want %s", pid, i, gotEvs[i], wantEvs[i])
+				break
+			}
+		}
+	}
+}
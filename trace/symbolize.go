@@ -0,0 +1,201 @@
+package trace
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"debug/gosym"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"golang.org/x/exp/slices"
+)
+
+// Symbolize resolves the PCs of the trace's stacks using the symbol tables of the ELF binary of the traced program and
+// fills in the functions, files, and lines that are missing from their frames. This is useful for traces that were
+// produced by tools that don't symbolize stacks, or that lost the information along the way. Information that is
+// already present in the trace is retained.
+//
+// Files and lines are looked up in the binary's pclntab, which even stripped Go binaries contain. If the binary has
+// DWARF, functions are looked up in it instead, which attributes PCs of inlined calls to the inlined functions and not
+// to the functions they were inlined into. The Go runtime records one PC per logical frame, including inlined frames,
+// and these PCs point at the calls and not at the return addresses, so each PC resolves to exactly one frame.
+//
+// The PCs must be link-time addresses; PCs of position-independent executables that have been loaded at a different
+// address cannot be resolved. PCs that cannot be resolved, and DWARF that cannot be read, are reported in
+// Trace.Diagnostics and don't stop the remaining PCs from being resolved. An error is only returned if the binary
+// cannot be used at all.
+func (tr *Trace) Symbolize(r io.ReaderAt) error {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pclntab := f.Section(".gopclntab")
+	text := f.Section(".text")
+	if pclntab == nil || text == nil {
+		return errors.New("binary has no Go symbol table")
+	}
+	data, err := pclntab.Data()
+	if err != nil {
+		return fmt.Errorf("couldn't read Go symbol table: %w", err)
+	}
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(data, text.Addr))
+	if err != nil {
+		return fmt.Errorf("couldn't parse Go symbol table: %w", err)
+	}
+
+	// Collect the PCs whose frames are incomplete. Stacks share most of their PCs, so each PC is only collected once.
+	var pcs []uint64
+	seen := map[uint64]struct{}{}
+	for _, stk := range tr.Stacks {
+		for _, pc := range stk {
+			if pc == 0 {
+				continue
+			}
+			if _, ok := seen[pc]; ok {
+				continue
+			}
+			seen[pc] = struct{}{}
+			frame, ok := tr.PCs[pc]
+			if ok && frame.Fn != "" && frame.File != "" && frame.Line != 0 {
+				continue
+			}
+			if !ok {
+				if tr.PCs == nil {
+					tr.PCs = make(map[uint64]Frame)
+				}
+				tr.PCs[pc] = Frame{PC: pc}
+			}
+			pcs = append(pcs, pc)
+		}
+	}
+	if len(pcs) == 0 {
+		return nil
+	}
+
+	var fns map[uint64]string
+	if d, err := f.DWARF(); err == nil {
+		// Binaries built with -ldflags=-w don't have DWARF, which isn't an error. But if they do have it, then it should
+		// be valid.
+		fns, err = dwarfFunctions(d, pcs)
+		if err != nil {
+			// The symbol table still has functions, they just don't account for inlining.
+			tr.Diagnostics = append(tr.Diagnostics, Diagnostic{
+				Offset: -1,
+				Reason: fmt.Sprintf("couldn't read DWARF, inlined functions will be attributed to their callers: %s", err),
+			})
+			fns = nil
+		}
+	}
+
+	var unresolved []uint64
+	for _, pc := range pcs {
+		frame := tr.PCs[pc]
+		file, line, fn := table.PCToLine(pc)
+		if fn == nil {
+			unresolved = append(unresolved, pc)
+			continue
+		}
+		if frame.Fn == "" {
+			if name, ok := fns[pc]; ok {
+				frame.Fn = name
+			} else {
+				frame.Fn = fn.Name
+			}
+		}
+		if frame.File == "" {
+			frame.File = file
+		}
+		if frame.Line == 0 {
+			frame.Line = line
+		}
+		tr.PCs[pc] = frame
+	}
+	if len(unresolved) > 0 {
+		sort.Slice(unresolved, func(i, j int) bool { return unresolved[i] < unresolved[j] })
+		tr.Diagnostics = append(tr.Diagnostics, Diagnostic{
+			Offset: -1,
+			Reason: fmt.Sprintf("couldn't symbolize %d of %d PCs, starting with %#x; the binary may not match the trace",
+				len(unresolved), len(pcs), unresolved[0]),
+		})
+	}
+	return nil
+}
+
+// dwarfFunctions maps each of pcs to the innermost function that it belongs to, taking inlined calls into account.
+func dwarfFunctions(d *dwarf.Data, pcs []uint64) (map[uint64]string, error) {
+	lookups := slices.Clone(pcs)
+	sort.Slice(lookups, func(i, j int) bool { return lookups[i] < lookups[j] })
+
+	type function struct {
+		ranges [][2]uint64
+		// The function's name, or the offset of the entry that has its name, for inlined calls and concrete
+		// instances of abstract functions.
+		name   string
+		origin dwarf.Offset
+		depth  int
+	}
+	var fns []function
+	names := map[dwarf.Offset]string{}
+
+	dr := d.Reader()
+	depth := 0
+	for {
+		e, err := dr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			break
+		}
+		if e.Tag == 0 {
+			// End of the children of the current entry.
+			depth--
+			continue
+		}
+		if e.Tag == dwarf.TagSubprogram || e.Tag == dwarf.TagInlinedSubroutine {
+			name, _ := e.Val(dwarf.AttrName).(string)
+			if name != "" {
+				names[e.Offset] = name
+			}
+			ranges, err := d.Ranges(e)
+			if err != nil {
+				return nil, err
+			}
+			if len(ranges) > 0 {
+				origin, _ := e.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+				fns = append(fns, function{ranges: ranges, name: name, origin: origin, depth: depth})
+			}
+		}
+		if e.Children {
+			depth++
+		}
+	}
+
+	out := make(map[uint64]string, len(lookups))
+	best := make(map[uint64]int, len(lookups))
+	for _, fn := range fns {
+		name := fn.name
+		if name == "" {
+			name = names[fn.origin]
+			if name == "" {
+				continue
+			}
+		}
+		for _, rng := range fn.ranges {
+			i := sort.Search(len(lookups), func(i int) bool { return lookups[i] >= rng[0] })
+			for ; i < len(lookups) && lookups[i] < rng[1]; i++ {
+				pc := lookups[i]
+				if d, ok := best[pc]; ok && d >= fn.depth {
+					continue
+				}
+				best[pc] = fn.depth
+				out[pc] = name
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,132 @@
+package trace
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// symbolizeProgram prints the frames of a stack that contains an inlined call.
+const symbolizeProgram = `package main
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+)
+
+//go:noinline
+func callers() []runtime.Frame {
+	return inlined()
+}
+
+func inlined() []runtime.Frame {
+	return frames()
+}
+
+//go:noinline
+func frames() []runtime.Frame {
+	pcs := make([]uintptr, 16)
+	pcs = pcs[:runtime.Callers(1, pcs)]
+	var out []runtime.Frame
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		frame.Func = nil
+		out = append(out, frame)
+		if !more {
+			break
+		}
+	}
+	return out
+}
+
+func main() {
+	json.NewEncoder(os.Stdout).Encode(callers())
+}
+`
+
+func TestSymbolize(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Symbolize only supports ELF binaries")
+	}
+	gocmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not available")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(symbolizeProgram), 0666); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, "prog")
+	cmd := exec.Command(gocmd, "build", "-o", exe, "main.go")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GO111MODULE=off", "CGO_ENABLED=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("couldn't build program: %s\n%s", err, out)
+	}
+	out, err := exec.Command(exe).Output()
+	if err != nil {
+		t.Fatalf("couldn't run program: %s", err)
+	}
+	var want []runtime.Frame
+	if err := json.Unmarshal(out, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	// Build a trace whose frames have no symbols, from the program's stack.
+	tr := &Trace{
+		Stacks: map[uint32][]uint64{1: nil},
+		PCs:    map[uint64]Frame{},
+	}
+	for _, frame := range want {
+		pc := uint64(frame.PC)
+		tr.Stacks[1] = append(tr.Stacks[1], pc)
+		tr.PCs[pc] = Frame{PC: pc}
+	}
+	// Frames with symbols are left alone. The PC of the innermost frame belongs to runtime.Callers, which
+	// CallersFrames skipped, so it wouldn't resolve to main.frames anyway.
+	tr.PCs[uint64(want[0].PC)] = Frame{PC: uint64(want[0].PC), Fn: "fn", File: "file", Line: 1}
+	// A PC outside of the binary, shared by two stacks, is reported once and doesn't stop the other PCs from being
+	// symbolized.
+	const badPC = 0x10
+	tr.Stacks[1] = append(tr.Stacks[1], badPC)
+	tr.Stacks[2] = []uint64{badPC, uint64(want[1].PC)}
+
+	f, err := os.Open(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := tr.Symbolize(f); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.Diagnostics) != 1 || !strings.Contains(tr.Diagnostics[0].Reason, "1 of") {
+		t.Errorf("got diagnostics %v, want one for the unresolved PC", tr.Diagnostics)
+	}
+	if frame := tr.PC(badPC); frame.Fn != "" {
+		t.Errorf("symbolized PC outside of binary: %v", frame)
+	}
+	if frame := tr.PC(uint64(want[0].PC)); frame.Fn != "fn" || frame.File != "file" || frame.Line != 1 {
+		t.Errorf("symbolized frame that already had symbols: %v", frame)
+	}
+	var sawInlined bool
+	for _, w := range want[1:] {
+		got := tr.PC(uint64(w.PC))
+		if got.Fn != w.Function || got.File != w.File || got.Line != w.Line {
+			t.Errorf("got %s %s:%d for PC %#x, want %s %s:%d", got.Fn, got.File, got.Line, w.PC, w.Function, w.File, w.Line)
+		}
+		if w.Function == "main.inlined" {
+			sawInlined = true
+		}
+	}
+	if !sawInlined {
+		t.Errorf("stack doesn't contain inlined frame")
+	}
+}
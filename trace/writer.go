@@ -0,0 +1,393 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"golang.org/x/exp/slices"
+)
+
+// A Resolver resolves the stacks, strings and PCs that events refer to. Both Trace and Parser implement Resolver.
+type Resolver interface {
+	Stack(id uint32) []uint64
+	String(id uint64) string
+	PC(pc uint64) Frame
+}
+
+// A Writer encodes events in the runtime's binary trace format.
+//
+// Events are written in the format of the trace's version: the legacy format for Go 1.21 and older, and the
+// generation-based format for Go 1.22 and newer. Sequence numbers are renumbered, and the thread IDs of goroutines that
+// were already in syscalls when tracing started are lost. The generation-based format only allows some events, such as
+// stop-the-world pauses and the creation of tasks, to happen on goroutines. Such events that don't belong to a
+// goroutine, as synthesized by Crop, are delayed until a goroutine is running, and pauses that end before then are
+// dropped.
+type Writer struct {
+	w   *bufio.Writer
+	r   Resolver
+	err error
+
+	version int
+	// The encoder for the generation-based format, or nil.
+	v2 *v2Writer
+
+	// Buffered batches, per processor.
+	batches map[int32]*writerBatch
+
+	// The goroutine state expected by the parser's ordering of events, used to compute sequence numbers.
+	gs map[uint64]gState
+	// The processor that is running each goroutine, for attributing events to processors.
+	gProcs map[uint64]int32
+
+	strings map[string]uint64
+	stacks  map[uint32]struct{}
+	lastTs  Timestamp
+	buf     []byte
+	args    []uint64
+}
+
+type writerBatch struct {
+	data   []byte
+	ts     Timestamp
+	lastTs Timestamp
+	// The goroutine that the parser will attribute events to.
+	lastG uint64
+}
+
+// maxWriterBatchSize is the size at which we flush batches. The runtime uses similarly sized buffers.
+const maxWriterBatchSize = 64 << 10
+
+// NewWriter returns a writer that writes events from a trace of the given version to w. The trace's header is written
+// immediately.
+func NewWriter(w io.Writer, version int, r Resolver) (*Writer, error) {
+	switch version {
+	case 1011, 1019, 1021, 1022, 1023, 1025, 1026:
+	default:
+		return nil, fmt.Errorf("unsupported trace file version %d.%d", version/1000, version%1000)
+	}
+
+	tw := &Writer{
+		w:       bufio.NewWriter(w),
+		r:       r,
+		version: version,
+		batches: make(map[int32]*writerBatch),
+		gs:      make(map[uint64]gState),
+		gProcs:  make(map[uint64]int32),
+		strings: make(map[string]uint64),
+		stacks:  make(map[uint32]struct{}),
+	}
+	if version >= 1022 {
+		tw.v2 = newV2Writer(tw.w, version, r)
+	}
+	hdr := fmt.Sprintf("go %d.%d trace", version/1000, version%1000)
+	hdr += string(make([]byte, headerLength-len(hdr)))
+	if _, err := tw.w.WriteString(hdr); err != nil {
+		return nil, err
+	}
+	return tw, nil
+}
+
+// Write writes all of tr's events to w.
+func Write(w io.Writer, tr *Trace) error {
+	tw, err := NewWriter(w, tr.Version, tr)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < tr.Events.Len(); i++ {
+		if err := tw.WriteEvent(tr.Events.Ptr(i)); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// WriteEvent writes an event. Events have to be written in order, either as returned by Parser.Next or as found in
+// Trace.Events.
+func (w *Writer) WriteEvent(ev *Event) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.writeEvent(ev)
+	return w.err
+}
+
+// Close writes the remaining batches, the stacks and strings referred to by events and the frequency. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.close()
+	if w.err == nil {
+		w.err = errors.New("writer is closed")
+		return nil
+	}
+	return w.err
+}
+
+func (w *Writer) writeEvent(ev *Event) error {
+	desc := &EventDescriptions[ev.Type]
+	if ev.Type == EvNone || desc.Name == "" || ev.Type == EvBatch || ev.Type == EvFrequency || ev.Type == EvStack ||
+		ev.Type == EvString || ev.Type == EvTimerGoroutine {
+		return fmt.Errorf("can't write event of type %d", ev.Type)
+	}
+	if w.v2 != nil {
+		return w.v2.writeEvent(ev)
+	}
+	if desc.minVersion > w.version {
+		return fmt.Errorf("can't write %s event in version %d.%d trace", desc.Name, w.version/1000, w.version%1000)
+	}
+
+	if ev.Type == EvCPUSample {
+		// CPU samples aren't subject to ordering and carry their own timestamps and processors. We write them to
+		// separate batches so that they don't affect the timestamps of other events.
+		b := w.batch(ProfileP, ev.Ts)
+		args := append(w.args[:0], 0, uint64(ev.Ts), uint64(int64(ev.P)), ev.G, uint64(ev.StkID))
+		w.args = args
+		w.stacks[ev.StkID] = struct{}{}
+		b.data = appendEvent(b.data, ev.Type, args)
+		return w.flush(ProfileP)
+	}
+
+	if ev.Ts < w.lastTs {
+		return ErrTimeOrder
+	}
+	w.lastTs = ev.Ts
+
+	// Compute the sequence numbers that the parser needs to order events.
+	args := ev.Args
+	switch ev.Type {
+	case EvGoStart, EvGoStartLabel:
+		args[1] = w.gs[ev.G].seq
+	case EvGoUnblock:
+		args[1] = w.gs[args[0]].seq
+	case EvGoSysExit:
+		// We don't know when the syscall returned, as opposed to when the event was emitted, and use the event's
+		// timestamp for both.
+		args[1] = w.gs[args[0]].seq
+		args[2] = 0
+	case EvGCStart:
+		args[0] = w.gs[garbage].seq
+	}
+	tev := *ev
+	tev.Args = args
+	g, init, next := stateTransition(&tev)
+	if !transitionReady(g, w.gs[g], init) {
+		return fmt.Errorf("can't encode %s event at time %d: impossible goroutine state transition", desc.Name, ev.Ts)
+	}
+	if err := transition(w.gs, g, init, next); err != nil {
+		return err
+	}
+
+	pid := w.proc(ev)
+	b := w.batch(pid, ev.Ts)
+
+	vals := append(w.args[:0], uint64(ev.Ts-b.lastTs))
+	b.lastTs = ev.Ts
+	for i := range desc.Args {
+		v := args[i]
+		switch {
+		case ev.Type == EvGoStartLabel && i == ArgGoStartLabelLabelID,
+			ev.Type == EvUserTaskCreate && i == ArgUserTaskCreateTypeID,
+			ev.Type == EvUserRegion && i == ArgUserRegionTypeID,
+			ev.Type == EvUserLog && i == ArgUserLogKeyID:
+			v = w.str(b, w.r.String(v))
+		}
+		vals = append(vals, v)
+	}
+	if desc.Stack {
+		vals = append(vals, uint64(ev.StkID))
+		w.stacks[ev.StkID] = struct{}{}
+	}
+	if ev.Type == EvGoCreate {
+		w.stacks[uint32(args[ArgGoCreateStack])] = struct{}{}
+	}
+	w.args = vals
+
+	if ev.Type == EvUserLog {
+		// User log events are special in that they always use the length-prefixed encoding, and carry their message
+		// inline.
+		b.data = appendEvent(b.data, ev.Type, vals)
+		msg := w.r.String(args[ArgUserLogMessage])
+		b.data = binary.AppendUvarint(b.data, uint64(len(msg)))
+		b.data = append(b.data, msg...)
+	} else {
+		b.data = appendEvent(b.data, ev.Type, vals)
+	}
+
+	// Track the goroutine that the parser will attribute the P's events to.
+	switch ev.Type {
+	case EvGoStart, EvGoStartLabel:
+		b.lastG = ev.G
+		w.gProcs[ev.G] = pid
+	case EvGoEnd, EvGoStop, EvGoSched, EvGoPreempt,
+		EvGoSleep, EvGoBlock, EvGoBlockSend, EvGoBlockRecv,
+		EvGoBlockSelect, EvGoBlockSync, EvGoBlockCond, EvGoBlockNet,
+		EvGoSysBlock, EvGoBlockGC:
+		delete(w.gProcs, b.lastG)
+		b.lastG = 0
+	}
+
+	return w.flush(pid)
+}
+
+// proc returns the processor whose batch an event has to be written to. Post-processing moves some events to fake
+// processors, which don't exist in the file format.
+func (w *Writer) proc(ev *Event) int32 {
+	if ev.P >= 0 && ev.P < FakeP {
+		return ev.P
+	}
+	switch ev.Type {
+	case EvGoSysExit, EvGoWaiting, EvGoInSyscall:
+		// These events explicitly specify their goroutine.
+		return -1
+	}
+	if ev.G != 0 {
+		if pid, ok := w.gProcs[ev.G]; ok {
+			return pid
+		}
+	}
+	return -1
+}
+
+func (w *Writer) batch(pid int32, ts Timestamp) *writerBatch {
+	b, ok := w.batches[pid]
+	if !ok {
+		b = &writerBatch{}
+		w.batches[pid] = b
+	}
+	if len(b.data) == 0 {
+		b.ts = ts
+		b.lastTs = ts
+	}
+	return b
+}
+
+// flush writes the batch of processor pid if it's grown large enough.
+func (w *Writer) flush(pid int32) error {
+	b := w.batches[pid]
+	if len(b.data) < maxWriterBatchSize {
+		return nil
+	}
+	return w.writeBatch(pid, b)
+}
+
+func (w *Writer) writeBatch(pid int32, b *writerBatch) error {
+	if len(b.data) == 0 {
+		return nil
+	}
+	p := uint64(pid)
+	if pid < 0 || pid >= FakeP {
+		p = math.MaxUint64
+	}
+	w.buf = appendEvent(w.buf[:0], EvBatch, []uint64{p, uint64(b.ts)})
+	if _, err := w.w.Write(w.buf); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(b.data); err != nil {
+		return err
+	}
+	b.data = b.data[:0]
+	return nil
+}
+
+// str returns the ID of a string, adding the string to batch b if necessary. The parser only looks for strings
+// inside of batches.
+func (w *Writer) str(b *writerBatch, s string) uint64 {
+	if s == "" {
+		return 0
+	}
+	if id, ok := w.strings[s]; ok {
+		return id
+	}
+	id := uint64(len(w.strings) + 1)
+	w.strings[s] = id
+
+	b.data = append(b.data, EvString)
+	b.data = binary.AppendUvarint(b.data, id)
+	b.data = binary.AppendUvarint(b.data, uint64(len(s)))
+	b.data = append(b.data, s...)
+	return id
+}
+
+func (w *Writer) close() error {
+	if w.v2 != nil {
+		return w.v2.close()
+	}
+	pids := make([]int32, 0, len(w.batches))
+	for pid := range w.batches {
+		pids = append(pids, pid)
+	}
+	slices.Sort(pids)
+	for _, pid := range pids {
+		if err := w.writeBatch(pid, w.batches[pid]); err != nil {
+			return err
+		}
+	}
+
+	// The parser expects stacks and the frequency to be part of a batch.
+	b := &writerBatch{ts: w.lastTs}
+	b.data = appendEvent(b.data, EvFrequency, []uint64{1e9})
+	ids := make([]uint32, 0, len(w.stacks))
+	for id := range w.stacks {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var vals []uint64
+	for _, id := range ids {
+		pcs := w.r.Stack(id)
+		if id == 0 || len(pcs) == 0 {
+			continue
+		}
+		for {
+			vals = append(vals[:0], uint64(id), uint64(len(pcs)))
+			for _, pc := range pcs {
+				frame := w.r.PC(pc)
+				vals = append(vals, pc, w.str(b, frame.Fn), w.str(b, frame.File), uint64(frame.Line))
+			}
+			// The parser limits the size of length-prefixed events. We'd rather lose the outermost frames of
+			// extremely deep stacks than fail.
+			if encodedSize(vals) <= 2048 {
+				break
+			}
+			pcs = pcs[:len(pcs)-1]
+		}
+		b.data = appendEvent(b.data, EvStack, vals)
+	}
+	if err := w.writeBatch(-1, b); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+// appendEvent appends the encoding of an event, consisting of its type and its arguments, to buf.
+func appendEvent(buf []byte, typ byte, args []uint64) []byte {
+	// The number of arguments is encoded using two bits. The value 3 indicates that arguments are prefixed by their
+	// byte length. EvUserLog always uses the prefix, to make room for its message.
+	if len(args) <= 3 && typ != EvUserLog {
+		buf = append(buf, typ|byte(len(args)-1)<<6)
+		for _, v := range args {
+			buf = binary.AppendUvarint(buf, v)
+		}
+		return buf
+	}
+	buf = append(buf, typ|3<<6)
+	buf = binary.AppendUvarint(buf, uint64(encodedSize(args)))
+	for _, v := range args {
+		buf = binary.AppendUvarint(buf, v)
+	}
+	return buf
+}
+
+func encodedSize(args []uint64) int {
+	var tmp [binary.MaxVarintLen64]byte
+	n := 0
+	for _, v := range args {
+		n += binary.PutUvarint(tmp[:], v)
+	}
+	return n
+}
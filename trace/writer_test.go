@@ -0,0 +1,103 @@
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// comparableEvents turns events into strings that can be compared across traces. Sequence numbers and the IDs of
+// strings and stacks aren't preserved by Writer and get replaced by what they refer to.
+func comparableEvents(tr *Trace) []string {
+	out := make([]string, tr.Events.Len())
+	for i := range out {
+		ev := tr.Events.Get(i)
+		desc := &EventDescriptions[ev.Type]
+		args := ev.Args
+		var extra []string
+		switch ev.Type {
+		case EvGoStart, EvGoStartLabel, EvGoUnblock:
+			args[1] = 0
+		case EvGoSysExit:
+			args[1], args[2] = 0, 0
+		case EvGCStart:
+			args[0] = 0
+		case EvSTWStart:
+			extra = append(extra, tr.STWReason(args[ArgSTWStartKind]).String())
+			args[ArgSTWStartKind] = 0
+		}
+		switch ev.Type {
+		case EvGoStartLabel:
+			extra = append(extra, tr.Strings[args[ArgGoStartLabelLabelID]])
+			args[ArgGoStartLabelLabelID] = 0
+		case EvUserTaskCreate, EvUserRegion:
+			extra = append(extra, tr.Strings[args[2]])
+			args[2] = 0
+		case EvUserLog:
+			extra = append(extra, tr.Strings[args[ArgUserLogKeyID]], tr.Strings[args[ArgUserLogMessage]])
+			args[ArgUserLogKeyID], args[ArgUserLogMessage] = 0, 0
+		case EvGoCreate:
+			extra = append(extra, fmt.Sprint(tr.Stacks[uint32(args[ArgGoCreateStack])]))
+			args[ArgGoCreateStack] = 0
+		}
+		var stk []uint64
+		if desc.Stack || ev.Type == EvGoStart || ev.Type == EvGoStartLabel {
+			stk = tr.Stacks[ev.StkID]
+		}
+		out[i] = fmt.Sprintf("%d %s p=%d g=%d args=%v stk=%v %q", ev.Ts, desc.Name, ev.P, ev.G, args, stk, extra)
+	}
+	// Events with identical timestamps may be ordered differently.
+	sort.Strings(out)
+	return out
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	files, err := os.ReadDir("./testdata")
+	if err != nil {
+		t.Fatalf("failed to read ./testdata: %v", err)
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), "_good") {
+			continue
+		}
+		t.Run(f.Name(), func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("./testdata", f.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := Parse(bytes.NewReader(data), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := Write(&buf, &want); err != nil {
+				t.Fatalf("failed to write trace: %s", err)
+			}
+			got, err := Parse(&buf, nil)
+			if err != nil {
+				t.Fatalf("failed to parse written trace: %s", err)
+			}
+			if got.Version != want.Version {
+				t.Fatalf("got version %d, want %d", got.Version, want.Version)
+			}
+			if got.Events.Len() != want.Events.Len() {
+				t.Fatalf("got %d events, want %d", got.Events.Len(), want.Events.Len())
+			}
+			gotEvs, wantEvs := comparableEvents(&got), comparableEvents(&want)
+			n := 0
+			for i := range wantEvs {
+				if gotEvs[i] != wantEvs[i] {
+					t.Errorf("event %d: got %s, want %s", i, gotEvs[i], wantEvs[i])
+					n++
+					if n > 5 {
+						return
+					}
+				}
+			}
+		})
+	}
+}
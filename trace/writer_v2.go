@@ -0,0 +1,806 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"golang.org/x/exp/slices"
+)
+
+// v2Writer encodes events in the generation-based format. The parser translates that format to our events by
+// emulating the scheduler, and v2Writer runs the same emulation in reverse: it assigns events to Ms, tracks the state of
+// goroutines and processors, and picks the events and sequence numbers that will make the parser produce the events we
+// were given.
+type v2Writer struct {
+	w       *bufio.Writer
+	r       Resolver
+	version int
+	gen     uint64
+
+	gs map[uint64]*v2WriterG
+	ps map[int32]*v2WriterP
+	ms map[uint64]*v2WriterM
+	// The M we use for events that don't happen on an M that holds a processor or goroutine.
+	idle      *v2WriterM
+	nextFakeM uint64
+
+	gcSeq     uint64
+	gcRunning bool
+
+	// A goroutine creation that we haven't written yet, because it depends on the next event.
+	create *Event
+	// The goroutine whose syscall blocked, waiting for its processor to be stopped.
+	blocked uint64
+	// Events that require a goroutine, but that happened while no goroutine was running.
+	delayed []Event
+
+	strings map[string]uint64
+	stacks  map[uint32]struct{}
+	samples []byte
+	lastTs  Timestamp
+	buf     []byte
+}
+
+type v2WriterG struct {
+	status uint8
+	seq    uint64
+	// The M the goroutine is running on or is in a syscall on.
+	m *v2WriterM
+	// Whether the parser considers the goroutine's syscall to have blocked.
+	sysBlocked bool
+	// Whether the goroutine was created by a status event without a stack. The parser fills in the start function from
+	// the goroutine's first stack in the same generation, which we have to prevent when the event we're encoding
+	// doesn't have a start function.
+	noStack bool
+}
+
+type v2WriterP struct {
+	status uint8
+	seq    uint64
+	m      *v2WriterM
+}
+
+type v2WriterM struct {
+	id uint64
+	g  uint64
+	p  int32
+
+	data   []byte
+	ts     uint64
+	lastTs uint64
+}
+
+// v2WriterFakeM is the first M ID we use for Ms that don't exist in the events. It's large enough not to collide with
+// thread IDs.
+const v2WriterFakeM = 1 << 62
+
+func newV2Writer(w *bufio.Writer, version int, r Resolver) *v2Writer {
+	v2 := &v2Writer{
+		w:         w,
+		r:         r,
+		version:   version,
+		gen:       1,
+		gs:        make(map[uint64]*v2WriterG),
+		ps:        make(map[int32]*v2WriterP),
+		ms:        make(map[uint64]*v2WriterM),
+		nextFakeM: v2WriterFakeM,
+		strings:   make(map[string]uint64),
+		stacks:    make(map[uint32]struct{}),
+	}
+	v2.idle = v2.fakeM()
+	return v2
+}
+
+func (w *v2Writer) m(mid uint64) *v2WriterM {
+	m, ok := w.ms[mid]
+	if !ok {
+		m = &v2WriterM{id: mid, p: -1}
+		w.ms[mid] = m
+	}
+	return m
+}
+
+func (w *v2Writer) fakeM() *v2WriterM {
+	m := w.m(w.nextFakeM)
+	w.nextFakeM++
+	return m
+}
+
+// emit appends an event to the current batch of M m.
+func (w *v2Writer) emit(m *v2WriterM, typ byte, ts Timestamp, args ...uint64) error {
+	if len(args) != int(v2EventArgs[typ])-1 {
+		panic(fmt.Sprintf("event %d has %d arguments, expected %d", typ, len(args), v2EventArgs[typ]-1))
+	}
+	if len(m.data) == 0 {
+		m.ts = uint64(ts)
+		m.lastTs = uint64(ts)
+	}
+	m.data = append(m.data, typ)
+	m.data = binary.AppendUvarint(m.data, uint64(ts)-m.lastTs)
+	m.lastTs = uint64(ts)
+	for _, v := range args {
+		m.data = binary.AppendUvarint(m.data, v)
+	}
+	// Leave enough room for another event.
+	if len(m.data) >= maxBatchSize-64 {
+		return w.writeMBatch(m)
+	}
+	return nil
+}
+
+func (w *v2Writer) writeMBatch(m *v2WriterM) error {
+	if len(m.data) == 0 {
+		return nil
+	}
+	err := w.writeBatch(m.id, m.ts, m.data)
+	m.data = m.data[:0]
+	return err
+}
+
+func (w *v2Writer) writeBatch(mid, ts uint64, data []byte) error {
+	w.buf = append(w.buf[:0], ev2EventBatch)
+	for _, v := range []uint64{w.gen, mid, ts, uint64(len(data))} {
+		w.buf = binary.AppendUvarint(w.buf, v)
+	}
+	if _, err := w.w.Write(w.buf); err != nil {
+		return err
+	}
+	_, err := w.w.Write(data)
+	return err
+}
+
+// str returns the ID of a string in the current generation.
+func (w *v2Writer) str(s string) uint64 {
+	if s == "" {
+		return 0
+	}
+	// The parser limits the length of strings. We'd rather truncate extremely long function names than fail.
+	if len(s) > maxEventTrailerDataSize {
+		s = s[:maxEventTrailerDataSize]
+	}
+	if id, ok := w.strings[s]; ok {
+		return id
+	}
+	id := uint64(len(w.strings) + 1)
+	w.strings[s] = id
+	return id
+}
+
+// stk returns the ID of a stack in the current generation, which is the same as its global ID.
+func (w *v2Writer) stk(id uint32) uint64 {
+	if id != 0 {
+		w.stacks[id] = struct{}{}
+	}
+	return uint64(id)
+}
+
+// proc returns the state of processor pid, emitting a status event the first time we see it.
+func (w *v2Writer) proc(ts Timestamp, pid int32) (*v2WriterP, error) {
+	if p, ok := w.ps[pid]; ok {
+		return p, nil
+	}
+	p := &v2WriterP{status: procIdle}
+	w.ps[pid] = p
+	return p, w.emit(w.idle, ev2ProcStatus, ts, uint64(pid), procIdle)
+}
+
+// holder returns the M holding processor pid, or nil.
+func (w *v2Writer) holder(pid int32) *v2WriterM {
+	if p, ok := w.ps[pid]; ok {
+		return p.m
+	}
+	return nil
+}
+
+// mOf returns the M for events that don't require a processor or goroutine.
+func (w *v2Writer) mOf(ev *Event) *v2WriterM {
+	if g, ok := w.gs[ev.G]; ok && g.m != nil {
+		return g.m
+	}
+	if ev.P >= 0 && ev.P < FakeP {
+		if m := w.holder(ev.P); m != nil {
+			return m
+		}
+	}
+	return w.idle
+}
+
+// mWithG returns the M for events that require a goroutine. Events that don't belong to a goroutine, such as the ones
+// synthesized by Crop, get attributed to an arbitrary goroutine. It returns nil if no M has a goroutine.
+func (w *v2Writer) mWithG(ev *Event) *v2WriterM {
+	if g, ok := w.gs[ev.G]; ok && g.m != nil {
+		return g.m
+	}
+	var out *v2WriterM
+	for _, m := range w.ms {
+		if m.g != 0 && (out == nil || m.id < out.id) {
+			out = m
+		}
+	}
+	return out
+}
+
+// running returns the goroutine of an event that requires a running goroutine.
+func (w *v2Writer) running(ev *Event) (*v2WriterG, error) {
+	g, ok := w.gs[ev.G]
+	if !ok || g.status != goRunning || g.m == nil || g.m.p == -1 {
+		return nil, w.impossible(ev)
+	}
+	return g, nil
+}
+
+func (w *v2Writer) impossible(ev *Event) error {
+	return fmt.Errorf("can't encode %s event at time %d: impossible goroutine state transition", EventDescriptions[ev.Type].Name, ev.Ts)
+}
+
+func (w *v2Writer) writeEvent(ev *Event) error {
+	if err := w.encode(ev); err != nil {
+		return err
+	}
+	if len(w.delayed) == 0 || w.create != nil || w.blocked != 0 || w.mWithG(&w.delayed[0]) == nil {
+		return nil
+	}
+	// A goroutine is running now; write the events that had to wait for one.
+	evs := w.delayed
+	w.delayed = nil
+	for i := range evs {
+		evs[i].Ts = w.lastTs
+		if err := w.encode(&evs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delay delays an event that requires a goroutine until one is running.
+func (w *v2Writer) delay(ev *Event) {
+	w.delayed = append(w.delayed, *ev)
+}
+
+func (w *v2Writer) encode(ev *Event) error {
+	if ev.Type == EvCPUSample {
+		// CPU samples carry their own timestamps and are written to a separate batch.
+		if len(w.samples) == 0 {
+			w.samples = append(w.samples, ev2CPUSamples)
+		}
+		pid := uint64(math.MaxUint64)
+		if ev.P >= 0 && ev.P < FakeP {
+			pid = uint64(ev.P)
+		}
+		w.samples = append(w.samples, ev2CPUSample)
+		for _, v := range []uint64{uint64(ev.Ts), 0, pid, ev.G, w.stk(ev.StkID)} {
+			w.samples = binary.AppendUvarint(w.samples, v)
+		}
+		if len(w.samples) >= maxBatchSize-64 {
+			err := w.writeBatch(0, 0, w.samples)
+			w.samples = w.samples[:0]
+			return err
+		}
+		return nil
+	}
+
+	if ev.Ts < w.lastTs {
+		return ErrTimeOrder
+	}
+	w.lastTs = ev.Ts
+	ts := ev.Ts
+
+	if w.blocked != 0 && (ev.Type != EvProcStop || w.holder(ev.P) != w.gs[w.blocked].m) {
+		// The parser only emits EvGoSysBlock right before the processor stops.
+		return w.impossible(ev)
+	}
+	if w.create != nil {
+		if done, err := w.writeCreate(ev); err != nil || done {
+			return err
+		}
+	}
+	if g, ok := w.gs[ev.G]; ok {
+		if g.noStack && ev.StkID != 0 {
+			// Start a new generation so that the parser doesn't use this stack for the goroutine's start function.
+			if err := w.nextGeneration(ts); err != nil {
+				return err
+			}
+		}
+		if g.status == goSyscall && !g.sysBlocked && w.blocked == 0 && ev.Type != EvGoSysBlock && ev.Type != EvGoSysExit {
+			// The goroutine returned from a syscall that didn't block, which older traces don't have an event for.
+			if err := w.syscallEnd(ts, g); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch ev.Type {
+	case EvGoCreate:
+		if _, ok := w.gs[ev.Args[0]]; ok {
+			return w.impossible(ev)
+		}
+		// Whether we write a status event or a creation event depends on the next event.
+		evCopy := *ev
+		w.create = &evCopy
+
+	case EvProcStart:
+		p, err := w.proc(ts, ev.P)
+		if err != nil {
+			return err
+		}
+		m := w.m(ev.Args[0])
+		if p.status != procIdle || m.p != -1 {
+			return w.impossible(ev)
+		}
+		p.seq++
+		p.status = procRunning
+		p.m = m
+		m.p = ev.P
+		return w.emit(m, ev2ProcStart, ts, uint64(ev.P), p.seq)
+
+	case EvProcStop:
+		m := w.holder(ev.P)
+		if m == nil {
+			return w.impossible(ev)
+		}
+		if g, ok := w.gs[m.g]; ok && g.status == goSyscall && !g.sysBlocked && w.blocked == 0 {
+			if err := w.syscallEnd(ts, g); err != nil {
+				return err
+			}
+		}
+		if w.blocked != 0 {
+			// Stopping the processor makes the parser emit EvGoSysBlock.
+			w.gs[w.blocked].sysBlocked = true
+			w.blocked = 0
+		}
+		p := w.ps[ev.P]
+		p.status = procIdle
+		p.m = nil
+		m.p = -1
+		return w.emit(m, ev2ProcStop, ts)
+
+	case EvGoStart, EvGoStartLabel:
+		g, ok := w.gs[ev.G]
+		m := w.holder(ev.P)
+		if !ok || g.status != goRunnable || m == nil || m.g != 0 {
+			return w.impossible(ev)
+		}
+		g.seq++
+		g.status = goRunning
+		g.m = m
+		m.g = ev.G
+		if err := w.emit(m, ev2GoStart, ts, ev.G, g.seq); err != nil {
+			return err
+		}
+		if ev.Type == EvGoStartLabel {
+			return w.emit(m, ev2GoLabel, ts, w.str(w.r.String(ev.Args[ArgGoStartLabelLabelID])))
+		}
+
+	case EvGoEnd:
+		g, err := w.running(ev)
+		if err != nil {
+			return err
+		}
+		m := g.m
+		delete(w.gs, ev.G)
+		m.g = 0
+		return w.emit(m, ev2GoDestroy, ts)
+
+	case EvGoSched, EvGoPreempt:
+		g, err := w.running(ev)
+		if err != nil {
+			return err
+		}
+		reason := "yield"
+		if ev.Type == EvGoPreempt {
+			reason = "preempted"
+		}
+		m := g.m
+		g.status = goRunnable
+		g.m = nil
+		m.g = 0
+		return w.emit(m, ev2GoStop, ts, w.str(reason), w.stk(ev.StkID))
+
+	case EvGoStop, EvGoSleep, EvGoBlock, EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect, EvGoBlockSync, EvGoBlockCond,
+		EvGoBlockNet, EvGoBlockGC:
+		g, err := w.running(ev)
+		if err != nil {
+			return err
+		}
+		m := g.m
+		g.status = goWaiting
+		g.m = nil
+		m.g = 0
+		return w.emit(m, ev2GoBlock, ts, w.str(v2BlockReasons[ev.Type]), w.stk(ev.StkID))
+
+	case EvGoUnblock:
+		g, ok := w.gs[ev.Args[0]]
+		if !ok || g.status != goWaiting {
+			return w.impossible(ev)
+		}
+		// The parser attributes the event to the M's goroutine if it is running.
+		m := w.idle
+		if cur, ok := w.gs[ev.G]; ok && cur.status == goRunning && cur.m != nil {
+			m = cur.m
+		}
+		g.seq++
+		g.status = goRunnable
+		return w.emit(m, ev2GoUnblock, ts, ev.Args[0], g.seq, w.stk(ev.StkID))
+
+	case EvGoSysCall:
+		g, err := w.running(ev)
+		if err != nil {
+			return err
+		}
+		p := w.ps[g.m.p]
+		p.seq++
+		p.status = procSyscall
+		g.status = goSyscall
+		return w.emit(g.m, ev2GoSyscallBegin, ts, p.seq, w.stk(ev.StkID))
+
+	case EvGoSysBlock:
+		g, ok := w.gs[ev.G]
+		if !ok || g.status != goSyscall || g.sysBlocked || g.m == nil {
+			return w.impossible(ev)
+		}
+		if g.m.p != -1 {
+			// The parser emits EvGoSysBlock when the processor stops, which has to be the next event.
+			w.blocked = ev.G
+			break
+		}
+		// Without a processor, ending the goroutine in the syscall is the only way to make the parser emit
+		// EvGoSysBlock. Starting the next cgo callback on the same thread brings it back.
+		g.sysBlocked = true
+		if err := w.emit(g.m, ev2GoDestroySyscall, ts); err != nil {
+			return err
+		}
+		return w.emit(g.m, ev2GoCreateSyscall, ts, ev.G)
+
+	case EvGoSysExit:
+		g, ok := w.gs[ev.Args[0]]
+		if !ok || g.status != goSyscall || !g.sysBlocked || g.m == nil {
+			return w.impossible(ev)
+		}
+		m := g.m
+		if m.p != -1 && w.ps[m.p].status == procSyscall {
+			return w.impossible(ev)
+		}
+		g.status = goRunnable
+		g.sysBlocked = false
+		g.m = nil
+		m.g = 0
+		return w.emit(m, ev2GoSyscallEndBlocked, ts)
+
+	case EvSTWStart:
+		m := w.mWithG(ev)
+		if m == nil {
+			w.delay(ev)
+			break
+		}
+		return w.emit(m, ev2STWBegin, ts, w.str(w.r.String(ev.Args[ArgSTWStartKind])), w.stk(ev.StkID))
+
+	case EvSTWDone:
+		for i, dev := range w.delayed {
+			if dev.Type == EvSTWStart {
+				// The world started again before any goroutine ran. We lose the pause.
+				w.delayed = slices.Delete(w.delayed, i, i+1)
+				return nil
+			}
+		}
+		return w.emit(w.mOf(ev), ev2STWEnd, ts)
+
+	case EvGCStart:
+		if w.gcRunning {
+			return w.impossible(ev)
+		}
+		w.gcSeq++
+		w.gcRunning = true
+		return w.emit(w.mOf(ev), ev2GCBegin, ts, w.gcSeq, w.stk(ev.StkID))
+
+	case EvGCDone:
+		if !w.gcRunning {
+			return w.impossible(ev)
+		}
+		w.gcSeq++
+		w.gcRunning = false
+		return w.emit(w.mOf(ev), ev2GCEnd, ts, w.gcSeq)
+
+	case EvGCSweepStart, EvGCSweepDone:
+		m := w.holder(ev.P)
+		if m == nil {
+			return w.impossible(ev)
+		}
+		if ev.Type == EvGCSweepStart {
+			return w.emit(m, ev2GCSweepBegin, ts, w.stk(ev.StkID))
+		}
+		return w.emit(m, ev2GCSweepEnd, ts, ev.Args[0], ev.Args[1])
+
+	case EvGCMarkAssistStart, EvGCMarkAssistDone:
+		m := w.mWithG(ev)
+		if m == nil {
+			w.delay(ev)
+			break
+		}
+		if ev.Type == EvGCMarkAssistStart {
+			return w.emit(m, ev2GCMarkAssistBegin, ts, w.stk(ev.StkID))
+		}
+		return w.emit(m, ev2GCMarkAssistEnd, ts)
+
+	case EvHeapAlloc:
+		return w.emit(w.mOf(ev), ev2HeapAlloc, ts, ev.Args[0])
+
+	case EvHeapGoal:
+		return w.emit(w.mOf(ev), ev2HeapGoal, ts, ev.Args[0])
+
+	case EvGomaxprocs:
+		return w.emit(w.mOf(ev), ev2ProcsChange, ts, ev.Args[0], w.stk(ev.StkID))
+
+	case EvUserTaskCreate, EvUserTaskEnd, EvUserRegion, EvUserLog:
+		m := w.mWithG(ev)
+		if m == nil {
+			w.delay(ev)
+			break
+		}
+		args := &ev.Args
+		switch ev.Type {
+		case EvUserTaskCreate:
+			return w.emit(m, ev2UserTaskBegin, ts, args[0], args[1], w.str(w.r.String(args[ArgUserTaskCreateTypeID])), w.stk(ev.StkID))
+		case EvUserTaskEnd:
+			return w.emit(m, ev2UserTaskEnd, ts, args[0], w.stk(ev.StkID))
+		case EvUserRegion:
+			typ := byte(ev2UserRegionBegin)
+			if args[1] == 1 {
+				typ = ev2UserRegionEnd
+			}
+			return w.emit(m, typ, ts, args[0], w.str(w.r.String(args[ArgUserRegionTypeID])), w.stk(ev.StkID))
+		case EvUserLog:
+			return w.emit(m, ev2UserLog, ts, args[0], w.str(w.r.String(args[ArgUserLogKeyID])), w.str(w.r.String(args[ArgUserLogMessage])), w.stk(ev.StkID))
+		}
+
+	case EvGoWaiting, EvGoInSyscall:
+		// These only follow the creation of goroutines, which writeCreate took care of.
+		return w.impossible(ev)
+
+	default:
+		return fmt.Errorf("can't write %s event in version %d.%d trace", EventDescriptions[ev.Type].Name, w.version/1000, w.version%1000)
+	}
+	return nil
+}
+
+// v2BlockReasons are the reasons for blocking that the parser maps to the event types of older traces.
+var v2BlockReasons = map[byte]string{
+	EvGoStop:        "forever",
+	EvGoBlockNet:    "network",
+	EvGoBlockSelect: "select",
+	EvGoBlockCond:   "sync.(*Cond).Wait",
+	EvGoBlockSync:   "sync",
+	EvGoBlockSend:   "chan send",
+	EvGoBlockRecv:   "chan receive",
+	EvGoBlockGC:     "GC mark assist wait for work",
+	EvGoSleep:       "sleep",
+}
+
+// syscallEnd ends the syscall of a goroutine that returned without blocking.
+func (w *v2Writer) syscallEnd(ts Timestamp, g *v2WriterG) error {
+	if g.m == nil || g.m.p == -1 {
+		return fmt.Errorf("can't encode syscall exit at time %d: impossible goroutine state transition", ts)
+	}
+	g.status = goRunning
+	w.ps[g.m.p].status = procRunning
+	return w.emit(g.m, ev2GoSyscallEnd, ts)
+}
+
+// writeCreate writes the pending goroutine creation. Goroutines that existed before the events started are followed by
+// EvGoWaiting or EvGoInSyscall if they weren't runnable, and goroutines that were created blocked are followed by
+// EvGoWaiting. writeCreate reports whether next, which may be nil, was one of these events.
+func (w *v2Writer) writeCreate(next *Event) (bool, error) {
+	ev := w.create
+	w.create = nil
+	gid := ev.Args[0]
+	fnStk := uint32(ev.Args[ArgGoCreateStack])
+	follows := func(typ byte) bool { return next != nil && next.Type == typ && next.G == gid }
+
+	if ev.P >= 0 && ev.P < FakeP {
+		m := w.holder(ev.P)
+		if m == nil {
+			return false, w.impossible(ev)
+		}
+		g := &v2WriterG{status: goRunnable}
+		w.gs[gid] = g
+		typ := byte(ev2GoCreate)
+		if follows(EvGoWaiting) && w.version >= 1023 {
+			g.status = goWaiting
+			typ = ev2GoCreateBlocked
+		}
+		return typ == ev2GoCreateBlocked, w.emit(m, typ, ev.Ts, gid, w.stk(fnStk), w.stk(ev.StkID))
+	}
+
+	// The parser turns status events for goroutines it hasn't seen before into creation events.
+	g := &v2WriterG{status: goRunnable}
+	w.gs[gid] = g
+	m := w.idle
+	var mid uint64
+	switch {
+	case follows(EvGoWaiting):
+		g.status = goWaiting
+	case follows(EvGoInSyscall):
+		// We don't know the thread the goroutine is in a syscall on.
+		m = w.fakeM()
+		mid = m.id
+		m.g = gid
+		g.m = m
+		g.status = goSyscall
+		g.sysBlocked = true
+	}
+	consumed := g.status != goRunnable
+	if fnStk != 0 && w.version >= 1023 {
+		return consumed, w.emit(m, ev2GoStatusStack, ev.Ts, gid, mid, uint64(g.status), w.stk(fnStk))
+	}
+	// In Go 1.22, the parser fills in the start function from the goroutine's first stack instead.
+	g.noStack = fnStk == 0
+	return consumed, w.emit(m, ev2GoStatus, ev.Ts, gid, mid, uint64(g.status))
+}
+
+// nextGeneration ends the current generation and starts a new one, emitting the state of all processors and goroutines.
+func (w *v2Writer) nextGeneration(ts Timestamp) error {
+	if err := w.endGeneration(); err != nil {
+		return err
+	}
+	w.gen++
+	w.strings = make(map[string]uint64)
+	w.stacks = make(map[uint32]struct{})
+
+	pids := make([]int32, 0, len(w.ps))
+	for pid := range w.ps {
+		pids = append(pids, pid)
+	}
+	slices.Sort(pids)
+	for _, pid := range pids {
+		p := w.ps[pid]
+		p.seq = 0
+		m := w.idle
+		if p.m != nil {
+			m = p.m
+		}
+		if err := w.emit(m, ev2ProcStatus, ts, uint64(pid), uint64(p.status)); err != nil {
+			return err
+		}
+	}
+
+	gids := make([]uint64, 0, len(w.gs))
+	for gid := range w.gs {
+		gids = append(gids, gid)
+	}
+	slices.Sort(gids)
+	for _, gid := range gids {
+		g := w.gs[gid]
+		g.seq = 0
+		g.noStack = false
+		m := w.idle
+		var mid uint64
+		if g.m != nil {
+			m = g.m
+			mid = m.id
+		}
+		if err := w.emit(m, ev2GoStatus, ts, gid, mid, uint64(g.status)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endGeneration writes the remaining batches of the current generation, as well as its strings, stacks and frequency.
+func (w *v2Writer) endGeneration() error {
+	mids := make([]uint64, 0, len(w.ms))
+	for mid := range w.ms {
+		mids = append(mids, mid)
+	}
+	slices.Sort(mids)
+	for _, mid := range mids {
+		if err := w.writeMBatch(w.ms[mid]); err != nil {
+			return err
+		}
+	}
+	if len(w.samples) > 0 {
+		if err := w.writeBatch(0, 0, w.samples); err != nil {
+			return err
+		}
+		w.samples = w.samples[:0]
+	}
+
+	// Stacks refer to strings, so we have to encode them first.
+	ids := make([]uint32, 0, len(w.stacks))
+	for id := range w.stacks {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var stacks [][]byte
+	var data []byte
+	for _, id := range ids {
+		pcs := w.r.Stack(id)
+		if len(pcs) == 0 {
+			continue
+		}
+		// We'd rather lose the outermost frames of extremely deep stacks than fail.
+		if len(pcs) > maxFramesPerStack {
+			pcs = pcs[:maxFramesPerStack]
+		}
+		if len(data) == 0 {
+			data = append(data, ev2Stacks)
+		}
+		data = append(data, ev2Stack)
+		data = binary.AppendUvarint(data, uint64(id))
+		data = binary.AppendUvarint(data, uint64(len(pcs)))
+		for _, pc := range pcs {
+			frame := w.r.PC(pc)
+			for _, v := range []uint64{pc, w.str(frame.Fn), w.str(frame.File), uint64(frame.Line)} {
+				data = binary.AppendUvarint(data, v)
+			}
+		}
+		if len(data) >= maxBatchSize-(maxFramesPerStack*4+3)*binary.MaxVarintLen64 {
+			stacks = append(stacks, data)
+			data = nil
+		}
+	}
+	if len(data) > 0 {
+		stacks = append(stacks, data)
+	}
+
+	strs := make([]string, len(w.strings))
+	for s, id := range w.strings {
+		strs[id-1] = s
+	}
+	data = nil
+	for i, s := range strs {
+		if len(data) == 0 {
+			data = append(data, ev2Strings)
+		}
+		data = append(data, ev2String)
+		data = binary.AppendUvarint(data, uint64(i+1))
+		data = binary.AppendUvarint(data, uint64(len(s)))
+		data = append(data, s...)
+		if len(data) >= maxBatchSize-maxEventTrailerDataSize-2*binary.MaxVarintLen64-1 {
+			if err := w.writeBatch(0, 0, data); err != nil {
+				return err
+			}
+			data = data[:0]
+		}
+	}
+	if len(data) > 0 {
+		if err := w.writeBatch(0, 0, data); err != nil {
+			return err
+		}
+	}
+	for _, data := range stacks {
+		if err := w.writeBatch(0, 0, data); err != nil {
+			return err
+		}
+	}
+
+	// Our timestamps are in nanoseconds.
+	data = data[:0]
+	if w.version >= 1025 {
+		data = append(data, ev2Sync)
+	}
+	data = append(data, ev2Frequency)
+	data = binary.AppendUvarint(data, 1e9)
+	if err := w.writeBatch(0, 0, data); err != nil {
+		return err
+	}
+	if w.version >= 1026 {
+		return w.w.WriteByte(ev2EndOfGeneration)
+	}
+	return nil
+}
+
+func (w *v2Writer) close() error {
+	if w.create != nil {
+		if _, err := w.writeCreate(nil); err != nil {
+			return err
+		}
+	}
+	if w.blocked != 0 {
+		return fmt.Errorf("can't encode syscall block of goroutine %d: processor didn't stop", w.blocked)
+	}
+	if err := w.endGeneration(); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}